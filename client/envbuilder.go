@@ -1,8 +1,10 @@
 package client
 
 import (
+	"log"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 
 	"mcp-debug/config"
@@ -44,6 +46,27 @@ var ImplicitDenylist = []string{
 	"no_proxy",
 }
 
+// SecretSuffixes mark variable names that likely hold credentials. With
+// inherit.denySecrets set, variables whose names end in one of them
+// (compared case-insensitively) are only inherited when listed in Extra.
+var SecretSuffixes = []string{
+	"_TOKEN",
+	"_SECRET",
+	"_KEY",
+	"_PASSWORD",
+}
+
+// LooksLikeSecret reports whether name matches one of SecretSuffixes
+func LooksLikeSecret(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, suffix := range SecretSuffixes {
+		if strings.HasSuffix(upper, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 // BuildEnvironment constructs the environment for an MCP server based on
 // tier-based inheritance rules and configuration overrides.
 //
@@ -51,6 +74,7 @@ var ImplicitDenylist = []string{
 //   - Tier 1 (baseline): Always inherited unless explicitly denied
 //   - Tier 2 (network/TLS): Inherited when TLS inheritance enabled
 //   - Implicit denylist: Blocked by default (e.g., HTTP_PROXY)
+//   - Secret-like names: Blocked with denySecrets unless listed in Extra
 //   - Extra variables: Additional variables specified in config
 //   - Prefix matching: Variables matching configured prefixes
 //
@@ -67,7 +91,7 @@ var ImplicitDenylist = []string{
 //   - proxyInherit: Proxy-level inheritance configuration (may be nil)
 //
 // Returns:
-//   - []string: Environment in "KEY=value" format for exec.Cmd.Env
+//   - []string: Environment in "KEY=value" format for exec.Cmd.Env, sorted by key
 func BuildEnvironment(serverConfig *config.ServerConfig, proxyInherit *config.InheritConfig) []string {
 	isWindows := runtime.GOOS == "windows"
 
@@ -75,7 +99,12 @@ func BuildEnvironment(serverConfig *config.ServerConfig, proxyInherit *config.In
 	denyMap := buildDenyMap(serverConfig, proxyInherit, isWindows)
 
 	// Build parent environment map (normalized lookup keys)
-	parentMap := buildParentMap()
+	strict := (serverConfig.Inherit != nil && serverConfig.Inherit.Strict) ||
+		(proxyInherit != nil && proxyInherit.Strict)
+	parentMap := buildParentMap(strict)
+
+	denySecrets := (serverConfig.Inherit != nil && serverConfig.Inherit.DenySecrets) ||
+		(proxyInherit != nil && proxyInherit.DenySecrets)
 
 	// Result map: normalized_key -> (original_key, value)
 	envMap := make(map[string]struct {
@@ -103,6 +132,9 @@ func BuildEnvironment(serverConfig *config.ServerConfig, proxyInherit *config.In
 				return // Explicitly denied
 			}
 		}
+		if denySecrets && !explicitExtra && LooksLikeSecret(key) {
+			return
+		}
 
 		if val, exists := parentMap[lookupKey]; exists {
 			envMap[lookupKey] = struct {
@@ -160,6 +192,9 @@ func BuildEnvironment(serverConfig *config.ServerConfig, proxyInherit *config.In
 		if denyMap[lookupKey] {
 			continue // Already denied
 		}
+		if denySecrets && LooksLikeSecret(lookupKey) {
+			continue // Secret-like names need to be listed in Extra
+		}
 		// Check if any prefix matches
 		for _, prefix := range prefixes {
 			normalizedPrefix := normalizeKey(prefix, isWindows)
@@ -194,9 +229,16 @@ func BuildEnvironment(serverConfig *config.ServerConfig, proxyInherit *config.In
 		}{key, value}
 	}
 
-	// Build final result
+	// Build final result, sorted by key so the output is the same every run
+	lookupKeys := make([]string, 0, len(envMap))
+	for lookupKey := range envMap {
+		lookupKeys = append(lookupKeys, lookupKey)
+	}
+	sort.Strings(lookupKeys)
+
 	result := make([]string, 0, len(envMap))
-	for _, entry := range envMap {
+	for _, lookupKey := range lookupKeys {
+		entry := envMap[lookupKey]
 		result = append(result, entry.key+"="+entry.value)
 	}
 
@@ -231,12 +273,22 @@ func buildDenyMap(serverConfig *config.ServerConfig, proxyInherit *config.Inheri
 }
 
 // buildParentMap creates a normalized map of parent environment variables.
+// A key that appears more than once takes its last value; with
+// warnDuplicates set, each such key is logged, since it can point to a
+// misconfigured launcher.
 // Returns: map[normalized_key]value
-func buildParentMap() map[string]string {
+func buildParentMap(warnDuplicates bool) map[string]string {
 	isWindows := runtime.GOOS == "windows"
 	parentMap := make(map[string]string)
+	environ := os.Environ()
 
-	for _, entry := range os.Environ() {
+	if warnDuplicates {
+		for _, key := range duplicateEnvKeys(environ, isWindows) {
+			log.Printf("Warning: parent environment contains %s more than once; using the last value", key)
+		}
+	}
+
+	for _, entry := range environ {
 		key, value := splitEnvEntry(entry)
 		if key == "" {
 			continue
@@ -247,3 +299,29 @@ func buildParentMap() map[string]string {
 
 	return parentMap
 }
+
+// duplicateEnvKeys returns the keys that appear more than once in entries
+// after normalization, in the order they first appear
+func duplicateEnvKeys(entries []string, isWindows bool) []string {
+	counts := make(map[string]int)
+	var order []string
+	for _, entry := range entries {
+		key, _ := splitEnvEntry(entry)
+		if key == "" {
+			continue
+		}
+		lookupKey := normalizeKey(key, isWindows)
+		if counts[lookupKey] == 0 {
+			order = append(order, lookupKey)
+		}
+		counts[lookupKey]++
+	}
+
+	var duplicates []string
+	for _, key := range order {
+		if counts[key] > 1 {
+			duplicates = append(duplicates, key)
+		}
+	}
+	return duplicates
+}
@@ -571,3 +571,129 @@ func restoreEnvironment(oldEnv []string) {
 		}
 	}
 }
+
+// TestDuplicateEnvKeys tests detection of keys repeated in the parent environment
+func TestDuplicateEnvKeys(t *testing.T) {
+	entries := []string{"PATH=/usr/bin", "HOME=/home/user", "Path=/opt/bin", "HOME=/root", "=C:=C:\\", "TZ=UTC"}
+
+	unix := duplicateEnvKeys(entries, false)
+	if strings.Join(unix, ",") != "HOME" {
+		t.Errorf("expected only HOME to be duplicated on Unix, got %v", unix)
+	}
+
+	// Windows keys are case-insensitive, so Path repeats PATH
+	windows := duplicateEnvKeys(entries, true)
+	if strings.Join(windows, ",") != "PATH,HOME" {
+		t.Errorf("expected PATH and HOME to be duplicated on Windows, got %v", windows)
+	}
+}
+
+// TestBuildEnvironment_DenySecrets tests that secret-like names are only
+// inherited when listed in Extra
+func TestBuildEnvironment_DenySecrets(t *testing.T) {
+	// Save and restore environment
+	oldEnv := os.Environ()
+	defer restoreEnvironment(oldEnv)
+
+	// Set up test environment
+	os.Clearenv()
+	os.Setenv("HOME", "/home/user")
+	os.Setenv("DATTO_API_URL", "https://api.datto.com")
+	os.Setenv("DATTO_API_KEY", "key123")
+	os.Setenv("DATTO_Auth_Token", "token123")
+	os.Setenv("DATTO_DB_PASSWORD", "hunter2")
+	os.Setenv("DATTO_CLIENT_SECRET", "secret123")
+
+	// Proxy-level denySecrets applies to a server inheriting by prefix
+	serverCfg := &config.ServerConfig{
+		Inherit: &config.InheritConfig{
+			Mode:   config.InheritTier1,
+			Prefix: []string{"DATTO_"},
+			Extra:  []string{"DATTO_API_KEY"},
+		},
+	}
+	proxyInherit := &config.InheritConfig{DenySecrets: true}
+
+	result := BuildEnvironment(serverCfg, proxyInherit)
+	resultMap := sliceToMap(result)
+
+	if resultMap["DATTO_API_URL"] != "https://api.datto.com" {
+		t.Error("DATTO_API_URL should be inherited (prefix match, not secret-like)")
+	}
+	if resultMap["DATTO_API_KEY"] != "key123" {
+		t.Error("DATTO_API_KEY should be inherited (listed in extra)")
+	}
+	for _, name := range []string{"DATTO_Auth_Token", "DATTO_DB_PASSWORD", "DATTO_CLIENT_SECRET"} {
+		if _, ok := resultMap[name]; ok {
+			t.Errorf("%s should NOT be inherited (secret-like name)", name)
+		}
+	}
+
+	// Without denySecrets prefix matches are inherited as before
+	resultMap = sliceToMap(BuildEnvironment(serverCfg, nil))
+	if resultMap["DATTO_DB_PASSWORD"] != "hunter2" {
+		t.Error("DATTO_DB_PASSWORD should be inherited without denySecrets")
+	}
+}
+
+// TestBuildEnvironment_SortedOutput tests that the result is sorted by key
+func TestBuildEnvironment_SortedOutput(t *testing.T) {
+	// Save and restore environment
+	oldEnv := os.Environ()
+	defer restoreEnvironment(oldEnv)
+
+	// Set up test environment
+	os.Clearenv()
+	os.Setenv("USER", "user")
+	os.Setenv("HOME", "/home/user")
+	os.Setenv("PATH", "/usr/bin")
+	os.Setenv("APP_ZONE", "eu")
+	os.Setenv("APP_MODE", "dev")
+
+	serverCfg := &config.ServerConfig{
+		Inherit: &config.InheritConfig{
+			Mode:   config.InheritTier1,
+			Prefix: []string{"APP_"},
+		},
+		Env: map[string]string{
+			"ZETA":  "last",
+			"ALPHA": "first",
+		},
+	}
+
+	want := []string{
+		"ALPHA=first",
+		"APP_MODE=dev",
+		"APP_ZONE=eu",
+		"HOME=/home/user",
+		"PATH=/usr/bin",
+		"USER=user",
+		"ZETA=last",
+	}
+	for i := 0; i < 5; i++ {
+		result := BuildEnvironment(serverCfg, nil)
+		if strings.Join(result, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("expected sorted environment %v, got %v", want, result)
+		}
+	}
+}
+
+// TestLooksLikeSecret tests the secret name heuristic
+func TestLooksLikeSecret(t *testing.T) {
+	tests := map[string]bool{
+		"GITHUB_TOKEN":          true,
+		"AWS_SECRET":            true,
+		"OPENAI_API_KEY":        true,
+		"db_password":           true,
+		"PATH":                  false,
+		"KEYBOARD_LAYOUT":       false,
+		"SSH_AUTH_SOCK":         false,
+		"TOKEN":                 false,
+		"DATTO_API_KEY_ID_FILE": false,
+	}
+	for name, want := range tests {
+		if got := LooksLikeSecret(name); got != want {
+			t.Errorf("LooksLikeSecret(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"syscall"
+)
+
+// FakeClient is an in-memory MCPClient for tests. Tools, tool results and
+// failures are scripted through setters; no process is started.
+type FakeClient struct {
+	serverName string
+	tools      []ToolInfo
+	results    map[string]*CallToolResult
+	toolErrors map[string]error
+	callFunc   func(name string, args map[string]interface{}) (*CallToolResult, error)
+
+	connectErr error
+	initErr    error
+	listErr    error
+
+	env      []string // Reported by Environment; replaced by a snapshot on Connect
+	snapshot []string
+
+	connected   bool
+	initialized bool
+	calls       []FakeCall
+	logLevel    string
+	notify      NotificationHandler
+	mu          sync.Mutex
+}
+
+// FakeCall records a CallTool invocation on a FakeClient
+type FakeCall struct {
+	Name string
+	Args map[string]interface{}
+}
+
+// NewFakeClient creates a fake client exposing the given tools
+func NewFakeClient(serverName string, tools ...ToolInfo) *FakeClient {
+	return &FakeClient{
+		serverName: serverName,
+		tools:      tools,
+		results:    make(map[string]*CallToolResult),
+		toolErrors: make(map[string]error),
+	}
+}
+
+// SetTools replaces the tools returned by ListTools
+func (f *FakeClient) SetTools(tools ...ToolInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tools = tools
+}
+
+// SetToolResult sets the result returned when toolName is called
+func (f *FakeClient) SetToolResult(toolName string, result *CallToolResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[toolName] = result
+}
+
+// SetToolError makes calls to toolName fail with err
+func (f *FakeClient) SetToolError(toolName string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.toolErrors[toolName] = err
+}
+
+// SetCallToolFunc handles all tool calls with fn, overriding scripted results
+func (f *FakeClient) SetCallToolFunc(fn func(name string, args map[string]interface{}) (*CallToolResult, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callFunc = fn
+}
+
+// SetConnectError makes Connect fail with err
+func (f *FakeClient) SetConnectError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connectErr = err
+}
+
+// SetInitializeError makes Initialize fail with err
+func (f *FakeClient) SetInitializeError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initErr = err
+}
+
+// SetListToolsError makes ListTools fail with err
+func (f *FakeClient) SetListToolsError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listErr = err
+}
+
+// Crash simulates the server process dying: the client is marked
+// disconnected and further calls fail with a broken pipe TransportError
+func (f *FakeClient) Crash() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = false
+}
+
+// SetNotificationHandler sets the handler that receives Notify calls
+func (f *FakeClient) SetNotificationHandler(handler NotificationHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notify = handler
+}
+
+// Notify delivers a server notification to the registered handler
+func (f *FakeClient) Notify(method string, params json.RawMessage) {
+	f.mu.Lock()
+	notify := f.notify
+	f.mu.Unlock()
+
+	if notify != nil {
+		notify(method, params)
+	}
+}
+
+// Calls returns the tool calls received so far, in order
+func (f *FakeClient) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+// Connect marks the client connected unless a connect error is scripted
+func (f *FakeClient) Connect(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.connectErr != nil {
+		return f.connectErr
+	}
+	if f.snapshot != nil {
+		f.env = f.snapshot
+	}
+	f.connected = true
+	return nil
+}
+
+// SetEnvironment sets the environment Environment reports, standing in for
+// the one a real server process would be started with
+func (f *FakeClient) SetEnvironment(env []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.env = env
+}
+
+// SetEnvironmentSnapshot makes Connect use env as the environment
+func (f *FakeClient) SetEnvironmentSnapshot(env []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshot = env
+}
+
+// Environment returns the scripted or snapshot environment
+func (f *FakeClient) Environment() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.env...)
+}
+
+// Initialize returns a fixed handshake result unless an error is scripted
+func (f *FakeClient) Initialize(ctx context.Context) (*InitializeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.initErr != nil {
+		return nil, f.initErr
+	}
+	f.initialized = true
+	return f.initializeResult(), nil
+}
+
+// InitializeResult returns what Initialize answered, nil before Initialize
+func (f *FakeClient) InitializeResult() *InitializeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.initialized {
+		return nil
+	}
+	return f.initializeResult()
+}
+
+// initializeResult returns the fixed handshake result
+func (f *FakeClient) initializeResult() *InitializeResult {
+	return &InitializeResult{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities: map[string]interface{}{
+			"tools":   map[string]interface{}{"listChanged": true},
+			"logging": map[string]interface{}{},
+		},
+		ServerInfo: ServerInfo{Name: f.serverName, Version: "fake"},
+	}
+}
+
+// ProtocolVersion returns the version Initialize reports
+func (f *FakeClient) ProtocolVersion() string {
+	return ProtocolVersion
+}
+
+// SetLogLevel records level; the fake always supports logging
+func (f *FakeClient) SetLogLevel(ctx context.Context, level string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logLevel = level
+	return nil
+}
+
+// LogLevel returns the level last set with SetLogLevel
+func (f *FakeClient) LogLevel() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logLevel
+}
+
+// ListTools returns the scripted tools
+func (f *FakeClient) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return append([]ToolInfo(nil), f.tools...), nil
+}
+
+// CallTool records the call and returns the scripted result or error.
+// Tools without a scripted result echo their name as text.
+func (f *FakeClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, FakeCall{Name: name, Args: args})
+	connected := f.connected
+	callFunc := f.callFunc
+	result, hasResult := f.results[name]
+	err := f.toolErrors[name]
+	f.mu.Unlock()
+
+	if !connected {
+		return nil, &TransportError{Server: f.serverName, Op: "failed to write request", Err: syscall.EPIPE}
+	}
+	if callFunc != nil {
+		return callFunc(name, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hasResult {
+		return result, nil
+	}
+	return &CallToolResult{
+		Content: []ContentItem{{Type: "text", Text: name}},
+	}, nil
+}
+
+// Close marks the client disconnected
+func (f *FakeClient) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = false
+	return nil
+}
+
+// ServerName returns the configured server name
+func (f *FakeClient) ServerName() string {
+	return f.serverName
+}
+
+// IsConnected returns true between Connect and Close/Crash
+func (f *FakeClient) IsConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
@@ -30,6 +31,48 @@ type MCPClient interface {
 	IsConnected() bool
 }
 
+// NotificationInitialized is sent to a server once its initialize
+// response was accepted
+const NotificationInitialized = "notifications/initialized"
+
+// NotificationToolsListChanged is sent by servers whose tool set changed
+const NotificationToolsListChanged = "notifications/tools/list_changed"
+
+// NotificationMessage carries a log message from a server with the logging
+// capability
+const NotificationMessage = "notifications/message"
+
+// NotificationHandler receives notifications sent by an MCP server
+type NotificationHandler func(method string, params json.RawMessage)
+
+// Notifier is implemented by clients that can deliver server notifications.
+// Handlers must not block or call back into the client.
+type Notifier interface {
+	SetNotificationHandler(handler NotificationHandler)
+}
+
+// LogLevelSetter is implemented by clients that can set the minimum level
+// of the log messages their server sends
+type LogLevelSetter interface {
+	// SetLogLevel sends logging/setLevel. It fails with ErrLoggingUnsupported
+	// when the server didn't advertise the logging capability.
+	SetLogLevel(ctx context.Context, level string) error
+}
+
+// ErrLoggingUnsupported is returned by SetLogLevel for servers without the
+// logging capability
+var ErrLoggingUnsupported = errors.New("server does not support logging")
+
+// EnvironmentSnapshotter is implemented by clients that start a process and
+// can report and reuse the exact environment it was started with
+type EnvironmentSnapshotter interface {
+	// Environment returns the environment of the last started process
+	Environment() []string
+	// SetEnvironmentSnapshot makes Connect start the process with exactly
+	// env, skipping inheritance and ${{VAR}} expansion
+	SetEnvironmentSnapshot(env []string)
+}
+
 // InitializeResult represents the result of MCP initialize request
 type InitializeResult struct {
 	ProtocolVersion string                 `json:"protocolVersion"`
@@ -52,27 +95,84 @@ type ToolInfo struct {
 
 // CallToolResult represents the result of a tool invocation
 type CallToolResult struct {
-	Content []ContentItem `json:"content"`
-	IsError bool          `json:"isError,omitempty"`
+	Content           []ContentItem          `json:"content"`
+	StructuredContent json.RawMessage        `json:"structuredContent,omitempty"`
+	Meta              map[string]interface{} `json:"_meta,omitempty"`
+	IsError           bool                   `json:"isError,omitempty"`
 }
 
-// ContentItem represents a piece of content in the tool result
+// ContentItem represents a piece of content in the tool result. Only text
+// is decoded into fields; Raw keeps the item exactly as the server sent it,
+// so images, resources and annotations can be forwarded unchanged.
 type ContentItem struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type string          `json:"type"`
+	Text string          `json:"text,omitempty"`
+	Raw  json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the type and text of an item and keeps its raw JSON
+func (c *ContentItem) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Type string `json:"type"`
+		Text string `json:"text,omitempty"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.Type = fields.Type
+	c.Text = fields.Text
+	c.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON writes the raw item when there is one, so a result that is
+// re-encoded (for example in a recording) keeps its non-text content
+func (c ContentItem) MarshalJSON() ([]byte, error) {
+	if len(c.Raw) > 0 {
+		return c.Raw, nil
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text,omitempty"`
+	}{c.Type, c.Text})
 }
 
 // ClientError represents an error from the MCP client
 type ClientError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Server  string `json:"server"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Server  string      `json:"server"`
 }
 
 func (e *ClientError) Error() string {
 	return fmt.Sprintf("[%s] %s (code: %d)", e.Server, e.Message, e.Code)
 }
 
+// ErrNotConnected is returned when a request is made on a client that is not
+// connected to its server
+var ErrNotConnected = errors.New("client not connected")
+
+// TransportError reports a failure of the connection to the server itself
+// (a broken pipe, a closed stream, a process that is not running), as
+// opposed to an error the server returned for a request
+type TransportError struct {
+	Server string
+	Op     string // What was being attempted, e.g. "failed to write request"
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
 // NewClientError creates a new client error with server context
 func NewClientError(server string, code int, message string) *ClientError {
 	return &ClientError{
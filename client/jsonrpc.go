@@ -23,6 +23,29 @@ type JSONRPCResponse struct {
 	ID      int64           `json:"id"`
 }
 
+// JSONRPCNotification represents a JSON-RPC 2.0 notification (no ID)
+type JSONRPCNotification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// parseNotification returns the notification in line, or nil if line is not
+// a notification (it has an ID or no method)
+func parseNotification(line []byte) *JSONRPCNotification {
+	var message struct {
+		JSONRPCNotification
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(line, &message); err != nil {
+		return nil
+	}
+	if message.Method == "" || len(message.ID) > 0 {
+		return nil
+	}
+	return &message.JSONRPCNotification
+}
+
 // JSONRPCError represents a JSON-RPC 2.0 error
 type JSONRPCError struct {
 	Code    int    `json:"code"`
@@ -51,6 +74,11 @@ type CallToolParams struct {
 	Arguments map[string]interface{} `json:"arguments"`
 }
 
+// ListToolsParams represents parameters for a paginated tools/list request
+type ListToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
 // RequestIDGenerator generates unique request IDs
 type RequestIDGenerator struct {
 	counter int64
@@ -61,32 +89,48 @@ func (g *RequestIDGenerator) NextID() int64 {
 	return atomic.AddInt64(&g.counter, 1)
 }
 
+// Client identity sent to backends in the initialize handshake
+const (
+	ProxyClientName    = "dynamic-mcp-proxy"
+	ProxyClientVersion = "1.0.0"
+)
+
+// NewInitializeParams creates the parameters of an initialize request
+func NewInitializeParams(clientName, clientVersion string) InitializeParams {
+	return InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities: map[string]interface{}{
+			"tools": map[string]interface{}{},
+		},
+		ClientInfo: ClientInfo{
+			Name:    clientName,
+			Version: clientVersion,
+		},
+	}
+}
+
 // NewInitializeRequest creates a new initialize request
 func NewInitializeRequest(idGen *RequestIDGenerator, clientName, clientVersion string) *JSONRPCRequest {
 	return &JSONRPCRequest{
 		JSONRPC: "2.0",
 		Method:  "initialize",
-		Params: InitializeParams{
-			ProtocolVersion: "2024-11-05",
-			Capabilities: map[string]interface{}{
-				"tools": map[string]interface{}{},
-			},
-			ClientInfo: ClientInfo{
-				Name:    clientName,
-				Version: clientVersion,
-			},
-		},
-		ID: idGen.NextID(),
+		Params:  NewInitializeParams(clientName, clientVersion),
+		ID:      idGen.NextID(),
 	}
 }
 
-// NewListToolsRequest creates a new tools/list request
-func NewListToolsRequest(idGen *RequestIDGenerator) *JSONRPCRequest {
-	return &JSONRPCRequest{
+// NewListToolsRequest creates a new tools/list request. A non-empty cursor
+// requests the page following a previous response's nextCursor.
+func NewListToolsRequest(idGen *RequestIDGenerator, cursor string) *JSONRPCRequest {
+	request := &JSONRPCRequest{
 		JSONRPC: "2.0",
 		Method:  "tools/list",
 		ID:      idGen.NextID(),
 	}
+	if cursor != "" {
+		request.Params = ListToolsParams{Cursor: cursor}
+	}
+	return request
 }
 
 // NewCallToolRequest creates a new tools/call request
@@ -102,12 +146,23 @@ func NewCallToolRequest(idGen *RequestIDGenerator, toolName string, args map[str
 	}
 }
 
+// NewSetLevelRequest creates a new logging/setLevel request
+func NewSetLevelRequest(idGen *RequestIDGenerator, level string) *JSONRPCRequest {
+	return &JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "logging/setLevel",
+		Params:  map[string]string{"level": level},
+		ID:      idGen.NextID(),
+	}
+}
+
 // ParseResponse parses a JSON-RPC response and returns typed result
 func ParseResponse(response *JSONRPCResponse, result interface{}) error {
 	if response.Error != nil {
 		return &ClientError{
 			Code:    response.Error.Code,
 			Message: response.Error.Message,
+			Data:    response.Error.Data,
 		}
 	}
 	
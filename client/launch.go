@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+
+	"mcp-debug/config"
+)
+
+// LaunchSpec is the command line and environment a server process is
+// started with
+type LaunchSpec struct {
+	Command string
+	Args    []string
+	Env     []string
+}
+
+// ResolveLaunch returns how a StdioClient configured from serverConfig
+// starts the server, with connect-time ${{VAR}} templates expanded against
+// the current environment. It lets a launch be reproduced outside the proxy.
+func ResolveLaunch(serverConfig config.ServerConfig, proxyInherit *config.InheritConfig) (*LaunchSpec, error) {
+	args, err := expandArgs(serverConfig.Args)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for key, value := range serverConfig.Env {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	launchEnv, err := launchEnvironment(env, serverConfig.ResolveInheritConfig(proxyInherit))
+	if err != nil {
+		return nil, err
+	}
+	return &LaunchSpec{Command: serverConfig.Command, Args: args, Env: launchEnv}, nil
+}
+
+// expandArgs expands connect-time ${{VAR}} templates in args
+func expandArgs(args []string) ([]string, error) {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		value, err := config.ExpandLazyVars(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand args: %w", err)
+		}
+		expanded[i] = value
+	}
+	return expanded, nil
+}
+
+// launchEnvironment builds the environment of a server process from its
+// KEY=VALUE overrides and inheritance config. It returns nil, meaning the
+// proxy's own environment, when both are nil.
+func launchEnvironment(env []string, inheritCfg *config.InheritConfig) ([]string, error) {
+	if env == nil && inheritCfg == nil {
+		return nil, nil
+	}
+
+	// Convert []string env to map[string]string for overrides
+	overrides := make(map[string]string)
+	for _, entry := range env {
+		key, value := splitEnvEntry(entry)
+		if key != "" {
+			expanded, err := config.ExpandLazyVars(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand env %s: %w", key, err)
+			}
+			overrides[key] = expanded
+		}
+	}
+
+	// Build a minimal ServerConfig with environment overrides and inheritance config
+	serverConfig := &config.ServerConfig{
+		Env:     overrides,
+		Inherit: inheritCfg,
+	}
+
+	// BuildEnvironment handles defaulting to tier1 if Inherit is nil
+	return BuildEnvironment(serverConfig, nil), nil
+}
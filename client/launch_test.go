@@ -0,0 +1,35 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"mcp-debug/config"
+)
+
+func TestResolveLaunch(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "abc")
+	t.Setenv("MCP_TEST_INHERITED", "parent")
+
+	spec, err := ResolveLaunch(config.ServerConfig{
+		Command: "server",
+		Args:    []string{"--token=${{MCP_TEST_TOKEN}}"},
+		Env:     map[string]string{"TOKEN": "${{MCP_TEST_TOKEN}}"},
+		Inherit: &config.InheritConfig{Mode: config.InheritNone, Extra: []string{"MCP_TEST_INHERITED"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("resolve launch: %v", err)
+	}
+	if spec.Command != "server" || !reflect.DeepEqual(spec.Args, []string{"--token=abc"}) {
+		t.Errorf("expected expanded command line, got %s %v", spec.Command, spec.Args)
+	}
+
+	env := make(map[string]string)
+	for _, entry := range spec.Env {
+		key, value := splitEnvEntry(entry)
+		env[key] = value
+	}
+	if env["TOKEN"] != "abc" || env["MCP_TEST_INHERITED"] != "parent" {
+		t.Errorf("expected the configured and inherited variables, got %v", spec.Env)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package client
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"mcp-debug/config"
+)
+
+// applyResourceLimits sets the configured rlimits on a started process with
+// prlimit(2). Soft and hard limits are both set, so the server cannot raise
+// them again.
+func applyResourceLimits(pid int, limits *config.ResourceLimits) error {
+	for _, limit := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"maxMemory", syscall.RLIMIT_AS, limits.MemoryBytes()},
+		{"maxCPUTime", syscall.RLIMIT_CPU, limits.CPUSeconds()},
+		{"maxOpenFiles", syscall.RLIMIT_NOFILE, limits.MaxOpenFiles},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+		rlimit := syscall.Rlimit{Cur: limit.value, Max: limit.value}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(pid), uintptr(limit.resource), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("failed to set %s: %w", limit.name, errno)
+		}
+	}
+	return nil
+}
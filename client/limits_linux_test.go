@@ -0,0 +1,48 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"mcp-debug/config"
+)
+
+func TestResourceLimitsApplied(t *testing.T) {
+	c := NewStdioClient("limited", "cat", nil)
+	c.SetResourceLimits(&config.ResourceLimits{
+		MaxMemory:    "256MB",
+		MaxCPUTime:   "30s",
+		MaxOpenFiles: 64,
+	})
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", c.cmd.Process.Pid))
+	if err != nil {
+		t.Fatalf("read limits: %v", err)
+	}
+	for _, want := range []struct{ name, value string }{
+		{"Max address space", "268435456"},
+		{"Max cpu time", "30"},
+		{"Max open files", "64"},
+	} {
+		found := false
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, want.name) {
+				fields := strings.Fields(strings.TrimPrefix(line, want.name))
+				found = len(fields) >= 2 && fields[0] == want.value && fields[1] == want.value
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected %s limit %s, got limits:\n%s", want.name, want.value, data)
+		}
+	}
+}
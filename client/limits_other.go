@@ -0,0 +1,16 @@
+//go:build !linux
+
+package client
+
+import (
+	"log"
+	"runtime"
+
+	"mcp-debug/config"
+)
+
+// applyResourceLimits is a no-op outside Linux
+func applyResourceLimits(pid int, limits *config.ResourceLimits) error {
+	log.Printf("Warning: resource limits are not supported on %s and are ignored", runtime.GOOS)
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package client
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// processGroup lets a server process be killed together with any processes
+// it started (e.g. npx spawning node)
+type processGroup struct {
+	pgid int
+}
+
+// setProcessGroup makes cmd start in a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// newProcessGroup returns the process group of a command started after
+// setProcessGroup; its id is the process id
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	return &processGroup{pgid: cmd.Process.Pid}, nil
+}
+
+// kill sends SIGKILL to every process in the group
+func (g *processGroup) kill() error {
+	err := syscall.Kill(-g.pgid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// processAlive reports whether pid exists and is not a zombie
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true // No procfs: trust kill(0)
+	}
+	// The state follows the parenthesized command name
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestCloseKillsChildProcesses(t *testing.T) {
+	// The server starts a grandchild and reports its pid in a notification,
+	// like npx spawning node
+	c := NewStdioClient("spawner", "sh", []string{"-c",
+		`sleep 60 & echo "{\"jsonrpc\":\"2.0\",\"method\":\"test/pid\",\"params\":$!}"; wait`})
+	pids := make(chan string, 1)
+	c.SetNotificationHandler(func(method string, params json.RawMessage) {
+		if method == "test/pid" {
+			pids <- string(params)
+		}
+	})
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	var pid string
+	select {
+	case pid = <-pids:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no child pid reported")
+	}
+	childPid, err := strconv.Atoi(pid)
+	if err != nil {
+		t.Fatalf("invalid child pid %q", pid)
+	}
+	if !processAlive(childPid) {
+		t.Fatalf("child %d is not running", childPid)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(childPid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(childPid, syscall.SIGKILL)
+			t.Fatalf("child process %d survived Close", childPid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package client
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+)
+
+const (
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitKillOnJobClose      = 0x2000
+)
+
+// jobObjectExtendedLimit mirrors JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+type jobObjectExtendedLimit struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+	IoInfo                  [6]uint64
+	ProcessMemoryLimit      uintptr
+	JobMemoryLimit          uintptr
+	PeakProcessMemoryUsed   uintptr
+	PeakJobMemoryUsed       uintptr
+}
+
+// processGroup lets a server process be killed together with any processes
+// it started (e.g. npx spawning node). On Windows this is a Job Object that
+// also kills its processes if the proxy exits without closing it.
+type processGroup struct {
+	job syscall.Handle
+}
+
+// setProcessGroup is a no-op on Windows: the process is assigned to a Job
+// Object once it has started
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// newProcessGroup creates a Job Object and assigns the started process to it.
+// Processes it creates from then on belong to the job as well.
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	job, _, err := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return nil, fmt.Errorf("CreateJobObject: %w", err)
+	}
+
+	info := jobObjectExtendedLimit{LimitFlags: jobObjectLimitKillOnJobClose}
+	if ok, _, err := procSetInformationJobObject.Call(job, jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return nil, fmt.Errorf("SetInformationJobObject: %w", err)
+	}
+
+	process, err := syscall.OpenProcess(syscall.PROCESS_TERMINATE|0x0100 /* PROCESS_SET_QUOTA */, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return nil, fmt.Errorf("OpenProcess: %w", err)
+	}
+	defer syscall.CloseHandle(process)
+
+	if ok, _, err := procAssignProcessToJobObject.Call(job, uintptr(process)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return nil, fmt.Errorf("AssignProcessToJobObject: %w", err)
+	}
+	return &processGroup{job: syscall.Handle(job)}, nil
+}
+
+// kill terminates every process in the job and releases it
+func (g *processGroup) kill() error {
+	defer syscall.CloseHandle(g.job)
+	if ok, _, err := procTerminateJobObject.Call(uintptr(g.job), 1); ok == 0 {
+		return fmt.Errorf("TerminateJobObject: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion is the MCP protocol version the proxy requests from
+// backends in the initialize handshake
+const ProtocolVersion = "2024-11-05"
+
+// SupportedProtocolVersions lists the MCP protocol versions a backend may
+// answer initialize with
+var SupportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// ErrProtocolVersion is wrapped by ProtocolVersionError
+var ErrProtocolVersion = errors.New("unsupported MCP protocol version")
+
+// ProtocolVersionError reports a backend that answered initialize with a
+// protocol version the proxy doesn't support
+type ProtocolVersionError struct {
+	Server    string
+	Requested string // Version the proxy asked for
+	Reported  string // Version the backend answered with, empty if none
+}
+
+func (e *ProtocolVersionError) Error() string {
+	reported := fmt.Sprintf("%q", e.Reported)
+	if e.Reported == "" {
+		reported = "no version"
+	}
+	return fmt.Sprintf("%v: server %s answered initialize with %s; the proxy requested %q and supports %s",
+		ErrProtocolVersion, e.Server, reported, e.Requested, strings.Join(SupportedProtocolVersions, ", "))
+}
+
+func (e *ProtocolVersionError) Unwrap() error {
+	return ErrProtocolVersion
+}
+
+// CheckProtocolVersion returns a ProtocolVersionError unless reported is
+// one of SupportedProtocolVersions
+func CheckProtocolVersion(serverName, reported string) error {
+	for _, version := range SupportedProtocolVersions {
+		if reported == version {
+			return nil
+		}
+	}
+	return &ProtocolVersionError{Server: serverName, Requested: ProtocolVersion, Reported: reported}
+}
+
+// ProtocolReporter is implemented by clients that can report the protocol
+// version their server answered initialize with
+type ProtocolReporter interface {
+	// ProtocolVersion returns the negotiated version, empty before Initialize
+	ProtocolVersion() string
+}
+
+// InitializeReporter is implemented by clients that keep their server's
+// answer to initialize, so the capabilities it advertised can be shown
+type InitializeReporter interface {
+	// InitializeResult returns the server's answer, nil before Initialize
+	InitializeResult() *InitializeResult
+}
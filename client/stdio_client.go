@@ -2,18 +2,28 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"os/exec"
+	"sort"
 	"sync"
 	"time"
 
 	"mcp-debug/config"
 )
 
+// Default request timeouts, used until SetTimeouts is called
+const (
+	DefaultConnectionTimeout = 10 * time.Second
+	DefaultCallTimeout       = 30 * time.Second
+)
+
 // StdioClient implements MCPClient using stdio transport
 type StdioClient struct {
 	serverName string
@@ -21,16 +31,38 @@ type StdioClient struct {
 	args       []string
 	env        []string
 	inheritCfg *config.InheritConfig  // NEW: inheritance configuration
+	limits     *config.ResourceLimits // Applied to the process after it starts
+	notify     NotificationHandler    // Receives notifications read while awaiting responses
+	snapshot   []string               // Used as is instead of resolving the environment, when set
+	startedEnv []string               // Environment of the last started process
+	trace      bool                   // Log each Connect and Initialize step with timing
+	anyVersion bool                   // Warn instead of failing on an unsupported protocol version
+	protocol   string                 // Protocol version the server answered initialize with
+	logging    bool                   // The server advertised the logging capability
+	initResult *InitializeResult      // The server's answer to the last successful initialize
 
 	cmd      *exec.Cmd
+	group    *processGroup // Kills the server together with its child processes
 	stdin    io.WriteCloser
 	stdout   io.ReadCloser
 	reader   *bufio.Reader
+	incoming *responseRouter // Reads stdout in the background while connected
 	idGen    *RequestIDGenerator
 
+	connectTimeout time.Duration // Bounds the initialize handshake
+	callTimeout    time.Duration // Bounds tools/list and tools/call
+
 	connected bool
 	mu        sync.Mutex
-	requestMu sync.Mutex  // Serialize all I/O operations
+	requestMu sync.Mutex // Serializes writes to stdin
+}
+
+// responseRouter hands the responses read from one connection's stdout to
+// the requests waiting for them, by request id
+type responseRouter struct {
+	pending map[int64]chan *JSONRPCResponse // Guarded by StdioClient.mu
+	done    chan struct{}                   // Closed when stdout ends
+	err     error                           // Why stdout ended; set before done is closed
 }
 
 // NewStdioClient creates a new stdio-based MCP client
@@ -40,6 +72,9 @@ func NewStdioClient(serverName, command string, args []string) *StdioClient {
 		command:    command,
 		args:       args,
 		idGen:      &RequestIDGenerator{},
+
+		connectTimeout: DefaultConnectionTimeout,
+		callTimeout:    DefaultCallTimeout,
 	}
 }
 
@@ -48,11 +83,92 @@ func (c *StdioClient) SetEnvironment(env []string) {
 	c.env = env
 }
 
+// SetEnvironmentSnapshot makes Connect start the process with exactly env,
+// e.g. the Environment of an earlier connect, so a reconnect doesn't pick up
+// changes to the proxy's own environment. Nil resolves it again.
+func (c *StdioClient) SetEnvironmentSnapshot(env []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshot = env
+}
+
+// Environment returns the environment the server process was last started
+// with, or nil before the first Connect
+func (c *StdioClient) Environment() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.startedEnv...)
+}
+
 // SetInheritConfig sets the inheritance configuration for environment variables
 func (c *StdioClient) SetInheritConfig(cfg *config.InheritConfig) {
 	c.inheritCfg = cfg
 }
 
+// SetResourceLimits sets rlimits for the server process. Limits are applied
+// right after the process starts, so they don't cover its first instructions.
+// They are ignored with a warning on platforms other than Linux.
+func (c *StdioClient) SetResourceLimits(limits *config.ResourceLimits) {
+	c.limits = limits
+}
+
+// SetConnectTrace enables logging each step of Connect and Initialize with
+// the time since the step began, to tell a failed spawn from a failed or
+// slow handshake
+func (c *StdioClient) SetConnectTrace(enabled bool) {
+	c.trace = enabled
+}
+
+// SetAllowAnyProtocol makes Initialize log a warning instead of failing
+// when the server answers with a protocol version the proxy doesn't support
+func (c *StdioClient) SetAllowAnyProtocol(allowed bool) {
+	c.anyVersion = allowed
+}
+
+// ProtocolVersion returns the protocol version the server answered
+// initialize with, empty before Initialize
+func (c *StdioClient) ProtocolVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protocol
+}
+
+// InitializeResult returns the server's answer to initialize, including the
+// capabilities it advertised, or nil before Initialize
+func (c *StdioClient) InitializeResult() *InitializeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.initResult
+}
+
+// tracef logs a connect trace step when tracing is enabled
+func (c *StdioClient) tracef(start time.Time, format string, args ...interface{}) {
+	if c.trace {
+		log.Printf("[TRACE] %s: %s (+%v)", c.serverName, fmt.Sprintf(format, args...), time.Since(start).Round(time.Microsecond))
+	}
+}
+
+// SetTimeouts sets how long to wait for the initialize handshake and for
+// other requests such as tool calls. Zero leaves a timeout unchanged.
+func (c *StdioClient) SetTimeouts(connectTimeout, callTimeout time.Duration) {
+	if connectTimeout > 0 {
+		c.connectTimeout = connectTimeout
+	}
+	if callTimeout > 0 {
+		c.callTimeout = callTimeout
+	}
+}
+
+// SetNotificationHandler sets the handler for server notifications. Stdout
+// is read in the background, so notifications are delivered as soon as the
+// server sends them, also between requests. The handler runs on the reading
+// goroutine.
+func (c *StdioClient) SetNotificationHandler(handler NotificationHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = handler
+}
+
 // Connect establishes connection to the MCP server
 func (c *StdioClient) Connect(ctx context.Context) error {
 	c.mu.Lock()
@@ -61,31 +177,24 @@ func (c *StdioClient) Connect(ctx context.Context) error {
 	if c.connected {
 		return nil
 	}
+	start := time.Now()
 	
-	// Create command
-	c.cmd = exec.CommandContext(ctx, c.command, c.args...)
-	if c.env != nil || c.inheritCfg != nil {
-		// Convert []string env to map[string]string for overrides
-		overrides := make(map[string]string)
-		if c.env != nil {
-			for _, entry := range c.env {
-				key, value := splitEnvEntry(entry)
-				if key != "" {
-					overrides[key] = value
-				}
-			}
-		}
-
-		// Build a minimal ServerConfig with environment overrides and inheritance config
-		serverConfig := &config.ServerConfig{
-			Env:     overrides,
-			Inherit: c.inheritCfg,
-		}
+	// Expand connect-time ${{VAR}} templates so each (re)connect sees the
+	// current environment
+	args, err := expandArgs(c.args)
+	if err != nil {
+		return err
+	}
 
-		// BuildEnvironment handles defaulting to tier1 if Inherit is nil
-		c.cmd.Env = BuildEnvironment(serverConfig, nil)
+	// Create command
+	c.cmd = exec.CommandContext(ctx, c.command, args...)
+	if c.snapshot != nil {
+		c.cmd.Env = append([]string(nil), c.snapshot...)
+	} else if c.cmd.Env, err = launchEnvironment(c.env, c.inheritCfg); err != nil {
+		return err
 	}
 	// Note: When both c.env and c.inheritCfg are nil, c.cmd.Env stays nil (Go's default)
+	c.tracef(start, "starting %s with %d args", c.command, len(args))
 	
 	// Create pipes
 	stdin, err := c.cmd.StdinPipe()
@@ -101,15 +210,43 @@ func (c *StdioClient) Connect(ctx context.Context) error {
 	}
 	c.stdout = stdout
 	c.reader = bufio.NewReader(stdout)
+	c.tracef(start, "stdin/stdout pipes established")
 	
-	// Start the process
+	// Start the process in its own process group so Close can kill any
+	// processes it starts as well
+	setProcessGroup(c.cmd)
 	if err := c.cmd.Start(); err != nil {
 		stdin.Close()
 		stdout.Close()
+		c.tracef(start, "spawn failed: %v", err)
 		return fmt.Errorf("failed to start MCP server: %w", err)
 	}
+	c.tracef(start, "process spawned (pid %d)", c.cmd.Process.Pid)
 
+	c.startedEnv = c.cmd.Env
+	if c.startedEnv == nil {
+		c.startedEnv = os.Environ()
+	}
+
+	group, err := newProcessGroup(c.cmd)
+	if err != nil {
+		log.Printf("Warning: child processes of server '%s' may outlive it: %v", c.serverName, err)
+	}
+	c.group = group
+
+	if c.limits != nil {
+		if err := applyResourceLimits(c.cmd.Process.Pid, c.limits); err != nil {
+			stdin.Close()
+			stdout.Close()
+			c.cmd.Process.Kill()
+			c.cmd.Wait()
+			return fmt.Errorf("failed to apply resource limits: %w", err)
+		}
+	}
+
+	c.startReading()
 	c.connected = true
+	c.tracef(start, "connected")
 	log.Printf("[DEBUG] StdioClient.Connect() SUCCESS: %s - connected=%v", c.serverName, c.connected)
 	return nil
 }
@@ -122,27 +259,64 @@ func (c *StdioClient) Initialize(ctx context.Context) (*InitializeResult, error)
 	c.mu.Unlock()
 
 	if !connected {
-		return nil, fmt.Errorf("client not connected")
+		return nil, &TransportError{Server: c.serverName, Err: ErrNotConnected}
 	}
 	
 	// Create initialize request
-	request := NewInitializeRequest(c.idGen, "dynamic-mcp-proxy", "1.0.0")
+	request := NewInitializeRequest(c.idGen, ProxyClientName, ProxyClientVersion)
+	start := time.Now()
+	c.tracef(start, "sending initialize request (timeout %v)", c.connectTimeout)
 	
 	// Send request and get response
-	response, err := c.sendRequest(ctx, request)
+	response, err := c.sendRequest(ctx, request, c.connectTimeout)
 	if err != nil {
+		c.tracef(start, "initialize request failed: %v", err)
 		return nil, fmt.Errorf("initialize request failed: %w", err)
 	}
 	
 	// Parse initialize result
 	var result InitializeResult
 	if err := ParseResponse(response, &result); err != nil {
+		c.tracef(start, "initialize response rejected: %v", err)
 		return nil, fmt.Errorf("failed to parse initialize response: %w", err)
 	}
+	c.tracef(start, "initialize response received: protocol %s, server %s %s, capabilities %v",
+		result.ProtocolVersion, result.ServerInfo.Name, result.ServerInfo.Version, capabilityNames(result.Capabilities))
+
+	if err := CheckProtocolVersion(c.serverName, result.ProtocolVersion); err != nil {
+		if !c.anyVersion {
+			c.tracef(start, "initialize response rejected: %v", err)
+			return nil, err
+		}
+		log.Printf("Warning: %v; continuing on a best-effort basis (proxy.protocolMismatch is warn)", err)
+	}
+
+	// Complete the handshake; the server may ignore requests until then
+	if err := c.sendNotification(NotificationInitialized); err != nil {
+		c.tracef(start, "initialized notification failed: %v", err)
+		return nil, err
+	}
+	c.tracef(start, "initialized notification sent")
+
+	c.mu.Lock()
+	c.protocol = result.ProtocolVersion
+	_, c.logging = result.Capabilities["logging"]
+	c.initResult = &result
+	c.mu.Unlock()
 	
 	return &result, nil
 }
 
+// capabilityNames returns the sorted names of a server's capabilities
+func capabilityNames(capabilities map[string]interface{}) []string {
+	names := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ListTools discovers available tools from the server
 func (c *StdioClient) ListTools(ctx context.Context) ([]ToolInfo, error) {
 	// Check connected state with proper mutex
@@ -151,27 +325,42 @@ func (c *StdioClient) ListTools(ctx context.Context) ([]ToolInfo, error) {
 	c.mu.Unlock()
 
 	if !connected {
-		return nil, fmt.Errorf("client not connected")
-	}
-	
-	// Create tools/list request
-	request := NewListToolsRequest(c.idGen)
-	
-	// Send request and get response
-	response, err := c.sendRequest(ctx, request)
-	if err != nil {
-		return nil, fmt.Errorf("tools/list request failed: %w", err)
+		return nil, &TransportError{Server: c.serverName, Err: ErrNotConnected}
 	}
 	
-	// Parse tools list result
-	var result struct {
-		Tools []ToolInfo `json:"tools"`
-	}
-	if err := ParseResponse(response, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse tools/list response: %w", err)
+	// Follow nextCursor until the server reports no further pages
+	var tools []ToolInfo
+	cursor := ""
+	seen := make(map[string]bool)
+	for {
+		request := NewListToolsRequest(c.idGen, cursor)
+
+		// Send request and get response
+		response, err := c.sendRequest(ctx, request, c.callTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("tools/list request failed: %w", err)
+		}
+
+		// Parse tools list result
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor,omitempty"`
+		}
+		if err := ParseResponse(response, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse tools/list response: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		// Guard against servers that hand out the same cursor forever
+		if seen[result.NextCursor] {
+			return nil, fmt.Errorf("tools/list pagination loop: cursor %q repeated", result.NextCursor)
+		}
+		seen[result.NextCursor] = true
+		cursor = result.NextCursor
 	}
-	
-	return result.Tools, nil
 }
 
 // CallTool invokes a specific tool with arguments
@@ -185,14 +374,14 @@ func (c *StdioClient) CallTool(ctx context.Context, name string, args map[string
 
 	if !connected {
 		log.Printf("[DEBUG] CallTool(%s, %s): FAILED - client not connected", c.serverName, name)
-		return nil, fmt.Errorf("client not connected")
+		return nil, &TransportError{Server: c.serverName, Err: ErrNotConnected}
 	}
 	
 	// Create tools/call request
 	request := NewCallToolRequest(c.idGen, name, args)
 	
 	// Send request and get response
-	response, err := c.sendRequest(ctx, request)
+	response, err := c.sendRequest(ctx, request, c.callTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("tools/call request failed: %w", err)
 	}
@@ -206,6 +395,27 @@ func (c *StdioClient) CallTool(ctx context.Context, name string, args map[string
 	return &result, nil
 }
 
+// SetLogLevel asks the server to send log messages of level and above
+func (c *StdioClient) SetLogLevel(ctx context.Context, level string) error {
+	c.mu.Lock()
+	logging := c.logging
+	c.mu.Unlock()
+
+	if !logging {
+		return ErrLoggingUnsupported
+	}
+
+	response, err := c.sendRequest(ctx, NewSetLevelRequest(c.idGen, level), c.callTimeout)
+	if err != nil {
+		return fmt.Errorf("logging/setLevel request failed: %w", err)
+	}
+	var result struct{}
+	if err := ParseResponse(response, &result); err != nil {
+		return fmt.Errorf("logging/setLevel failed: %w", err)
+	}
+	return nil
+}
+
 // Close terminates the connection
 func (c *StdioClient) Close() error {
 	c.mu.Lock()
@@ -232,7 +442,13 @@ func (c *StdioClient) Close() error {
 	
 	// Terminate process
 	if c.cmd != nil && c.cmd.Process != nil {
-		if err := c.cmd.Process.Kill(); err != nil {
+		if c.group != nil {
+			if err := c.group.kill(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to kill process group: %w", err))
+			}
+			c.group = nil
+		}
+		if err := c.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
 			errs = append(errs, fmt.Errorf("failed to kill process: %w", err))
 		}
 		
@@ -264,23 +480,28 @@ func (c *StdioClient) IsConnected() bool {
 	return c.connected
 }
 
-// sendRequest sends a JSON-RPC request and waits for response
-func (c *StdioClient) sendRequest(ctx context.Context, request *JSONRPCRequest) (*JSONRPCResponse, error) {
-	// Check connected state with proper mutex
+// sendRequest sends a JSON-RPC request and waits up to timeout for the
+// response. A server that doesn't answer in time is assumed to be stuck, so
+// the connection is closed.
+func (c *StdioClient) sendRequest(ctx context.Context, request *JSONRPCRequest, timeout time.Duration) (*JSONRPCResponse, error) {
+	// Register for the response before sending, so it can't be missed
+	waiter := make(chan *JSONRPCResponse, 1)
 	c.mu.Lock()
-	connected := c.connected
-	c.mu.Unlock()
-
-	if !connected {
-		return nil, fmt.Errorf("client not connected")
+	if !c.connected {
+		c.mu.Unlock()
+		return nil, &TransportError{Server: c.serverName, Err: ErrNotConnected}
 	}
-
-	// Serialize all I/O operations
-	c.requestMu.Lock()
-	defer c.requestMu.Unlock()
+	incoming := c.incoming
+	incoming.pending[request.ID] = waiter
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(incoming.pending, request.ID)
+		c.mu.Unlock()
+	}()
 
 	// Set timeout for the request
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Serialize request
@@ -289,27 +510,105 @@ func (c *StdioClient) sendRequest(ctx context.Context, request *JSONRPCRequest)
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Send request - now protected by mutex
+	// Send request; writes are serialized so requests don't interleave
 	requestLine := append(requestBytes, '\n')
-	if _, err := c.stdin.Write(requestLine); err != nil {
-		return nil, fmt.Errorf("failed to write request: %w", err)
+	c.requestMu.Lock()
+	_, err = c.stdin.Write(requestLine)
+	c.requestMu.Unlock()
+	if err != nil {
+		return nil, &TransportError{Server: c.serverName, Op: "failed to write request", Err: err}
+	}
+
+	select {
+	case response := <-waiter:
+		return response, nil
+	case <-incoming.done:
+		// The response may have been read just before stdout ended
+		select {
+		case response := <-waiter:
+			return response, nil
+		default:
+		}
+		return nil, &TransportError{Server: c.serverName, Op: "failed to read response", Err: incoming.err}
+	case <-ctx.Done():
+		c.Close()
+		return nil, &TransportError{Server: c.serverName, Op: fmt.Sprintf("no response to %s within %v", request.Method, timeout), Err: ctx.Err()}
 	}
+}
 
-	// Read response - now protected by mutex
-	responseLine, err := c.reader.ReadBytes('\n')
+// sendNotification sends a JSON-RPC notification, which gets no response
+func (c *StdioClient) sendNotification(method string) error {
+	notificationBytes, err := json.Marshal(JSONRPCNotification{JSONRPC: "2.0", Method: method})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	// Parse and validate response
-	var response JSONRPCResponse
-	if err := json.Unmarshal(responseLine, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	c.requestMu.Lock()
+	_, err = c.stdin.Write(append(notificationBytes, '\n'))
+	c.requestMu.Unlock()
+	if err != nil {
+		return &TransportError{Server: c.serverName, Op: "failed to write notification", Err: err}
 	}
+	return nil
+}
 
-	if response.ID != request.ID {
-		return nil, fmt.Errorf("response ID mismatch: expected %d, got %d", request.ID, response.ID)
+// startReading starts reading the server's stdout in the background, so
+// notifications are handled as they arrive and the server never blocks on
+// a full pipe while no request is waiting
+func (c *StdioClient) startReading() {
+	incoming := &responseRouter{
+		pending: make(map[int64]chan *JSONRPCResponse),
+		done:    make(chan struct{}),
 	}
+	c.incoming = incoming
+	go c.readMessages(c.reader, incoming)
+}
+
+// readMessages reads stdout until it ends. Responses go to the request
+// waiting for them and notifications to the handler. Lines that aren't JSON
+// objects, e.g. debug output a server prints to stdout, are logged and
+// skipped rather than breaking the stream.
+func (c *StdioClient) readMessages(reader *bufio.Reader, incoming *responseRouter) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			incoming.err = err
+			close(incoming.done)
+			return
+		}
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 {
+			continue
+		}
+		if trimmed[0] != '{' || !json.Valid(trimmed) {
+			log.Printf("[%s stdout] ignoring output that isn't a JSON-RPC message: %s", c.serverName, trimmed)
+			continue
+		}
 
-	return &response, nil
-}
\ No newline at end of file
+		if notification := parseNotification(trimmed); notification != nil {
+			c.mu.Lock()
+			notify := c.notify
+			c.mu.Unlock()
+			if notify != nil {
+				notify(notification.Method, notification.Params)
+			}
+			continue
+		}
+
+		var response JSONRPCResponse
+		if err := json.Unmarshal(trimmed, &response); err != nil {
+			log.Printf("[%s stdout] ignoring malformed response: %v", c.serverName, err)
+			continue
+		}
+		c.mu.Lock()
+		waiter, ok := incoming.pending[response.ID]
+		delete(incoming.pending, response.ID)
+		c.mu.Unlock()
+		if !ok {
+			log.Printf("[%s stdout] ignoring response to request %d, which is no longer waiting", c.serverName, response.ID)
+			continue
+		}
+		waiter <- &response
+	}
+}
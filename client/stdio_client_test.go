@@ -0,0 +1,614 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPipeClient returns a connected StdioClient whose requests are answered
+// by respond instead of a subprocess. A *JSONRPCError from respond is sent
+// as an error response. respond also sees notifications, which get no
+// response.
+func newPipeClient(t *testing.T, respond func(request map[string]interface{}) interface{}) *StdioClient {
+	t.Helper()
+
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	t.Cleanup(func() {
+		requestWriter.Close()
+		responseWriter.Close()
+	})
+
+	go func() {
+		scanner := bufio.NewScanner(requestReader)
+		for scanner.Scan() {
+			var request map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+				return
+			}
+			result := respond(request)
+			if _, isRequest := request["id"]; !isRequest {
+				// Notifications get no response
+				continue
+			}
+			message := map[string]interface{}{"jsonrpc": "2.0", "id": request["id"], "result": result}
+			if rpcErr, ok := result.(*JSONRPCError); ok {
+				message = map[string]interface{}{"jsonrpc": "2.0", "id": request["id"], "error": rpcErr}
+			}
+			response, _ := json.Marshal(message)
+			fmt.Fprintf(responseWriter, "%s\n", response)
+		}
+	}()
+
+	c := NewStdioClient("paged", "unused", nil)
+	c.stdin = requestWriter
+	c.reader = bufio.NewReader(responseReader)
+	c.startReading()
+	c.connected = true
+	return c
+}
+
+func TestListToolsFollowsCursor(t *testing.T) {
+	var cursors []interface{}
+	c := newPipeClient(t, func(request map[string]interface{}) interface{} {
+		var cursor interface{}
+		if params, ok := request["params"].(map[string]interface{}); ok {
+			cursor = params["cursor"]
+		}
+		cursors = append(cursors, cursor)
+
+		if cursor == nil {
+			return map[string]interface{}{
+				"tools":      []map[string]interface{}{{"name": "first"}, {"name": "second"}},
+				"nextCursor": "page-2",
+			}
+		}
+		return map[string]interface{}{
+			"tools": []map[string]interface{}{{"name": "third"}},
+		}
+	})
+
+	tools, err := c.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 3 || tools[2].Name != "third" {
+		t.Fatalf("expected tools from both pages, got %+v", tools)
+	}
+	if len(cursors) != 2 || cursors[1] != "page-2" {
+		t.Errorf("expected second request with cursor page-2, got %v", cursors)
+	}
+}
+
+func TestListToolsRejectsCursorLoop(t *testing.T) {
+	c := newPipeClient(t, func(request map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"tools":      []map[string]interface{}{{"name": "again"}},
+			"nextCursor": "same",
+		}
+	})
+
+	if _, err := c.ListTools(context.Background()); err == nil {
+		t.Fatal("expected error for repeated cursor")
+	}
+}
+
+func TestSendRequestSkipsNotifications(t *testing.T) {
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	defer requestWriter.Close()
+	defer responseWriter.Close()
+
+	go func() {
+		scanner := bufio.NewScanner(requestReader)
+		for scanner.Scan() {
+			var request map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &request)
+			fmt.Fprintf(responseWriter, "%s\n", `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+			fmt.Fprintf(responseWriter, `{"jsonrpc":"2.0","id":%v,"result":{"tools":[{"name":"only"}]}}`+"\n", request["id"])
+		}
+	}()
+
+	c := NewStdioClient("notifying", "unused", nil)
+	c.stdin = requestWriter
+	c.reader = bufio.NewReader(responseReader)
+	c.startReading()
+	c.connected = true
+
+	var methods []string
+	c.SetNotificationHandler(func(method string, params json.RawMessage) {
+		methods = append(methods, method)
+	})
+
+	tools, err := c.ListTools(context.Background())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "only" {
+		t.Errorf("expected response after notification, got %+v", tools)
+	}
+	if len(methods) != 1 || methods[0] != NotificationToolsListChanged {
+		t.Errorf("expected list_changed notification, got %v", methods)
+	}
+}
+
+func TestNotificationsDeliveredWhileIdle(t *testing.T) {
+	responseReader, responseWriter := io.Pipe()
+	defer responseWriter.Close()
+
+	c := NewStdioClient("idle", "unused", nil)
+	c.reader = bufio.NewReader(responseReader)
+	methods := make(chan string, 1)
+	c.SetNotificationHandler(func(method string, params json.RawMessage) {
+		methods <- method
+	})
+	c.startReading()
+	c.connected = true
+
+	// No request is in flight; the notification must still arrive
+	fmt.Fprintf(responseWriter, "%s\n", `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+	select {
+	case method := <-methods:
+		if method != NotificationToolsListChanged {
+			t.Errorf("expected list_changed, got %s", method)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification sent between requests was not delivered")
+	}
+}
+
+func TestResponsesRoutedByID(t *testing.T) {
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	defer requestWriter.Close()
+	defer responseWriter.Close()
+
+	// Answer two calls in the opposite order they were sent
+	go func() {
+		scanner := bufio.NewScanner(requestReader)
+		var requests []map[string]interface{}
+		for len(requests) < 2 && scanner.Scan() {
+			var request map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &request)
+			requests = append(requests, request)
+		}
+		for i := len(requests) - 1; i >= 0; i-- {
+			params := requests[i]["params"].(map[string]interface{})
+			fmt.Fprintf(responseWriter, `{"jsonrpc":"2.0","id":%v,"result":{"content":[{"type":"text","text":%q}]}}`+"\n",
+				requests[i]["id"], params["name"])
+		}
+	}()
+
+	c := NewStdioClient("concurrent", "unused", nil)
+	c.stdin = requestWriter
+	c.reader = bufio.NewReader(responseReader)
+	c.startReading()
+	c.connected = true
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"first", "second"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.CallTool(context.Background(), name, nil)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				return
+			}
+			if len(result.Content) != 1 || result.Content[0].Text != name {
+				t.Errorf("%s: got the response of another call: %+v", name, result.Content)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSendRequestSkipsNonJSONOutput(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	defer requestWriter.Close()
+	defer responseWriter.Close()
+
+	go func() {
+		scanner := bufio.NewScanner(requestReader)
+		for scanner.Scan() {
+			var request map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &request)
+			// One write, so the junk after the response doesn't block the pipe
+			fmt.Fprintf(responseWriter, "DEBUG: handling %v\n\n"+
+				`{"jsonrpc":"2.0","id":%v,"result":{"tools":[{"name":"only"}]}}`+"\n"+
+				"{not json either\n42\n\"ok\"\n", request["method"], request["id"])
+		}
+	}()
+
+	c := NewStdioClient("noisy", "unused", nil)
+	c.stdin = requestWriter
+	c.reader = bufio.NewReader(responseReader)
+	c.startReading()
+	c.connected = true
+
+	// The junk after each response is read ahead of the next one
+	for i := 0; i < 2; i++ {
+		tools, err := c.ListTools(context.Background())
+		if err != nil {
+			t.Fatalf("ListTools %d failed: %v", i+1, err)
+		}
+		if len(tools) != 1 || tools[0].Name != "only" {
+			t.Errorf("ListTools %d: expected response after junk, got %+v", i+1, tools)
+		}
+	}
+
+	// Let the reader log the junk after the last response
+	responseWriter.Close()
+	<-c.incoming.done
+
+	for _, line := range []string{
+		"[noisy stdout] ignoring output that isn't a JSON-RPC message: DEBUG: handling tools/list",
+		"[noisy stdout] ignoring output that isn't a JSON-RPC message: {not json either",
+		"[noisy stdout] ignoring output that isn't a JSON-RPC message: 42",
+		`[noisy stdout] ignoring output that isn't a JSON-RPC message: "ok"`,
+	} {
+		if !strings.Contains(logs.String(), line) {
+			t.Errorf("expected %q in logs:\n%s", line, logs.String())
+		}
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	defer requestWriter.Close()
+
+	// The server exits after reading the request without answering
+	go func() {
+		bufio.NewReader(requestReader).ReadBytes('\n')
+		responseWriter.Close()
+	}()
+
+	c := NewStdioClient("crashing", "unused", nil)
+	c.stdin = requestWriter
+	c.reader = bufio.NewReader(responseReader)
+	c.startReading()
+	c.connected = true
+
+	_, err := c.CallTool(context.Background(), "read", nil)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || !errors.Is(err, io.EOF) {
+		t.Fatalf("expected TransportError wrapping EOF, got %v", err)
+	}
+
+	c.connected = false
+	_, err = c.CallTool(context.Background(), "read", nil)
+	if !errors.As(err, &transportErr) || !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected TransportError wrapping ErrNotConnected, got %v", err)
+	}
+
+	// A malformed response is not a transport error
+	backend := newPipeClient(t, func(request map[string]interface{}) interface{} { return "not a result" })
+	if _, err := backend.CallTool(context.Background(), "read", nil); err == nil || errors.As(err, &transportErr) {
+		t.Errorf("expected non-transport error, got %v", err)
+	}
+}
+
+func TestInitializeBoundedByConnectionTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// The server never answers initialize, but answers tool calls at once
+	c := newPipeClient(t, func(request map[string]interface{}) interface{} {
+		if request["method"] == "initialize" {
+			<-release
+		}
+		return map[string]interface{}{"content": []interface{}{}}
+	})
+	c.SetTimeouts(50*time.Millisecond, time.Minute)
+
+	start := time.Now()
+	_, err := c.Initialize(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("initialize took %v, expected the 50ms connection timeout to apply", elapsed)
+	}
+	if c.IsConnected() {
+		t.Error("expected client to be closed after a timed out request")
+	}
+}
+
+func TestCallToolUsesCallTimeout(t *testing.T) {
+	c := newPipeClient(t, func(request map[string]interface{}) interface{} {
+		time.Sleep(100 * time.Millisecond)
+		return map[string]interface{}{"content": []interface{}{}}
+	})
+	// A short connection timeout must not cut off a slower tool call
+	c.SetTimeouts(10*time.Millisecond, 5*time.Second)
+
+	if _, err := c.CallTool(context.Background(), "slow", nil); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+}
+
+func TestConnectExpandsLazyVars(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "first")
+
+	c := NewStdioClient("lazy", "cat", []string{"--token=${{MCP_TEST_TOKEN}}"})
+	c.SetEnvironment([]string{"TOKEN=${{MCP_TEST_TOKEN}}"})
+
+	for _, token := range []string{"first", "rotated"} {
+		t.Setenv("MCP_TEST_TOKEN", token)
+		if err := c.Connect(context.Background()); err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		if arg := c.cmd.Args[1]; arg != "--token="+token {
+			t.Errorf("expected arg '--token=%s', got %q", token, arg)
+		}
+		found := false
+		for _, entry := range c.cmd.Env {
+			found = found || entry == "TOKEN="+token
+		}
+		if !found {
+			t.Errorf("expected TOKEN=%s in environment", token)
+		}
+		c.Close()
+	}
+
+	// The stored template is kept for the next connect
+	if c.args[0] != "--token=${{MCP_TEST_TOKEN}}" {
+		t.Errorf("expected template to be preserved, got %q", c.args[0])
+	}
+}
+
+func TestConnectReusesEnvironmentSnapshot(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "first")
+
+	c := NewStdioClient("snap", "cat", nil)
+	c.SetEnvironment([]string{"TOKEN=${{MCP_TEST_TOKEN}}"})
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	snapshot := c.Environment()
+	c.Close()
+
+	hasToken := func(env []string, token string) bool {
+		for _, entry := range env {
+			if entry == "TOKEN="+token {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasToken(snapshot, "first") {
+		t.Fatalf("expected TOKEN=first in the started environment, got %v", snapshot)
+	}
+
+	// With the snapshot, a changed parent environment is not picked up
+	t.Setenv("MCP_TEST_TOKEN", "rotated")
+	c.SetEnvironmentSnapshot(snapshot)
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if !hasToken(c.cmd.Env, "first") || hasToken(c.cmd.Env, "rotated") {
+		t.Errorf("expected the snapshot environment to be reused")
+	}
+	c.Close()
+
+	// Clearing it resolves the environment again
+	c.SetEnvironmentSnapshot(nil)
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if !hasToken(c.Environment(), "rotated") {
+		t.Errorf("expected the environment to be resolved again")
+	}
+	c.Close()
+}
+
+func TestConnectTrace(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	c := NewStdioClient("traced", "cat", nil)
+	c.SetConnectTrace(true)
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	c.Close()
+
+	initialized := newPipeClient(t, func(request map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.2.3"},
+		}
+	})
+	initialized.SetConnectTrace(true)
+	if _, err := initialized.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+
+	for _, step := range []string{
+		"[TRACE] traced: stdin/stdout pipes established",
+		"[TRACE] traced: process spawned (pid ",
+		"[TRACE] traced: connected",
+		"[TRACE] paged: sending initialize request",
+		"[TRACE] paged: initialize response received: protocol 2025-03-26, server fake 1.2.3, capabilities [tools]",
+	} {
+		if !strings.Contains(logs.String(), step) {
+			t.Errorf("expected trace step %q in:\n%s", step, logs.String())
+		}
+	}
+
+	// Without tracing nothing is logged
+	logs.Reset()
+	quiet := newPipeClient(t, func(request map[string]interface{}) interface{} { return map[string]interface{}{} })
+	quiet.Initialize(context.Background())
+	if strings.Contains(logs.String(), "[TRACE]") {
+		t.Errorf("expected no trace output, got:\n%s", logs.String())
+	}
+}
+
+func TestInitializeProtocolVersionMismatch(t *testing.T) {
+	respond := func(request map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"protocolVersion": "2099-01-01",
+			"capabilities":    map[string]interface{}{},
+			"serverInfo":      map[string]interface{}{"name": "future", "version": "9.0"},
+		}
+	}
+
+	c := newPipeClient(t, respond)
+	_, err := c.Initialize(context.Background())
+	if !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("expected ErrProtocolVersion, got %v", err)
+	}
+	for _, want := range []string{`"2099-01-01"`, `"` + ProtocolVersion + `"`, "server paged"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %s, got %v", want, err)
+		}
+	}
+	if c.ProtocolVersion() != "" {
+		t.Errorf("expected no protocol version after a rejected handshake, got %q", c.ProtocolVersion())
+	}
+
+	// Best effort: the handshake succeeds with a warning
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	lenient := newPipeClient(t, respond)
+	lenient.SetAllowAnyProtocol(true)
+	if _, err := lenient.Initialize(context.Background()); err != nil {
+		t.Fatalf("expected best-effort initialize to succeed, got %v", err)
+	}
+	if lenient.ProtocolVersion() != "2099-01-01" {
+		t.Errorf("expected protocol version 2099-01-01, got %q", lenient.ProtocolVersion())
+	}
+	if !strings.Contains(logs.String(), "Warning: unsupported MCP protocol version") {
+		t.Errorf("expected a warning, got:\n%s", logs.String())
+	}
+}
+
+func TestInitializeHandshake(t *testing.T) {
+	serverInfo := map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+	}
+	tests := []struct {
+		name    string
+		result  interface{}
+		wantErr string
+		want    []string
+	}{
+		{
+			name:   "accepted",
+			result: serverInfo,
+			want:   []string{"initialize", NotificationInitialized},
+		},
+		{
+			name:    "rejected",
+			result:  &JSONRPCError{Code: -32600, Message: "unsupported client"},
+			wantErr: "unsupported client",
+			want:    []string{"initialize"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			methods := make(chan string, 10)
+			c := newPipeClient(t, func(request map[string]interface{}) interface{} {
+				methods <- fmt.Sprint(request["method"])
+				return tt.result
+			})
+
+			result, err := c.Initialize(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if c.InitializeResult() != nil {
+					t.Error("expected no initialize result after a rejected handshake")
+				}
+			} else if err != nil || result.ServerInfo.Name != "fake" {
+				t.Fatalf("expected the handshake to succeed, got %+v, %v", result, err)
+			}
+
+			// Collect what the backend received, waiting briefly for
+			// anything beyond what's expected
+			var got []string
+			for {
+				wait := time.Second
+				if len(got) >= len(tt.want) {
+					wait = 50 * time.Millisecond
+				}
+				select {
+				case method := <-methods:
+					got = append(got, method)
+					continue
+				case <-time.After(wait):
+				}
+				break
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v sent, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	var levels []interface{}
+	respond := func(capabilities map[string]interface{}) func(request map[string]interface{}) interface{} {
+		return func(request map[string]interface{}) interface{} {
+			if request["method"] == "logging/setLevel" {
+				levels = append(levels, request["params"].(map[string]interface{})["level"])
+				return map[string]interface{}{}
+			}
+			return map[string]interface{}{
+				"protocolVersion": ProtocolVersion,
+				"capabilities":    capabilities,
+				"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+			}
+		}
+	}
+
+	c := newPipeClient(t, respond(map[string]interface{}{"logging": map[string]interface{}{}}))
+	if _, err := c.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := c.SetLogLevel(context.Background(), "warning"); err != nil {
+		t.Fatalf("setLevel: %v", err)
+	}
+	if len(levels) != 1 || levels[0] != "warning" {
+		t.Errorf("expected one setLevel request for warning, got %v", levels)
+	}
+
+	// Servers without the logging capability aren't asked
+	silent := newPipeClient(t, respond(map[string]interface{}{}))
+	if _, err := silent.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := silent.SetLogLevel(context.Background(), "debug"); !errors.Is(err, ErrLoggingUnsupported) {
+		t.Errorf("expected ErrLoggingUnsupported, got %v", err)
+	}
+	if len(levels) != 1 {
+		t.Errorf("expected no request to a server without logging, got %v", levels)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StartLevels groups the servers by dependsOn: every server's dependencies
+// are in earlier levels, so each level can be started once the previous
+// ones are up. Servers keep their config order within a level, and without
+// any dependsOn all servers are in a single level. A dependency on an
+// unknown server or a cycle is an error.
+func (c *ProxyConfig) StartLevels() ([][]ServerConfig, error) {
+	index := make(map[string]int, len(c.Servers))
+	for i, server := range c.Servers {
+		index[server.Name] = i
+	}
+	for _, server := range c.Servers {
+		for _, dependency := range server.DependsOn {
+			if dependency == server.Name {
+				return nil, fmt.Errorf("server %s: dependsOn names the server itself", server.Name)
+			}
+			if _, ok := index[dependency]; !ok {
+				return nil, fmt.Errorf("server %s: dependsOn names unknown server %q", server.Name, dependency)
+			}
+		}
+	}
+
+	var levels [][]ServerConfig
+	placed := make(map[string]bool, len(c.Servers))
+	for len(placed) < len(c.Servers) {
+		var level []ServerConfig
+		for _, server := range c.Servers {
+			if !placed[server.Name] && dependenciesPlaced(server, placed) {
+				level = append(level, server)
+			}
+		}
+		if len(level) == 0 {
+			return nil, fmt.Errorf("dependsOn cycle: %s", c.dependencyCycle(placed))
+		}
+		for _, server := range level {
+			placed[server.Name] = true
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// dependenciesPlaced reports whether all of the server's dependencies are
+// in placed
+func dependenciesPlaced(server ServerConfig, placed map[string]bool) bool {
+	for _, dependency := range server.DependsOn {
+		if !placed[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyCycle describes a cycle among the servers not in placed, e.g.
+// "a -> b -> a". Every unplaced server depends on another unplaced one, so
+// following those dependencies must come back around.
+func (c *ProxyConfig) dependencyCycle(placed map[string]bool) string {
+	servers := make(map[string]ServerConfig, len(c.Servers))
+	start := ""
+	for _, server := range c.Servers {
+		servers[server.Name] = server
+		if start == "" && !placed[server.Name] {
+			start = server.Name
+		}
+	}
+
+	var path []string
+	seen := make(map[string]int)
+	for name := start; ; {
+		if i, ok := seen[name]; ok {
+			return strings.Join(append(path[i:], name), " -> ")
+		}
+		seen[name] = len(path)
+		path = append(path, name)
+		for _, dependency := range servers[name].DependsOn {
+			if !placed[dependency] {
+				name = dependency
+				break
+			}
+		}
+	}
+}
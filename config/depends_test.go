@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStartLevels(t *testing.T) {
+	cfg := &ProxyConfig{Servers: []ServerConfig{
+		{Name: "app", DependsOn: []string{"db", "cache"}},
+		{Name: "db"},
+		{Name: "reports", DependsOn: []string{"app"}},
+		{Name: "cache"},
+		{Name: "search", DependsOn: []string{"db"}},
+	}}
+
+	levels, err := cfg.StartLevels()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got [][]string
+	for _, level := range levels {
+		var names []string
+		for _, server := range level {
+			names = append(names, server.Name)
+		}
+		got = append(got, names)
+	}
+	want := [][]string{{"db", "cache"}, {"app", "search"}, {"reports"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected levels %v, got %v", want, got)
+	}
+
+	// Without dependsOn every server starts at once
+	flat := &ProxyConfig{Servers: []ServerConfig{{Name: "a"}, {Name: "b"}}}
+	if levels, _ := flat.StartLevels(); len(levels) != 1 || len(levels[0]) != 2 {
+		t.Errorf("expected a single level, got %v", levels)
+	}
+}
+
+func TestStartLevelsErrors(t *testing.T) {
+	tests := []struct {
+		servers  []ServerConfig
+		errMatch string
+	}{
+		{[]ServerConfig{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"c"}}, {Name: "c", DependsOn: []string{"b"}}}, "dependsOn cycle: b -> c -> b"},
+		{[]ServerConfig{{Name: "a", DependsOn: []string{"a"}}}, "names the server itself"},
+		{[]ServerConfig{{Name: "a", DependsOn: []string{"db"}}}, `unknown server "db"`},
+	}
+
+	for _, tt := range tests {
+		cfg := &ProxyConfig{Servers: tt.servers}
+		if _, err := cfg.StartLevels(); err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+			t.Errorf("expected error containing %q, got %v", tt.errMatch, err)
+		}
+	}
+
+	// Validate rejects a cycle when the config is loaded
+	if _, err := LoadConfigFromString(`
+servers:
+  - name: "a"
+    prefix: "a"
+    transport: "stdio"
+    command: "a-server"
+    dependsOn: ["b"]
+  - name: "b"
+    prefix: "b"
+    transport: "stdio"
+    command: "b-server"
+    dependsOn: ["a"]
+`); err == nil || !strings.Contains(err.Error(), "dependsOn cycle: a -> b -> a") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namedListKeys are the top-level lists whose items are named and merged
+// across the files of a config directory, rather than replaced
+var namedListKeys = map[string]bool{
+	"servers":        true,
+	"compositeTools": true,
+}
+
+// ConfigDirFiles returns the *.yaml and *.yml files of a config directory,
+// sorted by filename
+func ConfigDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.Type().IsRegular() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("config directory %s has no *.yaml or *.yml files", dir)
+	}
+	return files, nil
+}
+
+// parseConfigDir merges the files of a config directory, in filename order,
+// into one config. Each file is checked on its own, so errors name it, and
+// proxy.unknownFields applies to the file that sets it. Servers and
+// composite tools are collected from all files; a name defined in two files
+// is an error naming both. Other settings are merged key by key, a later
+// file's value replacing an earlier one.
+func parseConfigDir(dir string) (*ProxyConfig, error) {
+	files, err := ConfigDirFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged *yaml.Node
+	defined := make(map[string]string) // "servers/NAME" -> file defining it
+	for _, file := range files {
+		data, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := parseConfig(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: failed to parse YAML config: %w", file, err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue // Empty, or only comments
+		}
+		fragment := doc.Content[0]
+
+		if err := checkDuplicateNames(fragment, file, defined); err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = fragment
+		} else {
+			mergeConfigNodes(merged, fragment, true)
+		}
+	}
+
+	var config ProxyConfig
+	if merged != nil {
+		if err := merged.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	}
+	return &config, nil
+}
+
+// checkDuplicateNames records which file defines each server and composite
+// tool of a fragment, failing on a name an earlier file already defined
+func checkDuplicateNames(fragment *yaml.Node, file string, defined map[string]string) error {
+	for i := 0; i+1 < len(fragment.Content); i += 2 {
+		key, list := fragment.Content[i].Value, fragment.Content[i+1]
+		if !namedListKeys[key] || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+			nameNode := mappingValue(item, "name")
+			if nameNode == nil || nameNode.Value == "" {
+				continue // Reported by Validate
+			}
+			name := nameNode.Value
+			id := key + "/" + name
+			if other, ok := defined[id]; ok {
+				kind := strings.TrimSuffix(key, "s")
+				return fmt.Errorf("duplicate %s name %s: defined in both %s and %s", kind, name, other, file)
+			}
+			defined[id] = file
+		}
+	}
+	return nil
+}
+
+// mergeConfigNodes merges the mapping src into dst. Keys only in src are
+// added; nested mappings are merged; named top-level lists are appended to;
+// anything else in src replaces the value in dst.
+func mergeConfigNodes(dst, src *yaml.Node, topLevel bool) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		existing := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				existing = j + 1
+				break
+			}
+		}
+		if existing < 0 {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+
+		current := dst.Content[existing]
+		switch {
+		case topLevel && namedListKeys[key.Value] && current.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			current.Content = append(current.Content, value.Content...)
+		case current.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeConfigNodes(current, value, false)
+		default:
+			dst.Content[existing] = value
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigDir writes fragments, keyed by filename, to a temp directory
+func writeConfigDir(t *testing.T, fragments map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range fragments {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigDirectory(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"00-proxy.yaml": `
+proxy:
+  healthCheckInterval: "30s"
+  connectionTimeout: "10s"
+`,
+		"20-git.yml": `
+servers:
+  - name: "git"
+    prefix: "git"
+    transport: "stdio"
+    command: "git-server"
+    dependsOn: ["fs"]
+`,
+		"10-fs.yaml": `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+`,
+		"30-overrides.yaml": `
+proxy:
+  connectionTimeout: "20s"
+compositeTools:
+  - name: "search_all"
+    calls:
+      - server: "fs"
+        tool: "search"
+      - server: "git"
+        tool: "search"
+`,
+		"README.md":     "not a fragment",
+		"40-empty.yaml": "# nothing here yet\n",
+	})
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Servers) != 2 || cfg.Servers[0].Name != "fs" || cfg.Servers[1].Name != "git" {
+		t.Errorf("expected servers in filename order, got %+v", cfg.Servers)
+	}
+	if cfg.Proxy.ConnectionTimeout != "20s" {
+		t.Errorf("expected the later file's connectionTimeout, got %q", cfg.Proxy.ConnectionTimeout)
+	}
+	if cfg.Proxy.HealthCheckInterval != "30s" {
+		t.Errorf("expected healthCheckInterval kept from the earlier file, got %q", cfg.Proxy.HealthCheckInterval)
+	}
+	if len(cfg.CompositeTools) != 1 {
+		t.Errorf("expected the composite tool, got %+v", cfg.CompositeTools)
+	}
+}
+
+func TestLoadConfigDirectoryErrors(t *testing.T) {
+	server := func(name, prefix string) string {
+		return "servers:\n  - name: " + name + "\n    prefix: " + prefix + "\n    transport: stdio\n    command: " + name + "-server\n"
+	}
+
+	tests := []struct {
+		name      string
+		fragments map[string]string
+		errMatch  []string
+	}{
+		{
+			name:      "duplicate server name",
+			fragments: map[string]string{"a.yaml": server("fs", "fs"), "b.yaml": server("fs", "fs2")},
+			errMatch:  []string{"duplicate server name fs: defined in both", "a.yaml", "b.yaml"},
+		},
+		{
+			name:      "invalid fragment",
+			fragments: map[string]string{"a.yaml": server("fs", "fs"), "b.yaml": "servers:\n  - name: git\n    comand: git-server\n"},
+			errMatch:  []string{"b.yaml: invalid configuration", "comand"},
+		},
+		{
+			name:      "validated after merge",
+			fragments: map[string]string{"a.yaml": server("fs", "tools"), "b.yaml": server("git", "tools")},
+			errMatch:  []string{"duplicate server prefix: tools"},
+		},
+		{
+			name:      "no fragments",
+			fragments: map[string]string{"config.json": "{}"},
+			errMatch:  []string{"has no *.yaml or *.yml files"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfigDir(t, tt.fragments))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, match := range tt.errMatch {
+				if !strings.Contains(err.Error(), match) {
+					t.Errorf("expected error containing %q, got %v", match, err)
+				}
+			}
+		})
+	}
+}
@@ -1,51 +1,172 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"os"
-	
+
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig loads and validates the proxy configuration from a file
+// LoadConfig loads and validates the proxy configuration from a file, or
+// from a directory of *.yaml and *.yml fragments merged in filename order
 func LoadConfig(path string) (*ProxyConfig, error) {
-	// Read configuration file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-	
-	// Parse YAML
-	var config ProxyConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+	var config *ProxyConfig
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if config, err = parseConfigDir(path); err != nil {
+			return nil, err
+		}
+	} else {
+		// Read configuration file
+		data, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse YAML
+		if config, err = parseConfig(data); err != nil {
+			return nil, err
+		}
 	}
-	
+
 	// Expand environment variables
-	config.ExpandEnvVars()
-	
+	if err := config.ExpandEnvVars(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
-	
-	return &config, nil
+
+	return config, nil
 }
 
-// LoadConfigFromString loads configuration from a YAML string (for testing)
-func LoadConfigFromString(yamlData string) (*ProxyConfig, error) {
+// parseConfig decodes a YAML config strictly: unknown fields, values of the
+// wrong type and unknown enum values are errors naming the offending path.
+// With proxy.unknownFields set to warn, unknown fields are logged and
+// ignored instead.
+func parseConfig(data []byte) (*ProxyConfig, error) {
+	warnUnknown := unknownFieldPolicy(data) == UnknownFieldsWarn
+
+	var problems []error
+	for _, problem := range checkSchema(data) {
+		if problem.Unknown && warnUnknown {
+			log.Printf("Warning: config %v; ignoring it", problem)
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(problems...))
+	}
+
 	var config ProxyConfig
-	if err := yaml.Unmarshal([]byte(yamlData), &config); err != nil {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(!warnUnknown)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
-	
+	return &config, nil
+}
+
+// unknownFieldPolicy reads proxy.unknownFields ahead of the full decode, as
+// it decides how that decode treats unknown fields
+func unknownFieldPolicy(data []byte) UnknownFieldPolicy {
+	var settings struct {
+		Proxy struct {
+			UnknownFields UnknownFieldPolicy `yaml:"unknownFields"`
+		} `yaml:"proxy"`
+	}
+	yaml.Unmarshal(data, &settings)
+	return settings.Proxy.UnknownFields
+}
+
+// readConfigFile reads a config file, explaining the common first-run
+// failures. The underlying error stays wrapped, so errors.Is(err,
+// fs.ErrNotExist) and fs.ErrPermission still work.
+func readConfigFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("config file %s does not exist (create one with 'config init'): %w", path, err)
+	case errors.Is(err, fs.ErrPermission):
+		return nil, fmt.Errorf("permission denied reading config file %s: %w", path, err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	case info.IsDir():
+		return nil, fmt.Errorf("config path %s is a directory; this command needs a single config file", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrPermission) {
+		return nil, fmt.Errorf("permission denied reading config file %s: %w", path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return data, nil
+}
+
+// LoadConfigFromString loads configuration from a YAML string, e.g. a config
+// read from stdin
+func LoadConfigFromString(yamlData string) (*ProxyConfig, error) {
+	config, err := parseConfig([]byte(yamlData))
+	if err != nil {
+		return nil, err
+	}
+
 	// Expand environment variables
-	config.ExpandEnvVars()
-	
+	if err := config.ExpandEnvVars(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
-	
+
+	return config, nil
+}
+
+// LoadRawConfig parses a configuration file without expanding environment
+// variables or validating it. Use this when the config will be written back,
+// so ${VAR} templates are preserved.
+func LoadRawConfig(path string) (*ProxyConfig, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ProxyConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+
 	return &config, nil
-}
\ No newline at end of file
+}
+
+// SaveConfig validates a raw configuration and writes it to path as YAML.
+// The config is validated as it would be loaded (with env expansion), but
+// written unexpanded. An existing file is edited in place: only changed keys
+// are rewritten, so comments and anchors elsewhere are kept.
+func SaveConfig(path string, config *ProxyConfig) error {
+	data, err := rewriteConfig(readOriginalConfig(path), config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := LoadConfigFromString(string(data)); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -219,6 +223,429 @@ servers:
 `,
 			errMatch: "invalid timeout format",
 		},
+		{
+			name: "negative idleTimeout",
+			yamlData: `
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    idleTimeout: "-5m"
+`,
+			errMatch: "idleTimeout must not be negative",
+		},
+		{
+			name: "invalid duplicate tool policy",
+			yamlData: `
+servers: []
+proxy:
+  duplicateTools: "merge"
+`,
+			errMatch: "invalid duplicateTools",
+		},
+		{
+			name: "invalid onNoTools policy",
+			yamlData: `
+servers: []
+proxy:
+  onNoTools: "retry"
+`,
+			errMatch: "invalid onNoTools",
+		},
+		{
+			name: "allowEmpty contradicts onNoTools",
+			yamlData: `
+servers: []
+proxy:
+  allowEmpty: true
+  onNoTools: "exit"
+`,
+			errMatch: "contradicts onNoTools",
+		},
+		{
+			name: "invalid resultContent mode",
+			yamlData: `
+servers: []
+proxy:
+  resultContent: "html"
+`,
+			errMatch: "invalid resultContent",
+		},
+		{
+			name: "invalid unknownFields policy",
+			yamlData: `
+servers: []
+proxy:
+  unknownFields: "ignore"
+`,
+			errMatch: "invalid unknownFields",
+		},
+		{
+			name: "invalid startupBudget",
+			yamlData: `
+servers: []
+proxy:
+  startupBudget: "soon"
+`,
+			errMatch: "invalid startupBudget",
+		},
+		{
+			name: "invalid protocolMismatch policy",
+			yamlData: `
+servers: []
+proxy:
+  protocolMismatch: "ignore"
+`,
+			errMatch: "invalid protocolMismatch",
+		},
+		{
+			name: "warmup call without tool",
+			yamlData: `
+servers:
+  - name: "docs"
+    prefix: "docs"
+    transport: "stdio"
+    command: "docs-server"
+    warmup:
+      - arguments: {path: "/srv/index"}
+`,
+			errMatch: "warmup call 0: tool is required",
+		},
+		{
+			name: "invalid warmup onFailure",
+			yamlData: `
+servers:
+  - name: "docs"
+    prefix: "docs"
+    transport: "stdio"
+    command: "docs-server"
+    warmup:
+      - tool: "load_index"
+        onFailure: "ignore"
+`,
+			errMatch: "invalid onFailure",
+		},
+		{
+			name: "invalid transform op",
+			yamlData: `
+servers:
+  - name: "search"
+    prefix: "search"
+    transport: "stdio"
+    command: "search-server"
+    transforms:
+      query:
+        - op: "uppercase"
+`,
+			errMatch: "servers[0].transforms.query[0].op: invalid op",
+		},
+		{
+			name: "truncate transform without max",
+			yamlData: `
+servers:
+  - name: "search"
+    prefix: "search"
+    transport: "stdio"
+    command: "search-server"
+    transforms:
+      query:
+        - op: "truncate"
+`,
+			errMatch: "truncate needs a positive max",
+		},
+		{
+			name: "negative server maxResultBytes",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    maxResultBytes: -1
+`,
+			errMatch: "server fs: maxResultBytes must not be negative",
+		},
+		{
+			name: "transcript without a file",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    transcript:
+      maxBytes: 200
+`,
+			errMatch: "server fs: transcript: file is required",
+		},
+		{
+			name: "hidden argument without a default",
+			yamlData: `
+servers:
+  - name: "tracker"
+    prefix: "tracker"
+    transport: "stdio"
+    command: "tracker-server"
+    defaultArguments:
+      list_issues:
+        limit: 20
+    hiddenArguments:
+      list_issues: ["project"]
+`,
+			errMatch: "hidden argument project of tool list_issues needs a value in defaultArguments",
+		},
+		{
+			name: "invalid record metadataFormat",
+			yamlData: `
+servers: []
+record:
+  metadataFormat: "json"
+`,
+			errMatch: "invalid metadataFormat",
+		},
+		{
+			name: "record metadataTemplate with meta format",
+			yamlData: `
+servers: []
+record:
+  metadataFormat: "meta"
+  metadataTemplate: "Recording to {file}"
+`,
+			errMatch: "metadataTemplate only applies",
+		},
+		{
+			name: "composite tool with unknown server",
+			yamlData: `
+servers:
+  - name: "web"
+    prefix: "web"
+    transport: "stdio"
+    command: "web-server"
+compositeTools:
+  - name: "search_all"
+    calls:
+      - server: "web"
+        tool: "search"
+      - server: "docs"
+        tool: "search"
+`,
+			errMatch: `composite tool 0: call 1: unknown server "docs"`,
+		},
+		{
+			name: "composite tool named like a management tool",
+			yamlData: `
+servers:
+  - name: "web"
+    prefix: "web"
+    transport: "stdio"
+    command: "web-server"
+compositeTools:
+  - name: "server_list"
+    calls:
+      - server: "web"
+        tool: "search"
+`,
+			errMatch: "reserved for a management tool",
+		},
+		{
+			name: "empty server tag",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    tags: ["owner:platform", ""]
+`,
+			errMatch: "server fs: tag 1 must not be empty",
+		},
+		{
+			name: "invalid defaultTimeout",
+			yamlData: `
+servers: []
+proxy:
+  defaultTimeout: "0s"
+`,
+			errMatch: "defaultTimeout must be positive",
+		},
+		{
+			name: "invalid toolNames mode",
+			yamlData: `
+servers: []
+proxy:
+  toolNames: "strip"
+`,
+			errMatch: "invalid toolNames",
+		},
+		{
+			name: "invalid toolNameReplacement",
+			yamlData: `
+servers: []
+proxy:
+  toolNameReplacement: "."
+`,
+			errMatch: "invalid toolNameReplacement",
+		},
+		{
+			name: "negative maxDynamicServers",
+			yamlData: `
+servers: []
+proxy:
+  maxDynamicServers: -1
+`,
+			errMatch: "maxDynamicServers must not be negative",
+		},
+		{
+			name: "invalid logLevel",
+			yamlData: `
+servers: []
+proxy:
+  logLevel: verbose
+`,
+			errMatch: "invalid logLevel",
+		},
+		{
+			name: "invalid logFormat",
+			yamlData: `
+servers: []
+proxy:
+  logFormat: xml
+`,
+			errMatch: "invalid logFormat",
+		},
+		{
+			name: "prefix with noPrefix",
+			yamlData: `
+servers:
+  - name: "primary"
+    prefix: "primary"
+    noPrefix: true
+    transport: "stdio"
+    command: "/usr/bin/primary"
+`,
+			errMatch: "mutually exclusive",
+		},
+		{
+			name: "noPrefix servers with the same tool",
+			yamlData: `
+servers:
+  - name: "primary"
+    noPrefix: true
+    transport: "stdio"
+    command: "/usr/bin/primary"
+    defaultArguments:
+      search:
+        limit: 10
+  - name: "secondary"
+    noPrefix: true
+    transport: "stdio"
+    command: "/usr/bin/secondary"
+    warmup:
+      - tool: "search"
+`,
+			errMatch: "server secondary: noPrefix tool search is also exposed unprefixed by server primary",
+		},
+		{
+			name: "negative server maxRetries",
+			yamlData: `
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    maxRetries: -1
+`,
+			errMatch: "maxRetries must not be negative",
+		},
+		{
+			name: "invalid sanitizer pattern",
+			yamlData: `
+servers:
+  - name: "shell"
+    prefix: "sh"
+    transport: "stdio"
+    command: "/usr/bin/shell-server"
+    sanitizer:
+      - pattern: "[unclosed"
+        action: "block"
+`,
+			errMatch: "sanitizer rule 0: invalid pattern",
+		},
+		{
+			name: "invalid sanitizer action",
+			yamlData: `
+servers:
+  - name: "shell"
+    prefix: "sh"
+    transport: "stdio"
+    command: "/usr/bin/shell-server"
+    sanitizer:
+      - pattern: "[;&|]"
+        action: "drop"
+`,
+			errMatch: "invalid action",
+		},
+		{
+			name: "unknown management tool",
+			yamlData: `
+servers: []
+proxy:
+  management:
+    enabledTools: ["server_list", "server_delete"]
+`,
+			errMatch: "unknown management tool \"server_delete\"",
+		},
+		{
+			name: "enabledTools with readOnly",
+			yamlData: `
+servers: []
+proxy:
+  management:
+    readOnly: true
+    enabledTools: ["server_list"]
+`,
+			errMatch: "mutually exclusive",
+		},
+		{
+			name: "invalid allowedCommands args pattern",
+			yamlData: `
+servers: []
+proxy:
+  management:
+    allowedCommands:
+      - command: "npx"
+        args: "-y (unclosed"
+`,
+			errMatch: "allowedCommands 0: invalid args pattern",
+		},
+		{
+			name: "invalid maxMemory",
+			yamlData: `
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    limits:
+      maxMemory: "lots"
+`,
+			errMatch: "limits: invalid maxMemory",
+		},
+		{
+			name: "invalid rate limit action",
+			yamlData: `
+servers:
+  - name: "api"
+    prefix: "api"
+    transport: "stdio"
+    command: "/usr/bin/api-server"
+    rateLimit:
+      requestsPerSecond: 5
+      onLimit: "drop"
+`,
+			errMatch: "servers[0].rateLimit.onLimit: invalid onLimit",
+		},
 	}
 
 	for _, tt := range tests {
@@ -267,21 +694,179 @@ servers:
 	}
 }
 
-func TestGetServerTimeout(t *testing.T) {
+func TestExpandEnvVarsDefaults(t *testing.T) {
+	os.Setenv("TEST_SET_VAR", "from-env")
+	os.Setenv("TEST_EMPTY_VAR", "")
+	os.Unsetenv("TEST_UNSET_VAR")
+	defer os.Unsetenv("TEST_SET_VAR")
+	defer os.Unsetenv("TEST_EMPTY_VAR")
+
 	tests := []struct {
 		name     string
-		timeout  string
+		value    string
 		expected string
 	}{
-		{"default timeout", "", "30s"},
-		{"custom timeout", "60s", "1m0s"},
-		{"invalid timeout", "invalid", "30s"},
+		{"set variable ignores default", "${TEST_SET_VAR:-fallback}", "from-env"},
+		{"unset variable uses default", "${TEST_UNSET_VAR:-fallback}", "fallback"},
+		{"empty variable uses colon default", "${TEST_EMPTY_VAR:-fallback}", "fallback"},
+		{"empty variable keeps empty without colon", "${TEST_EMPTY_VAR-fallback}", ""},
+		{"unset variable uses default without colon", "${TEST_UNSET_VAR-fallback}", "fallback"},
+		{"default inside path", "/opt/${TEST_UNSET_VAR:-bin}/server", "/opt/bin/server"},
+		{"required and set", "${TEST_SET_VAR:?must be set}", "from-env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnvVar(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExpandEnvVarsRequiredMissing(t *testing.T) {
+	os.Unsetenv("TEST_REQUIRED_TOKEN")
+
+	yamlData := `
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    env:
+      TOKEN: "${TEST_REQUIRED_TOKEN:?set TEST_REQUIRED_TOKEN to your API token}"
+`
+
+	_, err := LoadConfigFromString(yamlData)
+	if err == nil {
+		t.Fatal("expected error for required variable")
+	}
+	if !containsString(err.Error(), "TEST_REQUIRED_TOKEN") {
+		t.Errorf("expected error to name the variable, got '%s'", err.Error())
+	}
+	if !containsString(err.Error(), "set TEST_REQUIRED_TOKEN to your API token") {
+		t.Errorf("expected error to include the message, got '%s'", err.Error())
+	}
+}
+
+func TestExpandEnvVarsEmptyCommand(t *testing.T) {
+	os.Unsetenv("TEST_MISSING_BINARY")
+
+	yamlData := `
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "${TEST_MISSING_BINARY}"
+`
+
+	_, err := LoadConfigFromString(yamlData)
+	if err == nil {
+		t.Fatal("expected error for command that expanded to empty")
+	}
+	if !containsString(err.Error(), "${TEST_MISSING_BINARY}") {
+		t.Errorf("expected error to name the original template, got '%s'", err.Error())
+	}
+	if !containsString(err.Error(), "server test") {
+		t.Errorf("expected error to name the server, got '%s'", err.Error())
+	}
+}
+
+func TestExpandLazyVars(t *testing.T) {
+	t.Setenv("TEST_LOAD_VAR", "load")
+	t.Setenv("TEST_TOKEN", "first")
+
+	cfg, err := LoadConfigFromString(`
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    args: ["--host=${TEST_LOAD_VAR}", "--token=${{TEST_TOKEN}}"]
+    env:
+      TOKEN: "${{TEST_TOKEN:-none}}"
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Load time expands ${VAR} but leaves ${{VAR}} for connect time
+	server := cfg.Servers[0]
+	if server.Args[0] != "--host=load" {
+		t.Errorf("expected load-time expansion, got %q", server.Args[0])
+	}
+	if server.Args[1] != "--token=${{TEST_TOKEN}}" || server.Env["TOKEN"] != "${{TEST_TOKEN:-none}}" {
+		t.Fatalf("expected connect-time templates to be preserved, got %v %v", server.Args, server.Env)
+	}
+
+	// Connect time sees the current value
+	t.Setenv("TEST_TOKEN", "rotated")
+	got, err := ExpandLazyVars(server.Args[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "--token=rotated" {
+		t.Errorf("expected '--token=rotated', got %q", got)
+	}
+
+	os.Unsetenv("TEST_TOKEN")
+	if got, _ := ExpandLazyVars(server.Env["TOKEN"]); got != "none" {
+		t.Errorf("expected default 'none', got %q", got)
+	}
+	if _, err := ExpandLazyVars("${{TEST_TOKEN:?rotate first}}"); err == nil {
+		t.Error("expected error for required unset variable")
+	}
+	if _, err := expandEnvVar("--token=${{TEST_TOKEN"); err == nil {
+		t.Error("expected error for unterminated template")
+	}
+}
+
+func TestGetServerTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      string
+		proxyDefault string
+		expected     string
+	}{
+		{"default timeout", "", "", "30s"},
+		{"custom timeout", "60s", "", "1m0s"},
+		{"invalid timeout", "invalid", "", "30s"},
+		{"proxy default", "", "45s", "45s"},
+		{"server override", "60s", "45s", "1m0s"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := ServerConfig{Timeout: tt.timeout}
-			duration := server.GetServerTimeout()
+			duration := server.GetServerTimeout(tt.proxyDefault)
+			if duration.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, duration.String())
+			}
+		})
+	}
+}
+
+func TestGetConnectionTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		server       string
+		proxyDefault string
+		expected     string
+	}{
+		{"default", "", "", "10s"},
+		{"proxy setting", "", "5s", "5s"},
+		{"server override", "1m", "5s", "1m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The tool call timeout must not affect the connection timeout
+			server := ServerConfig{ConnectionTimeout: tt.server, Timeout: "300s"}
+			duration := server.GetConnectionTimeout(tt.proxyDefault)
 			if duration.String() != tt.expected {
 				t.Errorf("expected %s, got %s", tt.expected, duration.String())
 			}
@@ -300,12 +885,288 @@ func TestGetProxySettings(t *testing.T) {
 	if settings.ConnectionTimeout != "10s" {
 		t.Errorf("expected default connectionTimeout '10s', got '%s'", settings.ConnectionTimeout)
 	}
+	if settings.DefaultTimeout != "30s" {
+		t.Errorf("expected default defaultTimeout '30s', got '%s'", settings.DefaultTimeout)
+	}
 
-	if settings.MaxRetries != 3 {
-		t.Errorf("expected default maxRetries 3, got %d", settings.MaxRetries)
+	if settings.MaxRetries == nil || *settings.MaxRetries != 3 {
+		t.Errorf("expected default maxRetries 3, got %v", settings.MaxRetries)
+	}
+
+	if settings.OnNoTools != NoToolsStart {
+		t.Errorf("expected default onNoTools 'start', got '%s'", settings.OnNoTools)
+	}
+	for _, allowEmpty := range []bool{true, false} {
+		cfg := &ProxyConfig{Proxy: ProxySettings{AllowEmpty: &allowEmpty}}
+		want := NoToolsExit
+		if allowEmpty {
+			want = NoToolsStart
+		}
+		if got := cfg.GetProxySettings().OnNoTools; got != want {
+			t.Errorf("allowEmpty %v: expected onNoTools '%s', got '%s'", allowEmpty, want, got)
+		}
+	}
+}
+
+func TestLoadConfigNoPrefix(t *testing.T) {
+	cfg, err := LoadConfigFromString(`
+servers:
+  - name: "primary"
+    noPrefix: true
+    transport: "stdio"
+    command: "/usr/bin/primary"
+  - name: "helper"
+    prefix: "helper"
+    transport: "stdio"
+    command: "/usr/bin/helper"
+  - name: "secondary"
+    noPrefix: true
+    transport: "stdio"
+    command: "/usr/bin/secondary"
+    defaultArguments:
+      search:
+        limit: 10
+`)
+	if err != nil {
+		t.Fatalf("expected noPrefix servers without overlapping tools to load, got %v", err)
+	}
+	if prefix := cfg.Servers[0].ToolPrefix(); prefix != "" {
+		t.Errorf("expected empty tool prefix for noPrefix server, got %q", prefix)
+	}
+	if prefix := cfg.Servers[1].ToolPrefix(); prefix != "helper" {
+		t.Errorf("expected tool prefix 'helper', got %q", prefix)
+	}
+}
+
+func TestLoadConfigDefaultArguments(t *testing.T) {
+	cfg, err := LoadConfigFromString(`
+servers:
+  - name: "tracker"
+    prefix: "tracker"
+    transport: "stdio"
+    command: "tracker-server"
+    defaultArguments:
+      list_issues:
+        project: "web"
+        limit: 20
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := cfg.Servers[0].DefaultArguments["list_issues"]
+	if args["project"] != "web" || args["limit"] != 20 {
+		t.Errorf("unexpected default arguments %v", args)
+	}
+}
+
+func TestResolveMaxRetries(t *testing.T) {
+	cfg, err := LoadConfigFromString(`
+servers:
+  - name: "flaky"
+    prefix: "flaky"
+    transport: "stdio"
+    command: "/usr/bin/flaky"
+    maxRetries: 8
+  - name: "strict"
+    prefix: "strict"
+    transport: "stdio"
+    command: "/usr/bin/strict"
+    maxRetries: 0
+  - name: "default"
+    prefix: "default"
+    transport: "stdio"
+    command: "/usr/bin/default"
+proxy:
+  maxRetries: 2
+`)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	proxyDefault := *cfg.GetProxySettings().MaxRetries
+	expected := map[string]int{"flaky": 8, "strict": 0, "default": 2}
+	for _, server := range cfg.Servers {
+		if got := server.ResolveMaxRetries(proxyDefault); got != expected[server.Name] {
+			t.Errorf("server %s: expected %d retries, got %d", server.Name, expected[server.Name], got)
+		}
+	}
+}
+
+func TestProxyMaxRetriesZeroKept(t *testing.T) {
+	cfg, err := LoadConfigFromString(`
+servers:
+  - name: "strict"
+    prefix: "strict"
+    transport: "stdio"
+    command: "/usr/bin/strict"
+proxy:
+  maxRetries: 0
+`)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	if retries := *cfg.GetProxySettings().MaxRetries; retries != 0 {
+		t.Errorf("expected an explicit maxRetries 0 to fail fast, got %d retries", retries)
 	}
 }
 
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsString(s[1:], substr) || s[:len(substr)] == substr)
 }
+
+func TestManagementToolEnabled(t *testing.T) {
+	all := ManagementSettings{}
+	readOnly := ManagementSettings{ReadOnly: true}
+	listed := ManagementSettings{EnabledTools: []string{"server_list", "record_start"}}
+
+	tests := []struct {
+		tool                  string
+		all, readOnly, listed bool
+	}{
+		{"server_add", true, false, false},
+		{"server_list", true, true, true},
+		{"proxy_info", true, true, false},
+		{"record_start", true, false, true},
+	}
+	for _, tt := range tests {
+		if got := all.ToolEnabled(tt.tool); got != tt.all {
+			t.Errorf("default: ToolEnabled(%s) = %v, want %v", tt.tool, got, tt.all)
+		}
+		if got := readOnly.ToolEnabled(tt.tool); got != tt.readOnly {
+			t.Errorf("readOnly: ToolEnabled(%s) = %v, want %v", tt.tool, got, tt.readOnly)
+		}
+		if got := listed.ToolEnabled(tt.tool); got != tt.listed {
+			t.Errorf("enabledTools: ToolEnabled(%s) = %v, want %v", tt.tool, got, tt.listed)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0},
+		{"1048576", 1048576},
+		{"512MB", 512 << 20},
+		{"512m", 512 << 20},
+		{"1GiB", 1 << 30},
+		{"64 KB", 64 << 10},
+		{"100B", 100},
+		{"17179869183GB", 17179869183 << 30},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"lots", "-1MB", "1.5GB", "MB", "17179869184GB", "18446744073709551616"} {
+		if _, err := ParseByteSize(input); err == nil {
+			t.Errorf("ParseByteSize(%q): expected error", input)
+		}
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "config init") {
+		t.Errorf("expected a not-exist error suggesting config init, got %v", err)
+	}
+
+	_, err = LoadConfig(dir)
+	if err == nil || !strings.Contains(err.Error(), "has no *.yaml or *.yml files") {
+		t.Errorf("expected an empty config directory error, got %v", err)
+	}
+	if _, err := LoadRawConfig(dir); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("expected a directory error from LoadRawConfig, got %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+	unreadable := filepath.Join(dir, "unreadable.yaml")
+	if err := os.WriteFile(unreadable, []byte("servers: []\n"), 0o000); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(unreadable)
+	if !errors.Is(err, fs.ErrPermission) || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected a permission error, got %v", err)
+	}
+}
+
+func TestSaveConfigPreservesComments(t *testing.T) {
+	original := `# Team proxy config
+servers:
+  # Main filesystem server
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "npx"
+    args: &fsargs ["-y", "@modelcontextprotocol/filesystem"]
+    description: "Shared drive" # ask platform first
+    tags: ["owner:platform"]
+    env:
+      LOG_LEVEL: "info" # keep quiet
+  - name: "fs2"
+    prefix: "fs2"
+    transport: "stdio"
+    command: "npx"
+    args: *fsargs
+
+proxy:
+  maxRetries: 3 # retries before giving up
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadRawConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetValue("proxy.maxRetries", "5"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Servers = append(cfg.Servers, ServerConfig{Name: "math", Prefix: "math", Transport: "stdio", Command: "math-server"})
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	for _, want := range []string{
+		"# Team proxy config",
+		"# Main filesystem server",
+		"# keep quiet",
+		"maxRetries: 5 # retries before giving up",
+		"&fsargs",
+		"*fsargs",
+		"name: math",
+		`description: "Shared drive" # ask platform first`,
+	} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("expected %q in saved config:\n%s", want, saved)
+		}
+	}
+	// Untouched zero-valued fields must not be written out
+	if strings.Contains(saved, "healthCheckInterval") {
+		t.Errorf("unexpected unrelated keys in saved config:\n%s", saved)
+	}
+
+	reloaded, err := LoadRawConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Proxy.MaxRetries == nil || *reloaded.Proxy.MaxRetries != 5 || len(reloaded.Servers) != 3 || len(reloaded.Servers[1].Args) != 2 || reloaded.Servers[0].Tags[0] != "owner:platform" {
+		t.Errorf("unexpected reloaded config: %+v", reloaded)
+	}
+}
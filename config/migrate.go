@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MigrateConfig upgrades an old-style configuration (servers with ad-hoc env
+// only) to the inherit schema by adding an explicit proxy-level inherit block
+// set to tier1, the implicit default. Everything else is left untouched.
+//
+// Returns the migrated YAML and whether anything changed. Configs that already
+// declare a proxy-level inherit block are returned unchanged, so running the
+// migration twice is a no-op.
+func MigrateConfig(data []byte) ([]byte, bool, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, false, fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+
+	// Empty documents are treated as an empty mapping
+	if doc.Kind == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, false, fmt.Errorf("config root must be a mapping")
+	}
+	root := doc.Content[0]
+
+	// Already migrated: proxy-level inherit block present
+	if mappingValue(root, "inherit") != nil {
+		return data, false, nil
+	}
+
+	inherit := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "mode"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(InheritTier1)},
+		},
+	}
+	key := &yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Tag:         "!!str",
+		Value:       "inherit",
+		HeadComment: "Environment inheritance defaults (added by config migrate)",
+	}
+	root.Content = append(root.Content, key, inherit)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, false, fmt.Errorf("failed to encode migrated config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, false, fmt.Errorf("failed to encode migrated config: %w", err)
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
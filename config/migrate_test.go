@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrateConfigAddsInherit(t *testing.T) {
+	yamlData := `# My servers
+servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+    env:
+      API_KEY: "value" # keep me
+proxy:
+  maxRetries: 3
+`
+
+	migrated, changed, err := MigrateConfig([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected config to change")
+	}
+
+	cfg, err := LoadConfigFromString(string(migrated))
+	if err != nil {
+		t.Fatalf("migrated config failed to load: %v", err)
+	}
+	if cfg.Inherit == nil || cfg.Inherit.Mode != InheritTier1 {
+		t.Errorf("expected proxy-level inherit mode tier1, got %+v", cfg.Inherit)
+	}
+	if cfg.Servers[0].Env["API_KEY"] != "value" {
+		t.Errorf("expected env to be preserved, got %v", cfg.Servers[0].Env)
+	}
+	if cfg.Proxy.MaxRetries == nil || *cfg.Proxy.MaxRetries != 3 {
+		t.Errorf("expected maxRetries to be preserved, got %v", cfg.Proxy.MaxRetries)
+	}
+	if !strings.Contains(string(migrated), "# keep me") {
+		t.Error("expected comments to be preserved")
+	}
+}
+
+func TestMigrateConfigIdempotent(t *testing.T) {
+	yamlData := `servers:
+  - name: "test"
+    prefix: "test"
+    transport: "stdio"
+    command: "/usr/bin/test"
+`
+
+	first, changed, err := MigrateConfig([]byte(yamlData))
+	if err != nil || !changed {
+		t.Fatalf("expected first migration to change config (err=%v)", err)
+	}
+
+	second, changed, err := MigrateConfig(first)
+	if err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+	if changed {
+		t.Error("expected second migration to be a no-op")
+	}
+	if string(second) != string(first) {
+		t.Error("expected second migration output to be identical")
+	}
+}
+
+func TestMigrateConfigKeepsExistingInherit(t *testing.T) {
+	yamlData := `inherit:
+  mode: all
+servers: []
+`
+
+	migrated, changed, err := MigrateConfig([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if changed {
+		t.Error("expected config with inherit block to be unchanged")
+	}
+	if string(migrated) != yamlData {
+		t.Error("expected output to match input")
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// packageRunners maps the scheme of a package spec to the command line that
+// runs the package. The package name is appended to it.
+var packageRunners = map[string][]string{
+	"npm":  {"npx", "-y"},
+	"pypi": {"uvx"},
+	"uvx":  {"uvx"},
+}
+
+// ExpandPackageSpec expands a command given as a package spec, such as
+// "npm:@scope/pkg" or "pypi:mcp-server-git", into the command line that
+// runs the package. args are appended after the package name. Any other
+// command is returned unchanged.
+func ExpandPackageSpec(command string, args []string) (string, []string, error) {
+	scheme, pkg, ok := strings.Cut(command, ":")
+	runner, known := packageRunners[scheme]
+	if !ok || !known {
+		return command, args, nil
+	}
+	if pkg == "" {
+		return "", nil, fmt.Errorf("package spec %q names no package", command)
+	}
+	if strings.HasPrefix(pkg, "-") || strings.ContainsAny(pkg, " \t\n") {
+		return "", nil, fmt.Errorf("package spec %q has an invalid package name", command)
+	}
+
+	expanded := append(append([]string{}, runner[1:]...), pkg)
+	return runner[0], append(expanded, args...), nil
+}
+
+// expandPackageSpec expands the server's command when it is a package spec
+func (server *ServerConfig) expandPackageSpec() error {
+	command, args, err := ExpandPackageSpec(server.Command, server.Args)
+	if err != nil {
+		return err
+	}
+	server.Command, server.Args = command, args
+	return nil
+}
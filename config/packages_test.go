@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandPackageSpec(t *testing.T) {
+	tests := []struct {
+		command     string
+		args        []string
+		wantCommand string
+		wantArgs    []string
+		wantErr     bool
+	}{
+		{"npm:@scope/pkg", nil, "npx", []string{"-y", "@scope/pkg"}, false},
+		{"npm:pkg@1.2.0", []string{"/data"}, "npx", []string{"-y", "pkg@1.2.0", "/data"}, false},
+		{"pypi:mcp-server-git", nil, "uvx", []string{"mcp-server-git"}, false},
+		{"uvx:mcp-server-time", []string{"--local-timezone", "UTC"}, "uvx", []string{"mcp-server-time", "--local-timezone", "UTC"}, false},
+		{"/usr/bin/server", []string{"--stdio"}, "/usr/bin/server", []string{"--stdio"}, false},
+		{`C:\tools\server.exe`, nil, `C:\tools\server.exe`, nil, false},
+		{"npm:", nil, "", nil, true},
+		{"npm:--registry=evil", nil, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		command, args, err := ExpandPackageSpec(tt.command, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.command)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.command, err)
+			continue
+		}
+		if command != tt.wantCommand || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.command, tt.wantCommand, tt.wantArgs, command, args)
+		}
+	}
+}
+
+func TestLoadConfigPackageSpec(t *testing.T) {
+	yamlData := `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "npm:@modelcontextprotocol/server-filesystem"
+    args: ["/tmp"]
+`
+
+	cfg, err := LoadConfigFromString(yamlData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := cfg.Servers[0]
+	if server.Command != "npx" {
+		t.Errorf("expected command 'npx', got '%s'", server.Command)
+	}
+	want := []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"}
+	if !reflect.DeepEqual(server.Args, want) {
+		t.Errorf("expected args %v, got %v", want, server.Args)
+	}
+
+	if _, err := LoadConfigFromString(`
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "npm:"
+`); err == nil || !containsString(err.Error(), "server fs") {
+		t.Errorf("expected an error naming the server, got %v", err)
+	}
+}
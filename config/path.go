@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GetValue returns the value at a dotted key path such as "proxy.maxRetries"
+// or "servers.0.command". Path segments match YAML field names; numeric
+// segments index into lists.
+func (c *ProxyConfig) GetValue(key string) (interface{}, error) {
+	target, err := resolvePath(reflect.ValueOf(c).Elem(), key, false)
+	if err != nil {
+		return nil, err
+	}
+	return target.value.Interface(), nil
+}
+
+// SetValue sets the value at a dotted key path. The value is parsed as YAML
+// for non-string fields, so lists can be given as "[a, b]" and numbers or
+// booleans as plain literals. Missing nested blocks are created as needed.
+func (c *ProxyConfig) SetValue(key, value string) error {
+	target, err := resolvePath(reflect.ValueOf(c).Elem(), key, true)
+	if err != nil {
+		return err
+	}
+
+	parsed := reflect.New(target.value.Type()).Elem()
+	if parsed.Kind() == reflect.String {
+		parsed.SetString(value)
+	} else if err := yaml.Unmarshal([]byte(value), parsed.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid value %q for %s (%s): %w", value, key, target.value.Type(), err)
+	}
+
+	// Map entries are not addressable: set through the parent map
+	if target.mapParent.IsValid() {
+		target.mapParent.SetMapIndex(target.mapKey, parsed)
+		return nil
+	}
+	target.value.Set(parsed)
+	return nil
+}
+
+// pathTarget is the result of resolving a key path. Map entries carry their
+// parent map and key since they can only be written through the map.
+type pathTarget struct {
+	value     reflect.Value
+	mapParent reflect.Value
+	mapKey    reflect.Value
+}
+
+// resolvePath walks a dotted key path from root. When create is true, nil
+// pointers and maps along the way are allocated so the target can be set.
+func resolvePath(root reflect.Value, key string, create bool) (pathTarget, error) {
+	if key == "" {
+		return pathTarget{}, fmt.Errorf("key is required")
+	}
+
+	current := root
+	segments := strings.Split(key, ".")
+	for i, segment := range segments {
+		// Dereference pointers, allocating when setting
+		if current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				if !create {
+					return pathTarget{}, fmt.Errorf("key %q is not set", strings.Join(segments[:i], "."))
+				}
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+
+		path := strings.Join(segments[:i+1], ".")
+
+		switch current.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByYAMLName(current, segment)
+			if !ok {
+				return pathTarget{}, fmt.Errorf("unknown key %q", path)
+			}
+			current = field
+		case reflect.Slice:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return pathTarget{}, fmt.Errorf("key %q: %q is not a list index", path, segment)
+			}
+			if index < 0 || index >= current.Len() {
+				return pathTarget{}, fmt.Errorf("key %q: index %d out of range (length %d)", path, index, current.Len())
+			}
+			current = current.Index(index)
+		case reflect.Map:
+			if current.IsNil() {
+				if !create {
+					return pathTarget{}, fmt.Errorf("key %q is not set", path)
+				}
+				current.Set(reflect.MakeMap(current.Type()))
+			}
+			mapKey := reflect.ValueOf(segment)
+			entry := current.MapIndex(mapKey)
+			if i == len(segments)-1 && create {
+				value := reflect.New(current.Type().Elem()).Elem()
+				if entry.IsValid() {
+					value.Set(entry)
+				}
+				return pathTarget{value: value, mapParent: current, mapKey: mapKey}, nil
+			}
+			if !entry.IsValid() {
+				return pathTarget{}, fmt.Errorf("key %q is not set", path)
+			}
+			current = entry
+		default:
+			return pathTarget{}, fmt.Errorf("key %q: %s has no nested keys", path, strings.Join(segments[:i], "."))
+		}
+	}
+
+	return pathTarget{value: current}, nil
+}
+
+// fieldByYAMLName finds the struct field whose yaml tag name matches name
+func fieldByYAMLName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
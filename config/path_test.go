@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestGetSetValue(t *testing.T) {
+	cfg := &ProxyConfig{
+		Servers: []ServerConfig{
+			{Name: "test", Prefix: "test", Transport: "stdio", Command: "/usr/bin/test"},
+		},
+	}
+
+	if err := cfg.SetValue("proxy.maxRetries", "5"); err != nil {
+		t.Fatalf("set maxRetries: %v", err)
+	}
+	if cfg.Proxy.MaxRetries == nil || *cfg.Proxy.MaxRetries != 5 {
+		t.Errorf("expected maxRetries 5, got %v", cfg.Proxy.MaxRetries)
+	}
+
+	if err := cfg.SetValue("servers.0.command", "/usr/bin/other"); err != nil {
+		t.Fatalf("set command: %v", err)
+	}
+	value, err := cfg.GetValue("servers.0.command")
+	if err != nil {
+		t.Fatalf("get command: %v", err)
+	}
+	if value != "/usr/bin/other" {
+		t.Errorf("expected '/usr/bin/other', got %v", value)
+	}
+
+	if err := cfg.SetValue("servers.0.args", "[--verbose, --port, 8080]"); err != nil {
+		t.Fatalf("set args: %v", err)
+	}
+	if len(cfg.Servers[0].Args) != 3 || cfg.Servers[0].Args[2] != "8080" {
+		t.Errorf("expected 3 args, got %v", cfg.Servers[0].Args)
+	}
+
+	if err := cfg.SetValue("servers.0.env.DEBUG", "1"); err != nil {
+		t.Fatalf("set env: %v", err)
+	}
+	if cfg.Servers[0].Env["DEBUG"] != "1" {
+		t.Errorf("expected env DEBUG=1, got %v", cfg.Servers[0].Env)
+	}
+
+	if err := cfg.SetValue("servers.0.inherit.mode", "all"); err != nil {
+		t.Fatalf("set inherit mode: %v", err)
+	}
+	if cfg.Servers[0].Inherit == nil || cfg.Servers[0].Inherit.Mode != InheritAll {
+		t.Errorf("expected inherit mode 'all', got %+v", cfg.Servers[0].Inherit)
+	}
+}
+
+func TestGetSetValueErrors(t *testing.T) {
+	cfg := &ProxyConfig{
+		Servers: []ServerConfig{{Name: "test"}},
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		errMatch string
+	}{
+		{"unknown key", "proxy.maxRetry", "1", "unknown key"},
+		{"index out of range", "servers.3.command", "x", "out of range"},
+		{"non-numeric index", "servers.first.command", "x", "not a list index"},
+		{"wrong type", "proxy.maxRetries", "many", "invalid value"},
+		{"nested scalar", "proxy.maxRetries.value", "1", "has no nested keys"},
+		{"empty key", "", "x", "key is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfg.SetValue(tt.key, tt.value)
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if !containsString(err.Error(), tt.errMatch) {
+				t.Errorf("expected error containing '%s', got '%s'", tt.errMatch, err.Error())
+			}
+		})
+	}
+
+	if _, err := cfg.GetValue("inherit.mode"); err == nil {
+		t.Error("expected error getting unset inherit block")
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// rewriteConfig encodes config as an edit of the YAML document in original.
+// Only keys whose values changed since original was written are touched, so
+// comments, anchors and key order elsewhere survive the round trip. When the
+// original can't be edited faithfully the config is marshaled from scratch.
+func rewriteConfig(original []byte, config *ProxyConfig) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(original, &doc); err != nil || len(doc.Content) == 0 {
+		return yaml.Marshal(config)
+	}
+
+	var before ProxyConfig
+	if err := doc.Decode(&before); err != nil {
+		return yaml.Marshal(config)
+	}
+
+	var oldNode, newNode yaml.Node
+	if err := oldNode.Encode(&before); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := newNode.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	applyNodeChanges(doc.Content[0], &oldNode, &newNode)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	// An edit can change more than intended when the changed value was
+	// shared through an anchor; fall back rather than write the wrong config
+	var after ProxyConfig
+	if err := yaml.Unmarshal(buf.Bytes(), &after); err != nil || !sameValue(&after, config) {
+		return yaml.Marshal(config)
+	}
+	return buf.Bytes(), nil
+}
+
+// applyNodeChanges edits target, a node of the original document, with the
+// differences between oldNode and newNode, the encodings of the config as it
+// was read and as it is to be written
+func applyNodeChanges(target, oldNode, newNode *yaml.Node) {
+	if sameNode(oldNode, newNode) {
+		return
+	}
+
+	switch {
+	case target.Kind == yaml.MappingNode && oldNode.Kind == yaml.MappingNode && newNode.Kind == yaml.MappingNode:
+		applyMappingChanges(target, oldNode, newNode)
+	case target.Kind == yaml.SequenceNode && oldNode.Kind == yaml.SequenceNode && newNode.Kind == yaml.SequenceNode &&
+		len(target.Content) == len(oldNode.Content):
+		for i := 0; i < len(newNode.Content) && i < len(target.Content); i++ {
+			applyNodeChanges(target.Content[i], oldNode.Content[i], newNode.Content[i])
+		}
+		if len(newNode.Content) < len(target.Content) {
+			target.Content = target.Content[:len(newNode.Content)]
+		} else {
+			target.Content = append(target.Content, newNode.Content[len(target.Content):]...)
+		}
+	default:
+		replaceNode(target, newNode)
+	}
+}
+
+// applyMappingChanges updates, adds and removes the keys that differ
+func applyMappingChanges(target, oldNode, newNode *yaml.Node) {
+	for i := 0; i+1 < len(newNode.Content); i += 2 {
+		key, value := newNode.Content[i], newNode.Content[i+1]
+		oldValue := mappingValue(oldNode, key.Value)
+		if oldValue != nil && sameNode(oldValue, value) {
+			continue
+		}
+
+		targetValue := mappingValue(target, key.Value)
+		switch {
+		case targetValue == nil:
+			target.Content = append(target.Content, key, value)
+		case oldValue == nil:
+			replaceNode(targetValue, value)
+		default:
+			applyNodeChanges(targetValue, oldValue, value)
+		}
+	}
+
+	for i := 0; i+1 < len(oldNode.Content); i += 2 {
+		key := oldNode.Content[i].Value
+		if mappingValue(newNode, key) == nil {
+			removeMappingKey(target, key)
+		}
+	}
+}
+
+// replaceNode overwrites target with value, keeping target's comments
+func replaceNode(target, value *yaml.Node) {
+	head, line, foot := target.HeadComment, target.LineComment, target.FootComment
+	*target = *value
+	target.HeadComment, target.LineComment, target.FootComment = head, line, foot
+}
+
+// removeMappingKey deletes key and its value from a mapping
+func removeMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// sameNode reports whether two nodes decode to the same value
+func sameNode(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// sameValue reports whether two configs encode to the same YAML
+func sameValue(a, b *ProxyConfig) bool {
+	ad, aerr := yaml.Marshal(a)
+	bd, berr := yaml.Marshal(b)
+	return aerr == nil && berr == nil && bytes.Equal(ad, bd)
+}
+
+// readOriginalConfig returns the current contents of path, or nil if it
+// can't be read
+func readOriginalConfig(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaError reports a config value that doesn't fit the config's shape:
+// an unknown field, a value of the wrong type or an unknown enum value.
+// Path locates the value, e.g. "servers[1].inherit.mode".
+type SchemaError struct {
+	Path    string
+	Line    int
+	Message string
+	Unknown bool // The field is not part of the config; see proxy.unknownFields
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+}
+
+// enumValues lists the allowed values of the config's enum types
+var enumValues = map[reflect.Type][]string{
+	reflect.TypeOf(InheritMode("")):          {string(InheritNone), string(InheritTier1), string(InheritTier1Tier2), string(InheritAll)},
+	reflect.TypeOf(RateLimitAction("")):      {string(RateLimitWait), string(RateLimitReject)},
+	reflect.TypeOf(SanitizerAction("")):      {string(SanitizerBlock), string(SanitizerWarn)},
+	reflect.TypeOf(TransformOp("")):          {string(TransformTrim), string(TransformExtract), string(TransformTruncate)},
+	reflect.TypeOf(WarmupPolicy("")):         {string(WarmupFail), string(WarmupContinue)},
+	reflect.TypeOf(DuplicateToolPolicy("")):  {string(DuplicateToolsAllow), string(DuplicateToolsError), string(DuplicateToolsFirstWins), string(DuplicateToolsRename)},
+	reflect.TypeOf(NoToolsPolicy("")):        {string(NoToolsStart), string(NoToolsExit)},
+	reflect.TypeOf(ResultContentMode("")):    {string(ResultContentPassthrough), string(ResultContentText)},
+	reflect.TypeOf(RecordMetadataFormat("")): {string(RecordMetadataText), string(RecordMetadataMeta), string(RecordMetadataNone)},
+	reflect.TypeOf(ProtocolPolicy("")):       {string(ProtocolMismatchError), string(ProtocolMismatchWarn)},
+	reflect.TypeOf(UnknownFieldPolicy("")):   {string(UnknownFieldsError), string(UnknownFieldsWarn)},
+	reflect.TypeOf(ToolNameMode("")):         {string(ToolNamesSanitize), string(ToolNamesKeep)},
+	reflect.TypeOf(LogLevel("")):             {string(LogLevelDebug), string(LogLevelInfo), string(LogLevelWarn)},
+	reflect.TypeOf(LogFormat("")):            {string(LogFormatText), string(LogFormatJSON)},
+	reflect.TypeOf(CompositeMerge("")):       {string(CompositeMergeConcat), string(CompositeMergeJSON), string(CompositeMergeFirst)},
+}
+
+// checkSchema checks parsed YAML against the shape of ProxyConfig and
+// returns every problem found
+func checkSchema(data []byte) []*SchemaError {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		// Syntax errors are reported by the decoder
+		return nil
+	}
+
+	var problems []*SchemaError
+	checkNode(doc.Content[0], reflect.TypeOf(ProxyConfig{}), "", &problems)
+	return problems
+}
+
+// checkNode checks node against t, appending problems found at path
+func checkNode(node *yaml.Node, t reflect.Type, path string, problems *[]*SchemaError) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.ShortTag() == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	report := func(format string, args ...interface{}) {
+		at := path
+		if at == "" {
+			at = "(root)"
+		}
+		*problems = append(*problems, &SchemaError{Path: at, Line: node.Line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			report("expected a mapping, got %s", describeNode(node))
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				// Merge keys bring in fields of the same struct
+				checkNode(value, t, path, problems)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown field %q", key.Value)
+				if suggestion := closestName(key.Value, fields); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				*problems = append(*problems, &SchemaError{Path: joinPath(path, key.Value), Line: key.Line, Message: message, Unknown: true})
+				continue
+			}
+			checkNode(value, field, joinPath(path, key.Value), problems)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			report("expected a mapping, got %s", describeNode(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkNode(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), problems)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			report("expected a list, got %s", describeNode(node))
+			return
+		}
+		for i, item := range node.Content {
+			checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			report("expected a string, got %s", describeNode(node))
+			return
+		}
+		if allowed, ok := enumValues[t]; ok && !slices.Contains(allowed, node.Value) {
+			report("invalid %s %q: must be one of: %s", lastSegment(path), node.Value, strings.Join(allowed, ", "))
+		}
+	case reflect.Bool:
+		if node.ShortTag() != "!!bool" {
+			report("expected true or false, got %s", describeNode(node))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.ShortTag() != "!!int" {
+			report("expected a whole number, got %s", describeNode(node))
+		}
+	case reflect.Float32, reflect.Float64:
+		if tag := node.ShortTag(); tag != "!!int" && tag != "!!float" {
+			report("expected a number, got %s", describeNode(node))
+		}
+	}
+}
+
+// yamlFields maps the YAML names of a struct's fields to their types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// describeNode names the kind of a YAML value for error messages
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return fmt.Sprintf("the number %s", node.Value)
+	case "!!bool":
+		return fmt.Sprintf("the boolean %s", node.Value)
+	}
+	return fmt.Sprintf("the string %q", node.Value)
+}
+
+// closestName returns the field name within two edits of name, if any
+func closestName(name string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func lastSegment(path string) string {
+	if i := strings.LastIndexAny(path, ".]"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yamlData string
+		errMatch []string
+	}{
+		{
+			name: "unknown field",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transpot: "stdio"
+    command: "fs-server"
+`,
+			errMatch: []string{`line 5: servers[0].transpot: unknown field "transpot" (did you mean "transport"?)`},
+		},
+		{
+			name: "unknown top-level field",
+			yamlData: `
+servers: []
+telemetry: true
+`,
+			errMatch: []string{`telemetry: unknown field "telemetry"`},
+		},
+		{
+			name: "string instead of list",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    args: "--root /tmp"
+`,
+			errMatch: []string{`servers[0].args: expected a list, got the string "--root /tmp"`},
+		},
+		{
+			name: "string instead of number",
+			yamlData: `
+servers: []
+proxy:
+  maxRetries: "three"
+`,
+			errMatch: []string{`proxy.maxRetries: expected a whole number, got the string "three"`},
+		},
+		{
+			name: "string instead of tags",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    tags: "readonly"
+`,
+			errMatch: []string{`servers[0].tags: expected a list, got the string "readonly"`},
+		},
+		{
+			name: "list instead of mapping",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    env: ["DEBUG=1"]
+`,
+			errMatch: []string{"servers[0].env: expected a mapping, got a list"},
+		},
+		{
+			name: "nested enum",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+  - name: "db"
+    prefix: "db"
+    transport: "stdio"
+    command: "db-server"
+    inherit:
+      mode: "tier3"
+`,
+			errMatch: []string{`servers[1].inherit.mode: invalid mode "tier3": must be one of: none, tier1, tier1+tier2, all`},
+		},
+		{
+			name: "every problem is reported",
+			yamlData: `
+servers:
+  - name: "fs"
+    prefx: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    noPrefix: "yes"
+`,
+			errMatch: []string{
+				`servers[0].prefx: unknown field "prefx" (did you mean "prefix"?)`,
+				`servers[0].noPrefix: expected true or false, got the string "yes"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigFromString(tt.yamlData)
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			for _, match := range tt.errMatch {
+				if !strings.Contains(err.Error(), match) {
+					t.Errorf("expected error containing '%s', got '%s'", match, err.Error())
+				}
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Errorf("expected a SchemaError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSchemaAccepts(t *testing.T) {
+	// Anchors, merge keys and numbers given for strings
+	cfg, err := LoadConfigFromString(`
+servers:
+  - &fs
+    name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    args: ["--port", 8080]
+    env:
+      RETRIES: 3
+    inherit:
+      mode: "all"
+    rateLimit:
+      requestsPerSecond: 2
+  - <<: *fs
+    name: "fs2"
+    prefix: "fs2"
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Servers[1].Command != "fs-server" || cfg.Servers[0].Args[1] != "8080" || cfg.Servers[0].Inherit.Mode != InheritAll {
+		t.Errorf("unexpected config: %+v", cfg.Servers)
+	}
+}
+
+// misspelledConfig has several typos, each of which would otherwise leave
+// its setting at the default
+const misspelledConfig = `
+servers:
+  - name: "fs"
+    prefix: "fs"
+    transport: "stdio"
+    command: "fs-server"
+    timout: "90s"
+    maxRetires: 5
+proxy:
+  conectionTimeout: "30s"
+  unknownFields: %q
+`
+
+func TestUnknownFieldsPolicy(t *testing.T) {
+	typos := []string{
+		`servers[0].timout: unknown field "timout" (did you mean "timeout"?)`,
+		`servers[0].maxRetires: unknown field "maxRetires" (did you mean "maxRetries"?)`,
+		`proxy.conectionTimeout: unknown field "conectionTimeout" (did you mean "connectionTimeout"?)`,
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, err := LoadConfigFromString(fmt.Sprintf(misspelledConfig, "error"))
+		if err == nil {
+			t.Fatal("expected unknown fields to fail loading")
+		}
+		for _, typo := range typos {
+			if !strings.Contains(err.Error(), typo) {
+				t.Errorf("expected error containing '%s', got '%s'", typo, err.Error())
+			}
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		var logged bytes.Buffer
+		log.SetOutput(&logged)
+		defer log.SetOutput(os.Stderr)
+
+		cfg, err := LoadConfigFromString(fmt.Sprintf(misspelledConfig, "warn"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Servers[0].Timeout != "" || cfg.Proxy.ConnectionTimeout != "" {
+			t.Errorf("expected the misspelled fields to be ignored, got %+v", cfg)
+		}
+		for _, typo := range typos {
+			if !strings.Contains(logged.String(), typo) {
+				t.Errorf("expected a warning containing '%s', got:\n%s", typo, logged.String())
+			}
+		}
+	})
+
+	t.Run("warn keeps other errors", func(t *testing.T) {
+		_, err := LoadConfigFromString(`
+servers: []
+proxy:
+  unknownFields: "warn"
+  maxRetries: "many"
+`)
+		if err == nil || !strings.Contains(err.Error(), "proxy.maxRetries: expected a whole number") {
+			t.Errorf("expected the wrong type to fail loading, got %v", err)
+		}
+	})
+}
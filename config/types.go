@@ -2,7 +2,12 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,27 +29,425 @@ type InheritConfig struct {
 	Prefix                  []string    `yaml:"prefix,omitempty"`
 	Deny                    []string    `yaml:"deny,omitempty"`
 	AllowDeniedIfExplicit   bool        `yaml:"allow_denied_if_explicit,omitempty"`
+	Strict                  bool        `yaml:"strict,omitempty"` // Warn about a suspicious parent environment, e.g. duplicate keys
+	DenySecrets             bool        `yaml:"denySecrets,omitempty"` // Don't inherit variables named like secrets unless listed in extra
 }
 
 // ProxyConfig represents the main configuration for the proxy server
 type ProxyConfig struct {
-	Servers []ServerConfig `yaml:"servers"`
-	Proxy   ProxySettings  `yaml:"proxy"`
-	Inherit *InheritConfig `yaml:"inherit,omitempty"`  // NEW: proxy-level defaults
+	Servers        []ServerConfig  `yaml:"servers"`
+	Proxy          ProxySettings   `yaml:"proxy"`
+	Inherit        *InheritConfig  `yaml:"inherit,omitempty"`  // NEW: proxy-level defaults
+	Record         RecordSettings  `yaml:"record,omitempty"`
+	CompositeTools []CompositeTool `yaml:"compositeTools,omitempty"` // Tools that fan out to several backend tools
 }
 
 // ServerConfig represents configuration for a remote MCP server
 type ServerConfig struct {
-	Name      string            `yaml:"name"`
-	Prefix    string            `yaml:"prefix"`
-	Transport string            `yaml:"transport"`
-	Command   string            `yaml:"command,omitempty"`
-	Args      []string          `yaml:"args,omitempty"`
-	Env       map[string]string `yaml:"env,omitempty"`
-	Inherit   *InheritConfig    `yaml:"inherit,omitempty"`  // NEW: per-server inheritance
-	URL       string            `yaml:"url,omitempty"`
-	Auth      *AuthConfig       `yaml:"auth,omitempty"`
-	Timeout   string            `yaml:"timeout,omitempty"`
+	Name              string            `yaml:"name"`
+	Description       string            `yaml:"description,omitempty"` // What the server is for; shown by server_list and server_status, not used to connect
+	Tags              []string          `yaml:"tags,omitempty"`        // Labels such as an owner or team, shown with the description
+	Prefix            string            `yaml:"prefix,omitempty"`
+	NoPrefix          bool              `yaml:"noPrefix,omitempty"` // Expose tools under their original names
+	Transport         string            `yaml:"transport"`
+	Command           string            `yaml:"command,omitempty"`
+	Args              []string          `yaml:"args,omitempty"`
+	Env               map[string]string `yaml:"env,omitempty"`
+	Inherit           *InheritConfig    `yaml:"inherit,omitempty"`  // NEW: per-server inheritance
+	URL               string            `yaml:"url,omitempty"`
+	Auth              *AuthConfig       `yaml:"auth,omitempty"`
+	Timeout           string            `yaml:"timeout,omitempty"` // Tool call timeout
+	ConnectionTimeout string            `yaml:"connectionTimeout,omitempty"` // Overrides proxy.connectionTimeout
+	MaxRetries        *int              `yaml:"maxRetries,omitempty"` // Overrides proxy.maxRetries; 0 fails fast
+	MaxResultBytes    *int              `yaml:"maxResultBytes,omitempty"` // Overrides proxy.maxResultBytes; 0 = unlimited
+	Sanitizer         []SanitizerRule   `yaml:"sanitizer,omitempty"` // Argument checks applied before forwarding tool calls
+	Limits            *ResourceLimits   `yaml:"limits,omitempty"` // Resource limits for the stdio process (Linux only)
+	RateLimit         *RateLimitConfig  `yaml:"rateLimit,omitempty"` // Token bucket limits for tool calls
+	IdleTimeout       string            `yaml:"idleTimeout,omitempty"` // Disconnect after this long without tool calls; reconnect on next call
+	FlattenPrefix     bool              `yaml:"flattenPrefix,omitempty"` // Don't prefix tools already named with the prefix (nested proxies)
+	Required          bool              `yaml:"required,omitempty"` // Fail startup if this server doesn't connect
+	Warmup            []WarmupCall      `yaml:"warmup,omitempty"` // Tool calls made after each successful initialize
+	DependsOn         []string          `yaml:"dependsOn,omitempty"` // Servers that must connect before this one is started
+	Transforms        ToolTransforms    `yaml:"transforms,omitempty"` // Result transforms per tool, keyed by original (unprefixed) name
+	DefaultArguments  ToolArguments     `yaml:"defaultArguments,omitempty"` // Arguments merged into calls per tool, keyed by original name; the client's values win
+	HiddenArguments   ToolArgumentNames `yaml:"hiddenArguments,omitempty"` // Arguments left out of a tool's schema, keyed by original name; each needs a default
+	Transcript        *TranscriptConfig `yaml:"transcript,omitempty"` // Human-readable log of this server's tool calls
+
+	unexpanded *unexpandedValues // Args and URL as written, before ${VAR} expansion
+}
+
+// unexpandedValues keeps a server's args and URL as written in the config
+// together with what they expanded to, so Redacted can show the templates
+// instead of secrets taken from the environment, as long as they're still
+// the values the server runs with
+type unexpandedValues struct {
+	args, expandedArgs []string
+	url, expandedURL   string
+}
+
+// RateLimitAction is taken when a tool call exceeds its rate limit
+type RateLimitAction string
+
+const (
+	RateLimitWait   RateLimitAction = "wait"   // Delay the call until a token is available, within the call timeout
+	RateLimitReject RateLimitAction = "reject" // Fail the call with a rate limit error
+)
+
+// RateLimitConfig limits tool calls to a server with a token bucket per
+// tool. The server-level rate applies to each tool separately; Tools
+// overrides it for individual tools, keyed by their original (unprefixed)
+// name.
+type RateLimitConfig struct {
+	RequestsPerSecond float64                  `yaml:"requestsPerSecond,omitempty"` // 0 = unlimited
+	Burst             int                      `yaml:"burst,omitempty"`             // Calls allowed at once (default 1)
+	OnLimit           RateLimitAction          `yaml:"onLimit,omitempty"`           // wait (default) or reject
+	Tools             map[string]ToolRateLimit `yaml:"tools,omitempty"`
+}
+
+// ToolRateLimit overrides the server's rate limit for one tool
+type ToolRateLimit struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"` // 0 = unlimited
+	Burst             int     `yaml:"burst,omitempty"`
+}
+
+// Validate checks the rates, bursts and action
+func (r *RateLimitConfig) Validate() error {
+	if r.RequestsPerSecond < 0 || r.Burst < 0 {
+		return fmt.Errorf("requestsPerSecond and burst must not be negative")
+	}
+	switch r.OnLimit {
+	case "", RateLimitWait, RateLimitReject:
+	default:
+		return fmt.Errorf("invalid onLimit %q: must be one of: wait, reject", r.OnLimit)
+	}
+	for name, tool := range r.Tools {
+		if tool.RequestsPerSecond < 0 || tool.Burst < 0 {
+			return fmt.Errorf("tool %s: requestsPerSecond and burst must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ForTool returns the rate and burst for a tool. A rate of 0 means the tool
+// is not limited.
+func (r *RateLimitConfig) ForTool(name string) (float64, int) {
+	rate, burst := r.RequestsPerSecond, r.Burst
+	if tool, ok := r.Tools[name]; ok {
+		rate, burst = tool.RequestsPerSecond, tool.Burst
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate, burst
+}
+
+// Action returns the configured onLimit action, defaulting to wait
+func (r *RateLimitConfig) Action() RateLimitAction {
+	if r.OnLimit == "" {
+		return RateLimitWait
+	}
+	return r.OnLimit
+}
+
+// ResourceLimits caps the resources of a stdio server process. They are
+// applied as rlimits on Linux and ignored with a warning elsewhere.
+type ResourceLimits struct {
+	MaxMemory    string `yaml:"maxMemory,omitempty"`    // Address space, e.g. "512MB" (binary units)
+	MaxCPUTime   string `yaml:"maxCPUTime,omitempty"`   // CPU time, e.g. "60s"; the process is killed when exceeded
+	MaxOpenFiles uint64 `yaml:"maxOpenFiles,omitempty"` // Open file descriptors
+}
+
+// DefaultTranscriptMaxBytes bounds each logged value when a transcript
+// doesn't set maxBytes
+const DefaultTranscriptMaxBytes = 500
+
+// TranscriptConfig appends a human-readable entry for each of a server's
+// tool calls to a file: the arguments, with secret-looking ones redacted,
+// and a summary of the result
+type TranscriptConfig struct {
+	File     string `yaml:"file"`
+	MaxBytes int    `yaml:"maxBytes,omitempty"` // Longest arguments or result text logged per call (default 500)
+}
+
+// Validate checks that the transcript names a file
+func (t *TranscriptConfig) Validate() error {
+	if t.File == "" {
+		return fmt.Errorf("file is required")
+	}
+	if t.MaxBytes < 0 {
+		return fmt.Errorf("maxBytes must not be negative")
+	}
+	return nil
+}
+
+// GetMaxBytes returns the bound on each logged value
+func (t *TranscriptConfig) GetMaxBytes() int {
+	if t.MaxBytes == 0 {
+		return DefaultTranscriptMaxBytes
+	}
+	return t.MaxBytes
+}
+
+// Validate checks the limit formats
+func (l *ResourceLimits) Validate() error {
+	if _, err := ParseByteSize(l.MaxMemory); err != nil {
+		return fmt.Errorf("invalid maxMemory: %w", err)
+	}
+	if l.MaxCPUTime != "" {
+		d, err := time.ParseDuration(l.MaxCPUTime)
+		if err != nil {
+			return fmt.Errorf("invalid maxCPUTime: %w", err)
+		}
+		if d < time.Second {
+			return fmt.Errorf("invalid maxCPUTime: must be at least 1s")
+		}
+	}
+	return nil
+}
+
+// MemoryBytes returns the memory limit in bytes, or 0 when unset
+func (l *ResourceLimits) MemoryBytes() uint64 {
+	size, _ := ParseByteSize(l.MaxMemory)
+	return size
+}
+
+// CPUSeconds returns the CPU time limit in whole seconds, or 0 when unset
+func (l *ResourceLimits) CPUSeconds() uint64 {
+	d, err := time.ParseDuration(l.MaxCPUTime)
+	if err != nil {
+		return 0
+	}
+	return uint64(d / time.Second)
+}
+
+// ParseByteSize parses a size such as "512MB", "1GiB" or "1048576".
+// K, M and G suffixes are binary (1K = 1024 bytes). An empty string is 0.
+func ParseByteSize(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	upper := strings.ToUpper(value)
+	multiplier := uint64(1)
+	for _, unit := range []struct {
+		suffixes   []string
+		multiplier uint64
+	}{
+		{[]string{"GIB", "GB", "G"}, 1 << 30},
+		{[]string{"MIB", "MB", "M"}, 1 << 20},
+		{[]string{"KIB", "KB", "K"}, 1 << 10},
+		{[]string{"B"}, 1},
+	} {
+		found := false
+		for _, suffix := range unit.suffixes {
+			if strings.HasSuffix(upper, suffix) {
+				upper = strings.TrimSpace(strings.TrimSuffix(upper, suffix))
+				multiplier = unit.multiplier
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	n, err := strconv.ParseUint(upper, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	if n > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("invalid size %q: too large", value)
+	}
+	return n * multiplier, nil
+}
+
+// SanitizerAction is taken when a sanitizer rule matches a tool call argument
+type SanitizerAction string
+
+const (
+	SanitizerBlock SanitizerAction = "block" // Reject the call without forwarding it
+	SanitizerWarn  SanitizerAction = "warn"  // Log the match and forward the call
+)
+
+// TransformOp is a built-in tool result transform
+type TransformOp string
+
+const (
+	TransformTrim     TransformOp = "trim"     // Trim surrounding whitespace
+	TransformExtract  TransformOp = "extract"  // Parse the text as JSON and keep the value at path
+	TransformTruncate TransformOp = "truncate" // Keep the first max characters
+)
+
+// TransformStep is one step of a tool's result transform pipeline, applied
+// to each text item of successful results
+type TransformStep struct {
+	Op   TransformOp `yaml:"op"`
+	Path string      `yaml:"path,omitempty"` // extract: dotted path, list indexes as numbers, e.g. "items.0.name"
+	Max  int         `yaml:"max,omitempty"`  // truncate: characters to keep
+}
+
+// ToolTransforms maps original tool names to their transform pipelines
+type ToolTransforms map[string][]TransformStep
+
+// ToolArguments maps original tool names to arguments for them
+type ToolArguments map[string]map[string]interface{}
+
+// ToolArgumentNames maps original tool names to names of their arguments
+type ToolArgumentNames map[string][]string
+
+// Validate checks that the step has a known op and the fields it needs
+func (s *TransformStep) Validate() error {
+	switch s.Op {
+	case TransformTrim:
+	case TransformExtract:
+		if s.Path == "" {
+			return fmt.Errorf("extract needs a path")
+		}
+	case TransformTruncate:
+		if s.Max <= 0 {
+			return fmt.Errorf("truncate needs a positive max")
+		}
+	default:
+		return fmt.Errorf("invalid op %q: must be one of: trim, extract, truncate", s.Op)
+	}
+	return nil
+}
+
+// WarmupPolicy defines what happens when a warmup call fails
+type WarmupPolicy string
+
+const (
+	WarmupFail     WarmupPolicy = "fail"     // Fail the connection
+	WarmupContinue WarmupPolicy = "continue" // Log a warning and use the server anyway
+)
+
+// WarmupCall is a tool call the proxy makes right after initializing a
+// server, for backends that need one before they are ready, e.g. to load an
+// index. Tool is the backend's own (unprefixed) tool name.
+type WarmupCall struct {
+	Tool      string                 `yaml:"tool"`
+	Arguments map[string]interface{} `yaml:"arguments,omitempty"`
+	OnFailure WarmupPolicy           `yaml:"onFailure,omitempty"` // fail (default) or continue
+}
+
+// Validate checks that the call names a tool and has a valid policy
+func (c *WarmupCall) Validate() error {
+	if c.Tool == "" {
+		return fmt.Errorf("tool is required")
+	}
+	switch c.OnFailure {
+	case "", WarmupFail, WarmupContinue:
+	default:
+		return fmt.Errorf("invalid onFailure %q: must be one of: fail, continue", c.OnFailure)
+	}
+	return nil
+}
+
+// CompositeMerge defines how a composite tool combines its calls' results
+type CompositeMerge string
+
+const (
+	CompositeMergeConcat CompositeMerge = "concat" // The content of every successful call, each under a header naming its server
+	CompositeMergeJSON   CompositeMerge = "json"   // A JSON array with one {server, tool, result or error} object per call
+	CompositeMergeFirst  CompositeMerge = "first"  // The first successful result, in the order the calls are listed
+)
+
+// CompositeTool is a tool the proxy implements itself by calling several
+// backend tools concurrently and merging their results, e.g. one search
+// across several servers
+type CompositeTool struct {
+	Name        string          `yaml:"name"`
+	Description string          `yaml:"description,omitempty"`
+	Calls       []CompositeCall `yaml:"calls"`
+	Merge       CompositeMerge  `yaml:"merge,omitempty"`      // concat (default), json or first
+	Timeout     string          `yaml:"timeout,omitempty"`    // Bounds the whole fan-out; each call is also bounded by its server's timeout
+	RequireAll  bool            `yaml:"requireAll,omitempty"` // Fail the tool if any call fails, instead of only if all do
+}
+
+// CompositeCall is one backend tool called by a composite tool. The
+// composite tool's arguments are passed on, overridden by Arguments.
+type CompositeCall struct {
+	Server    string                 `yaml:"server"`
+	Tool      string                 `yaml:"tool"` // Original (unprefixed) tool name
+	Arguments map[string]interface{} `yaml:"arguments,omitempty"`
+}
+
+// Validate checks the composite tool's name, calls and settings against
+// the configured servers
+func (t *CompositeTool) Validate(servers map[string]bool) error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !ValidToolName(t.Name) {
+		return fmt.Errorf("invalid name %q: may only contain letters, digits, '_' and '-'", t.Name)
+	}
+	if containsName(ManagementTools, t.Name) {
+		return fmt.Errorf("name %q is reserved for a management tool", t.Name)
+	}
+	if len(t.Calls) == 0 {
+		return fmt.Errorf("calls must not be empty")
+	}
+	for i, call := range t.Calls {
+		if call.Server == "" || call.Tool == "" {
+			return fmt.Errorf("call %d: server and tool are required", i)
+		}
+		if !servers[call.Server] {
+			return fmt.Errorf("call %d: unknown server %q", i, call.Server)
+		}
+	}
+	switch t.Merge {
+	case "", CompositeMergeConcat, CompositeMergeJSON, CompositeMergeFirst:
+	default:
+		return fmt.Errorf("invalid merge %q: must be one of: concat, json, first", t.Merge)
+	}
+	if t.Timeout != "" {
+		if d, err := time.ParseDuration(t.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout format: %w", err)
+		} else if d <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// GetTimeout returns the composite tool's timeout, or 0 if unset
+func (t *CompositeTool) GetTimeout() time.Duration {
+	d, _ := time.ParseDuration(t.Timeout)
+	return d
+}
+
+// SanitizerRule matches string arguments of tool calls against a regular
+// expression. Rules are advisory tooling, not a security boundary.
+type SanitizerRule struct {
+	Name    string          `yaml:"name,omitempty"` // Shown in logs and errors; defaults to the pattern
+	Pattern string          `yaml:"pattern"`
+	Action  SanitizerAction `yaml:"action"`
+}
+
+// Validate checks that the rule has a valid pattern and action
+func (r *SanitizerRule) Validate() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	switch r.Action {
+	case SanitizerBlock, SanitizerWarn:
+	default:
+		return fmt.Errorf("invalid action %q: must be one of: block, warn", r.Action)
+	}
+	return nil
+}
+
+// DisplayName returns the rule name, or its pattern when unnamed
+func (r *SanitizerRule) DisplayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Pattern
 }
 
 // AuthConfig represents authentication configuration
@@ -55,23 +458,324 @@ type AuthConfig struct {
 	Password string `yaml:"password,omitempty"`
 }
 
+// DuplicateToolPolicy defines how tool name clashes across servers are handled
+type DuplicateToolPolicy string
+
+const (
+	DuplicateToolsAllow     DuplicateToolPolicy = "allow"
+	DuplicateToolsError     DuplicateToolPolicy = "error"
+	DuplicateToolsFirstWins DuplicateToolPolicy = "first-wins"
+	DuplicateToolsRename    DuplicateToolPolicy = "rename"
+)
+
+// UnknownFieldPolicy defines how fields the config doesn't define are handled
+type UnknownFieldPolicy string
+
+const (
+	UnknownFieldsError UnknownFieldPolicy = "error" // Fail loading the config
+	UnknownFieldsWarn  UnknownFieldPolicy = "warn"  // Log each field and ignore it
+)
+
+// NoToolsPolicy defines startup behavior when no backend tools are discovered
+type NoToolsPolicy string
+
+const (
+	NoToolsStart NoToolsPolicy = "start" // Start empty; add servers with server_add
+	NoToolsExit  NoToolsPolicy = "exit"  // Fail startup
+)
+
+// noToolsPolicy returns the onNoTools policy an allowEmpty value stands for
+func noToolsPolicy(allowEmpty bool) NoToolsPolicy {
+	if allowEmpty {
+		return NoToolsStart
+	}
+	return NoToolsExit
+}
+
+// ResultContentMode defines how backend tool result content is forwarded
+type ResultContentMode string
+
+const (
+	ResultContentPassthrough ResultContentMode = "passthrough" // Forward images, resources and annotations as sent
+	ResultContentText        ResultContentMode = "text"        // Join all text into a single text item
+)
+
+// RecordMetadataFormat defines how the recording file is reported on tool
+// results while recording
+type RecordMetadataFormat string
+
+const (
+	RecordMetadataText RecordMetadataFormat = "text" // Append a text item to the content
+	RecordMetadataMeta RecordMetadataFormat = "meta" // Put it in the result's _meta, out of the user-facing content
+	RecordMetadataNone RecordMetadataFormat = "none" // Don't report it
+)
+
+// RecordSettings controls recording output
+type RecordSettings struct {
+	MetadataFormat    RecordMetadataFormat `yaml:"metadataFormat,omitempty"`
+	MetadataTemplate  string               `yaml:"metadataTemplate,omitempty"`  // Text for the text format; {file} and {path} are replaced
+	OriginalToolNames bool                 `yaml:"originalToolNames,omitempty"` // Also record the backend's name of each proxied tool
+	Index             bool                 `yaml:"index,omitempty"`             // Also write FILE.idx locating each message, for seeking in large recordings
+}
+
+// ProtocolPolicy defines what happens when a backend answers
+// initialize with a protocol version the proxy doesn't support
+type ProtocolPolicy string
+
+const (
+	ProtocolMismatchError ProtocolPolicy = "error" // Fail the connection
+	ProtocolMismatchWarn  ProtocolPolicy = "warn"  // Log a warning and carry on, best effort
+)
+
+// ToolNameMode defines how exposed tool names with characters outside
+// [a-zA-Z0-9_-] are handled. Some clients reject the whole tool list when
+// one name has such characters.
+type ToolNameMode string
+
+const (
+	ToolNamesSanitize ToolNameMode = "sanitize" // Replace invalid characters with toolNameReplacement
+	ToolNamesKeep     ToolNameMode = "keep"     // Expose names as the backend reports them
+)
+
+// validToolNameChar reports whether clients accept r in a tool name
+func validToolNameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
+
+// ValidToolName reports whether name only has characters every client
+// accepts in a tool name
+func ValidToolName(name string) bool {
+	return strings.IndexFunc(name, func(r rune) bool { return !validToolNameChar(r) }) < 0
+}
+
+// SanitizeToolName replaces each character of name that clients may reject
+// with replacement
+func SanitizeToolName(name, replacement string) string {
+	var sanitized strings.Builder
+	for _, r := range name {
+		if validToolNameChar(r) {
+			sanitized.WriteRune(r)
+		} else {
+			sanitized.WriteString(replacement)
+		}
+	}
+	return sanitized.String()
+}
+
+// LogLevel filters what the proxy logs
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug" // Everything, including [DEBUG] client traces
+	LogLevelInfo  LogLevel = "info"  // Everything except [DEBUG] lines
+	LogLevelWarn  LogLevel = "warn"  // Only lines reporting a warning, error or failure
+)
+
+// ParseLogLevel parses a logLevel value
+func ParseLogLevel(value string) (LogLevel, error) {
+	switch level := LogLevel(value); level {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn:
+		return level, nil
+	}
+	return "", fmt.Errorf("invalid logLevel %q: must be one of: debug, info, warn", value)
+}
+
+// LogFormat defines how log lines are written
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json" // One object per line with time, level and msg
+)
+
+// ParseLogFormat parses a logFormat value
+func ParseLogFormat(value string) (LogFormat, error) {
+	switch format := LogFormat(value); format {
+	case LogFormatText, LogFormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid logFormat %q: must be one of: text, json", value)
+}
+
+// DefaultMaxRetries is how many times a failed connect is retried when
+// proxy.maxRetries is unset
+const DefaultMaxRetries = 3
+
+// Default identity the proxy reports to MCP clients. The version is the
+// build version, so the CLI and embedding programs report the same one.
+const (
+	DefaultProxyName    = "Dynamic MCP Proxy"
+	DefaultProxyVersion = "1.1.0"
+)
+
 // ProxySettings represents proxy-level settings
 type ProxySettings struct {
-	HealthCheckInterval string `yaml:"healthCheckInterval"`
-	ConnectionTimeout   string `yaml:"connectionTimeout"`
-	MaxRetries          int    `yaml:"maxRetries"`
+	Name                string              `yaml:"name,omitempty"`    // Server name reported to clients
+	Version             string              `yaml:"version,omitempty"` // Server version reported to clients
+	HealthCheckInterval string              `yaml:"healthCheckInterval"`
+	ConnectionTimeout   string              `yaml:"connectionTimeout"`
+	DefaultTimeout      string              `yaml:"defaultTimeout,omitempty"` // Tool call timeout of servers without their own (default 30s)
+	StartupBudget       string              `yaml:"startupBudget,omitempty"`  // Total time Initialize waits for servers to connect (unset = no limit)
+	MaxRetries          *int                `yaml:"maxRetries,omitempty"`     // Connect retries per server (default 3); 0 fails fast
+	DuplicateTools      DuplicateToolPolicy `yaml:"duplicateTools,omitempty"`
+	OnNoTools           NoToolsPolicy       `yaml:"onNoTools,omitempty"`
+	AllowEmpty          *bool               `yaml:"allowEmpty,omitempty"` // Shorthand for onNoTools: true = start, false = exit; also --allow-empty
+	ResultContent       ResultContentMode   `yaml:"resultContent,omitempty"`
+	ToolNames           ToolNameMode        `yaml:"toolNames,omitempty"`
+	ProtocolMismatch    ProtocolPolicy      `yaml:"protocolMismatch,omitempty"`
+	UnknownFields       UnknownFieldPolicy  `yaml:"unknownFields,omitempty"`
+	ToolNameReplacement string              `yaml:"toolNameReplacement,omitempty"` // Replaces each invalid character (default "_")
+	MaxDynamicServers   int                 `yaml:"maxDynamicServers,omitempty"` // Servers server_add may add (0 = unlimited)
+	MaxResultBytes      int                 `yaml:"maxResultBytes,omitempty"`    // Successful results larger than this are truncated for the client (0 = unlimited)
+	SnapshotEnv         bool                `yaml:"snapshotEnv,omitempty"`       // Reconnect servers with the environment of their first launch
+	LogFile             string              `yaml:"logFile,omitempty"`           // Overridden by --log and MCP_LOG_FILE
+	LogLevel            LogLevel            `yaml:"logLevel,omitempty"`          // Overridden by --log-level and MCP_LOG_LEVEL
+	LogFormat           LogFormat           `yaml:"logFormat,omitempty"`         // Overridden by --log-format and MCP_LOG_FORMAT
+	TraceConnect        bool                `yaml:"traceConnect,omitempty"`      // Log each spawn and handshake step with timing; also --trace-connect
+	Management          ManagementSettings  `yaml:"management,omitempty"`
+}
+
+// ServerName returns the name the proxy reports to clients
+func (p ProxySettings) ServerName() string {
+	if p.Name == "" {
+		return DefaultProxyName
+	}
+	return p.Name
+}
+
+// GetStartupBudget returns how long Initialize waits in total for servers
+// to connect, or 0 for no limit
+func (p ProxySettings) GetStartupBudget() time.Duration {
+	budget, err := time.ParseDuration(p.StartupBudget)
+	if err != nil || budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// ServerVersion returns the version the proxy reports to clients
+func (p ProxySettings) ServerVersion() string {
+	if p.Version == "" {
+		return DefaultProxyVersion
+	}
+	return p.Version
+}
+
+// ManagementTools lists the proxy's management tools
+var ManagementTools = []string{
+	"server_add", "server_remove", "server_list", "server_status", "server_tools",
+	"proxy_info", "proxy_config", "proxy_degraded", "proxy_load", "server_latency", "server_stats_reset",
+	"record_start", "record_stop", "server_disconnect", "server_reconnect", "server_rediscover",
+	"server_log_level", "server_call",
+}
+
+// ReadOnlyManagementTools lists the management tools that only report state
+var ReadOnlyManagementTools = []string{
+	"server_list", "server_status", "server_tools", "proxy_info", "proxy_config", "proxy_degraded",
+	"proxy_load", "server_latency",
+}
+
+// ManagementSettings controls which management tools the proxy exposes.
+// By default all are exposed.
+type ManagementSettings struct {
+	EnabledTools    []string         `yaml:"enabledTools,omitempty"`    // Expose only these tools
+	ReadOnly        bool             `yaml:"readOnly,omitempty"`        // Expose only ReadOnlyManagementTools
+	AllowedCommands []AllowedCommand `yaml:"allowedCommands,omitempty"` // Commands server_add may launch (empty = any)
+}
+
+// AllowedCommand permits server_add and server_reconnect to launch a command.
+// A command containing a path separator must match exactly; a bare name only
+// matches the same bare name, resolved through PATH. Args, when set, is a
+// regular expression that must match the whole space-joined argument list.
+type AllowedCommand struct {
+	Command string `yaml:"command"`
+	Args    string `yaml:"args,omitempty"`
+}
+
+// Matches reports whether the entry permits command with args
+func (a *AllowedCommand) Matches(command string, args []string) bool {
+	if strings.ContainsRune(a.Command, filepath.Separator) || strings.Contains(a.Command, "/") {
+		if filepath.Clean(a.Command) != filepath.Clean(command) {
+			return false
+		}
+	} else if a.Command != command {
+		return false
+	}
+
+	if a.Args == "" {
+		return true
+	}
+	re, err := regexp.Compile("^(?:" + a.Args + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strings.Join(args, " "))
+}
+
+// CommandAllowed reports whether a dynamically added server may run command
+// with args. Any command is allowed when no allowlist is configured.
+func (m *ManagementSettings) CommandAllowed(command string, args []string) bool {
+	if len(m.AllowedCommands) == 0 {
+		return true
+	}
+	for _, allowed := range m.AllowedCommands {
+		if allowed.Matches(command, args) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the management tool names
+func (m *ManagementSettings) Validate() error {
+	if m.ReadOnly && len(m.EnabledTools) > 0 {
+		return fmt.Errorf("enabledTools and readOnly are mutually exclusive")
+	}
+	for _, name := range m.EnabledTools {
+		if !containsName(ManagementTools, name) {
+			return fmt.Errorf("unknown management tool %q: must be one of: %s", name, strings.Join(ManagementTools, ", "))
+		}
+	}
+	for i, allowed := range m.AllowedCommands {
+		if allowed.Command == "" {
+			return fmt.Errorf("allowedCommands %d: command is required", i)
+		}
+		if _, err := regexp.Compile(allowed.Args); err != nil {
+			return fmt.Errorf("allowedCommands %d: invalid args pattern: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ToolEnabled reports whether the named management tool should be exposed
+func (m *ManagementSettings) ToolEnabled(name string) bool {
+	switch {
+	case m.ReadOnly:
+		return containsName(ReadOnlyManagementTools, name)
+	case len(m.EnabledTools) > 0:
+		return containsName(m.EnabledTools, name)
+	default:
+		return true
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Validate validates the configuration
 func (c *ProxyConfig) Validate() error {
-	// Allow empty server lists for dynamic proxies
-	if len(c.Servers) == 0 {
-		return nil
-	}
-	
+	// Empty server lists are allowed for dynamic proxies
+
 	// Check for unique server names and prefixes
 	names := make(map[string]bool)
 	prefixes := make(map[string]bool)
+	unprefixedTools := make(map[string]string) // Tool name to the noPrefix server configuring it
 	
 	for i, server := range c.Servers {
 		// Validate server name
@@ -83,14 +787,26 @@ func (c *ProxyConfig) Validate() error {
 		}
 		names[server.Name] = true
 		
-		// Validate prefix
-		if server.Prefix == "" {
-			return fmt.Errorf("server %s: prefix is required", server.Name)
-		}
-		if prefixes[server.Prefix] {
-			return fmt.Errorf("duplicate server prefix: %s", server.Prefix)
+		// Validate prefix (noPrefix servers expose unprefixed tool names)
+		if server.NoPrefix {
+			if server.Prefix != "" {
+				return fmt.Errorf("server %s: prefix and noPrefix are mutually exclusive", server.Name)
+			}
+			for _, tool := range c.configuredToolNames(server) {
+				if other, taken := unprefixedTools[tool]; taken {
+					return fmt.Errorf("server %s: noPrefix tool %s is also exposed unprefixed by server %s", server.Name, tool, other)
+				}
+				unprefixedTools[tool] = server.Name
+			}
+		} else {
+			if server.Prefix == "" {
+				return fmt.Errorf("server %s: prefix is required", server.Name)
+			}
+			if prefixes[server.Prefix] {
+				return fmt.Errorf("duplicate server prefix: %s", server.Prefix)
+			}
+			prefixes[server.Prefix] = true
 		}
-		prefixes[server.Prefix] = true
 		
 		// Validate transport
 		if server.Transport != "stdio" && server.Transport != "http" {
@@ -107,6 +823,12 @@ func (c *ProxyConfig) Validate() error {
 				return fmt.Errorf("server %s: url is required for http transport", server.Name)
 			}
 		}
+
+		for j, tag := range server.Tags {
+			if strings.TrimSpace(tag) == "" {
+				return fmt.Errorf("server %s: tag %d must not be empty", server.Name, j)
+			}
+		}
 		
 		// Validate timeout format if specified
 		if server.Timeout != "" {
@@ -115,6 +837,74 @@ func (c *ProxyConfig) Validate() error {
 			}
 		}
 
+		if server.ConnectionTimeout != "" {
+			if _, err := time.ParseDuration(server.ConnectionTimeout); err != nil {
+				return fmt.Errorf("server %s: invalid connectionTimeout format: %w", server.Name, err)
+			}
+		}
+
+		if server.IdleTimeout != "" {
+			if d, err := time.ParseDuration(server.IdleTimeout); err != nil {
+				return fmt.Errorf("server %s: invalid idleTimeout format: %w", server.Name, err)
+			} else if d < 0 {
+				return fmt.Errorf("server %s: idleTimeout must not be negative", server.Name)
+			}
+		}
+
+		if server.MaxRetries != nil && *server.MaxRetries < 0 {
+			return fmt.Errorf("server %s: maxRetries must not be negative", server.Name)
+		}
+
+		if server.MaxResultBytes != nil && *server.MaxResultBytes < 0 {
+			return fmt.Errorf("server %s: maxResultBytes must not be negative", server.Name)
+		}
+
+		if server.Limits != nil {
+			if err := server.Limits.Validate(); err != nil {
+				return fmt.Errorf("server %s: limits: %w", server.Name, err)
+			}
+		}
+
+		if server.RateLimit != nil {
+			if err := server.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("server %s: rateLimit: %w", server.Name, err)
+			}
+		}
+
+		if server.Transcript != nil {
+			if err := server.Transcript.Validate(); err != nil {
+				return fmt.Errorf("server %s: transcript: %w", server.Name, err)
+			}
+		}
+
+		for j, rule := range server.Sanitizer {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("server %s: sanitizer rule %d: %w", server.Name, j, err)
+			}
+		}
+
+		for tool, steps := range server.Transforms {
+			for j, step := range steps {
+				if err := step.Validate(); err != nil {
+					return fmt.Errorf("server %s: transform %d of tool %s: %w", server.Name, j, tool, err)
+				}
+			}
+		}
+
+		for tool, names := range server.HiddenArguments {
+			for _, name := range names {
+				if _, ok := server.DefaultArguments[tool][name]; !ok {
+					return fmt.Errorf("server %s: hidden argument %s of tool %s needs a value in defaultArguments", server.Name, name, tool)
+				}
+			}
+		}
+
+		for j, call := range server.Warmup {
+			if err := call.Validate(); err != nil {
+				return fmt.Errorf("server %s: warmup call %d: %w", server.Name, j, err)
+			}
+		}
+
 		// Validate server-level inherit config
 		if server.Inherit != nil {
 			if err := server.Inherit.Validate(); err != nil {
@@ -123,6 +913,22 @@ func (c *ProxyConfig) Validate() error {
 		}
 	}
 
+	composites := make(map[string]bool, len(c.CompositeTools))
+	for i, tool := range c.CompositeTools {
+		if err := tool.Validate(names); err != nil {
+			return fmt.Errorf("composite tool %d: %w", i, err)
+		}
+		if composites[tool.Name] {
+			return fmt.Errorf("duplicate composite tool name: %s", tool.Name)
+		}
+		composites[tool.Name] = true
+	}
+
+	// Unknown dependencies and cycles
+	if _, err := c.StartLevels(); err != nil {
+		return err
+	}
+
 	// Validate proxy settings
 	if c.Proxy.HealthCheckInterval != "" {
 		if _, err := time.ParseDuration(c.Proxy.HealthCheckInterval); err != nil {
@@ -136,6 +942,100 @@ func (c *ProxyConfig) Validate() error {
 		}
 	}
 
+	if c.Proxy.DefaultTimeout != "" {
+		if d, err := time.ParseDuration(c.Proxy.DefaultTimeout); err != nil {
+			return fmt.Errorf("invalid defaultTimeout format: %w", err)
+		} else if d <= 0 {
+			return fmt.Errorf("defaultTimeout must be positive")
+		}
+	}
+
+	if c.Proxy.StartupBudget != "" {
+		if d, err := time.ParseDuration(c.Proxy.StartupBudget); err != nil {
+			return fmt.Errorf("invalid startupBudget format: %w", err)
+		} else if d < 0 {
+			return fmt.Errorf("startupBudget must not be negative")
+		}
+	}
+
+	if c.Proxy.MaxRetries != nil && *c.Proxy.MaxRetries < 0 {
+		return fmt.Errorf("maxRetries must not be negative")
+	}
+
+	if c.Proxy.MaxDynamicServers < 0 {
+		return fmt.Errorf("maxDynamicServers must not be negative")
+	}
+
+	switch c.Proxy.DuplicateTools {
+	case "", DuplicateToolsAllow, DuplicateToolsError, DuplicateToolsFirstWins, DuplicateToolsRename:
+	default:
+		return fmt.Errorf("invalid duplicateTools %q: must be one of: allow, error, first-wins, rename", c.Proxy.DuplicateTools)
+	}
+
+	switch c.Proxy.OnNoTools {
+	case "", NoToolsStart, NoToolsExit:
+	default:
+		return fmt.Errorf("invalid onNoTools %q: must be one of: start, exit", c.Proxy.OnNoTools)
+	}
+	if c.Proxy.AllowEmpty != nil && c.Proxy.OnNoTools != "" && c.Proxy.OnNoTools != noToolsPolicy(*c.Proxy.AllowEmpty) {
+		return fmt.Errorf("allowEmpty %v contradicts onNoTools %q; set only one of them", *c.Proxy.AllowEmpty, c.Proxy.OnNoTools)
+	}
+
+	switch c.Proxy.ProtocolMismatch {
+	case "", ProtocolMismatchError, ProtocolMismatchWarn:
+	default:
+		return fmt.Errorf("invalid protocolMismatch %q: must be one of: error, warn", c.Proxy.ProtocolMismatch)
+	}
+
+	switch c.Proxy.UnknownFields {
+	case "", UnknownFieldsError, UnknownFieldsWarn:
+	default:
+		return fmt.Errorf("invalid unknownFields %q: must be one of: error, warn", c.Proxy.UnknownFields)
+	}
+
+	switch c.Proxy.ToolNames {
+	case "", ToolNamesSanitize, ToolNamesKeep:
+	default:
+		return fmt.Errorf("invalid toolNames %q: must be one of: sanitize, keep", c.Proxy.ToolNames)
+	}
+	if c.Proxy.ToolNameReplacement != "" && !ValidToolName(c.Proxy.ToolNameReplacement) {
+		return fmt.Errorf("invalid toolNameReplacement %q: may only contain letters, digits, '_' and '-'", c.Proxy.ToolNameReplacement)
+	}
+
+	if c.Proxy.MaxResultBytes < 0 {
+		return fmt.Errorf("maxResultBytes must not be negative")
+	}
+
+	switch c.Proxy.ResultContent {
+	case "", ResultContentPassthrough, ResultContentText:
+	default:
+		return fmt.Errorf("invalid resultContent %q: must be one of: passthrough, text", c.Proxy.ResultContent)
+	}
+
+	switch c.Record.MetadataFormat {
+	case "", RecordMetadataText, RecordMetadataMeta, RecordMetadataNone:
+	default:
+		return fmt.Errorf("invalid metadataFormat %q: must be one of: text, meta, none", c.Record.MetadataFormat)
+	}
+	if c.Record.MetadataTemplate != "" && c.Record.MetadataFormat != "" && c.Record.MetadataFormat != RecordMetadataText {
+		return fmt.Errorf("metadataTemplate only applies to metadataFormat text, not %q", c.Record.MetadataFormat)
+	}
+
+	if c.Proxy.LogLevel != "" {
+		if _, err := ParseLogLevel(string(c.Proxy.LogLevel)); err != nil {
+			return err
+		}
+	}
+	if c.Proxy.LogFormat != "" {
+		if _, err := ParseLogFormat(string(c.Proxy.LogFormat)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Proxy.Management.Validate(); err != nil {
+		return fmt.Errorf("proxy.management: %w", err)
+	}
+
 	// Validate proxy-level inherit config
 	if c.Inherit != nil {
 		if err := c.Inherit.Validate(); err != nil {
@@ -146,89 +1046,352 @@ func (c *ProxyConfig) Validate() error {
 	return nil
 }
 
-// ExpandEnvVars expands environment variables in configuration values
-func (c *ProxyConfig) ExpandEnvVars() {
+// ExpandEnvVars expands environment variables in configuration values.
+// Supports ${VAR}, ${VAR:-default} and ${VAR:?message}; returns an error
+// naming the variable when a required variable is unset. Server commands
+// given as package specs, such as "npm:@scope/pkg", are then expanded into
+// the command that runs the package.
+func (c *ProxyConfig) ExpandEnvVars() error {
 	// Expand proxy-level inheritance config
-	expandInheritConfig(c.Inherit)
+	if err := expandInheritConfig(c.Inherit); err != nil {
+		return fmt.Errorf("inherit: %w", err)
+	}
+
+	var err error
+	if c.Proxy.LogFile, err = expandEnvVar(c.Proxy.LogFile); err != nil {
+		return fmt.Errorf("logFile: %w", err)
+	}
 
 	for i := range c.Servers {
 		server := &c.Servers[i]
+		if err := server.expandEnvVars(); err != nil {
+			return fmt.Errorf("server %s: %w", server.Name, err)
+		}
+		if err := server.expandPackageSpec(); err != nil {
+			return fmt.Errorf("server %s: %w", server.Name, err)
+		}
+	}
+
+	return nil
+}
 
-		// Expand command
-		server.Command = expandEnvVar(server.Command)
+// expandEnvVars expands environment variables in a single server's values
+func (server *ServerConfig) expandEnvVars() error {
+	var err error
 
-		// Expand args
-		for j := range server.Args {
-			server.Args[j] = expandEnvVar(server.Args[j])
+	// Expand command; a template that resolves to nothing would otherwise
+	// surface later as a cryptic "command not found"
+	commandTemplate := server.Command
+	if server.Command, err = expandEnvVar(server.Command); err != nil {
+		return err
+	}
+	if commandTemplate != "" && strings.TrimSpace(server.Command) == "" {
+		return fmt.Errorf("command %q expanded to an empty value (is the variable set?)", commandTemplate)
+	}
+
+	// Expand args
+	unexpanded := &unexpandedValues{args: slices.Clone(server.Args), url: server.URL}
+	for j := range server.Args {
+		if server.Args[j], err = expandEnvVar(server.Args[j]); err != nil {
+			return err
 		}
+	}
 
-		// Expand environment variables
-		for key, value := range server.Env {
-			server.Env[key] = expandEnvVar(value)
+	// Expand environment variables
+	for key, value := range server.Env {
+		if server.Env[key], err = expandEnvVar(value); err != nil {
+			return err
 		}
+	}
+
+	// Expand URL
+	urlTemplate := server.URL
+	if server.URL, err = expandEnvVar(server.URL); err != nil {
+		return err
+	}
+	if urlTemplate != "" && strings.TrimSpace(server.URL) == "" {
+		return fmt.Errorf("url %q expanded to an empty value (is the variable set?)", urlTemplate)
+	}
+	unexpanded.expandedArgs = slices.Clone(server.Args)
+	unexpanded.expandedURL = server.URL
+	server.unexpanded = unexpanded
 
-		// Expand URL
-		server.URL = expandEnvVar(server.URL)
+	// Expand auth fields
+	if server.Auth != nil {
+		if server.Auth.Token, err = expandEnvVar(server.Auth.Token); err != nil {
+			return err
+		}
+		if server.Auth.Username, err = expandEnvVar(server.Auth.Username); err != nil {
+			return err
+		}
+		if server.Auth.Password, err = expandEnvVar(server.Auth.Password); err != nil {
+			return err
+		}
+	}
 
-		// Expand auth fields
-		if server.Auth != nil {
-			server.Auth.Token = expandEnvVar(server.Auth.Token)
-			server.Auth.Username = expandEnvVar(server.Auth.Username)
-			server.Auth.Password = expandEnvVar(server.Auth.Password)
+	if server.Transcript != nil {
+		if server.Transcript.File, err = expandEnvVar(server.Transcript.File); err != nil {
+			return err
 		}
+	}
 
-		// Expand server-level inheritance config
-		expandInheritConfig(server.Inherit)
+	// Expand server-level inheritance config
+	if err := expandInheritConfig(server.Inherit); err != nil {
+		return fmt.Errorf("inherit: %w", err)
 	}
+
+	return nil
 }
 
 // expandInheritConfig expands environment variables in InheritConfig fields
-func expandInheritConfig(ic *InheritConfig) {
+func expandInheritConfig(ic *InheritConfig) error {
 	if ic == nil {
-		return
+		return nil
 	}
 
-	for i := range ic.Extra {
-		ic.Extra[i] = expandEnvVar(ic.Extra[i])
+	for _, list := range [][]string{ic.Extra, ic.Prefix, ic.Deny} {
+		for i := range list {
+			expanded, err := expandEnvVar(list[i])
+			if err != nil {
+				return err
+			}
+			list[i] = expanded
+		}
 	}
 
-	for i := range ic.Prefix {
-		ic.Prefix[i] = expandEnvVar(ic.Prefix[i])
+	return nil
+}
+
+// expandEnvVar expands environment variables in the format ${VAR}.
+// Shell-style modifiers are supported inside braces:
+//   - ${VAR:-default} uses default when VAR is unset or empty
+//   - ${VAR-default} uses default only when VAR is unset
+//   - ${VAR:?message} fails when VAR is unset or empty
+//   - ${VAR?message} fails only when VAR is unset
+//
+// Connect-time templates (${{VAR}}) are left in place for ExpandLazyVars.
+func expandEnvVar(value string) (string, error) {
+	if value == "" {
+		return value, nil
 	}
 
-	for i := range ic.Deny {
-		ic.Deny[i] = expandEnvVar(ic.Deny[i])
+	if !strings.Contains(value, "${") {
+		return value, nil
 	}
+
+	return mapLazyVars(value, expandEnvRefs, func(expr string) (string, error) {
+		return "${{" + expr + "}}", nil
+	})
 }
 
-// expandEnvVar expands environment variables in the format ${VAR}
-func expandEnvVar(value string) string {
-	if value == "" {
-		return value
+// ExpandLazyVars expands connect-time templates (${{VAR}}, with the same
+// modifiers as ${VAR}) against the current environment. Load-time ${VAR}
+// references have already been expanded by then, so only ${{...}} is
+// touched. This lets a reconnect pick up a rotated token.
+func ExpandLazyVars(value string) (string, error) {
+	if !strings.Contains(value, "${{") {
+		return value, nil
 	}
-	
-	// Simple expansion of ${VAR} format
-	if strings.Contains(value, "${") {
-		return os.ExpandEnv(value)
-	}
-	
-	return value
+
+	return mapLazyVars(value, func(text string) (string, error) {
+		return text, nil
+	}, lookupEnvExpr)
 }
 
-// GetServerTimeout returns the timeout duration for a server, with default
-func (s *ServerConfig) GetServerTimeout() time.Duration {
-	if s.Timeout == "" {
-		return 30 * time.Second // default timeout
+// mapLazyVars rebuilds value from the text outside its ${{...}} templates,
+// passed through text, and the expression inside each, passed through
+// template
+func mapLazyVars(value string, text, template func(string) (string, error)) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "${{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start:], "}}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${{ in %q", value)
+		}
+		end += start
+
+		before, err := text(value[:start])
+		if err != nil {
+			return "", err
+		}
+		expanded, err := template(value[start+len("${{") : end])
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(before)
+		result.WriteString(expanded)
+		value = value[end+len("}}"):]
 	}
-	
-	duration, err := time.ParseDuration(s.Timeout)
+
+	rest, err := text(value)
 	if err != nil {
-		return 30 * time.Second // fallback to default
+		return "", err
+	}
+	result.WriteString(rest)
+	return result.String(), nil
+}
+
+// expandEnvRefs expands ${VAR} references in value
+func expandEnvRefs(value string) (string, error) {
+	var expandErr error
+	expanded := os.Expand(value, func(expr string) string {
+		val, err := lookupEnvExpr(expr)
+		if err != nil && expandErr == nil {
+			expandErr = err
+		}
+		return val
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// lookupEnvExpr evaluates a brace expression such as "VAR:-default"
+func lookupEnvExpr(expr string) (string, error) {
+	name, op, arg := splitEnvExpr(expr)
+	val, set := os.LookupEnv(name)
+
+	switch op {
+	case ":-":
+		if val == "" {
+			return arg, nil
+		}
+	case "-":
+		if !set {
+			return arg, nil
+		}
+	case ":?", "?":
+		if !set || (op == ":?" && val == "") {
+			if arg == "" {
+				arg = "required but not set"
+			}
+			return "", fmt.Errorf("environment variable %s: %s", name, arg)
+		}
+	}
+	return val, nil
+}
+
+// splitEnvExpr splits a brace expression like "VAR:-default" into the variable
+// name, the modifier operator (":-", "-", ":?", "?" or "") and its argument
+func splitEnvExpr(expr string) (name, op, arg string) {
+	for i, ch := range expr {
+		switch ch {
+		case ':':
+			if i+1 < len(expr) && (expr[i+1] == '-' || expr[i+1] == '?') {
+				return expr[:i], expr[i : i+2], expr[i+2:]
+			}
+		case '-', '?':
+			return expr[:i], expr[i : i+1], expr[i+1:]
+		}
+	}
+	return expr, "", ""
+}
+
+// configuredToolNames returns the original names of the server's tools
+// that the config refers to, in its per-tool settings, warmup calls and
+// composite tools. The tools themselves are only known once it connects.
+func (c *ProxyConfig) configuredToolNames(server ServerConfig) []string {
+	seen := make(map[string]bool)
+	for tool := range server.Transforms {
+		seen[tool] = true
+	}
+	for tool := range server.DefaultArguments {
+		seen[tool] = true
+	}
+	for tool := range server.HiddenArguments {
+		seen[tool] = true
+	}
+	if server.RateLimit != nil {
+		for tool := range server.RateLimit.Tools {
+			seen[tool] = true
+		}
+	}
+	for _, call := range server.Warmup {
+		seen[call.Tool] = true
+	}
+	for _, composite := range c.CompositeTools {
+		for _, call := range composite.Calls {
+			if call.Server == server.Name {
+				seen[call.Tool] = true
+			}
+		}
+	}
+
+	tools := make([]string, 0, len(seen))
+	for tool := range seen {
+		tools = append(tools, tool)
+	}
+	slices.Sort(tools)
+	return tools
+}
+
+// ToolPrefix returns the prefix for this server's tool names, or "" when
+// noPrefix is set
+func (s *ServerConfig) ToolPrefix() string {
+	if s.NoPrefix {
+		return ""
+	}
+	return s.Prefix
+}
+
+// ToolPrefixFor returns the prefix for one of the server's tools. With
+// flattenPrefix, a tool whose name already starts with the prefix, such as
+// a tool of a nested mcp-debug proxy using the same prefix, gets none, so
+// it isn't prefixed twice.
+func (s *ServerConfig) ToolPrefixFor(toolName string) string {
+	prefix := s.ToolPrefix()
+	if s.FlattenPrefix && prefix != "" && strings.HasPrefix(toolName, prefix+"_") {
+		return ""
+	}
+	return prefix
+}
+
+// GetServerTimeout returns the tool call timeout for a server. The
+// server-level timeout overrides proxyDefault (proxy.defaultTimeout); both
+// fall back to 30s.
+func (s *ServerConfig) GetServerTimeout(proxyDefault string) time.Duration {
+	for _, value := range []string{s.Timeout, proxyDefault} {
+		if value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return 30 * time.Second
+}
+
+// GetIdleTimeout returns how long the server may go without tool calls
+// before it is disconnected. Zero, the default, keeps it connected.
+func (s *ServerConfig) GetIdleTimeout() time.Duration {
+	duration, err := time.ParseDuration(s.IdleTimeout)
+	if err != nil || duration < 0 {
+		return 0
 	}
-	
 	return duration
 }
 
+// GetConnectionTimeout returns how long to wait for a server to connect and
+// complete the initialize handshake. The server-level connectionTimeout
+// overrides proxyDefault (proxy.connectionTimeout); both fall back to 10s.
+func (s *ServerConfig) GetConnectionTimeout(proxyDefault string) time.Duration {
+	for _, value := range []string{s.ConnectionTimeout, proxyDefault} {
+		if value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return 10 * time.Second
+}
+
 // GetProxySettings returns proxy settings with defaults
 func (c *ProxyConfig) GetProxySettings() ProxySettings {
 	settings := c.Proxy
@@ -240,13 +1403,65 @@ func (c *ProxyConfig) GetProxySettings() ProxySettings {
 	if settings.ConnectionTimeout == "" {
 		settings.ConnectionTimeout = "10s"
 	}
-	if settings.MaxRetries == 0 {
-		settings.MaxRetries = 3
+	if settings.DefaultTimeout == "" {
+		settings.DefaultTimeout = "30s"
+	}
+	if settings.MaxRetries == nil {
+		maxRetries := DefaultMaxRetries
+		settings.MaxRetries = &maxRetries
+	}
+	if settings.DuplicateTools == "" {
+		settings.DuplicateTools = DuplicateToolsAllow
+	}
+	if settings.OnNoTools == "" {
+		settings.OnNoTools = NoToolsStart
+		if settings.AllowEmpty != nil {
+			settings.OnNoTools = noToolsPolicy(*settings.AllowEmpty)
+		}
+	}
+	if settings.ResultContent == "" {
+		settings.ResultContent = ResultContentPassthrough
+	}
+	if settings.ProtocolMismatch == "" {
+		settings.ProtocolMismatch = ProtocolMismatchError
+	}
+	if settings.UnknownFields == "" {
+		settings.UnknownFields = UnknownFieldsError
+	}
+	if settings.ToolNames == "" {
+		settings.ToolNames = ToolNamesSanitize
+	}
+	if settings.ToolNameReplacement == "" {
+		settings.ToolNameReplacement = "_"
+	}
+	if settings.LogLevel == "" {
+		settings.LogLevel = LogLevelDebug
+	}
+	if settings.LogFormat == "" {
+		settings.LogFormat = LogFormatText
 	}
 
 	return settings
 }
 
+// ResolveMaxRetries returns how many times to retry a failed connect for
+// this server. A server-level maxRetries overrides the proxy-level value.
+func (s *ServerConfig) ResolveMaxRetries(proxyDefault int) int {
+	if s.MaxRetries != nil {
+		return *s.MaxRetries
+	}
+	return proxyDefault
+}
+
+// ResolveMaxResultBytes returns the server's result size budget, or the
+// proxy default when the server doesn't set one. Zero means unlimited.
+func (s *ServerConfig) ResolveMaxResultBytes(proxyDefault int) int {
+	if s.MaxResultBytes != nil {
+		return *s.MaxResultBytes
+	}
+	return proxyDefault
+}
+
 // ResolveInheritConfig returns the effective inheritance config for a server.
 // Server-level config overrides proxy-level defaults.
 func (s *ServerConfig) ResolveInheritConfig(proxyDefault *InheritConfig) *InheritConfig {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"mcp-debug/config"
+)
+
+// configWatchDebounce coalesces the burst of events an editor produces when
+// saving (many write twice, or write a temp file and rename it)
+const configWatchDebounce = 300 * time.Millisecond
+
+// watchConfig calls reload with the freshly loaded config each time the file
+// at path changes, until ctx is cancelled. A config that fails to load or
+// validate is logged and skipped, so the running config stays in effect.
+//
+// The parent directory is watched rather than the file itself, so edits
+// that replace the file by renaming over it are still seen. A config
+// directory is watched for changes to any of its fragments, including ones
+// added or removed.
+func watchConfig(ctx context.Context, path string, debounce time.Duration, reload func(*config.ProxyConfig)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	watchDir := filepath.Dir(absPath)
+	isDir := false
+	if info, err := os.Stat(absPath); err == nil && info.IsDir() {
+		watchDir, isDir = absPath, true
+	}
+	if err := watcher.Add(watchDir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+		changed := path // The file the last relevant event was for
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if isDir {
+					if !isConfigFragment(event.Name) {
+						continue
+					}
+				} else if filepath.Clean(event.Name) != absPath || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+					continue
+				}
+				changed = event.Name
+				timer.Reset(debounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config watch error: %v", err)
+			case <-timer.C:
+				// A file caught between truncate and write reads as empty;
+				// the write that follows triggers another reload
+				if info, err := os.Stat(changed); err == nil && info.Size() == 0 {
+					continue
+				}
+				cfg, err := config.LoadConfig(path)
+				if err != nil {
+					log.Printf("Config change ignored, keeping the running config: %v", err)
+					continue
+				}
+				log.Printf("Config file changed, reloading: %s", path)
+				reload(cfg)
+			}
+		}
+	}()
+	return nil
+}
+
+// isConfigFragment reports whether a file in a config directory is one of
+// its fragments
+func isConfigFragment(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
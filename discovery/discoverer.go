@@ -10,9 +10,13 @@ import (
 	"mcp-debug/config"
 )
 
+// ConnectFunc creates, connects and initializes a client for a server
+type ConnectFunc func(ctx context.Context, serverConfig config.ServerConfig) (client.MCPClient, error)
+
 // Discoverer handles tool discovery from multiple MCP servers
 type Discoverer struct {
-	config *config.ProxyConfig
+	config  *config.ProxyConfig
+	connect ConnectFunc
 }
 
 // NewDiscoverer creates a new tool discoverer
@@ -22,43 +26,121 @@ func NewDiscoverer(cfg *config.ProxyConfig) *Discoverer {
 	}
 }
 
+// SetConnectFunc makes discovery connect through connect and keep each
+// successfully discovered client open in DiscoveryResult.Client. The caller
+// then owns those clients and must close them.
+func (d *Discoverer) SetConnectFunc(connect ConnectFunc) {
+	d.connect = connect
+}
+
 // DiscoverAll discovers tools from all configured servers concurrently
 func (d *Discoverer) DiscoverAll(ctx context.Context) ([]*DiscoveryResult, error) {
+	levels, err := d.config.StartLevels()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(d.config.Servers))
+	for i, serverConfig := range d.config.Servers {
+		index[serverConfig.Name] = i
+	}
 	results := make([]*DiscoveryResult, len(d.config.Servers))
-	var wg sync.WaitGroup
 	
-	// Start discovery for each server concurrently
-	for i, serverConfig := range d.config.Servers {
-		wg.Add(1)
-		go func(index int, cfg config.ServerConfig) {
-			defer wg.Done()
-			
-			result := d.discoverServer(ctx, cfg)
-			results[index] = result
-		}(i, serverConfig)
+	// Servers in a level are discovered concurrently, after the levels
+	// holding their dependencies
+	for _, level := range levels {
+		var wg sync.WaitGroup
+		for _, serverConfig := range level {
+			if failed := failedDependency(serverConfig, results, index); failed != "" {
+				results[index[serverConfig.Name]] = &DiscoveryResult{
+					ServerName:   serverConfig.Name,
+					ServerPrefix: serverConfig.ToolPrefix(),
+					Tools:        []RemoteTool{},
+					Error:        &DependencyError{Dependency: failed},
+				}
+				continue
+			}
+			wg.Add(1)
+			go func(index int, cfg config.ServerConfig) {
+				defer wg.Done()
+				
+				result := d.discoverServer(ctx, cfg)
+				if result.Error != nil && ctx.Err() != nil {
+					// Report why ctx ended rather than how it surfaced
+					result.Error = context.Cause(ctx)
+				}
+				results[index] = result
+			}(index[serverConfig.Name], serverConfig)
+		}
+		
+		// Wait for the level to complete
+		wg.Wait()
 	}
 	
-	// Wait for all discoveries to complete
-	wg.Wait()
+	// Servers stay connected until every level is discovered, so a
+	// dependency is still running while its dependents start
+	for _, result := range results {
+		d.release(result)
+	}
 	
 	return results, nil
 }
 
+// failedDependency returns the first of the server's dependencies whose
+// discovery failed, or "" if all succeeded
+func failedDependency(serverConfig config.ServerConfig, results []*DiscoveryResult, index map[string]int) string {
+	for _, dependency := range serverConfig.DependsOn {
+		if result := results[index[dependency]]; result == nil || !result.IsSuccessful() {
+			return dependency
+		}
+	}
+	return ""
+}
+
 // DiscoverServer discovers tools from a single server
 func (d *Discoverer) DiscoverServer(ctx context.Context, serverConfig config.ServerConfig) *DiscoveryResult {
-	return d.discoverServer(ctx, serverConfig)
+	result := d.discoverServer(ctx, serverConfig)
+	d.release(result)
+	return result
 }
 
-// discoverServer performs the actual discovery from a single server
+// discoverServer performs the actual discovery from a single server. On
+// success the client is left connected in result.Client.
 func (d *Discoverer) discoverServer(ctx context.Context, serverConfig config.ServerConfig) *DiscoveryResult {
 	start := time.Now()
 	
 	result := &DiscoveryResult{
 		ServerName:   serverConfig.Name,
-		ServerPrefix: serverConfig.Prefix,
+		ServerPrefix: serverConfig.ToolPrefix(),
 		Tools:        []RemoteTool{},
 	}
 	
+	var mcpClient client.MCPClient
+	var err error
+	if d.connect != nil {
+		if mcpClient, err = d.connect(ctx, serverConfig); err != nil {
+			err = &ConnectError{Err: err}
+		}
+	} else {
+		mcpClient, err = d.openClient(ctx, serverConfig)
+	}
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	
+	d.listTools(ctx, mcpClient, serverConfig, result)
+	if result.Error != nil {
+		closeClient(mcpClient)
+	} else {
+		result.Client = mcpClient
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// openClient creates, connects and initializes a client for discovery
+func (d *Discoverer) openClient(ctx context.Context, serverConfig config.ServerConfig) (client.MCPClient, error) {
 	// Create client based on transport type
 	var mcpClient client.MCPClient
 	var err error
@@ -73,54 +155,61 @@ func (d *Discoverer) discoverServer(ctx context.Context, serverConfig config.Ser
 	}
 	
 	if err != nil {
-		result.Error = fmt.Errorf("failed to create client: %w", err)
-		result.Duration = time.Since(start)
-		return result
+		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 	
-	// Ensure client is closed when done
-	defer func() {
-		if closeErr := mcpClient.Close(); closeErr != nil {
-			// Log error but don't override main error
-			fmt.Printf("Warning: failed to close client for %s: %v\n", serverConfig.Name, closeErr)
-		}
-	}()
-	
 	// Connect to server
 	if err := mcpClient.Connect(ctx); err != nil {
-		result.Error = fmt.Errorf("failed to connect: %w", err)
-		result.Duration = time.Since(start)
-		return result
+		closeClient(mcpClient)
+		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 	
 	// Initialize MCP protocol
-	_, err = mcpClient.Initialize(ctx)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to initialize: %w", err)
-		result.Duration = time.Since(start)
-		return result
+	if _, err := mcpClient.Initialize(ctx); err != nil {
+		closeClient(mcpClient)
+		return nil, fmt.Errorf("failed to initialize: %w", err)
 	}
 	
+	return mcpClient, nil
+}
+
+// release closes the client of a result once discovery is done with it.
+// Clients from a ConnectFunc belong to the caller and are left open.
+func (d *Discoverer) release(result *DiscoveryResult) {
+	if d.connect != nil || result.Client == nil {
+		return
+	}
+	closeClient(result.Client)
+	result.Client = nil
+}
+
+// closeClient closes a discovery client, logging rather than returning
+// any error
+func closeClient(mcpClient client.MCPClient) {
+	if err := mcpClient.Close(); err != nil {
+		fmt.Printf("Warning: failed to close client for %s: %v\n", mcpClient.ServerName(), err)
+	}
+}
+
+// listTools lists the tools of a connected client into result
+func (d *Discoverer) listTools(ctx context.Context, mcpClient client.MCPClient, serverConfig config.ServerConfig, result *DiscoveryResult) {
 	// List tools
 	toolInfos, err := mcpClient.ListTools(ctx)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to list tools: %w", err)
-		result.Duration = time.Since(start)
-		return result
+		return
 	}
 	
 	// Convert to prefixed tools
 	for _, toolInfo := range toolInfos {
-		remoteTool := CreatePrefixedTool(serverConfig.Name, serverConfig.Prefix, ToolInfo{
+		remoteTool := CreatePrefixedTool(serverConfig.Name, serverConfig.ToolPrefix(), ToolInfo{
 			Name:        toolInfo.Name,
 			Description: toolInfo.Description,
 			InputSchema: toolInfo.InputSchema,
 		})
+		remoteTool.PrefixedName = PrefixedToolName(serverConfig.ToolPrefixFor(toolInfo.Name), toolInfo.Name)
 		result.Tools = append(result.Tools, remoteTool)
 	}
-	
-	result.Duration = time.Since(start)
-	return result
 }
 
 // createStdioClient creates a stdio-based MCP client
@@ -131,6 +220,12 @@ func (d *Discoverer) createStdioClient(serverConfig config.ServerConfig) (client
 	inheritCfg := serverConfig.ResolveInheritConfig(d.config.Inherit)
 	stdioClient.SetInheritConfig(inheritCfg)
 
+	// Initialize is bounded by the connection timeout, tool calls by the server timeout
+	stdioClient.SetTimeouts(serverConfig.GetConnectionTimeout(d.config.Proxy.ConnectionTimeout), serverConfig.GetServerTimeout(d.config.Proxy.DefaultTimeout))
+	stdioClient.SetResourceLimits(serverConfig.Limits)
+	stdioClient.SetConnectTrace(d.config.Proxy.TraceConnect)
+	stdioClient.SetAllowAnyProtocol(d.config.Proxy.ProtocolMismatch == config.ProtocolMismatchWarn)
+
 	// Set environment variables if specified
 	if len(serverConfig.Env) > 0 {
 		var env []string
@@ -2,16 +2,52 @@ package discovery
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
+
+	"mcp-debug/client"
 )
 
+// ErrDependencyFailed is wrapped by DependencyError
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// DependencyError reports a server that was not started because a server it
+// dependsOn failed
+type DependencyError struct {
+	Dependency string
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("not started: %v: server %s did not connect", ErrDependencyFailed, e.Dependency)
+}
+
+func (e *DependencyError) Unwrap() error {
+	return ErrDependencyFailed
+}
+
+// ConnectError reports a server that the discoverer's ConnectFunc failed to
+// connect
+type ConnectError struct {
+	Err error
+}
+
+func (e *ConnectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
 // DiscoveryResult represents the result of discovering tools from a server
 type DiscoveryResult struct {
-	ServerName   string        `json:"serverName"`
-	ServerPrefix string        `json:"serverPrefix"`
-	Tools        []RemoteTool  `json:"tools"`
-	Error        error         `json:"error,omitempty"`
-	Duration     time.Duration `json:"duration"`
+	ServerName   string           `json:"serverName"`
+	ServerPrefix string           `json:"serverPrefix"`
+	Tools        []RemoteTool     `json:"tools"`
+	Error        error            `json:"error,omitempty"`
+	Duration     time.Duration    `json:"duration"`
+	Client       client.MCPClient `json:"-"` // Still connected, when the discoverer has a ConnectFunc
 }
 
 // RemoteTool represents a tool discovered from a remote server
@@ -34,9 +70,18 @@ func (r *DiscoveryResult) ToolCount() int {
 	return len(r.Tools)
 }
 
+// PrefixedToolName returns the exposed name of a tool. An empty prefix
+// (a noPrefix server) keeps the original name.
+func PrefixedToolName(serverPrefix, toolName string) string {
+	if serverPrefix == "" {
+		return toolName
+	}
+	return serverPrefix + "_" + toolName
+}
+
 // CreatePrefixedTool creates a RemoteTool with proper prefixing
 func CreatePrefixedTool(serverName, serverPrefix string, originalTool ToolInfo) RemoteTool {
-	prefixedName := serverPrefix + "_" + originalTool.Name
+	prefixedName := PrefixedToolName(serverPrefix, originalTool.Name)
 	
 	return RemoteTool{
 		OriginalName: originalTool.Name,
@@ -3,6 +3,7 @@ module mcp-debug
 go 1.24.2
 
 require (
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/mark3labs/mcp-go v0.43.2
 	github.com/metoro-io/mcp-golang v0.14.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -22,4 +23,5 @@ require (
 	github.com/tidwall/sjson v1.2.5 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+)
+
+// logLevels are the MCP logging levels, from most to least verbose
+var logLevels = []mcp.LoggingLevel{
+	mcp.LoggingLevelDebug, mcp.LoggingLevelInfo, mcp.LoggingLevelNotice, mcp.LoggingLevelWarning,
+	mcp.LoggingLevelError, mcp.LoggingLevelCritical, mcp.LoggingLevelAlert, mcp.LoggingLevelEmergency,
+}
+
+// validLogLevel reports whether level is an MCP logging level
+func validLogLevel(level string) bool {
+	for _, valid := range logLevels {
+		if string(valid) == level {
+			return true
+		}
+	}
+	return false
+}
+
+// setBackendLogLevels sends logging/setLevel to the named server, or to
+// every connected server when name is empty. It returns the servers the
+// level was set on and the error of each server that failed. With name
+// empty, servers without the logging capability are skipped silently.
+func (w *DynamicWrapper) setBackendLogLevels(ctx context.Context, name, level string) ([]string, map[string]error, error) {
+	w.mu.RLock()
+	setters := make(map[string]client.LogLevelSetter)
+	if name != "" {
+		serverInfo, err := w.lookupServer(name)
+		if err != nil {
+			w.mu.RUnlock()
+			return nil, nil, err
+		}
+		if !serverInfo.IsConnected {
+			w.mu.RUnlock()
+			return nil, nil, &ServerError{Server: name, Err: ErrServerDisconnected}
+		}
+		setter, ok := serverInfo.Client.(client.LogLevelSetter)
+		if !ok {
+			w.mu.RUnlock()
+			return nil, nil, fmt.Errorf("server '%s': %w", name, client.ErrLoggingUnsupported)
+		}
+		setters[name] = setter
+	} else {
+		for serverName, serverInfo := range w.dynamicServers {
+			if setter, ok := serverInfo.Client.(client.LogLevelSetter); ok && serverInfo.IsConnected {
+				setters[serverName] = setter
+			}
+		}
+	}
+	w.mu.RUnlock()
+
+	// Send outside the lock: each is a round-trip to the backend
+	var updated []string
+	failed := make(map[string]error)
+	for serverName, setter := range setters {
+		err := setter.SetLogLevel(ctx, level)
+		switch {
+		case err == nil:
+			updated = append(updated, serverName)
+		case name == "" && errors.Is(err, client.ErrLoggingUnsupported):
+		default:
+			failed[serverName] = err
+		}
+	}
+	sort.Strings(updated)
+	return updated, failed, nil
+}
+
+// forwardSetLevel passes a client's logging/setLevel on to all backends
+func (w *DynamicWrapper) forwardSetLevel(ctx context.Context, level string) {
+	updated, failed, _ := w.setBackendLogLevels(ctx, "", level)
+	for serverName, err := range failed {
+		log.Printf("Failed to set log level of server '%s' to %s: %v", serverName, level, err)
+	}
+	log.Printf("Client set log level %s; forwarded to %d servers", level, len(updated))
+}
+
+// relayLogMessage sends a backend's log message on to the client, with the
+// server name as the logger (or prefixed to the backend's own logger). It
+// runs on the client's stdout reader as each message arrives, so logs sent
+// between calls are relayed at once.
+func (w *DynamicWrapper) relayLogMessage(serverName string, params json.RawMessage) {
+	var message map[string]interface{}
+	if err := json.Unmarshal(params, &message); err != nil {
+		log.Printf("Ignoring malformed log message from server '%s': %v", serverName, err)
+		return
+	}
+	logger := serverName
+	if backendLogger, ok := message["logger"].(string); ok && backendLogger != "" {
+		logger = serverName + "/" + backendLogger
+	}
+	message["logger"] = logger
+
+	w.recordMessage("notification", "log_message", "", serverName, message)
+	w.baseServer.SendNotificationToAllClients(client.NotificationMessage, message)
+}
+
+func (w *DynamicWrapper) handleServerLogLevel(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_log_level", "proxy", request)
+
+	level, err := request.RequireString("level")
+	if err != nil || !validLogLevel(level) {
+		names := make([]string, len(logLevels))
+		for i, valid := range logLevels {
+			names[i] = string(valid)
+		}
+		result := mcp.NewToolResultError(fmt.Sprintf("level must be one of: %s", strings.Join(names, ", ")))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_log_level", "proxy", result)
+		return result, nil
+	}
+	name := request.GetString("name", "")
+
+	updated, failed, err := w.setBackendLogLevels(ctx, name, level)
+	if err == nil && name != "" && failed[name] != nil {
+		err = failed[name]
+	}
+	if err != nil {
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to set log level: %v", err))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_log_level", "proxy", result)
+		return result, nil
+	}
+
+	var result strings.Builder
+	if len(updated) == 0 && len(failed) == 0 {
+		result.WriteString(fmt.Sprintf("No connected server supports logging; level %s not set.\n", level))
+	} else if len(updated) > 0 {
+		result.WriteString(fmt.Sprintf("Set log level %s on: %s\n", level, strings.Join(updated, ", ")))
+	}
+	var failedNames []string
+	for serverName := range failed {
+		failedNames = append(failedNames, serverName)
+	}
+	for _, serverName := range sortedStrings(failedNames) {
+		result.WriteString(fmt.Sprintf("Failed on %s: %v\n", serverName, failed[serverName]))
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_log_level", "proxy", toolResult)
+	return toolResult, nil
+}
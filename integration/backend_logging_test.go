@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestServerLogLevelTool(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	result := callTool(t, w.handleServerLogLevel, map[string]interface{}{"name": "fs", "level": "warning"})
+	if result.IsError || !strings.Contains(resultText(result), "Set log level warning on: fs") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+	if fake.LogLevel() != "warning" {
+		t.Errorf("expected the backend level to be warning, got %q", fake.LogLevel())
+	}
+
+	if result := callTool(t, w.handleServerLogLevel, map[string]interface{}{"level": "verbose"}); !result.IsError {
+		t.Error("expected an error for an invalid level")
+	}
+	if result := callTool(t, w.handleServerLogLevel, map[string]interface{}{"name": "missing", "level": "debug"}); !result.IsError {
+		t.Error("expected an error for an unknown server")
+	}
+}
+
+func TestSetLevelForwardedAndLogsRelayed(t *testing.T) {
+	w, c := startTestProxy(t, &config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	received := make(chan mcp.JSONRPCNotification, 1)
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == client.NotificationMessage {
+			received <- notification
+		}
+	})
+
+	request := mcp.SetLevelRequest{}
+	request.Params.Level = mcp.LoggingLevelError
+	if err := c.SetLevel(context.Background(), request); err != nil {
+		t.Fatalf("setLevel: %v", err)
+	}
+	if fake.LogLevel() != "error" {
+		t.Errorf("expected setLevel to reach the backend, got %q", fake.LogLevel())
+	}
+
+	fake.Notify(client.NotificationMessage, json.RawMessage(`{"level":"error","logger":"db","data":"disk full"}`))
+	select {
+	case notification := <-received:
+		fields := notification.Params.AdditionalFields
+		if fields["logger"] != "fs/db" || fields["data"] != "disk full" {
+			t.Errorf("unexpected relayed log message: %+v", fields)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("log message was not relayed to the client")
+	}
+}
+
+func TestLogsRelayedBetweenCalls(t *testing.T) {
+	backend := testBackendConfig("backend")
+	backend.Env[testBackendLogEnv] = "idle backend log"
+	_, c := startTestProxy(t, &config.ProxyConfig{Servers: []config.ServerConfig{backend}})
+
+	received := make(chan mcp.JSONRPCNotification, 1)
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == client.NotificationMessage {
+			select {
+			case received <- notification:
+			default:
+			}
+		}
+	})
+
+	// No tool is called: the backend's log must still reach the client
+	select {
+	case notification := <-received:
+		fields := notification.Params.AdditionalFields
+		if fields["logger"] != "backend" || fields["data"] != "idle backend log" {
+			t.Errorf("unexpected relayed log message: %+v", fields)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("log message sent between calls was not relayed")
+	}
+}
@@ -0,0 +1,234 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// compositeOutcome is the result of one call made by a composite tool
+type compositeOutcome struct {
+	Call   config.CompositeCall
+	Result *mcp.CallToolResult
+}
+
+// registerCompositeTools registers the tools defined under compositeTools,
+// replacing those registered before. One whose name is already taken by a
+// backend tool is skipped.
+func (w *DynamicWrapper) registerCompositeTools() {
+	w.proxyServer.mu.RLock()
+	var stale []string
+	for _, name := range w.composites {
+		// A backend tool registered since then has replaced it already
+		if _, exists := w.proxyServer.registry.GetTool(name); !exists {
+			stale = append(stale, name)
+		}
+	}
+	composites := w.proxyServer.config.CompositeTools
+	w.proxyServer.mu.RUnlock()
+	if len(stale) > 0 {
+		w.baseServer.DeleteTools(stale...)
+	}
+	w.composites = nil
+
+	for _, composite := range composites {
+		w.proxyServer.mu.RLock()
+		_, exists := w.proxyServer.registry.GetTool(composite.Name)
+		tool := w.compositeMCPTool(composite)
+		w.proxyServer.mu.RUnlock()
+		if exists {
+			log.Printf("Warning: composite tool %s skipped: a backend tool is already registered under that name", composite.Name)
+			continue
+		}
+		w.baseServer.AddTool(tool, w.createCompositeHandler(composite))
+		w.composites = append(w.composites, composite.Name)
+		log.Printf("Registered composite tool %s calling %d tools", composite.Name, len(composite.Calls))
+	}
+}
+
+// compositeMCPTool builds the tool definition of a composite tool. It takes
+// the input schema of the first call's tool, when that tool is registered,
+// as the composite tool's arguments are passed on to every call. The caller
+// holds proxyServer.mu.
+func (w *DynamicWrapper) compositeMCPTool(composite config.CompositeTool) mcp.Tool {
+	description := composite.Description
+	if description == "" {
+		targets := make([]string, len(composite.Calls))
+		for i, call := range composite.Calls {
+			targets[i] = fmt.Sprintf("%s on %s", call.Tool, call.Server)
+		}
+		description = fmt.Sprintf("Calls %s and merges their results", strings.Join(targets, ", "))
+	}
+
+	first := composite.Calls[0]
+	for _, tool := range w.proxyServer.registry.GetServerTools(first.Server) {
+		if tool.OriginalName == first.Tool && len(tool.InputSchema) > 0 {
+			return mcp.NewToolWithRawSchema(composite.Name, description, tool.InputSchema)
+		}
+	}
+	return mcp.NewTool(composite.Name, mcp.WithDescription(description))
+}
+
+// compositeTarget returns the backend tool a composite tool's call goes
+// to: the registered tool, or, if the proxy didn't register it, the tool
+// under the name it would have had
+func (w *DynamicWrapper) compositeTarget(call config.CompositeCall) discovery.RemoteTool {
+	w.proxyServer.mu.RLock()
+	defer w.proxyServer.mu.RUnlock()
+
+	for _, tool := range w.proxyServer.registry.GetServerTools(call.Server) {
+		if tool.OriginalName == call.Tool {
+			return tool
+		}
+	}
+	target := discovery.RemoteTool{OriginalName: call.Tool, PrefixedName: call.Tool, ServerName: call.Server}
+	for _, serverConfig := range w.proxyServer.config.Servers {
+		if serverConfig.Name == call.Server {
+			target.ServerPrefix = serverConfig.ToolPrefix()
+			target.PrefixedName = discovery.PrefixedToolName(serverConfig.ToolPrefixFor(call.Tool), call.Tool)
+		}
+	}
+	return target
+}
+
+// createCompositeHandler returns the handler of a composite tool. It makes
+// every call concurrently, each bounded by its server's timeout and all by
+// the composite tool's timeout, and merges whatever results arrive. Calls
+// still running when the timeout expires are reported as failed. Each call
+// goes through its tool's arguments, sanitizer rules, rate limit and
+// transforms, as if the client had made it.
+func (w *DynamicWrapper) createCompositeHandler(composite config.CompositeTool) server.ToolHandlerFunc {
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		w.recordMessage("request", "tool_call", composite.Name, "proxy", request)
+		start := time.Now()
+
+		if timeout := composite.GetTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		type indexedResult struct {
+			index  int
+			result *mcp.CallToolResult
+		}
+		results := make(chan indexedResult, len(composite.Calls))
+		for i, call := range composite.Calls {
+			args := make(map[string]interface{})
+			for key, value := range request.GetArguments() {
+				args[key] = value
+			}
+			for key, value := range call.Arguments {
+				args[key] = value
+			}
+			go func() {
+				results <- indexedResult{i, w.callProxiedTool(ctx, w.compositeTarget(call), args).Result}
+			}()
+		}
+
+		outcomes := make([]compositeOutcome, len(composite.Calls))
+		for i, call := range composite.Calls {
+			outcomes[i].Call = call
+		}
+	collect:
+		for received := 0; received < len(composite.Calls); received++ {
+			select {
+			case r := <-results:
+				outcomes[r.index].Result = r.result
+			case <-ctx.Done():
+				break collect
+			}
+		}
+		for i, outcome := range outcomes {
+			if outcome.Result == nil {
+				outcomes[i].Result = mcp.NewToolResultError(fmt.Sprintf("[%s] no result within the composite tool's timeout: %v", outcome.Call.Server, ctx.Err()))
+			}
+		}
+
+		result := mergeCompositeResults(composite, outcomes)
+		result = w.addRecordingMetadata(result)
+		w.recordToolResponse(composite.Name, "", "proxy", result, start)
+		return result, nil
+	}
+
+	return w.withMiddleware(handler)
+}
+
+// mergeCompositeResults combines the outcomes of a composite tool's calls
+// as configured by its merge setting. The merged result is an error if
+// every call failed, or if any did and requireAll is set.
+func mergeCompositeResults(composite config.CompositeTool, outcomes []compositeOutcome) *mcp.CallToolResult {
+	var failures []string
+	for _, outcome := range outcomes {
+		if outcome.Result.IsError {
+			failures = append(failures, fmt.Sprintf("%s on %s: %s", outcome.Call.Tool, outcome.Call.Server, contentText(outcome.Result)))
+		}
+	}
+	if len(failures) == len(outcomes) || (composite.RequireAll && len(failures) > 0) {
+		return mcp.NewToolResultError(fmt.Sprintf("%s: %d of %d calls failed:\n%s",
+			composite.Name, len(failures), len(outcomes), strings.Join(failures, "\n")))
+	}
+
+	switch composite.Merge {
+	case config.CompositeMergeFirst:
+		for _, outcome := range outcomes {
+			if !outcome.Result.IsError {
+				return outcome.Result
+			}
+		}
+	case config.CompositeMergeJSON:
+		type entry struct {
+			Server string `json:"server"`
+			Tool   string `json:"tool"`
+			Result string `json:"result,omitempty"`
+			Error  string `json:"error,omitempty"`
+		}
+		entries := make([]entry, len(outcomes))
+		for i, outcome := range outcomes {
+			entries[i] = entry{Server: outcome.Call.Server, Tool: outcome.Call.Tool}
+			if outcome.Result.IsError {
+				entries[i].Error = contentText(outcome.Result)
+			} else {
+				entries[i].Result = contentText(outcome.Result)
+			}
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("%s: failed to encode results: %v", composite.Name, err))
+		}
+		return mcp.NewToolResultText(string(data))
+	}
+
+	merged := &mcp.CallToolResult{}
+	for _, outcome := range outcomes {
+		if outcome.Result.IsError {
+			continue
+		}
+		merged.Content = append(merged.Content, mcp.NewTextContent(fmt.Sprintf("[%s] %s:", outcome.Call.Server, outcome.Call.Tool)))
+		merged.Content = append(merged.Content, outcome.Result.Content...)
+	}
+	if len(failures) > 0 {
+		merged.Content = append(merged.Content, mcp.NewTextContent("Failed calls:\n"+strings.Join(failures, "\n")))
+	}
+	return merged
+}
+
+// contentText joins the text items of a result
+func contentText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,203 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+// newCompositeTestWrapper returns a wrapper with two connected servers,
+// web and docs, whose search tools echo their query
+func newCompositeTestWrapper(t *testing.T) (*DynamicWrapper, *client.FakeClient, *client.FakeClient) {
+	t.Helper()
+
+	echo := func(server string) func(string, map[string]interface{}) (*client.CallToolResult, error) {
+		return func(name string, args map[string]interface{}) (*client.CallToolResult, error) {
+			text := server + " results for " + args["query"].(string)
+			return &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: text}}}, nil
+		}
+	}
+	web := client.NewFakeClient("web")
+	web.SetCallToolFunc(echo("web"))
+	docs := client.NewFakeClient("docs")
+	docs.SetCallToolFunc(echo("docs"))
+
+	w := newTestWrapper(t, "web", web)
+	if err := docs.Connect(t.Context()); err != nil {
+		t.Fatalf("connect docs: %v", err)
+	}
+	w.dynamicServers["docs"] = &DynamicServerInfo{
+		Name:        "docs",
+		Client:      docs,
+		Config:      config.ServerConfig{Name: "docs", Prefix: "docs", Transport: "stdio"},
+		IsConnected: true,
+	}
+	return w, web, docs
+}
+
+func TestCompositeToolMerge(t *testing.T) {
+	w, _, docs := newCompositeTestWrapper(t)
+	calls := []config.CompositeCall{
+		{Server: "web", Tool: "search"},
+		{Server: "docs", Tool: "search", Arguments: map[string]interface{}{"limit": 5}},
+	}
+	args := map[string]interface{}{"query": "proxy"}
+
+	t.Run("concat", func(t *testing.T) {
+		handler := w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls})
+		result := callTool(t, handler, args)
+		if result.IsError || len(result.Content) != 4 {
+			t.Fatalf("expected a header and result per call, got %#v", result)
+		}
+		if got := contentText(result); got != "[web] search:\nweb results for proxy\n[docs] search:\ndocs results for proxy" {
+			t.Errorf("unexpected merged text %q", got)
+		}
+		docsCalls := docs.Calls()
+		if last := docsCalls[len(docsCalls)-1]; last.Args["query"] != "proxy" || last.Args["limit"] != 5 {
+			t.Errorf("expected the call's arguments merged over the tool's, got %+v", last.Args)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		handler := w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls, Merge: config.CompositeMergeJSON})
+		var entries []map[string]string
+		if err := json.Unmarshal([]byte(resultText(callTool(t, handler, args))), &entries); err != nil {
+			t.Fatalf("expected a JSON array: %v", err)
+		}
+		if len(entries) != 2 || entries[1]["server"] != "docs" || entries[1]["result"] != "docs results for proxy" {
+			t.Errorf("unexpected entries %v", entries)
+		}
+	})
+
+	t.Run("first", func(t *testing.T) {
+		handler := w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls, Merge: config.CompositeMergeFirst})
+		if got := resultText(callTool(t, handler, args)); got != "web results for proxy" {
+			t.Errorf("expected the first call's result, got %q", got)
+		}
+	})
+}
+
+func TestCompositeToolPartialFailure(t *testing.T) {
+	w, web, _ := newCompositeTestWrapper(t)
+	web.SetCallToolFunc(func(name string, args map[string]interface{}) (*client.CallToolResult, error) {
+		return nil, errors.New("index unavailable")
+	})
+	calls := []config.CompositeCall{{Server: "web", Tool: "search"}, {Server: "docs", Tool: "search"}}
+	args := map[string]interface{}{"query": "proxy"}
+
+	result := callTool(t, w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls}), args)
+	if result.IsError || !strings.Contains(contentText(result), "docs results for proxy") {
+		t.Fatalf("expected the successful call's result, got %#v", result)
+	}
+	if !strings.Contains(contentText(result), "search on web: [web] index unavailable") {
+		t.Errorf("expected the failed call to be listed, got %q", contentText(result))
+	}
+
+	result = callTool(t, w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls, RequireAll: true}), args)
+	if !result.IsError || !strings.Contains(resultText(result), "1 of 2 calls failed") {
+		t.Errorf("expected requireAll to fail the tool, got %#v", result)
+	}
+
+	result = callTool(t, w.createCompositeHandler(config.CompositeTool{Name: "search_all", Calls: calls[:1], Merge: config.CompositeMergeFirst}), args)
+	if !result.IsError {
+		t.Errorf("expected an error when every call fails, got %#v", result)
+	}
+}
+
+func TestCompositeToolTimeout(t *testing.T) {
+	w, web, _ := newCompositeTestWrapper(t)
+	web.SetCallToolFunc(func(name string, args map[string]interface{}) (*client.CallToolResult, error) {
+		time.Sleep(500 * time.Millisecond)
+		return &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "late"}}}, nil
+	})
+
+	handler := w.createCompositeHandler(config.CompositeTool{
+		Name:    "search_all",
+		Calls:   []config.CompositeCall{{Server: "web", Tool: "search"}, {Server: "docs", Tool: "search"}},
+		Timeout: "50ms",
+	})
+	start := time.Now()
+	result := callTool(t, handler, map[string]interface{}{"query": "proxy"})
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("expected the timeout to bound the call, took %v", elapsed)
+	}
+	text := contentText(result)
+	if result.IsError || !strings.Contains(text, "docs results for proxy") || !strings.Contains(text, "no result within the composite tool's timeout") {
+		t.Errorf("expected the docs result and a timeout for web, got %q", text)
+	}
+}
+
+func TestCompositeToolAppliesToolSettings(t *testing.T) {
+	w, _, docs := newCompositeTestWrapper(t)
+	docsConfig := &w.dynamicServers["docs"].Config
+	docsConfig.DefaultArguments = config.ToolArguments{"search": {"limit": 10, "index": "public"}}
+	docsConfig.HiddenArguments = config.ToolArgumentNames{"search": {"index"}}
+	docsConfig.Transforms = config.ToolTransforms{"search": {{Op: config.TransformTruncate, Max: 4}}}
+
+	handler := w.createCompositeHandler(config.CompositeTool{
+		Name:  "search_all",
+		Calls: []config.CompositeCall{{Server: "docs", Tool: "search"}},
+		Merge: config.CompositeMergeFirst,
+	})
+	result := callTool(t, handler, map[string]interface{}{"query": "proxy", "index": "private"})
+
+	docsCalls := docs.Calls()
+	last := docsCalls[len(docsCalls)-1]
+	if last.Args["limit"] != 10 || last.Args["index"] != "public" {
+		t.Errorf("expected the tool's default and hidden arguments, got %+v", last.Args)
+	}
+	if got := resultText(result); !strings.HasPrefix(got, "docs") || strings.Contains(got, "results") {
+		t.Errorf("expected the tool's transforms applied, got %q", got)
+	}
+}
+
+func TestReloadRegistersCompositeTools(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "search"})
+	})
+	servers := []config.ServerConfig{{Name: "web", Prefix: "web", Transport: "stdio", Command: "fake-server"}}
+	search := func(name string) config.CompositeTool {
+		return config.CompositeTool{Name: name, Calls: []config.CompositeCall{{Server: "web", Tool: "search"}}}
+	}
+
+	cfg := &config.ProxyConfig{Servers: servers, CompositeTools: []config.CompositeTool{search("search_all")}}
+	if _, err := w.Reload(t.Context(), cfg); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if w.baseServer.GetTool("search_all") == nil {
+		t.Fatal("expected the composite tool to be registered on reload")
+	}
+
+	cfg = &config.ProxyConfig{Servers: servers, CompositeTools: []config.CompositeTool{search("find_all"), search("web_search")}}
+	if _, err := w.Reload(t.Context(), cfg); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if w.baseServer.GetTool("search_all") != nil || w.baseServer.GetTool("find_all") == nil {
+		t.Error("expected the removed composite tool to be replaced")
+	}
+	if strings.Join(w.composites, ",") != "find_all" || w.baseServer.GetTool("web_search") == nil {
+		t.Errorf("expected web_search skipped as a backend tool's name, got %v", w.composites)
+	}
+}
+
+func TestRegisterCompositeTools(t *testing.T) {
+	w, _, _ := newCompositeTestWrapper(t)
+	w.proxyServer.config.CompositeTools = []config.CompositeTool{
+		{Name: "search_all", Calls: []config.CompositeCall{{Server: "web", Tool: "search"}}},
+	}
+	w.registerCompositeTools()
+
+	tool := w.baseServer.GetTool("search_all")
+	if tool == nil {
+		t.Fatal("expected the composite tool to be registered")
+	}
+	if tool.Tool.Description != "Calls search on web and merges their results" {
+		t.Errorf("unexpected default description %q", tool.Tool.Description)
+	}
+}
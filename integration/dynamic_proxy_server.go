@@ -11,6 +11,7 @@ import (
 	"github.com/metoro-io/mcp-golang/transport/stdio"
 	"mcp-debug/client"
 	"mcp-debug/config"
+	"mcp-debug/discovery"
 )
 
 // DiscoveredTool represents a tool discovered from a remote server
@@ -36,8 +37,8 @@ func NewDynamicProxyServer(cfg *config.ProxyConfig) *DynamicProxyServer {
 	// Create MCP server with stdio transport
 	mcpServer := mcp_golang.NewServer(
 		stdio.NewStdioServerTransport(),
-		mcp_golang.WithName("Dynamic MCP Proxy"),
-		mcp_golang.WithVersion("1.0.0"),
+		mcp_golang.WithName(cfg.Proxy.ServerName()),
+		mcp_golang.WithVersion(cfg.Proxy.ServerVersion()),
 		mcp_golang.WithInstructions("Dynamic MCP proxy that can connect to multiple MCP servers and expose their tools with prefixes"),
 	)
 
@@ -81,7 +82,7 @@ func (p *DynamicProxyServer) ConnectToServer(ctx context.Context, serverConfig c
 	for _, tool := range tools {
 		discoveredTool := &DiscoveredTool{
 			OriginalName:  tool.Name,
-			PrefixedName:  fmt.Sprintf("%s_%s", serverConfig.Prefix, tool.Name),
+			PrefixedName:  discovery.PrefixedToolName(serverConfig.ToolPrefixFor(tool.Name), tool.Name),
 			Description:   tool.Description,
 			ServerName:    serverName,
 		}
@@ -206,6 +207,11 @@ func (p *DynamicProxyServer) handleServerAdd(args interface{}) (*mcp_golang.Tool
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments")
 	}
+	name, err := normalizeServerName(addArgs.Name)
+	if err != nil {
+		return nil, err
+	}
+	addArgs.Name = name
 	
 	// Check if server already exists
 	p.mu.RLock()
@@ -224,9 +230,9 @@ func (p *DynamicProxyServer) handleServerAdd(args interface{}) (*mcp_golang.Tool
 	// Parse based on what was provided
 	if addArgs.Command != "" {
 		// Parse command into command and args
-		parts := strings.Fields(addArgs.Command)
-		if len(parts) == 0 {
-			return nil, fmt.Errorf("invalid command")
+		parts, err := parseCommand(addArgs.Command)
+		if err != nil {
+			return nil, err
 		}
 		serverConfig.Transport = "stdio"
 		serverConfig.Command = parts[0]
@@ -334,6 +340,12 @@ func (p *DynamicProxyServer) createAndConnectClient(ctx context.Context, serverC
 		inheritCfg := serverConfig.ResolveInheritConfig(p.config.Inherit)
 		stdioClient.SetInheritConfig(inheritCfg)
 
+		// Initialize is bounded by the connection timeout, tool calls by the server timeout
+		stdioClient.SetTimeouts(serverConfig.GetConnectionTimeout(p.config.Proxy.ConnectionTimeout), serverConfig.GetServerTimeout(p.config.Proxy.DefaultTimeout))
+		stdioClient.SetResourceLimits(serverConfig.Limits)
+		stdioClient.SetConnectTrace(p.config.Proxy.TraceConnect)
+		stdioClient.SetAllowAnyProtocol(p.config.Proxy.ProtocolMismatch == config.ProtocolMismatchWarn)
+
 		if serverConfig.Env != nil {
 			// Convert map[string]string to []string
 			envSlice := make([]string, 0, len(serverConfig.Env))
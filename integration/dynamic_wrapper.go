@@ -1,14 +1,23 @@
 package integration
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -17,22 +26,58 @@ import (
 	"mcp-debug/client"
 	"mcp-debug/config"
 	"mcp-debug/discovery"
+	"mcp-debug/proxy"
 )
 
 // DynamicWrapper provides dynamic server management for mark3labs/mcp-go
 type DynamicWrapper struct {
-	baseServer    *server.MCPServer
-	proxyServer   *ProxyServer
+	baseServer     *server.MCPServer
+	proxyServer    *ProxyServer
 	dynamicServers map[string]*DynamicServerInfo
-	mu            sync.RWMutex
-	
+	mu             sync.RWMutex
+	spawnMu        sync.RWMutex     // Held for reading while an idle server starts outside mu, and for writing to replace the config
+	middleware     []ToolMiddleware // Applied to proxied tool calls, outermost first
+	clientFactory  ClientFactory    // Creates clients for server_add/server_reconnect
+	summaryFormat  SummaryFormat    // How Initialize logs the startup summary
+	rateLimiter    *rateLimiter     // Per-tool token buckets for servers with a rateLimit
+	load           *loadTracker     // In-flight, queued and recent call counters for proxy_load
+	latency        *latencyStats    // Per-tool latency histograms for server_latency
+	composites     []string         // Names of the registered composite tools
+
+	// Disconnects servers that exceed their idleTimeout
+	idleCheckInterval time.Duration
+	stopIdle          chan struct{}
+
+	// Re-discovery on tools/list_changed, at most once per interval per server
+	rediscoverMu       sync.Mutex
+	rediscovery        map[string]*rediscoveryState
+	rediscoverInterval time.Duration
+
 	// Recording functionality
-	recordFile     *os.File
-	recordEnabled  bool
-	recordMu       sync.Mutex
-	recordFilename string // Path to the recording file (for metadata)
+	recordFile          *os.File
+	recordBuffer        *bufio.Writer // Buffers writes unless the flush policy is FlushAlways
+	recordStopFlush     chan struct{} // Stops the FlushInterval background flusher
+	recordEnabled       bool
+	recordMu            sync.Mutex
+	recordFilename      string // Path to the recording file (for metadata)
+	recordMaxBytes      int    // Max serialized size per recorded message (0 = unlimited)
+	recordFlush         FlushPolicy
+	recordFlushInterval time.Duration
+	recordStart         time.Time
+	recordCount         int   // Messages written to the current recording
+	recordOffset        int64 // Bytes written to the current recording, for its index
+	recordLine          int   // Lines written to the current recording, for its index
+	recordIndexFile     *os.File
+	recordIndex         *bufio.Writer // Writes the recording's index with record.index
+
+	tap *traceTap // Streams recorded messages to connected clients; guarded by recordMu
+
+	transcriptMu sync.Mutex // Serializes writes to server transcripts
 }
 
+// ClientFactory creates an unconnected client for a server configuration
+type ClientFactory func(serverConfig config.ServerConfig) client.MCPClient
+
 type DynamicServerInfo struct {
 	Name         string
 	Client       client.MCPClient
@@ -40,47 +85,97 @@ type DynamicServerInfo struct {
 	Config       config.ServerConfig
 	IsConnected  bool
 	ErrorMessage string
+	LastCall     time.Time // Start or end of the latest tool call, for idleTimeout
+	Idle         bool      // Disconnected by idleTimeout; the next tool call reconnects
+	EnvSnapshot  []string  // Environment of the first launch, reused on reconnect with proxy.snapshotEnv
+
+	waking *idleWake // Idle reconnect in progress, if any; guarded by the wrapper's mu
 }
 
 // RecordedMessage represents a JSON-RPC message with metadata
 type RecordedMessage struct {
-	Timestamp   time.Time       `json:"timestamp"`
-	Direction   string          `json:"direction"` // "request" or "response"
-	MessageType string          `json:"message_type"` // "tool_call", "initialize", etc.
-	ToolName    string          `json:"tool_name,omitempty"`
-	ServerName  string          `json:"server_name,omitempty"`
-	Message     json.RawMessage `json:"message"`
+	Timestamp        time.Time       `json:"timestamp"`
+	Direction        string          `json:"direction"`    // "request" or "response"
+	MessageType      string          `json:"message_type"` // "tool_call", "initialize", etc.
+	ToolName         string          `json:"tool_name,omitempty"`
+	OriginalToolName string          `json:"original_tool_name,omitempty"` // The backend's name for the tool, with record.originalToolNames
+	ServerName       string          `json:"server_name,omitempty"`
+	Message          json.RawMessage `json:"message"`
+	DurationMs       float64         `json:"duration_ms,omitempty"`            // Responses only: time since the matching request
+	Blocked          bool            `json:"blocked,omitempty"`                // Responses only: the call was blocked by a sanitizer rule
+	Untransformed    json.RawMessage `json:"untransformed,omitempty"`          // Responses only: the result before the tool's transforms
+	ClientTruncated  int             `json:"client_truncated_bytes,omitempty"` // Responses only: bytes of the result cut from the client's copy per maxResultBytes
+}
+
+// TruncatedMessage is recorded in place of a message exceeding the size limit
+type TruncatedMessage struct {
+	Truncated    bool `json:"_truncated"`
+	OriginalSize int  `json:"original_size"`
+}
+
+// HandshakeError is recorded as the initialize response when a backend's
+// initialize handshake fails
+type HandshakeError struct {
+	Error string `json:"error"`
 }
 
 // RecordingSession represents a complete recording session
 type RecordingSession struct {
-	StartTime   time.Time         `json:"start_time"`
-	ServerInfo  string            `json:"server_info"`
-	Messages    []RecordedMessage `json:"messages"`
+	StartTime  time.Time         `json:"start_time"`
+	ServerInfo string            `json:"server_info"`
+	Messages   []RecordedMessage `json:"messages"`
+}
+
+// WriteRecordingHeader writes the comment lines and session header that
+// start a recording file
+func WriteRecordingHeader(w io.Writer, session RecordingSession) error {
+	headerBytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# MCP Recording Session\n# Started: %s\n%s\n",
+		session.StartTime.Format(time.RFC3339), string(headerBytes))
+	return err
 }
 
 // NewDynamicWrapper creates a wrapper that adds dynamic capabilities
 func NewDynamicWrapper(cfg *config.ProxyConfig) *DynamicWrapper {
-	// Create base MCP server with management tools
+	// Create base MCP server with management tools. A client's
+	// logging/setLevel is passed on to the backends.
+	wrapper := &DynamicWrapper{}
+	hooks := &server.Hooks{}
+	hooks.AddAfterSetLevel(func(ctx context.Context, id any, request *mcp.SetLevelRequest, result *mcp.EmptyResult) {
+		wrapper.forwardSetLevel(ctx, string(request.Params.Level))
+	})
 	baseServer := server.NewMCPServer(
-		"Dynamic MCP Proxy",
-		"1.0.0",
+		cfg.Proxy.ServerName(),
+		cfg.Proxy.ServerVersion(),
 		server.WithToolCapabilities(true),
+		server.WithLogging(),
+		server.WithHooks(hooks),
 	)
-	
+
 	// Create proxy server
 	proxyServer := NewProxyServer(cfg)
 	proxyServer.mcpServer = baseServer
-	
-	wrapper := &DynamicWrapper{
-		baseServer:     baseServer,
-		proxyServer:    proxyServer,
-		dynamicServers: make(map[string]*DynamicServerInfo),
+
+	*wrapper = DynamicWrapper{
+		baseServer:         baseServer,
+		proxyServer:        proxyServer,
+		dynamicServers:     make(map[string]*DynamicServerInfo),
+		rediscovery:        make(map[string]*rediscoveryState),
+		rediscoverInterval: defaultRediscoverInterval,
+		summaryFormat:      SummaryText,
+		rateLimiter:        newRateLimiter(),
+		load:               newLoadTracker(),
+		latency:            newLatencyStats(),
+		idleCheckInterval:  defaultIdleCheckInterval,
 	}
-	
+	wrapper.clientFactory = wrapper.newStdioClient
+
 	// Register management tools
 	wrapper.registerManagementTools()
-	
+
 	return wrapper
 }
 
@@ -89,30 +184,50 @@ func (w *DynamicWrapper) EnableRecording(filename string) error {
 	w.recordMu.Lock()
 	defer w.recordMu.Unlock()
 
-
 	if w.recordEnabled {
-		return fmt.Errorf("recording already enabled")
+		return ErrRecordingActive
 	}
-	
+
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create recording file: %w", err)
 	}
 
+	// Write session header straight to the file so a file that can't be
+	// written is reported here rather than on the first buffered flush
+	start := time.Now()
+	session := RecordingSession{
+		StartTime:  start,
+		ServerInfo: fmt.Sprintf("%s v%s", w.proxyServer.config.Proxy.ServerName(), w.proxyServer.config.Proxy.ServerVersion()),
+		Messages:   []RecordedMessage{},
+	}
+	var header bytes.Buffer
+	if err := WriteRecordingHeader(&header, session); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write recording header: %w", err)
+	}
+	if _, err := file.Write(header.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write recording header: %w", err)
+	}
+
 	w.recordFile = file
 	w.recordFilename = filename
 	w.recordEnabled = true
+	w.recordStart = start
+	w.recordCount = 0
 
-	// Write session header
-	session := RecordingSession{
-		StartTime:  time.Now(),
-		ServerInfo: "Dynamic MCP Proxy v1.0.0",
-		Messages:   []RecordedMessage{},
+	if w.recordFlush == FlushInterval || w.recordFlush == FlushOnClose {
+		w.recordBuffer = bufio.NewWriter(file)
+	}
+	if w.recordFlush == FlushInterval {
+		w.recordStopFlush = make(chan struct{})
+		go w.runRecordFlusher(w.recordFlushInterval, w.recordStopFlush)
 	}
 
-	headerBytes, _ := json.Marshal(session)
-	fmt.Fprintf(file, "# MCP Recording Session\n# Started: %s\n%s\n",
-		session.StartTime.Format(time.RFC3339), string(headerBytes))
+	w.recordOffset = int64(header.Len())
+	w.recordLine = bytes.Count(header.Bytes(), []byte("\n"))
+	w.openRecordingIndex(filename)
 
 	// Inject recorder and metadata function into proxy server for static server recording
 	w.proxyServer.recorderFunc = w.recordMessage
@@ -122,51 +237,252 @@ func (w *DynamicWrapper) EnableRecording(filename string) error {
 	return nil
 }
 
+// SetClientFactory overrides how clients are created for server_add and
+// server_reconnect, e.g. to use client.FakeClient in tests
+func (w *DynamicWrapper) SetClientFactory(factory ClientFactory) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clientFactory = factory
+}
+
+// newStdioClient is the default ClientFactory
+func (w *DynamicWrapper) newStdioClient(serverConfig config.ServerConfig) client.MCPClient {
+	stdioClient := client.NewStdioClient(serverConfig.Name, serverConfig.Command, serverConfig.Args)
+
+	// Apply inheritance config (server override or proxy defaults)
+	inheritCfg := serverConfig.ResolveInheritConfig(w.proxyServer.config.Inherit)
+	stdioClient.SetInheritConfig(inheritCfg)
+
+	// Initialize is bounded by the connection timeout, tool calls by the server timeout
+	stdioClient.SetTimeouts(serverConfig.GetConnectionTimeout(w.proxyServer.config.Proxy.ConnectionTimeout), serverConfig.GetServerTimeout(w.proxyServer.config.Proxy.DefaultTimeout))
+	stdioClient.SetResourceLimits(serverConfig.Limits)
+	stdioClient.SetConnectTrace(w.proxyServer.config.Proxy.TraceConnect)
+	stdioClient.SetAllowAnyProtocol(w.proxyServer.config.Proxy.ProtocolMismatch == config.ProtocolMismatchWarn)
+
+	// Apply environment variables from the ServerConfig
+	if len(serverConfig.Env) > 0 {
+		var env []string
+		for key, value := range serverConfig.Env {
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
+		}
+		stdioClient.SetEnvironment(env)
+	}
+	return stdioClient
+}
+
+// DisableRecording stops recording and closes the recording file.
+// Recording can be enabled again afterwards with EnableRecording.
+func (w *DynamicWrapper) DisableRecording() error {
+	w.recordMu.Lock()
+	defer w.recordMu.Unlock()
+
+	if !w.recordEnabled {
+		return ErrRecordingInactive
+	}
+
+	w.recordEnabled = false
+	if w.recordStopFlush != nil {
+		close(w.recordStopFlush)
+		w.recordStopFlush = nil
+	}
+	if err := w.flushRecording(); err != nil {
+		log.Printf("Failed to flush recording file: %v", err)
+	}
+	w.closeRecordingIndex()
+	if err := w.recordFile.Close(); err != nil {
+		return fmt.Errorf("failed to close recording file: %w", err)
+	}
+	w.recordFile = nil
+	w.recordBuffer = nil
+
+	log.Printf("Recording stopped: %s (%d messages)", w.recordFilename, w.recordCount)
+	return nil
+}
+
+// ToggleRecording stops an active recording, or starts a new one in a fresh
+// timestamped file next to the previous recording
+func (w *DynamicWrapper) ToggleRecording() error {
+	if w.GetRecordingStatus().Enabled {
+		return w.DisableRecording()
+	}
+	return w.EnableRecording(w.nextRecordingFilename())
+}
+
+// RecordingStatus describes the current recording state
+type RecordingStatus struct {
+	Enabled   bool
+	Filename  string // Most recent recording file, even after it was stopped
+	StartTime time.Time
+	Messages  int
+}
+
+// GetRecordingStatus returns the current recording state
+func (w *DynamicWrapper) GetRecordingStatus() RecordingStatus {
+	w.recordMu.Lock()
+	defer w.recordMu.Unlock()
+
+	return RecordingStatus{
+		Enabled:   w.recordEnabled,
+		Filename:  w.recordFilename,
+		StartTime: w.recordStart,
+		Messages:  w.recordCount,
+	}
+}
+
+// nextRecordingFilename returns an unused timestamped recording filename in
+// the directory of the previous recording (or the working directory)
+func (w *DynamicWrapper) nextRecordingFilename() string {
+	dir := "."
+	if status := w.GetRecordingStatus(); status.Filename != "" {
+		dir = filepath.Dir(status.Filename)
+	}
+
+	base := "mcp-recording-" + time.Now().Format("20060102-150405")
+	filename := filepath.Join(dir, base+".jsonl")
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return filename
+		}
+		filename = filepath.Join(dir, fmt.Sprintf("%s-%d.jsonl", base, n))
+	}
+}
+
+// SetMaxMessageLogBytes caps the serialized size of each recorded message.
+// Larger messages are replaced by a TruncatedMessage marker. Zero disables the limit.
+func (w *DynamicWrapper) SetMaxMessageLogBytes(limit int) {
+	w.recordMu.Lock()
+	defer w.recordMu.Unlock()
+	w.recordMaxBytes = limit
+}
+
 // recordMessage records a JSON-RPC message with metadata
 func (w *DynamicWrapper) recordMessage(direction, messageType, toolName, serverName string, message interface{}) {
-	if !w.recordEnabled {
-		return
+	w.writeRecord(direction, messageType, toolName, "", serverName, message, 0, false, nil, 0)
+}
+
+// recordToolRequest records a call to a proxied tool, under its exposed
+// name and, with record.originalToolNames, the backend's name for it
+func (w *DynamicWrapper) recordToolRequest(toolName, originalToolName, serverName string, message interface{}) {
+	w.writeRecord("request", "tool_call", toolName, originalToolName, serverName, message, 0, false, nil, 0)
+}
+
+// recordToolResponse records a tool call response along with the time elapsed
+// since its request was recorded at start
+func (w *DynamicWrapper) recordToolResponse(toolName, originalToolName, serverName string, message interface{}, start time.Time) {
+	w.writeRecord("response", "tool_call", toolName, originalToolName, serverName, message, time.Since(start), false, nil, 0)
+}
+
+// recordTransformedResponse records a tool call response whose result went
+// through the tool's transforms, along with the result before them
+func (w *DynamicWrapper) recordTransformedResponse(toolName, originalToolName, serverName string, message, untransformed interface{}, start time.Time) {
+	w.writeRecord("response", "tool_call", toolName, originalToolName, serverName, message, time.Since(start), false, untransformed, 0)
+}
+
+// recordResultResponse records the response to a forwarded tool call: the
+// whole result, the result before the tool's transforms if it had any, and
+// the bytes cut from the client's copy for maxResultBytes
+func (w *DynamicWrapper) recordResultResponse(toolName, originalToolName, serverName string, message, untransformed *mcp.CallToolResult, clientTruncated int, start time.Time) {
+	var original interface{}
+	if untransformed != nil {
+		original = untransformed
 	}
-	
+	w.writeRecord("response", "tool_call", toolName, originalToolName, serverName, message, time.Since(start), false, original, clientTruncated)
+}
+
+// recordBlockedResponse records the response to a tool call that a sanitizer
+// rule blocked before it reached the backend
+func (w *DynamicWrapper) recordBlockedResponse(toolName, originalToolName, serverName string, message interface{}, start time.Time) {
+	w.writeRecord("response", "tool_call", toolName, originalToolName, serverName, message, time.Since(start), true, nil, 0)
+}
+
+func (w *DynamicWrapper) writeRecord(direction, messageType, toolName, originalToolName, serverName string, message interface{}, duration time.Duration, blocked bool, untransformed interface{}, clientTruncated int) {
 	w.recordMu.Lock()
 	defer w.recordMu.Unlock()
-	
+
+	tapping := w.tap != nil && w.tap.active()
+	if !w.recordEnabled && !tapping {
+		return
+	}
+
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Failed to marshal message for recording: %v", err)
 		return
 	}
-	
+
+	// Replace oversized messages with a truncation marker
+	if w.recordMaxBytes > 0 && len(messageBytes) > w.recordMaxBytes {
+		messageBytes, _ = json.Marshal(TruncatedMessage{
+			Truncated:    true,
+			OriginalSize: len(messageBytes),
+		})
+	}
+
 	recorded := RecordedMessage{
-		Timestamp:   time.Now(),
-		Direction:   direction,
-		MessageType: messageType,
-		ToolName:    toolName,
-		ServerName:  serverName,
-		Message:     json.RawMessage(messageBytes),
-	}
-	
+		Timestamp:       time.Now(),
+		Direction:       direction,
+		MessageType:     messageType,
+		ToolName:        toolName,
+		ServerName:      serverName,
+		Message:         json.RawMessage(messageBytes),
+		DurationMs:      float64(duration) / float64(time.Millisecond),
+		Blocked:         blocked,
+		ClientTruncated: clientTruncated,
+	}
+	if w.proxyServer.recordSettings().OriginalToolNames {
+		recorded.OriginalToolName = originalToolName
+	}
+	if untransformed != nil {
+		if untransformedBytes, err := json.Marshal(untransformed); err == nil && (w.recordMaxBytes == 0 || len(untransformedBytes) <= w.recordMaxBytes) {
+			recorded.Untransformed = untransformedBytes
+		}
+	}
+
 	recordedBytes, err := json.Marshal(recorded)
 	if err != nil {
 		log.Printf("Failed to marshal recorded message: %v", err)
 		return
 	}
-	
-	fmt.Fprintf(w.recordFile, "%s\n", string(recordedBytes))
-	w.recordFile.Sync() // Ensure immediate write
-}
 
-// addRecordingMetadata adds recording file information to tool results when recording is active
-func (w *DynamicWrapper) addRecordingMetadata(result *mcp.CallToolResult) *mcp.CallToolResult {
+	if tapping {
+		w.tap.broadcast(recordedBytes)
+	}
 	if !w.recordEnabled {
-		return result
+		return
+	}
+
+	if w.recordBuffer != nil {
+		// Flushed by the background flusher or when recording stops
+		fmt.Fprintf(w.recordBuffer, "%s\n", string(recordedBytes))
+	} else {
+		fmt.Fprintf(w.recordFile, "%s\n", string(recordedBytes))
+		w.recordFile.Sync() // Ensure immediate write
 	}
+	w.indexRecord(recorded, len(recordedBytes)+1)
+	if w.recordBuffer == nil && w.recordIndex != nil {
+		w.recordIndex.Flush()
+	}
+	w.recordCount++
+}
+
+// recordingMetadataPrefix starts the text item addRecordingMetadata adds
+const recordingMetadataPrefix = "📹 Recording: "
+
+// recordingMetaKey is the _meta field addRecordingMetadata sets with
+// record.metadataFormat meta
+const recordingMetaKey = "mcp-debug/recording"
 
+// addRecordingMetadata adds recording file information to tool results when recording is active.
+// A result that already carries it, from a backend that is itself a
+// recording mcp-debug proxy, is left with that single annotation.
+func (w *DynamicWrapper) addRecordingMetadata(result *mcp.CallToolResult) *mcp.CallToolResult {
 	w.recordMu.Lock()
+	enabled := w.recordEnabled
 	filename := w.recordFilename
 	w.recordMu.Unlock()
 
-	if filename == "" {
+	settings := w.proxyServer.recordSettings()
+	if !enabled || filename == "" || settings.MetadataFormat == config.RecordMetadataNone || hasRecordingMetadata(result) {
 		return result
 	}
 
@@ -176,31 +492,48 @@ func (w *DynamicWrapper) addRecordingMetadata(result *mcp.CallToolResult) *mcp.C
 		absPath = filename // fallback to original if abs fails
 	}
 
+	// Copy-on-write to avoid mutating input (keeps _meta and structured content)
+	newResult := &mcp.CallToolResult{
+		Result:            result.Result,
+		Content:           make([]mcp.Content, len(result.Content), len(result.Content)+1),
+		StructuredContent: result.StructuredContent,
+		IsError:           result.IsError,
+	}
+
+	// Copy existing content
+	copy(newResult.Content, result.Content)
+
+	if settings.MetadataFormat == config.RecordMetadataMeta {
+		meta := &mcp.Meta{AdditionalFields: make(map[string]any)}
+		if result.Meta != nil {
+			meta.ProgressToken = result.Meta.ProgressToken
+			maps.Copy(meta.AdditionalFields, result.Meta.AdditionalFields)
+		}
+		meta.AdditionalFields[recordingMetaKey] = map[string]any{"file": filename, "path": absPath}
+		newResult.Meta = meta
+		return newResult
+	}
+
 	// Build metadata text
 	metadataText := fmt.Sprintf(
-		"📹 Recording: %s\n   Full path: %s\n   Purpose: JSON-RPC message log for debugging and playback testing",
+		"%s%s\n   Full path: %s\n   Purpose: JSON-RPC message log for debugging and playback testing",
+		recordingMetadataPrefix,
 		filename,
 		absPath,
 	)
-
-	// Create metadata content item
-	metadataItem := mcp.NewTextContent(metadataText)
-
-	// Copy-on-write to avoid mutating input
-	newResult := &mcp.CallToolResult{
-		Content: make([]mcp.Content, len(result.Content), len(result.Content)+1),
-		IsError: result.IsError,
+	if settings.MetadataTemplate != "" {
+		// Keep the prefix so nested proxies and transcripts still spot it
+		metadataText = recordingMetadataPrefix + strings.NewReplacer("{file}", filename, "{path}", absPath).Replace(settings.MetadataTemplate)
 	}
 
-	// Copy existing content
-	copy(newResult.Content, result.Content)
-
 	// Append metadata to NEW slice
-	newResult.Content = append(newResult.Content, metadataItem)
+	newResult.Content = append(newResult.Content, mcp.NewTextContent(metadataText))
 
 	return newResult
 }
 
+// registerManagementTools registers the management tools enabled by the
+// proxy.management settings
 func (w *DynamicWrapper) registerManagementTools() {
 	// server_add tool
 	addTool := mcp.NewTool("server_add",
@@ -213,10 +546,19 @@ func (w *DynamicWrapper) registerManagementTools() {
 			mcp.Required(),
 			mcp.Description("Command to run (e.g., 'npx -y @modelcontextprotocol/filesystem /path')"),
 		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Connect and list the tools the server would add, then disconnect without registering anything"),
+		),
+		mcp.WithString("warmup_tool",
+			mcp.Description("Tool of the server to call once after it initializes, before its tools are added; the add fails if the call does"),
+		),
+		mcp.WithObject("warmup_arguments",
+			mcp.Description("Arguments for warmup_tool"),
+		),
 	)
-	
-	w.baseServer.AddTool(addTool, w.handleServerAdd)
-	
+
+	w.addManagementTool(addTool, w.handleServerAdd)
+
 	// server_remove tool
 	removeTool := mcp.NewTool("server_remove",
 		mcp.WithDescription("Remove an MCP server from the proxy"),
@@ -225,16 +567,116 @@ func (w *DynamicWrapper) registerManagementTools() {
 			mcp.Description("Name of the server to remove"),
 		),
 	)
-	
-	w.baseServer.AddTool(removeTool, w.handleServerRemove)
-	
+
+	w.addManagementTool(removeTool, w.handleServerRemove)
+
 	// server_list tool
 	listTool := mcp.NewTool("server_list",
 		mcp.WithDescription("List all connected MCP servers"),
 	)
-	
-	w.baseServer.AddTool(listTool, w.handleServerList)
-	
+
+	w.addManagementTool(listTool, w.handleServerList)
+
+	// server_status tool
+	statusTool := mcp.NewTool("server_status",
+		mcp.WithDescription("Show detailed status for servers, including duplicate tool decisions"),
+		mcp.WithString("name",
+			mcp.Description("Name of the server to show. If omitted, shows all servers."),
+		),
+	)
+
+	w.addManagementTool(statusTool, w.handleServerStatus)
+
+	// server_tools tool
+	serverToolsTool := mcp.NewTool("server_tools",
+		mcp.WithDescription("List all tools of one server with descriptions and argument summaries"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the server"),
+		),
+		mcp.WithBoolean("verbose",
+			mcp.Description("Include each tool's full input schema"),
+		),
+	)
+
+	w.addManagementTool(serverToolsTool, w.handleServerTools)
+
+	// proxy_info tool
+	infoTool := mcp.NewTool("proxy_info",
+		mcp.WithDescription("Show proxy information including server counts and recording state"),
+	)
+
+	w.addManagementTool(infoTool, w.handleProxyInfo)
+
+	// proxy_config tool
+	configTool := mcp.NewTool("proxy_config",
+		mcp.WithDescription("Show the effective running configuration as JSON, including servers added at runtime, with env values and credentials redacted"),
+	)
+
+	w.addManagementTool(configTool, w.handleProxyConfig)
+
+	// proxy_degraded tool
+	degradedTool := mcp.NewTool("proxy_degraded",
+		mcp.WithDescription("List tools that are currently unavailable because their server is disconnected"),
+	)
+
+	w.addManagementTool(degradedTool, w.handleProxyDegraded)
+
+	// proxy_load tool
+	loadTool := mcp.NewTool("proxy_load",
+		mcp.WithDescription("Show per-server tool call load: calls in flight, calls queued on rate limits, and recent throughput and latency"),
+	)
+
+	w.addManagementTool(loadTool, w.handleProxyLoad)
+
+	// server_latency tool
+	latencyTool := mcp.NewTool("server_latency",
+		mcp.WithDescription("Show the backend latency histogram and recent latency samples of a server's tools"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the server"),
+		),
+		mcp.WithString("tool",
+			mcp.Description("Original (unprefixed) name of one tool to show. If omitted, shows all tools."),
+		),
+		mcp.WithString("format",
+			mcp.Description("text (default) or json, which includes the raw samples for offline analysis"),
+		),
+	)
+
+	w.addManagementTool(latencyTool, w.handleServerLatency)
+
+	// server_stats_reset tool
+	statsResetTool := mcp.NewTool("server_stats_reset",
+		mcp.WithDescription("Clear the latency histograms and samples of a server's tools"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the server"),
+		),
+		mcp.WithString("tool",
+			mcp.Description("Original (unprefixed) name of one tool to reset. If omitted, resets all tools."),
+		),
+	)
+
+	w.addManagementTool(statsResetTool, w.handleServerStatsReset)
+
+	// record_start tool
+	recordStartTool := mcp.NewTool("record_start",
+		mcp.WithDescription("Start recording JSON-RPC traffic to a file"),
+		mcp.WithString("filename",
+			mcp.Description("Recording file path (defaults to a timestamped name)"),
+		),
+	)
+
+	w.addManagementTool(recordStartTool, w.handleRecordStart)
+
+	// record_stop tool
+	recordStopTool := mcp.NewTool("record_stop",
+		mcp.WithDescription("Stop the active recording and close the file"),
+	)
+
+	w.addManagementTool(recordStopTool, w.handleRecordStop)
+
 	// server_disconnect tool
 	disconnectTool := mcp.NewTool("server_disconnect",
 		mcp.WithDescription("Disconnect a server (tools remain but return errors)"),
@@ -243,9 +685,9 @@ func (w *DynamicWrapper) registerManagementTools() {
 			mcp.Description("Name of the server to disconnect"),
 		),
 	)
-	
-	w.baseServer.AddTool(disconnectTool, w.handleServerDisconnect)
-	
+
+	w.addManagementTool(disconnectTool, w.handleServerDisconnect)
+
 	// server_reconnect tool
 	reconnectTool := mcp.NewTool("server_reconnect",
 		mcp.WithDescription("Reconnect a server with optional new command (use after server_disconnect)"),
@@ -256,15 +698,121 @@ func (w *DynamicWrapper) registerManagementTools() {
 		mcp.WithString("command",
 			mcp.Description("New command to run. If omitted, uses stored configuration from config.yaml."),
 		),
+		mcp.WithBoolean("refresh_env",
+			mcp.Description("With proxy.snapshotEnv, resolve the environment again instead of reusing the one from the first launch"),
+		),
+	)
+
+	w.addManagementTool(reconnectTool, w.handleServerReconnect)
+
+	// server_rediscover tool
+	rediscoverTool := mcp.NewTool("server_rediscover",
+		mcp.WithDescription("List a connected server's tools again and register new ones, unregister removed ones and update changed ones, without reconnecting"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the server to re-discover"),
+		),
+	)
+
+	w.addManagementTool(rediscoverTool, w.handleServerRediscover)
+
+	// server_log_level tool
+	logLevelTool := mcp.NewTool("server_log_level",
+		mcp.WithDescription("Set the minimum level of the log messages a server sends (logging/setLevel); they are relayed to the client"),
+		mcp.WithString("level",
+			mcp.Required(),
+			mcp.Description("debug, info, notice, warning, error, critical, alert or emergency"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name of the server. If omitted, sets the level on every connected server that supports logging."),
+		),
 	)
-	
-	w.baseServer.AddTool(reconnectTool, w.handleServerReconnect)
+
+	w.addManagementTool(logLevelTool, w.handleServerLogLevel)
+
+	// server_call tool
+	callTool := mcp.NewTool("server_call",
+		mcp.WithDescription("Call any tool of a server by its original name, even one the proxy didn't register, and return the server's result as sent"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the server"),
+		),
+		mcp.WithString("tool",
+			mcp.Required(),
+			mcp.Description("Tool name as the server knows it, without the proxy's prefix"),
+		),
+		mcp.WithObject("arguments",
+			mcp.Description("Arguments for the tool"),
+		),
+	)
+
+	w.addManagementTool(callTool, w.handleServerCall)
+}
+
+// parseCommand splits a command line given to server_add or
+// server_reconnect into the command and its args, expanding a package spec
+// such as "npm:@scope/pkg" into the command that runs the package
+func parseCommand(command string) ([]string, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid command")
+	}
+	name, args, err := config.ExpandPackageSpec(parts[0], parts[1:])
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{name}, args...), nil
+}
+
+// normalizeServerName trims the name given to server_add, which is also
+// the server's tool prefix, and checks it only has characters every client
+// accepts in a tool name
+func normalizeServerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name must not be empty", ErrInvalidServerName)
+	}
+	if !config.ValidToolName(name) {
+		return "", fmt.Errorf("%w %q: may only contain letters, digits, '_' and '-'", ErrInvalidServerName, name)
+	}
+	return name, nil
+}
+
+// prefixOwnerLocked returns the server whose tools already use prefix, or
+// "" if there is none. Caller holds w.mu.
+func (w *DynamicWrapper) prefixOwnerLocked(prefix string) string {
+	for serverName, serverInfo := range w.dynamicServers {
+		if serverName == prefix || (!serverInfo.Config.NoPrefix && serverInfo.Config.Prefix == prefix) {
+			return serverName
+		}
+	}
+	return ""
+}
+
+// checkCommandAllowed checks a command line given to server_add or
+// server_reconnect against proxy.management.allowedCommands
+func (w *DynamicWrapper) checkCommandAllowed(parts []string) error {
+	if w.proxyServer.config.Proxy.Management.CommandAllowed(parts[0], parts[1:]) {
+		return nil
+	}
+	log.Printf("Refused to launch disallowed command: %s", strings.Join(parts, " "))
+	return fmt.Errorf("%w: '%s' is not permitted by proxy.management.allowedCommands", ErrCommandNotAllowed, strings.Join(parts, " "))
+}
+
+// addManagementTool registers a management tool unless proxy.management
+// settings disable it
+func (w *DynamicWrapper) addManagementTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if !w.proxyServer.config.Proxy.Management.ToolEnabled(tool.Name) {
+		log.Printf("Management tool %s disabled by proxy.management settings", tool.Name)
+		return
+	}
+	w.baseServer.AddTool(tool, handler)
 }
 
 func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Record the request
 	w.recordMessage("request", "tool_call", "server_add", "proxy", request)
-	
+
 	name, err := request.RequireString("name")
 	if err != nil {
 		result := mcp.NewToolResultError("name is required")
@@ -280,27 +828,57 @@ func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallTo
 		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
 		return result, nil
 	}
-	
+	dryRun := request.GetBool("dry_run", false)
+
+	// The name is also the prefix of the server's tools
+	name, err = normalizeServerName(name)
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
+		return result, nil
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	
+
 	// Check if already exists
 	if _, exists := w.dynamicServers[name]; exists {
-		result := mcp.NewToolResultError(fmt.Sprintf("Server '%s' already exists", name))
+		result := mcp.NewToolResultError((&ServerError{Server: name, Err: ErrServerAlreadyExists}).Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
+		return result, nil
+	}
+	if owner := w.prefixOwnerLocked(name); owner != "" {
+		result := mcp.NewToolResultError(fmt.Sprintf("%v '%s': server '%s' already uses it as its tool prefix", ErrInvalidServerName, name, owner))
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
 		return result, nil
 	}
 
-	// Parse command
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		result := mcp.NewToolResultError("Invalid command")
+	// A dry run doesn't keep the server, so it isn't limited
+	if limit := w.proxyServer.config.Proxy.MaxDynamicServers; !dryRun && limit > 0 && w.dynamicServerCountLocked() >= limit {
+		result := mcp.NewToolResultError(fmt.Sprintf("%v: maxDynamicServers is %d. Remove a server with server_remove first.", ErrServerLimitReached, limit))
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
 		return result, nil
 	}
-	
+
+	// Parse command
+	parts, err := parseCommand(command)
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
+		return result, nil
+	}
+	if err := w.checkCommandAllowed(parts); err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
+		return result, nil
+	}
+
 	// Create server config
 	serverConfig := config.ServerConfig{
 		Name:      name,
@@ -308,30 +886,24 @@ func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallTo
 		Transport: "stdio",
 		Command:   parts[0],
 		Args:      parts[1:],
-		Timeout:   "30s",
 	}
-	
-	// Create and connect client
-	stdioClient := client.NewStdioClient(name, serverConfig.Command, serverConfig.Args)
-
-	// Use default inheritance (tier1 or proxy defaults)
-	inheritCfg := serverConfig.ResolveInheritConfig(w.proxyServer.config.Inherit)
-	stdioClient.SetInheritConfig(inheritCfg)
-
-	if err := stdioClient.Connect(ctx); err != nil {
-		result := mcp.NewToolResultError(fmt.Sprintf("Failed to connect: %v", err))
-		result = w.addRecordingMetadata(result)
-		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
-		return result, nil
+	if warmupTool := request.GetString("warmup_tool", ""); warmupTool != "" {
+		warmupArgs, _ := request.GetArguments()["warmup_arguments"].(map[string]interface{})
+		serverConfig.Warmup = []config.WarmupCall{{Tool: warmupTool, Arguments: warmupArgs}}
 	}
 
-	if _, err := stdioClient.Initialize(ctx); err != nil {
-		stdioClient.Close()
-		result := mcp.NewToolResultError(fmt.Sprintf("Failed to initialize: %v", err))
+	// Create and connect client. A dry run's client is temporary and is
+	// always closed before returning.
+	stdioClient, err := w.connectServerClient(ctx, serverConfig, nil, !dryRun)
+	if err != nil {
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to start server: %v", err))
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
 		return result, nil
 	}
+	if dryRun {
+		defer stdioClient.Close()
+	}
 
 	// List tools
 	tools, err := stdioClient.ListTools(ctx)
@@ -342,7 +914,16 @@ func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallTo
 		w.recordMessage("response", "tool_call", "server_add", "proxy", result)
 		return result, nil
 	}
-	
+
+	logNestedProxy(name, toolInfoNames(tools))
+
+	if dryRun {
+		toolResult := mcp.NewToolResultText(w.formatDryRun(serverConfig, tools))
+		toolResult = w.addRecordingMetadata(toolResult)
+		w.recordMessage("response", "tool_call", "server_add", "proxy", toolResult)
+		return toolResult, nil
+	}
+
 	// Store server info
 	serverInfo := &DynamicServerInfo{
 		Name:        name,
@@ -351,42 +932,65 @@ func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallTo
 		Tools:       make([]string, 0, len(tools)),
 		IsConnected: true,
 	}
-	
-	// Register tools with proxy
-	registeredCount := 0
+	w.captureEnvSnapshotLocked(serverInfo)
+
+	// Apply duplicate tool policy before registering anything
+	w.proxyServer.mu.Lock()
+	var discoveredTools []discovery.RemoteTool
+	var conflicts []ToolConflict
 	for _, tool := range tools {
-		// Create discovered tool
-		discoveredTool := discovery.RemoteTool{
+		discoveredTool, register, conflict, err := w.proxyServer.resolveToolConflict(discovery.RemoteTool{
 			OriginalName: tool.Name,
 			PrefixedName: fmt.Sprintf("%s_%s", name, tool.Name),
 			Description:  tool.Description,
 			InputSchema:  tool.InputSchema,
 			ServerName:   name,
+			ServerPrefix: name,
+		})
+		if err != nil {
+			w.proxyServer.mu.Unlock()
+			stdioClient.Close()
+			result := mcp.NewToolResultError(fmt.Sprintf("Failed to register tools: %v", err))
+			result = w.addRecordingMetadata(result)
+			w.recordMessage("response", "tool_call", "server_add", "proxy", result)
+			return result, nil
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
 		}
-		
+		if register {
+			discoveredTools = append(discoveredTools, discoveredTool)
+		}
+	}
+	w.proxyServer.recordToolConflicts(conflicts)
+	w.proxyServer.mu.Unlock()
+
+	// Register tools with proxy
+	registeredCount := 0
+	for _, discoveredTool := range discoveredTools {
 		// Register with proxy registry
 		w.proxyServer.registry.RegisterTool(discoveredTool, stdioClient)
-		
+
 		// Create MCP tool
 		mcpTool := w.proxyServer.createMCPTool(discoveredTool)
-		
+
 		// Create proxy handler with disconnect checking
-		handler := w.createDynamicProxyHandler(name, discoveredTool.OriginalName)
-		
+		handler := w.createDynamicProxyHandler(discoveredTool)
+
 		// Add to MCP server
 		w.baseServer.AddTool(mcpTool, handler)
-		
+
 		serverInfo.Tools = append(serverInfo.Tools, discoveredTool.PrefixedName)
 		registeredCount++
 		log.Printf("Dynamically registered tool: %s", discoveredTool.PrefixedName)
 	}
-	
+
 	// Store server info
 	w.dynamicServers[name] = serverInfo
-	
+
 	// Also add to proxy server's client list
 	w.proxyServer.clients = append(w.proxyServer.clients, stdioClient)
-	
+
 	result := fmt.Sprintf("Added server '%s' with command: %s %s\nRegistered %d tools successfully.",
 		name, serverConfig.Command, strings.Join(serverConfig.Args, " "), registeredCount)
 
@@ -396,6 +1000,49 @@ func (w *DynamicWrapper) handleServerAdd(ctx context.Context, request mcp.CallTo
 	return toolResult, nil
 }
 
+// dynamicServerCountLocked returns how many servers were added with
+// server_add rather than from the config. The caller holds w.mu.
+func (w *DynamicWrapper) dynamicServerCountLocked() int {
+	static := make(map[string]bool, len(w.proxyServer.config.Servers))
+	for _, serverConfig := range w.proxyServer.config.Servers {
+		static[serverConfig.Name] = true
+	}
+	count := 0
+	for name := range w.dynamicServers {
+		if !static[name] {
+			count++
+		}
+	}
+	return count
+}
+
+// formatDryRun describes the tools a server_add would register, noting names
+// already taken. Nothing is registered.
+func (w *DynamicWrapper) formatDryRun(serverConfig config.ServerConfig, tools []client.ToolInfo) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Dry run for server '%s' with command: %s %s\n",
+		serverConfig.Name, serverConfig.Command, strings.Join(serverConfig.Args, " ")))
+	result.WriteString(fmt.Sprintf("Would register %d tools (nothing was registered):\n\n", len(tools)))
+
+	w.proxyServer.mu.RLock()
+	defer w.proxyServer.mu.RUnlock()
+
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	for _, tool := range tools {
+		prefixedName := w.proxyServer.exposedToolName(discovery.PrefixedToolName(serverConfig.ToolPrefixFor(tool.Name), tool.Name))
+		result.WriteString(fmt.Sprintf("- %s", prefixedName))
+		if existing, taken := w.proxyServer.registry.GetTool(prefixedName); taken {
+			result.WriteString(fmt.Sprintf(" (name taken by server %s)", existing.ServerName))
+		}
+		result.WriteString("\n")
+		if tool.Description != "" {
+			result.WriteString(fmt.Sprintf("  %s\n", tool.Description))
+		}
+		result.WriteString(fmt.Sprintf("  Arguments: %s\n", summarizeSchema(tool.InputSchema)))
+	}
+	return result.String()
+}
+
 func (w *DynamicWrapper) handleServerRemove(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Record the request
 	w.recordMessage("request", "tool_call", "server_remove", "proxy", request)
@@ -411,101 +1058,537 @@ func (w *DynamicWrapper) handleServerRemove(ctx context.Context, request mcp.Cal
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	serverInfo, exists := w.dynamicServers[name]
-	if !exists {
-		result := mcp.NewToolResultError(fmt.Sprintf("Server '%s' not found", name))
+	serverInfo, err := w.lookupServer(name)
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_remove", "proxy", result)
 		return result, nil
 	}
-	
+
 	// Note: We can't actually remove tools from mark3labs/mcp-go at runtime
 	// But we can close the connection and mark them as unavailable
-	
+
 	// Close client
 	if err := serverInfo.Client.Close(); err != nil {
 		log.Printf("Error closing client %s: %v", name, err)
 	}
-	
+
 	// Remove from maps
 	delete(w.dynamicServers, name)
-	
+	w.latency.reset(name, "")
+
 	// Remove from proxy server's client list
-	newClients := make([]client.MCPClient, 0, len(w.proxyServer.clients)-1)
+	newClients := make([]client.MCPClient, 0, len(w.proxyServer.clients))
 	for _, c := range w.proxyServer.clients {
 		if c != serverInfo.Client {
 			newClients = append(newClients, c)
 		}
 	}
-	w.proxyServer.clients = newClients
-	
-	result := fmt.Sprintf("Removed server '%s'. Note: %d tools remain registered but are now unavailable.",
-		name, len(serverInfo.Tools))
+	w.proxyServer.clients = newClients
+
+	result := fmt.Sprintf("Removed server '%s'. Note: %d tools remain registered but are now unavailable.",
+		name, len(serverInfo.Tools))
+
+	toolResult := mcp.NewToolResultText(result)
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_remove", "proxy", toolResult)
+	return toolResult, nil
+}
+
+func (w *DynamicWrapper) handleServerList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_list", "proxy", request)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var result strings.Builder
+	result.WriteString("Connected MCP Servers:\n")
+	result.WriteString("=====================\n\n")
+
+	// List static servers from initial config
+	staticCount := len(w.proxyServer.config.Servers)
+	if staticCount > 0 {
+		result.WriteString("Static servers (from config):\n")
+		for _, server := range w.proxyServer.config.Servers {
+			result.WriteString(fmt.Sprintf("- %s [static]\n", server.Name))
+		}
+		result.WriteString("\n")
+	}
+
+	// List dynamic servers
+	if len(w.dynamicServers) == 0 && staticCount == 0 {
+		result.WriteString("No servers connected.\n")
+	} else if len(w.dynamicServers) > 0 {
+		result.WriteString("Dynamic servers:\n")
+		for _, name := range w.sortedServerNames() {
+			info := w.dynamicServers[name]
+			status := "connected"
+			if !info.IsConnected {
+				status = "disconnected"
+				if info.ErrorMessage != "" {
+					status = fmt.Sprintf("disconnected (%s)", info.ErrorMessage)
+				}
+			}
+			result.WriteString(fmt.Sprintf("- %s [%s] - %d tools\n", name, status, len(info.Tools)))
+			writeServerDescription(&result, info.Config)
+
+			// List first few tools
+			tools := sortedStrings(info.Tools)
+			if len(tools) > 0 && len(tools) <= 5 {
+				for _, tool := range tools {
+					result.WriteString(fmt.Sprintf("  • %s\n", tool))
+				}
+			} else if len(tools) > 5 {
+				for i := 0; i < 3; i++ {
+					result.WriteString(fmt.Sprintf("  • %s\n", tools[i]))
+				}
+				result.WriteString(fmt.Sprintf("  • ... and %d more\n", len(info.Tools)-3))
+			}
+		}
+	}
+
+	totalServers := staticCount + len(w.dynamicServers)
+	result.WriteString(fmt.Sprintf("\nTotal servers: %d (static: %d, dynamic: %d)\n",
+		totalServers, staticCount, len(w.dynamicServers)))
+
+	if degraded := w.degradedServers(); len(degraded) > 0 {
+		result.WriteString("\n")
+		writeDegradedTools(&result, degraded)
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_list", "proxy", toolResult)
+	return toolResult, nil
+}
+
+// writeServerDescription writes a server's description and tags, if any,
+// under its entry in server_list and server_status
+func writeServerDescription(result *strings.Builder, serverConfig config.ServerConfig) {
+	if serverConfig.Description != "" {
+		result.WriteString(fmt.Sprintf("  Description: %s\n", serverConfig.Description))
+	}
+	if len(serverConfig.Tags) > 0 {
+		result.WriteString(fmt.Sprintf("  Tags: %s\n", strings.Join(serverConfig.Tags, ", ")))
+	}
+}
+
+// DegradedServer lists the tools made unavailable by a disconnected server
+type DegradedServer struct {
+	Server   string
+	Error    string
+	Tools    []string
+	Required bool // Marked required; startup fails without it
+}
+
+// degradedServers returns the disconnected servers and their tools, sorted
+// by server name. The caller must hold w.mu.
+func (w *DynamicWrapper) degradedServers() []DegradedServer {
+	var degraded []DegradedServer
+	for _, name := range w.sortedServerNames() {
+		info := w.dynamicServers[name]
+		if info.IsConnected {
+			continue
+		}
+		degraded = append(degraded, DegradedServer{
+			Server:   name,
+			Error:    info.ErrorMessage,
+			Tools:    sortedStrings(info.Tools),
+			Required: info.Config.Required,
+		})
+	}
+	return degraded
+}
+
+// writeDegradedTools writes the unavailable tools grouped by server
+func writeDegradedTools(result *strings.Builder, degraded []DegradedServer) {
+	count := 0
+	for _, server := range degraded {
+		count += len(server.Tools)
+	}
+	result.WriteString(fmt.Sprintf("Unavailable tools (%d, server disconnected):\n", count))
+	for _, server := range degraded {
+		name := server.Server
+		if server.Required {
+			name += " [required]"
+		}
+		if server.Error != "" {
+			result.WriteString(fmt.Sprintf("- %s (%s):\n", name, server.Error))
+		} else {
+			result.WriteString(fmt.Sprintf("- %s:\n", name))
+		}
+		for _, tool := range server.Tools {
+			result.WriteString(fmt.Sprintf("  • %s\n", tool))
+		}
+	}
+	result.WriteString("Use server_reconnect to restore them.\n")
+}
+
+// lookupServer returns the named dynamic server or a ServerError wrapping
+// ErrServerNotFound. The caller must hold w.mu.
+func (w *DynamicWrapper) lookupServer(name string) (*DynamicServerInfo, error) {
+	serverInfo, exists := w.dynamicServers[name]
+	if !exists {
+		return nil, &ServerError{Server: name, Err: ErrServerNotFound}
+	}
+	return serverInfo, nil
+}
+
+// sortedServerNames returns the names of all servers in sorted order.
+// Caller must hold w.mu.
+func (w *DynamicWrapper) sortedServerNames() []string {
+	names := make([]string, 0, len(w.dynamicServers))
+	for name := range w.dynamicServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedStrings returns a sorted copy of values
+func sortedStrings(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// describeCapabilities lists the capabilities a backend advertised, each
+// with its enabled flags, e.g. "logging, tools (listChanged)"
+func describeCapabilities(capabilities map[string]interface{}) string {
+	if len(capabilities) == 0 {
+		return "none advertised"
+	}
+
+	names := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	described := make([]string, 0, len(names))
+	for _, name := range names {
+		options, _ := capabilities[name].(map[string]interface{})
+		var flags []string
+		for flag, value := range options {
+			if enabled, _ := value.(bool); enabled {
+				flags = append(flags, flag)
+			}
+		}
+		if len(flags) == 0 {
+			described = append(described, name)
+			continue
+		}
+		sort.Strings(flags)
+		described = append(described, fmt.Sprintf("%s (%s)", name, strings.Join(flags, ", ")))
+	}
+	return strings.Join(described, ", ")
+}
+
+func (w *DynamicWrapper) handleServerStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_status", "proxy", request)
+
+	name := request.GetString("name", "")
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if name != "" {
+		if _, err := w.lookupServer(name); err != nil {
+			result := mcp.NewToolResultError(err.Error())
+			result = w.addRecordingMetadata(result)
+			w.recordMessage("response", "tool_call", "server_status", "proxy", result)
+			return result, nil
+		}
+	}
+
+	var result strings.Builder
+	result.WriteString("Server Status:\n")
+	result.WriteString("==============\n\n")
+
+	for _, serverName := range w.sortedServerNames() {
+		if name != "" && serverName != name {
+			continue
+		}
+		info := w.dynamicServers[serverName]
+
+		status := "connected"
+		if info.Idle {
+			status = "idle"
+		} else if !info.IsConnected {
+			status = "disconnected"
+		}
+		result.WriteString(fmt.Sprintf("%s [%s]\n", serverName, status))
+		writeServerDescription(&result, info.Config)
+		result.WriteString(fmt.Sprintf("  Transport: %s\n", info.Config.Transport))
+		if info.Config.Command != "" {
+			result.WriteString(fmt.Sprintf("  Command: %s %s\n", info.Config.Command, strings.Join(info.Config.Args, " ")))
+		}
+		if reporter, ok := info.Client.(client.ProtocolReporter); ok && info.IsConnected && reporter.ProtocolVersion() != "" {
+			result.WriteString(fmt.Sprintf("  Protocol: %s\n", reporter.ProtocolVersion()))
+		}
+		if reporter, ok := info.Client.(client.InitializeReporter); ok && info.IsConnected {
+			if initResult := reporter.InitializeResult(); initResult != nil {
+				if initResult.ServerInfo.Name != "" {
+					result.WriteString(fmt.Sprintf("  Server: %s %s\n", initResult.ServerInfo.Name, initResult.ServerInfo.Version))
+				}
+				result.WriteString(fmt.Sprintf("  Capabilities: %s\n", describeCapabilities(initResult.Capabilities)))
+			}
+		}
+		result.WriteString(fmt.Sprintf("  Tools: %d\n", len(info.Tools)))
+		if info.ErrorMessage != "" {
+			result.WriteString(fmt.Sprintf("  Error: %s\n", info.ErrorMessage))
+		}
+		if info.EnvSnapshot != nil {
+			result.WriteString(fmt.Sprintf("  Environment: snapshot of the first launch (%d variables)\n", len(info.EnvSnapshot)))
+		}
+		if timeout := info.Config.GetIdleTimeout(); timeout > 0 {
+			switch {
+			case info.Idle:
+				result.WriteString(fmt.Sprintf("  Idle timeout: %v (disconnected while idle; the next tool call reconnects)\n", timeout))
+			case info.IsConnected && !info.LastCall.IsZero():
+				result.WriteString(fmt.Sprintf("  Idle timeout: %v (last call %v ago)\n", timeout, time.Since(info.LastCall).Round(time.Second)))
+			default:
+				result.WriteString(fmt.Sprintf("  Idle timeout: %v\n", timeout))
+			}
+		}
+		if info.Config.RateLimit != nil {
+			result.WriteString(fmt.Sprintf("  Rate limit: %g requests/s, burst %d, on limit: %s\n",
+				info.Config.RateLimit.RequestsPerSecond, max(info.Config.RateLimit.Burst, 1), info.Config.RateLimit.Action()))
+			for _, state := range w.rateLimiter.state(serverName) {
+				result.WriteString(fmt.Sprintf("    %s: %g requests/s, burst %d, %.2f tokens available, %d delayed, %d rejected\n",
+					state.Tool, state.RequestsPerSecond, state.Burst, state.Tokens, state.Delayed, state.Rejected))
+			}
+		}
+		result.WriteString("\n")
+	}
+
+	// Duplicate tool decisions
+	conflicts := w.proxyServer.GetToolConflicts()
+	var shown []ToolConflict
+	for _, conflict := range conflicts {
+		if name == "" || conflict.ServerName == name || conflict.ConflictsWith == name {
+			shown = append(shown, conflict)
+		}
+	}
+	if len(shown) > 0 {
+		result.WriteString(fmt.Sprintf("Duplicate tools (policy: %s):\n", w.proxyServer.config.GetProxySettings().DuplicateTools))
+		for _, conflict := range shown {
+			result.WriteString(fmt.Sprintf("- %s\n", conflict))
+		}
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_status", "proxy", toolResult)
+	return toolResult, nil
+}
+
+func (w *DynamicWrapper) handleServerTools(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_tools", "proxy", request)
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		result := mcp.NewToolResultError("name is required")
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_tools", "proxy", result)
+		return result, nil
+	}
+	verbose := request.GetBool("verbose", false)
+
+	w.mu.RLock()
+	serverInfo, err := w.lookupServer(name)
+	connected := err == nil && serverInfo.IsConnected
+	w.mu.RUnlock()
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_tools", "proxy", result)
+		return result, nil
+	}
+
+	// The registry keeps a disconnected server's tools, so this is the
+	// last-known list until it reconnects
+	w.proxyServer.mu.RLock()
+	tools := w.proxyServer.registry.GetServerTools(name)
+	w.proxyServer.mu.RUnlock()
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Tools for server '%s' (%d):\n", name, len(tools)))
+	if !connected {
+		result.WriteString("Server is disconnected; showing the last known tools.\n")
+	}
+	result.WriteString("\n")
+
+	for _, tool := range tools {
+		result.WriteString(fmt.Sprintf("%s\n", tool.PrefixedName))
+		if tool.Description != "" {
+			result.WriteString(fmt.Sprintf("  %s\n", tool.Description))
+		}
+		result.WriteString(fmt.Sprintf("  Arguments: %s\n", summarizeSchema(tool.InputSchema)))
+		if verbose && len(tool.InputSchema) > 0 {
+			var schema bytes.Buffer
+			if err := json.Indent(&schema, tool.InputSchema, "  ", "  "); err == nil {
+				result.WriteString(fmt.Sprintf("  Schema: %s\n", schema.String()))
+			}
+		}
+		result.WriteString("\n")
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_tools", "proxy", toolResult)
+	return toolResult, nil
+}
+
+// summarizeSchema renders a tool's input schema as a one-line argument list,
+// e.g. "path (string, required), recursive (boolean)"
+func summarizeSchema(raw json.RawMessage) string {
+	var schema struct {
+		Properties map[string]struct {
+			Type interface{} `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &schema) != nil {
+		return "unknown"
+	}
+	if len(schema.Properties) == 0 {
+		return "none"
+	}
+
+	required := make(map[string]bool)
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		argType := "any"
+		if schema.Properties[name].Type != nil {
+			argType = fmt.Sprint(schema.Properties[name].Type)
+		}
+		if required[name] {
+			args = append(args, fmt.Sprintf("%s (%s, required)", name, argType))
+		} else {
+			args = append(args, fmt.Sprintf("%s (%s)", name, argType))
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+func (w *DynamicWrapper) handleProxyInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "proxy_info", "proxy", request)
+
+	w.mu.RLock()
+	totalServers := len(w.dynamicServers)
+	connected := 0
+	for _, info := range w.dynamicServers {
+		if info.IsConnected {
+			connected++
+		}
+	}
+	w.mu.RUnlock()
+
+	var result strings.Builder
+	result.WriteString("Proxy Info:\n")
+	result.WriteString("===========\n\n")
+	result.WriteString(fmt.Sprintf("Name: %s\n", w.proxyServer.config.Proxy.ServerName()))
+	result.WriteString(fmt.Sprintf("Version: %s\n", w.proxyServer.config.Proxy.ServerVersion()))
+	result.WriteString(fmt.Sprintf("Servers: %d (connected: %d)\n", totalServers, connected))
+	result.WriteString(fmt.Sprintf("Tools: %d\n", len(w.proxyServer.GetRegisteredTools())))
+
+	status := w.GetRecordingStatus()
+	if status.Enabled {
+		result.WriteString(fmt.Sprintf("Recording: enabled (%s, %d messages, started %s)\n",
+			status.Filename, status.Messages, status.StartTime.Format(time.RFC3339)))
+	} else {
+		result.WriteString("Recording: disabled\n")
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "proxy_info", "proxy", toolResult)
+	return toolResult, nil
+}
+
+func (w *DynamicWrapper) handleProxyDegraded(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "proxy_degraded", "proxy", request)
+
+	w.mu.RLock()
+	degraded := w.degradedServers()
+	w.mu.RUnlock()
+
+	var result strings.Builder
+	if len(degraded) == 0 {
+		result.WriteString("All servers are connected; no tools are unavailable.\n")
+	} else {
+		writeDegradedTools(&result, degraded)
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "proxy_degraded", "proxy", toolResult)
+	return toolResult, nil
+}
+
+func (w *DynamicWrapper) handleRecordStart(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filename := request.GetString("filename", "")
+	if filename == "" {
+		filename = w.nextRecordingFilename()
+	}
+
+	absPath, err := filepath.Abs(filename)
+	if err == nil {
+		err = w.EnableRecording(absPath)
+	}
+	if err != nil {
+		// Any recording already running captures the failed call
+		w.recordMessage("request", "tool_call", "record_start", "proxy", request)
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to start recording: %v", err))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "record_start", "proxy", result)
+		return result, nil
+	}
 
-	toolResult := mcp.NewToolResultText(result)
-	toolResult = w.addRecordingMetadata(toolResult)
-	w.recordMessage("response", "tool_call", "server_remove", "proxy", toolResult)
-	return toolResult, nil
+	// Recording starts with this call, so the request is captured too
+	w.recordMessage("request", "tool_call", "record_start", "proxy", request)
+
+	result := mcp.NewToolResultText(fmt.Sprintf("Recording started: %s", absPath))
+	result = w.addRecordingMetadata(result)
+	w.recordMessage("response", "tool_call", "record_start", "proxy", result)
+	return result, nil
 }
 
-func (w *DynamicWrapper) handleServerList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (w *DynamicWrapper) handleRecordStop(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Record the request
-	w.recordMessage("request", "tool_call", "server_list", "proxy", request)
+	w.recordMessage("request", "tool_call", "record_stop", "proxy", request)
 
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	
-	var result strings.Builder
-	result.WriteString("Connected MCP Servers:\n")
-	result.WriteString("=====================\n\n")
-	
-	// List static servers from initial config
-	staticCount := len(w.proxyServer.config.Servers)
-	if staticCount > 0 {
-		result.WriteString("Static servers (from config):\n")
-		for _, server := range w.proxyServer.config.Servers {
-			result.WriteString(fmt.Sprintf("- %s [static]\n", server.Name))
-		}
-		result.WriteString("\n")
-	}
-	
-	// List dynamic servers
-	if len(w.dynamicServers) == 0 && staticCount == 0 {
-		result.WriteString("No servers connected.\n")
-	} else if len(w.dynamicServers) > 0 {
-		result.WriteString("Dynamic servers:\n")
-		for name, info := range w.dynamicServers {
-			status := "connected"
-			if !info.IsConnected {
-				status = "disconnected"
-				if info.ErrorMessage != "" {
-					status = fmt.Sprintf("disconnected (%s)", info.ErrorMessage)
-				}
-			}
-			result.WriteString(fmt.Sprintf("- %s [%s] - %d tools\n", name, status, len(info.Tools)))
-			
-			// List first few tools
-			if len(info.Tools) > 0 && len(info.Tools) <= 5 {
-				for _, tool := range info.Tools {
-					result.WriteString(fmt.Sprintf("  • %s\n", tool))
-				}
-			} else if len(info.Tools) > 5 {
-				for i := 0; i < 3; i++ {
-					result.WriteString(fmt.Sprintf("  • %s\n", info.Tools[i]))
-				}
-				result.WriteString(fmt.Sprintf("  • ... and %d more\n", len(info.Tools)-3))
-			}
-		}
+	status := w.GetRecordingStatus()
+	if err := w.DisableRecording(); err != nil {
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to stop recording: %v", err))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "record_stop", "proxy", result)
+		return result, nil
 	}
-	
-	totalServers := staticCount + len(w.dynamicServers)
-	result.WriteString(fmt.Sprintf("\nTotal servers: %d (static: %d, dynamic: %d)\n",
-		totalServers, staticCount, len(w.dynamicServers)))
 
-	toolResult := mcp.NewToolResultText(result.String())
-	toolResult = w.addRecordingMetadata(toolResult)
-	w.recordMessage("response", "tool_call", "server_list", "proxy", toolResult)
-	return toolResult, nil
+	duration := time.Since(status.StartTime).Round(time.Millisecond)
+	result := mcp.NewToolResultText(fmt.Sprintf("Recording stopped: %s\nMessages: %d\nDuration: %s",
+		status.Filename, status.Messages, duration))
+	result = w.addRecordingMetadata(result)
+	w.recordMessage("response", "tool_call", "record_stop", "proxy", result)
+	return result, nil
 }
 
 func (w *DynamicWrapper) handleServerDisconnect(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -523,48 +1606,29 @@ func (w *DynamicWrapper) handleServerDisconnect(ctx context.Context, request mcp
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	serverInfo, exists := w.dynamicServers[name]
-	if !exists {
-		result := mcp.NewToolResultError(fmt.Sprintf("Server '%s' not found", name))
+	serverInfo, err := w.lookupServer(name)
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_disconnect", "proxy", result)
 		return result, nil
 	}
-	
+
 	if !serverInfo.IsConnected {
+		if serverInfo.Idle {
+			// Keep it down rather than reconnecting on the next call
+			serverInfo.Idle = false
+			serverInfo.ErrorMessage = "Server disconnected by user"
+		}
 		toolResult := mcp.NewToolResultText(fmt.Sprintf("Server '%s' is already disconnected", name))
 		toolResult = w.addRecordingMetadata(toolResult)
 		w.recordMessage("response", "tool_call", "server_disconnect", "proxy", toolResult)
 		return toolResult, nil
 	}
-	
-	log.Printf("Disconnecting server '%s'", name)
-	
-	// Close client and terminate process
-	if serverInfo.Client != nil {
-		log.Printf("Terminating process for server '%s'", name)
-		if err := serverInfo.Client.Close(); err != nil {
-			log.Printf("Error closing client %s: %v", name, err)
-		}
 
-		// Remove from proxy server's client list to prevent stale references
-		w.proxyServer.mu.Lock()
-		newClients := make([]client.MCPClient, 0, len(w.proxyServer.clients)-1)
-		for _, c := range w.proxyServer.clients {
-			if c.ServerName() != name {
-				newClients = append(newClients, c)
-			}
-		}
-		w.proxyServer.clients = newClients
-		w.proxyServer.mu.Unlock()
-		log.Printf("Removed client '%s' from proxy server's client list", name)
-	}
+	log.Printf("Disconnecting server '%s'", name)
+	w.disconnectServerLocked(serverInfo, "Server disconnected by user")
 
-	// Mark as disconnected but keep tools registered
-	serverInfo.IsConnected = false
-	serverInfo.ErrorMessage = "Server disconnected by user"
-	serverInfo.Client = nil
-	
 	result := fmt.Sprintf("Disconnected server '%s'. Tools remain registered but will return errors.\\nUse server_reconnect to restore with new binary/command.", name)
 	toolResult := mcp.NewToolResultText(result)
 	toolResult = w.addRecordingMetadata(toolResult)
@@ -586,20 +1650,21 @@ func (w *DynamicWrapper) handleServerReconnect(ctx context.Context, request mcp.
 
 	// Get command (optional now)
 	commandStr := request.GetString("command", "")
+	refreshEnv := request.GetBool("refresh_env", false)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	serverInfo, exists := w.dynamicServers[name]
-	if !exists {
-		result := mcp.NewToolResultError(fmt.Sprintf("Server '%s' not found", name))
+	serverInfo, err := w.lookupServer(name)
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
 		result = w.addRecordingMetadata(result)
 		w.recordMessage("response", "tool_call", "server_reconnect", "proxy", result)
 		return result, nil
 	}
 
 	if serverInfo.IsConnected {
-		toolResult := mcp.NewToolResultError(fmt.Sprintf("Server '%s' is still connected. Use server_disconnect first.", name))
+		toolResult := mcp.NewToolResultError(fmt.Sprintf("%v. Use server_disconnect first.", &ServerError{Server: name, Err: ErrServerConnected}))
 		toolResult = w.addRecordingMetadata(toolResult)
 		w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
 		return toolResult, nil
@@ -611,22 +1676,31 @@ func (w *DynamicWrapper) handleServerReconnect(ctx context.Context, request mcp.
 		// Command provided: parse and create new config
 		log.Printf("Reconnecting server '%s' with NEW command: %s", name, commandStr)
 
-		parts := strings.Fields(commandStr)
-		if len(parts) == 0 {
-			result := mcp.NewToolResultError("Invalid command")
+		parts, err := parseCommand(commandStr)
+		if err != nil {
+			result := mcp.NewToolResultError(err.Error())
+			result = w.addRecordingMetadata(result)
+			w.recordMessage("response", "tool_call", "server_reconnect", "proxy", result)
+			return result, nil
+		}
+		if err := w.checkCommandAllowed(parts); err != nil {
+			result := mcp.NewToolResultError(err.Error())
 			result = w.addRecordingMetadata(result)
 			w.recordMessage("response", "tool_call", "server_reconnect", "proxy", result)
 			return result, nil
 		}
 
-		// Create new config (preserves name/prefix, but loses env vars)
+		// Create new config (preserves name/prefix and description, but
+		// loses env vars)
 		serverConfig = config.ServerConfig{
-			Name:      name,
-			Prefix:    serverInfo.Config.Prefix,
-			Transport: "stdio",
-			Command:   parts[0],
-			Args:      parts[1:],
-			Timeout:   "30s",
+			Name:        name,
+			Description: serverInfo.Config.Description,
+			Tags:        serverInfo.Config.Tags,
+			Prefix:      serverInfo.Config.Prefix,
+			NoPrefix:    serverInfo.Config.NoPrefix,
+			Transport:   "stdio",
+			Command:     parts[0],
+			Args:        parts[1:],
 		}
 	} else {
 		// Command omitted: use stored config
@@ -643,39 +1717,19 @@ func (w *DynamicWrapper) handleServerReconnect(ctx context.Context, request mcp.
 		serverConfig = serverInfo.Config
 	}
 
-	// Create and connect new client
-	stdioClient := client.NewStdioClient(serverConfig.Name, serverConfig.Command, serverConfig.Args)
-
-	// Apply inheritance config from stored ServerConfig
-	inheritCfg := serverConfig.ResolveInheritConfig(w.proxyServer.config.Inherit)
-	stdioClient.SetInheritConfig(inheritCfg)
-
-	// Apply environment variables from stored ServerConfig
-	if len(serverConfig.Env) > 0 {
-		var env []string
-		for key, value := range serverConfig.Env {
-			env = append(env, fmt.Sprintf("%s=%s", key, value))
-		}
-		stdioClient.SetEnvironment(env)
+	// A new command, or an explicit refresh, resolves the environment again
+	if commandStr != "" || refreshEnv {
+		serverInfo.EnvSnapshot = nil
 	}
 
-	if err := stdioClient.Connect(ctx); err != nil {
+	// Create and connect new client (preserves stored env and inherit settings)
+	stdioClient, err := w.connectServerClient(ctx, serverConfig, serverInfo.EnvSnapshot, true)
+	if err != nil {
 		// Mark as disconnected but keep tools registered
 		serverInfo.IsConnected = false
-		serverInfo.ErrorMessage = fmt.Sprintf("Failed to connect: %v", err)
-		serverInfo.Config = serverConfig
-		toolResult := mcp.NewToolResultError(fmt.Sprintf("Failed to connect: %v", err))
-		toolResult = w.addRecordingMetadata(toolResult)
-		w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
-		return toolResult, nil
-	}
-
-	if _, err := stdioClient.Initialize(ctx); err != nil {
-		stdioClient.Close()
-		serverInfo.IsConnected = false
-		serverInfo.ErrorMessage = fmt.Sprintf("Failed to initialize: %v", err)
+		serverInfo.ErrorMessage = fmt.Sprintf("Failed to start server: %v", err)
 		serverInfo.Config = serverConfig
-		toolResult := mcp.NewToolResultError(fmt.Sprintf("Failed to initialize: %v", err))
+		toolResult := mcp.NewToolResultError(serverInfo.ErrorMessage)
 		toolResult = w.addRecordingMetadata(toolResult)
 		w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
 		return toolResult, nil
@@ -693,25 +1747,83 @@ func (w *DynamicWrapper) handleServerReconnect(ctx context.Context, request mcp.
 		w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
 		return toolResult, nil
 	}
-	
-	// Update server info (but NOT IsConnected yet - defer until all state updated)
-	serverInfo.Client = stdioClient
+
 	serverInfo.Config = serverConfig
+	w.attachClientLocked(serverInfo, stdioClient, tools)
+
+	// Build result message based on how we reconnected
+	var resultMsg string
+	if commandStr != "" {
+		resultMsg = fmt.Sprintf("Reconnected server '%s' with NEW command: %s %s\nServer now connected and tools updated.",
+			name, serverConfig.Command, strings.Join(serverConfig.Args, " "))
+	} else {
+		resultMsg = fmt.Sprintf("Reconnected server '%s' using STORED configuration\nServer now connected and tools updated.", name)
+	}
+
+	toolResult := mcp.NewToolResultText(resultMsg)
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
+	return toolResult, nil
+}
+
+// disconnectServerLocked closes a server's client but keeps its tools
+// registered, so they report the server as disconnected until it is
+// reconnected. The caller holds w.mu.
+func (w *DynamicWrapper) disconnectServerLocked(serverInfo *DynamicServerInfo, reason string) {
+	name := serverInfo.Name
+
+	// Close client and terminate process
+	if serverInfo.Client != nil {
+		log.Printf("Terminating process for server '%s'", name)
+		if err := serverInfo.Client.Close(); err != nil {
+			log.Printf("Error closing client %s: %v", name, err)
+		}
+
+		// Remove from proxy server's client list to prevent stale references
+		w.proxyServer.mu.Lock()
+		newClients := make([]client.MCPClient, 0, len(w.proxyServer.clients))
+		for _, c := range w.proxyServer.clients {
+			if c.ServerName() != name {
+				newClients = append(newClients, c)
+			}
+		}
+		w.proxyServer.clients = newClients
+		w.proxyServer.mu.Unlock()
+		log.Printf("Removed client '%s' from proxy server's client list", name)
+	}
+
+	// Mark as disconnected but keep tools registered
+	serverInfo.IsConnected = false
+	serverInfo.ErrorMessage = reason
+	serverInfo.Client = nil
+}
+
+// attachClientLocked makes a newly connected client serve a server's
+// registered tools, updating their descriptions and schemas from tools.
+// Tools keep their names; tools the server no longer lists stay registered.
+// The caller holds w.mu.
+func (w *DynamicWrapper) attachClientLocked(serverInfo *DynamicServerInfo, mcpClient client.MCPClient, tools []client.ToolInfo) {
+	name := serverInfo.Name
+
+	// Update server info (but NOT IsConnected yet - defer until all state updated)
+	serverInfo.Client = mcpClient
 	serverInfo.ErrorMessage = ""
+	serverInfo.Idle = false
+	serverInfo.LastCall = time.Now()
 
 	// Update proxy server's client list with proper mutex protection
 	w.proxyServer.mu.Lock()
 	clientFound := false
 	for i, c := range w.proxyServer.clients {
 		if c.ServerName() == name {
-			w.proxyServer.clients[i] = stdioClient
+			w.proxyServer.clients[i] = mcpClient
 			clientFound = true
 			break
 		}
 	}
 	if !clientFound {
 		// Client not in list (was removed by disconnect), append it
-		w.proxyServer.clients = append(w.proxyServer.clients, stdioClient)
+		w.proxyServer.clients = append(w.proxyServer.clients, mcpClient)
 		log.Printf("Added client '%s' to proxy server's client list", name)
 	} else {
 		log.Printf("Updated client '%s' in proxy server's client list", name)
@@ -719,158 +1831,241 @@ func (w *DynamicWrapper) handleServerReconnect(ctx context.Context, request mcp.
 	w.proxyServer.mu.Unlock()
 
 	// Update registry with new client (tools keep same names)
+	registered := make(map[string]discovery.RemoteTool)
+	for _, registeredTool := range w.proxyServer.registry.GetServerTools(name) {
+		registered[registeredTool.OriginalName] = registeredTool
+	}
 	for _, tool := range tools {
-		prefixedName := fmt.Sprintf("%s_%s", name, tool.Name)
-
-		// Check if this tool name exists in our registered tools
-		found := false
-		for _, registeredTool := range serverInfo.Tools {
-			if registeredTool == prefixedName {
-				found = true
-				break
-			}
-		}
-
+		// Only tools registered before the disconnect are updated
+		discoveredTool, found := registered[tool.Name]
 		if found {
+			changed := discoveredTool.Description != tool.Description || !bytes.Equal(discoveredTool.InputSchema, tool.InputSchema)
+
 			// Update registry with new client
-			discoveredTool := discovery.RemoteTool{
-				OriginalName: tool.Name,
-				PrefixedName: prefixedName,
-				Description:  tool.Description,
-				InputSchema:  tool.InputSchema,
-				ServerName:   name,
+			discoveredTool.Description = tool.Description
+			discoveredTool.InputSchema = tool.InputSchema
+			w.proxyServer.registry.RegisterTool(discoveredTool, mcpClient)
+			log.Printf("Updated tool registration: %s", discoveredTool.PrefixedName)
+
+			// The exposed definition is the one clients see; AddTool
+			// replaces it and notifies them of the changed tool list
+			if changed {
+				w.baseServer.AddTool(w.proxyServer.createMCPTool(discoveredTool), w.createDynamicProxyHandler(discoveredTool))
+				log.Printf("Re-registered tool %s with its new description and schema", discoveredTool.PrefixedName)
 			}
-			w.proxyServer.registry.RegisterTool(discoveredTool, stdioClient)
-			log.Printf("Updated tool registration: %s", prefixedName)
 		}
 	}
 
 	// NOW mark as connected (atomic state transition after all updates complete)
 	serverInfo.IsConnected = true
+	w.captureEnvSnapshotLocked(serverInfo)
 	log.Printf("Server '%s' marked as connected", name)
+}
 
-	// Build result message based on how we reconnected
-	var resultMsg string
-	if commandStr != "" {
-		resultMsg = fmt.Sprintf("Reconnected server '%s' with NEW command: %s %s\nServer now connected and tools updated.",
-			name, serverConfig.Command, strings.Join(serverConfig.Args, " "))
-	} else {
-		resultMsg = fmt.Sprintf("Reconnected server '%s' using STORED configuration\nServer now connected and tools updated.", name)
+// createDynamicProxyHandler creates a handler that checks connection status
+func (w *DynamicWrapper) createDynamicProxyHandler(tool discovery.RemoteTool) server.ToolHandlerFunc {
+	serverName := tool.ServerName
+	originalToolName := tool.OriginalName
+	prefixedToolName := tool.PrefixedName
+
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Record the tool call request
+		w.recordToolRequest(prefixedToolName, originalToolName, serverName, request)
+		start := time.Now()
+
+		call := w.callProxiedTool(ctx, tool, request.GetArguments())
+		result := w.addRecordingMetadata(call.Result)
+		if call.Blocked {
+			w.recordBlockedResponse(prefixedToolName, originalToolName, serverName, result, start)
+			return result, nil
+		}
+
+		// The recording keeps the whole result, the client may get it cut
+		w.recordResultResponse(prefixedToolName, originalToolName, serverName, result, call.Untransformed, call.Dropped, start)
+		if call.Dropped > 0 {
+			return w.addRecordingMetadata(call.Limited), nil
+		}
+		return result, nil
 	}
 
-	toolResult := mcp.NewToolResultText(resultMsg)
-	toolResult = w.addRecordingMetadata(toolResult)
-	w.recordMessage("response", "tool_call", "server_reconnect", "proxy", toolResult)
-	return toolResult, nil
+	return w.withMiddleware(w.withTranscript(tool, handler))
 }
 
-// createDynamicProxyHandler creates a handler that checks connection status
-func (w *DynamicWrapper) createDynamicProxyHandler(serverName, originalToolName string) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		// Record the tool call request
-		prefixedToolName := fmt.Sprintf("%s_%s", serverName, originalToolName)
-		w.recordMessage("request", "tool_call", prefixedToolName, serverName, request)
+// proxiedCall is the outcome of forwarding one call to a backend tool
+type proxiedCall struct {
+	Result        *mcp.CallToolResult // Whole result, after the tool's transforms
+	Untransformed *mcp.CallToolResult // Result before the transforms, if any ran
+	Limited       *mcp.CallToolResult // Result cut for maxResultBytes, if Dropped > 0
+	Dropped       int                 // Bytes cut from Limited
+	Blocked       bool                // Not forwarded: blocked by a sanitizer rule
+}
 
-		// Copy client reference while holding lock to prevent use-after-free
-		w.mu.RLock()
-		serverInfo, exists := w.dynamicServers[serverName]
-		var client client.MCPClient
-		if exists && serverInfo.IsConnected {
-			client = serverInfo.Client  // Copy reference
+// callProxiedTool forwards a call to a backend tool the way the tool's own
+// handler does: with the tool's default and hidden arguments, sanitizer
+// rules, rate limit, transforms and maxResultBytes, waking an idle server
+// first. Failures are returned as error results. Nothing is recorded.
+func (w *DynamicWrapper) callProxiedTool(ctx context.Context, tool discovery.RemoteTool, args map[string]interface{}) proxiedCall {
+	serverName := tool.ServerName
+	originalToolName := tool.OriginalName
+	prefixedToolName := tool.PrefixedName
+
+	// Copy client reference while holding lock to prevent use-after-free
+	w.mu.RLock()
+	serverInfo, exists := w.dynamicServers[serverName]
+	var mcpClient client.MCPClient
+	var sanitizer []config.SanitizerRule
+	var rateLimit *config.RateLimitConfig
+	var callTimeout time.Duration
+	var transforms []config.TransformStep
+	var defaults map[string]interface{}
+	var hidden []string
+	var maxResultBytes int
+	var idle bool
+	if exists {
+		sanitizer = serverInfo.Config.Sanitizer
+		transforms = serverInfo.Config.Transforms[originalToolName]
+		defaults = serverInfo.Config.DefaultArguments[originalToolName]
+		hidden = serverInfo.Config.HiddenArguments[originalToolName]
+		maxResultBytes = serverInfo.Config.ResolveMaxResultBytes(w.proxyServer.config.Proxy.MaxResultBytes)
+		rateLimit = serverInfo.Config.RateLimit
+		callTimeout = serverInfo.Config.GetServerTimeout(w.proxyServer.config.Proxy.DefaultTimeout)
+		idle = serverInfo.Idle
+		if serverInfo.IsConnected {
+			mcpClient = serverInfo.Client // Copy reference
 		}
-		w.mu.RUnlock()
+	}
+	w.mu.RUnlock()
 
-		if !exists {
-			result := mcp.NewToolResultError(fmt.Sprintf("Server '%s' not found", serverName))
-			result = w.addRecordingMetadata(result)
-			w.recordMessage("response", "tool_call", prefixedToolName, serverName, result)
-			return result, nil
+	if !exists {
+		return proxiedCall{Result: mcp.NewToolResultError((&ServerError{Server: serverName, Err: ErrServerNotFound}).Error())}
+	}
+
+	if mcpClient == nil && idle {
+		var err error
+		if mcpClient, err = w.wakeIdleServer(ctx, serverName); err != nil {
+			return proxiedCall{Result: mcp.NewToolResultError(fmt.Sprintf("[%s] failed to reconnect idle server: %v", serverName, err))}
 		}
+	}
 
-		if client == nil {
-			// Server disconnected
-			errorMsg := fmt.Sprintf("Server '%s' is disconnected", serverName)
-			if serverInfo.ErrorMessage != "" {
-				errorMsg += fmt.Sprintf(": %s", serverInfo.ErrorMessage)
-			}
-			errorMsg += "\nUse server_reconnect to restore connection."
-			result := mcp.NewToolResultError(errorMsg)
-			result = w.addRecordingMetadata(result)
-			w.recordMessage("response", "tool_call", prefixedToolName, serverName, result)
-			return result, nil
+	if mcpClient == nil {
+		// Server disconnected
+		errorMsg := (&ServerError{Server: serverName, Err: ErrServerDisconnected}).Error()
+		if serverInfo.ErrorMessage != "" {
+			errorMsg += fmt.Sprintf(": %s", serverInfo.ErrorMessage)
 		}
+		errorMsg += "\nUse server_reconnect to restore connection."
+		return proxiedCall{Result: mcp.NewToolResultError(errorMsg)}
+	}
+
+	// Take the arguments on top of the configured defaults
+	argsMap := make(map[string]interface{})
+	for key, value := range defaults {
+		argsMap[key] = value
+	}
+	for key, value := range args {
+		argsMap[key] = value
+	}
+	// Hidden arguments aren't in the client's schema, so always use ours
+	for _, name := range hidden {
+		argsMap[name] = defaults[name]
+	}
+
+	if match, blocked := checkSanitizer(serverName, prefixedToolName, sanitizer, argsMap); blocked {
+		result := mcp.NewToolResultError(fmt.Sprintf("Blocked by proxy: %s. The call was not forwarded to server '%s'.", match, serverName))
+		return proxiedCall{Result: result, Blocked: true}
+	}
 
-		// Extract arguments from the request
-		args := request.GetArguments()
-		argsMap := make(map[string]interface{})
-		for key, value := range args {
-			argsMap[key] = value
+	load := w.load.enqueue(serverName)
+	if rateLimit != nil {
+		if err := w.rateLimiter.wait(ctx, serverName, originalToolName, rateLimit, callTimeout); err != nil {
+			load.abandon()
+			return proxiedCall{Result: mcp.NewToolResultError(fmt.Sprintf("[%s] %v", serverName, err))}
 		}
+	}
 
-		// Forward the call to the remote server using copied client reference
-		// (safe from concurrent disconnect)
-		result, err := client.CallTool(ctx, originalToolName, argsMap)
-		if err != nil {
-			// Mark server as disconnected on connection errors
-			if isConnectionError(err) {
-				w.mu.Lock()
-				serverInfo.IsConnected = false
-				serverInfo.ErrorMessage = err.Error()
-				w.mu.Unlock()
-
-				errorMsg := fmt.Sprintf("Server '%s' connection failed: %v\nUse server_reconnect to restore connection.", serverName, err)
-				result := mcp.NewToolResultError(errorMsg)
-				result = w.addRecordingMetadata(result)
-				w.recordMessage("response", "tool_call", prefixedToolName, serverName, result)
-				return result, nil
-			}
-			
-			// Wrap error with server context
-			errorMsg := fmt.Sprintf("[%s] %v", serverName, err)
-			result := mcp.NewToolResultError(errorMsg)
-			result = w.addRecordingMetadata(result)
-			w.recordMessage("response", "tool_call", prefixedToolName, serverName, result)
-			return result, nil
+	// Forward the call to the remote server using copied client reference
+	// (safe from concurrent disconnect)
+	load.start()
+	w.touchServer(serverName)
+	result, err := mcpClient.CallTool(ctx, originalToolName, argsMap)
+	w.latency.observe(serverName, originalToolName, load.finish())
+	w.touchServer(serverName)
+	if err != nil {
+		// Backend answered with a JSON-RPC error: preserve code and data
+		var clientErr *client.ClientError
+		if errors.As(err, &clientErr) {
+			return proxiedCall{Result: newBackendErrorResult(serverName, clientErr)}
 		}
-		
-		// Transform the result back to MCP format
-		var finalResult *mcp.CallToolResult
-		if result.IsError {
-			if len(result.Content) > 0 {
-				finalResult = mcp.NewToolResultError(result.Content[0].Text)
-			} else {
-				finalResult = mcp.NewToolResultError("Tool execution failed")
-			}
-		} else {
-			// For successful results, convert content to text
-			if len(result.Content) > 0 {
-				var text string
-				for i, content := range result.Content {
-					if i > 0 {
-						text += "\n"
-					}
-					text += content.Text
-				}
-				finalResult = mcp.NewToolResultText(text)
-			} else {
-				finalResult = mcp.NewToolResultText("Tool executed successfully")
-			}
+
+		// Mark server as disconnected on connection errors
+		if isConnectionError(err) {
+			w.mu.Lock()
+			serverInfo.IsConnected = false
+			serverInfo.ErrorMessage = err.Error()
+			w.mu.Unlock()
+
+			errorMsg := fmt.Sprintf("Server '%s' connection failed: %v\nUse server_reconnect to restore connection.", serverName, err)
+			return proxiedCall{Result: mcp.NewToolResultError(errorMsg)}
+		}
+
+		// Wrap error with server context
+		return proxiedCall{Result: mcp.NewToolResultError(fmt.Sprintf("[%s] %v", serverName, err))}
+	}
+
+	// Transform the result back to MCP format
+	w.proxyServer.mu.RLock()
+	flattenText := w.proxyServer.config.GetProxySettings().ResultContent == config.ResultContentText
+	w.proxyServer.mu.RUnlock()
+	finalResult := proxy.TransformResult(result, flattenText)
+
+	// Apply the tool's configured transforms, keeping the original result
+	var untransformed *mcp.CallToolResult
+	if len(transforms) > 0 {
+		transformed, err := applyTransforms(finalResult, transforms)
+		if err != nil {
+			result := mcp.NewToolResultError(fmt.Sprintf("[%s] failed to transform the result of %s: %v", serverName, prefixedToolName, err))
+			return proxiedCall{Result: result, Untransformed: finalResult}
 		}
+		untransformed, finalResult = finalResult, transformed
+	}
 
-		finalResult = w.addRecordingMetadata(finalResult)
-		w.recordMessage("response", "tool_call", prefixedToolName, serverName, finalResult)
-		return finalResult, nil
+	// Cut a result too large for the client
+	limited, dropped := limitResultSize(finalResult, maxResultBytes)
+	return proxiedCall{Result: finalResult, Untransformed: untransformed, Limited: limited, Dropped: dropped}
+}
+
+// newBackendErrorResult converts a backend JSON-RPC error into an MCP error
+// result. The original code, message and data are kept in the result's _meta
+// under "error" so clients can react programmatically.
+func newBackendErrorResult(serverName string, clientErr *client.ClientError) *mcp.CallToolResult {
+	result := mcp.NewToolResultError(fmt.Sprintf("[%s] %s (code: %d)", serverName, clientErr.Message, clientErr.Code))
+
+	backendError := map[string]any{
+		"code":    clientErr.Code,
+		"message": clientErr.Message,
+		"server":  serverName,
+	}
+	if clientErr.Data != nil {
+		backendError["data"] = clientErr.Data
 	}
+	result.Meta = mcp.NewMetaFromMap(map[string]any{"error": backendError})
+
+	return result
 }
 
-// isConnectionError checks if an error indicates a connection problem
+// isConnectionError reports whether err is a failure of the connection to
+// the backend rather than an error from the tool itself. Only typed errors
+// count: a tool error whose text mentions "closed" or "timeout" must not
+// disconnect the server.
 func isConnectionError(err error) bool {
-	errStr := strings.ToLower(err.Error())
-	return strings.Contains(errStr, "connection") ||
-		strings.Contains(errStr, "broken pipe") ||
-		strings.Contains(errStr, "eof") ||
-		strings.Contains(errStr, "closed") ||
-		strings.Contains(errStr, "timeout")
+	var transportErr *client.TransportError
+	var connErr *ConnectionError
+	if errors.As(err, &transportErr) || errors.As(err, &connErr) || errors.Is(err, ErrServerDisconnected) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, os.ErrDeadlineExceeded)
 }
 
 // Initialize initializes the proxy with static servers
@@ -888,10 +2083,19 @@ func (w *DynamicWrapper) Initialize(ctx context.Context) error {
 	// Create dynamic handlers for ALL tools (including static servers)
 	// This allows hot-swapping to work correctly for all servers
 	w.createHandlersForAllTools()
+	w.registerCompositeTools()
 
+	w.logStartupSummary()
+	w.startIdleReaper()
 	return nil
 }
 
+// InitResult returns the per-server outcome of Initialize, or nil before
+// Initialize has run
+func (w *DynamicWrapper) InitResult() *InitResult {
+	return w.proxyServer.InitResult()
+}
+
 // populateStaticServers adds static servers from config to dynamicServers map
 func (w *DynamicWrapper) populateStaticServers() error {
 	w.mu.Lock()
@@ -927,16 +2131,15 @@ func (w *DynamicWrapper) populateStaticServers() error {
 				ErrorMessage: "",
 			}
 			w.dynamicServers[serverConfig.Name] = serverInfo
+			w.captureEnvSnapshotLocked(serverInfo)
+			w.watchNotifications(serverConfig.Name, matchingClient)
 			log.Printf("Added static server '%s' to dynamic management with %d tools",
 				serverConfig.Name, len(serverTools))
 		} else {
 			// FAILED: No client, but still add to enable reconnect
 			var errorMsg string
-			for _, result := range w.proxyServer.discoveryResults {
-				if result.ServerName == serverConfig.Name && result.Error != nil {
-					errorMsg = result.Error.Error()
-					break
-				}
+			if result, ok := w.proxyServer.initResult.Server(serverConfig.Name); ok {
+				errorMsg = result.Error
 			}
 			if errorMsg == "" {
 				errorMsg = "Failed to connect during initialization"
@@ -945,7 +2148,7 @@ func (w *DynamicWrapper) populateStaticServers() error {
 			serverInfo := &DynamicServerInfo{
 				Name:         serverConfig.Name,
 				Client:       nil,
-				Config:       serverConfig,  // Store config for reconnect
+				Config:       serverConfig, // Store config for reconnect
 				Tools:        []string{},
 				IsConnected:  false,
 				ErrorMessage: errorMsg,
@@ -967,16 +2170,11 @@ func (w *DynamicWrapper) createHandlersForAllTools() {
 
 	for _, tool := range allTools {
 		// Create MCP tool definition, preserving upstream inputSchema
-		description := fmt.Sprintf("[%s] %s", tool.ServerName, tool.Description)
-		var mcpTool mcp.Tool
-		if len(tool.InputSchema) > 0 {
-			mcpTool = mcp.NewToolWithRawSchema(tool.PrefixedName, description, tool.InputSchema)
-		} else {
-			mcpTool = mcp.NewTool(tool.PrefixedName, mcp.WithDescription(description))
-		}
+		// minus the server's hidden arguments
+		mcpTool := w.proxyServer.createMCPTool(tool)
 
 		// Create dynamic handler that looks up client at call time
-		handler := w.createDynamicProxyHandler(tool.ServerName, tool.OriginalName)
+		handler := w.createDynamicProxyHandler(tool)
 
 		// Register with MCP server
 		w.baseServer.AddTool(mcpTool, handler)
@@ -985,8 +2183,57 @@ func (w *DynamicWrapper) createHandlersForAllTools() {
 	}
 }
 
-// Start starts the MCP server
+// Start starts the MCP server and blocks until stdin is closed or
+// SIGINT/SIGTERM is received
 func (w *DynamicWrapper) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return w.StartContext(ctx)
+}
+
+// StartContext serves MCP over stdio until ctx is cancelled or stdin is
+// closed, then shuts down all backend connections
+func (w *DynamicWrapper) StartContext(ctx context.Context) error {
 	log.Println("Starting Dynamic MCP Proxy Server with management tools...")
-	return server.ServeStdio(w.baseServer)
-}
\ No newline at end of file
+	return serveStdio(ctx, w.baseServer, os.Stdin, os.Stdout, w.Shutdown)
+}
+
+// Shutdown closes all backend connections and stops any active recording
+func (w *DynamicWrapper) Shutdown(ctx context.Context) error {
+	log.Println("Shutting down dynamic proxy...")
+
+	var errs []error
+
+	w.mu.Lock()
+	if w.stopIdle != nil {
+		close(w.stopIdle)
+		w.stopIdle = nil
+	}
+	for name, info := range w.dynamicServers {
+		if info.Client == nil || !info.IsConnected {
+			continue
+		}
+		if err := info.Client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close client %s: %w", name, err))
+		}
+		info.IsConnected = false
+	}
+	w.mu.Unlock()
+
+	if w.GetRecordingStatus().Enabled {
+		if err := w.DisableRecording(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := w.stopTraceTap(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors during shutdown: %v", errs)
+	}
+
+	log.Println("Dynamic proxy shutdown complete")
+	return nil
+}
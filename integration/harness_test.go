@@ -0,0 +1,258 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/config"
+)
+
+// testBackendEnv makes the test binary act as a stdio MCP backend, so
+// round-trip tests need no separately built server
+const testBackendEnv = "MCP_DEBUG_TEST_BACKEND"
+
+// testBackendDelayEnv makes the test backend wait this long (a duration)
+// before serving, to stand in for a slow-starting server
+const testBackendDelayEnv = "MCP_DEBUG_TEST_BACKEND_DELAY"
+
+// testBackendLogEnv makes the test backend advertise logging and send this
+// text as a log message every 100ms, whether or not a request is in flight
+const testBackendLogEnv = "MCP_DEBUG_TEST_BACKEND_LOG"
+
+// testBackendListenEnv makes the test backend listen on a local TCP port
+// and write its address to this file, to stand in for a service other
+// servers depend on
+const testBackendListenEnv = "MCP_DEBUG_TEST_BACKEND_LISTEN"
+
+// testBackendNeedsEnv makes the test backend exit at startup unless it can
+// connect to the address in this file, to stand in for a server that needs
+// its dependency running
+const testBackendNeedsEnv = "MCP_DEBUG_TEST_BACKEND_NEEDS"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testBackendEnv) == "1" {
+		runTestBackend()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runTestBackend serves a tiny MCP server with echo and reverse tools on
+// stdio. tools/list is paginated one tool per page so the proxy has to
+// follow nextCursor to see both.
+func runTestBackend() {
+	if delay, err := time.ParseDuration(os.Getenv(testBackendDelayEnv)); err == nil {
+		time.Sleep(delay)
+	}
+
+	if addressFile := os.Getenv(testBackendListenEnv); addressFile != "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test backend: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(addressFile, []byte(listener.Addr().String()), 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "test backend: %v\n", err)
+			os.Exit(1)
+		}
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+	}
+	if addressFile := os.Getenv(testBackendNeedsEnv); addressFile != "" {
+		address, err := os.ReadFile(addressFile)
+		if err == nil {
+			var conn net.Conn
+			if conn, err = net.Dial("tcp", string(address)); err == nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test backend: dependency not running: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	options := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithPaginationLimit(1),
+	}
+	logText := os.Getenv(testBackendLogEnv)
+	if logText != "" {
+		options = append(options, server.WithLogging())
+	}
+	s := server.NewMCPServer("Test Backend", "1.0.0", options...)
+	if logText != "" {
+		go func() {
+			for range time.Tick(100 * time.Millisecond) {
+				s.SendNotificationToAllClients("notifications/message", map[string]any{"level": "info", "data": logText})
+			}
+		}()
+	}
+	s.AddTool(mcp.NewTool("echo",
+		mcp.WithDescription("Echo the message back"),
+		mcp.WithString("message", mcp.Required()),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("echo: " + request.GetString("message", "")), nil
+	})
+	s.AddTool(mcp.NewTool("reverse",
+		mcp.WithDescription("Reverse the message"),
+		mcp.WithString("message", mcp.Required()),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		runes := []rune(request.GetString("message", ""))
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return mcp.NewToolResultText(string(runes)), nil
+	})
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Fprintf(os.Stderr, "test backend: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// testBackendConfig returns a server config that launches the test backend
+func testBackendConfig(name string) config.ServerConfig {
+	return config.ServerConfig{
+		Name:      name,
+		Prefix:    name,
+		Transport: "stdio",
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=^$"},
+		Env:       map[string]string{testBackendEnv: "1"},
+	}
+}
+
+// startTestProxy initializes a DynamicWrapper for cfg, serves it over
+// in-memory pipes and returns an initialized MCP client talking to it.
+// Everything is torn down when the test ends.
+func startTestProxy(t *testing.T, cfg *config.ProxyConfig) (*DynamicWrapper, *mcpclient.Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+
+	w := NewDynamicWrapper(cfg)
+	if err := w.Initialize(ctx); err != nil {
+		cancel()
+		t.Fatalf("initialize proxy: %v", err)
+	}
+
+	// client -> proxy and proxy -> client pipes
+	proxyIn, clientOut := io.Pipe()
+	clientIn, proxyOut := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStdio(ctx, w.baseServer, proxyIn, proxyOut, w.Shutdown)
+	}()
+
+	c := mcpclient.NewClient(transport.NewIO(clientIn, clientOut, nil))
+	t.Cleanup(func() {
+		c.Close()
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("proxy exited with error: %v", err)
+		}
+	})
+
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("start client: %v", err)
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "harness", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		t.Fatalf("initialize client: %v", err)
+	}
+
+	return w, c
+}
+
+func TestStdioRoundTrip(t *testing.T) {
+	_, c := startTestProxy(t, &config.ProxyConfig{
+		Servers: []config.ServerConfig{testBackendConfig("backend")},
+	})
+	ctx := context.Background()
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	// Both pages of the backend's paginated tools/list must be registered
+	found := make(map[string]bool)
+	for _, tool := range tools.Tools {
+		found[tool.Name] = true
+	}
+	if !found["backend_echo"] || !found["backend_reverse"] {
+		t.Fatalf("expected backend_echo and backend_reverse in tool list, got %d tools", len(tools.Tools))
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "backend_echo"
+	request.Params.Arguments = map[string]interface{}{"message": "hello"}
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		t.Fatalf("call tool: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("tool returned error: %v", result.Content)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "echo: hello" {
+		t.Errorf("expected 'echo: hello', got %q", text)
+	}
+}
+
+func TestStdioRoundTripReconnect(t *testing.T) {
+	_, c := startTestProxy(t, &config.ProxyConfig{
+		Servers: []config.ServerConfig{testBackendConfig("backend")},
+	})
+	ctx := context.Background()
+
+	call := func(name string, args map[string]interface{}) *mcp.CallToolResult {
+		t.Helper()
+		request := mcp.CallToolRequest{}
+		request.Params.Name = name
+		request.Params.Arguments = args
+		result, err := c.CallTool(ctx, request)
+		if err != nil {
+			t.Fatalf("call %s: %v", name, err)
+		}
+		return result
+	}
+
+	if result := call("server_disconnect", map[string]interface{}{"name": "backend"}); result.IsError {
+		t.Fatalf("server_disconnect failed: %v", result.Content)
+	}
+	if result := call("backend_echo", map[string]interface{}{"message": "hi"}); !result.IsError {
+		t.Fatal("expected error calling tool of disconnected server")
+	}
+
+	if result := call("server_reconnect", map[string]interface{}{"name": "backend"}); result.IsError {
+		t.Fatalf("server_reconnect failed: %v", result.Content)
+	}
+	result := call("backend_echo", map[string]interface{}{"message": "again"})
+	if result.IsError {
+		t.Fatalf("tool call after reconnect failed: %v", result.Content)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "echo: again" {
+		t.Errorf("expected 'echo: again', got %q", text)
+	}
+}
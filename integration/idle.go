@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"mcp-debug/client"
+)
+
+// defaultIdleCheckInterval is how often servers are checked against their
+// idleTimeout
+const defaultIdleCheckInterval = 5 * time.Second
+
+// startIdleReaper disconnects idle servers in the background until Shutdown
+func (w *DynamicWrapper) startIdleReaper() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopIdle != nil {
+		return
+	}
+	stop := make(chan struct{})
+	w.stopIdle = stop
+	interval := w.idleCheckInterval
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				w.disconnectIdleServers(now)
+			}
+		}
+	}()
+}
+
+// disconnectIdleServers disconnects the servers whose idleTimeout has passed
+// since their last tool call, keeping their tools registered, and returns
+// their names. Servers with calls queued or in flight are never idle. A
+// server that hasn't been called yet is timed from the first check.
+func (w *DynamicWrapper) disconnectIdleServers(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var disconnected []string
+	for _, name := range w.sortedServerNames() {
+		info := w.dynamicServers[name]
+		timeout := info.Config.GetIdleTimeout()
+		if timeout == 0 || !info.IsConnected || w.load.busy(name) {
+			continue
+		}
+		if info.LastCall.IsZero() {
+			info.LastCall = now
+			continue
+		}
+		if now.Sub(info.LastCall) < timeout {
+			continue
+		}
+
+		log.Printf("Server '%s' idle for %v, disconnecting until its next tool call", name, now.Sub(info.LastCall).Round(time.Second))
+		w.disconnectServerLocked(info, fmt.Sprintf("Idle for more than %v; reconnects on the next tool call", timeout))
+		info.Idle = true
+		disconnected = append(disconnected, name)
+	}
+	return disconnected
+}
+
+// idleWake is a reconnect of an idle server in progress, shared by the
+// calls that arrive while it runs
+type idleWake struct {
+	done   chan struct{}
+	client client.MCPClient
+	err    error
+}
+
+// wakeIdleServer reconnects a server disconnected by its idleTimeout with
+// its stored config and returns the new client. The server is started
+// without holding w.mu, so calls to other servers aren't held up by it;
+// concurrent calls wait for the first reconnect and share its client.
+func (w *DynamicWrapper) wakeIdleServer(ctx context.Context, name string) (client.MCPClient, error) {
+	w.mu.Lock()
+	serverInfo, err := w.lookupServer(name)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	if serverInfo.IsConnected && serverInfo.Client != nil {
+		w.mu.Unlock()
+		return serverInfo.Client, nil
+	}
+	if wake := serverInfo.waking; wake != nil {
+		w.mu.Unlock()
+		select {
+		case <-wake.done:
+			return wake.client, wake.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !serverInfo.Idle {
+		// Disconnected by other means while this call waited
+		w.mu.Unlock()
+		return nil, &ServerError{Server: name, Err: ErrServerDisconnected}
+	}
+	wake := &idleWake{done: make(chan struct{})}
+	serverInfo.waking = wake
+	serverConfig, envSnapshot := serverInfo.Config, serverInfo.EnvSnapshot
+	w.mu.Unlock()
+
+	defer close(wake.done)
+
+	log.Printf("Reconnecting idle server '%s'", name)
+	w.spawnMu.RLock()
+	tools, mcpClient, err := w.startServerClient(ctx, serverConfig, envSnapshot)
+	w.spawnMu.RUnlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	serverInfo.waking = nil
+
+	switch {
+	case err != nil:
+		// Stays idle, so the next call tries again
+		serverInfo.ErrorMessage = fmt.Sprintf("Idle reconnect failed: %v", err)
+		wake.err = err
+	case w.dynamicServers[name] != serverInfo || !serverInfo.Idle:
+		// Removed, reloaded or disconnected while it started
+		mcpClient.Close()
+		wake.err = &ServerError{Server: name, Err: ErrServerDisconnected}
+	case serverInfo.IsConnected && serverInfo.Client != nil:
+		// Reconnected by server_reconnect in the meantime
+		mcpClient.Close()
+		wake.client = serverInfo.Client
+	default:
+		w.attachClientLocked(serverInfo, mcpClient, tools)
+		wake.client = mcpClient
+	}
+	return wake.client, wake.err
+}
+
+// touchServer marks a tool call to serverName for idleTimeout
+func (w *DynamicWrapper) touchServer(serverName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if info, exists := w.dynamicServers[serverName]; exists {
+		info.LastCall = time.Now()
+	}
+}
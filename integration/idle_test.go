@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestIdleServerDisconnectsAndReconnectsOnNextCall(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	connects := 0
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		connects++
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{
+		{Name: "lazy", Prefix: "lazy", Transport: "stdio", Command: "fake-server", IdleTimeout: "1m"},
+		{Name: "busy", Prefix: "busy", Transport: "stdio", Command: "fake-server"},
+	}}
+	if _, err := w.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	// The first check starts the clock for servers not called yet
+	now := time.Now()
+	if idle := w.disconnectIdleServers(now); len(idle) != 0 {
+		t.Fatalf("expected no idle servers on the first check, got %v", idle)
+	}
+	if idle := w.disconnectIdleServers(now.Add(30 * time.Second)); len(idle) != 0 {
+		t.Fatalf("expected lazy to stay connected within its idleTimeout, got %v", idle)
+	}
+	idle := w.disconnectIdleServers(now.Add(2 * time.Minute))
+	if len(idle) != 1 || idle[0] != "lazy" {
+		t.Fatalf("expected only lazy to be disconnected, got %v", idle)
+	}
+	info := w.dynamicServers["lazy"]
+	if info.IsConnected || !info.Idle || w.baseServer.GetTool("lazy_read") == nil {
+		t.Fatalf("expected lazy idle with its tools registered, got %+v", info)
+	}
+	if text := resultText(callTool(t, w.handleServerStatus, map[string]interface{}{"name": "lazy"})); !strings.Contains(text, "lazy [idle]") {
+		t.Errorf("expected server_status to show lazy as idle, got:\n%s", text)
+	}
+
+	// The next tool call reconnects it
+	result := callTool(t, w.baseServer.GetTool("lazy_read").Handler, nil)
+	if result.IsError {
+		t.Fatalf("expected the call to succeed after reconnecting, got %q", resultText(result))
+	}
+	if !info.IsConnected || info.Idle || connects != 3 {
+		t.Errorf("expected lazy reconnected once, got %+v (%d connects)", info, connects)
+	}
+	if idle := w.disconnectIdleServers(time.Now().Add(30 * time.Second)); len(idle) != 0 {
+		t.Errorf("expected the call to reset the idle clock, got %v", idle)
+	}
+}
+
+func TestIdleReconnectDoesNotBlockOtherServers(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{
+		{Name: "lazy", Prefix: "lazy", Transport: "stdio", Command: "fake-server", IdleTimeout: "1m"},
+		{Name: "busy", Prefix: "busy", Transport: "stdio", Command: "fake-server"},
+	}}
+	if _, err := w.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	now := time.Now()
+	w.disconnectIdleServers(now)
+	if idle := w.disconnectIdleServers(now.Add(2 * time.Minute)); len(idle) != 1 {
+		t.Fatalf("expected lazy to be disconnected, got %v", idle)
+	}
+
+	// Starting lazy again blocks until released
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	wakes := 0
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		mu.Lock()
+		wakes++
+		mu.Unlock()
+		close(started)
+		<-release
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+
+	results := make(chan *mcp.CallToolResult, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- callTool(t, w.baseServer.GetTool("lazy_read").Handler, nil)
+		}()
+	}
+	<-started
+
+	busy := make(chan *mcp.CallToolResult, 1)
+	go func() {
+		busy <- callTool(t, w.baseServer.GetTool("busy_read").Handler, nil)
+	}()
+	select {
+	case result := <-busy:
+		if result.IsError {
+			t.Errorf("expected the call to busy to succeed, got %q", resultText(result))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a call to busy to go through while lazy starts")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if result := <-results; result.IsError {
+			t.Errorf("expected the call to lazy to succeed, got %q", resultText(result))
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if wakes != 1 {
+		t.Errorf("expected the waiting calls to share one reconnect, got %d", wakes)
+	}
+}
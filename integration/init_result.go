@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"time"
+
+	"mcp-debug/config"
+)
+
+// ServerInitStatus is the startup outcome of one configured server
+type ServerInitStatus string
+
+const (
+	ServerInitConnected       ServerInitStatus = "connected"
+	ServerInitDiscoveryFailed ServerInitStatus = "discovery_failed" // Connecting or listing tools failed
+	ServerInitConnectFailed   ServerInitStatus = "connect_failed"   // Tools were listed, but the persistent client failed to connect
+	ServerInitDeferred        ServerInitStatus = "deferred"         // Not connected within proxy.startupBudget
+)
+
+// ServerInitResult describes how one configured server fared in Initialize
+type ServerInitResult struct {
+	Name     string           `json:"name"`
+	Status   ServerInitStatus `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Tools    int              `json:"tools"`    // Tools registered for the server
+	Duration time.Duration    `json:"duration"` // Time taken by discovery
+}
+
+// InitResult is the per-server breakdown of Initialize, in config order.
+// It is kept when Initialize fails, so callers can report which servers
+// were at fault; servers it did not get to have an empty Status.
+type InitResult struct {
+	Servers   []ServerInitResult `json:"servers"`
+	Connected int                `json:"connected"`
+	Failed    int                `json:"failed"`
+	Tools     int                `json:"tools"`
+}
+
+// Partial reports whether some servers connected and others failed
+func (r *InitResult) Partial() bool {
+	return r.Connected > 0 && r.Failed > 0
+}
+
+// FailedServers returns the servers that failed to start
+func (r *InitResult) FailedServers() []ServerInitResult {
+	var failed []ServerInitResult
+	for _, server := range r.Servers {
+		if server.Status == ServerInitDiscoveryFailed || server.Status == ServerInitConnectFailed || server.Status == ServerInitDeferred {
+			failed = append(failed, server)
+		}
+	}
+	return failed
+}
+
+// Server returns the result of the named server
+func (r *InitResult) Server(name string) (ServerInitResult, bool) {
+	for _, server := range r.Servers {
+		if server.Name == name {
+			return server, true
+		}
+	}
+	return ServerInitResult{}, false
+}
+
+// newInitResult creates an InitResult with an entry per configured server
+func newInitResult(servers []config.ServerConfig) *InitResult {
+	result := &InitResult{Servers: make([]ServerInitResult, len(servers))}
+	for i, serverConfig := range servers {
+		result.Servers[i] = ServerInitResult{Name: serverConfig.Name}
+	}
+	return result
+}
+
+// record sets the outcome of the named server and updates the totals
+func (r *InitResult) record(name string, status ServerInitStatus, tools int, duration time.Duration, err error) {
+	for i := range r.Servers {
+		server := &r.Servers[i]
+		if server.Name != name {
+			continue
+		}
+		server.Status = status
+		server.Tools = tools
+		server.Duration = duration
+		if err != nil {
+			server.Error = err.Error()
+		}
+		if status == ServerInitConnected {
+			r.Connected++
+			r.Tools += tools
+		} else {
+			r.Failed++
+		}
+		return
+	}
+}
+
+// InitResult returns the per-server outcome of Initialize, or nil before
+// Initialize has run
+func (p *ProxyServer) InitResult() *InitResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.initResult == nil {
+		return nil
+	}
+	result := *p.initResult
+	result.Servers = append([]ServerInitResult(nil), p.initResult.Servers...)
+	return &result
+}
@@ -0,0 +1,256 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets. A
+// last, unbounded bucket counts the calls slower than all of them.
+var latencyBounds = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+}
+
+// maxLatencySamples bounds the raw samples kept per tool; older ones are
+// overwritten
+const maxLatencySamples = 256
+
+// ToolLatency is the backend latency distribution of one tool
+type ToolLatency struct {
+	Tool      string          `json:"tool"` // Original (unprefixed) name
+	Count     int             `json:"count"`
+	MeanMs    float64         `json:"mean_ms"`
+	Buckets   []LatencyBucket `json:"buckets"`
+	SamplesMs []float64       `json:"samples_ms"` // The latest calls, oldest first
+}
+
+// LatencyBucket counts the calls that took at most LE and more than the
+// previous bucket's bound. The last bucket's LE is "+Inf".
+type LatencyBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+// toolLatency accumulates the latencies of one tool
+type toolLatency struct {
+	buckets []int // len(latencyBounds)+1
+	count   int
+	sum     time.Duration
+	samples []time.Duration // Ring buffer of up to maxLatencySamples
+	next    int             // Ring position of the next sample once full
+}
+
+// latencyStats keeps a latency histogram and recent samples per server and
+// tool, from the time the call reached the backend until it returned
+type latencyStats struct {
+	mu      sync.Mutex
+	servers map[string]map[string]*toolLatency // server -> original tool name -> stats
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{servers: make(map[string]map[string]*toolLatency)}
+}
+
+// observe records one call
+func (s *latencyStats) observe(serverName, tool string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tools, ok := s.servers[serverName]
+	if !ok {
+		tools = make(map[string]*toolLatency)
+		s.servers[serverName] = tools
+	}
+	stats, ok := tools[tool]
+	if !ok {
+		stats = &toolLatency{buckets: make([]int, len(latencyBounds)+1)}
+		tools[tool] = stats
+	}
+
+	stats.buckets[sort.Search(len(latencyBounds), func(i int) bool { return latency <= latencyBounds[i] })]++
+	stats.count++
+	stats.sum += latency
+	if len(stats.samples) < maxLatencySamples {
+		stats.samples = append(stats.samples, latency)
+	} else {
+		stats.samples[stats.next] = latency
+		stats.next = (stats.next + 1) % maxLatencySamples
+	}
+}
+
+// snapshot returns the latency of serverName's tools, sorted by tool name.
+// A non-empty tool limits it to that tool.
+func (s *latencyStats) snapshot(serverName, tool string) []ToolLatency {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latencies []ToolLatency
+	for name, stats := range s.servers[serverName] {
+		if tool != "" && name != tool {
+			continue
+		}
+		latency := ToolLatency{
+			Tool:      name,
+			Count:     stats.count,
+			MeanMs:    milliseconds(stats.sum / time.Duration(stats.count)),
+			Buckets:   make([]LatencyBucket, 0, len(stats.buckets)),
+			SamplesMs: make([]float64, 0, len(stats.samples)),
+		}
+		for i, count := range stats.buckets {
+			le := "+Inf"
+			if i < len(latencyBounds) {
+				le = latencyBounds[i].String()
+			}
+			latency.Buckets = append(latency.Buckets, LatencyBucket{LE: le, Count: count})
+		}
+		for i := range stats.samples {
+			sample := stats.samples[(stats.next+i)%len(stats.samples)]
+			latency.SamplesMs = append(latency.SamplesMs, milliseconds(sample))
+		}
+		latencies = append(latencies, latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].Tool < latencies[j].Tool })
+	return latencies
+}
+
+// reset clears the stats of serverName, or of one of its tools, and returns
+// how many tools were cleared
+func (s *latencyStats) reset(serverName, tool string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tools := s.servers[serverName]
+	if tool == "" {
+		delete(s.servers, serverName)
+		return len(tools)
+	}
+	if _, ok := tools[tool]; !ok {
+		return 0
+	}
+	delete(tools, tool)
+	return 1
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Latency returns the backend latency distribution of a server's tools.
+// Tools appear once they have been called.
+func (w *DynamicWrapper) Latency(serverName string) []ToolLatency {
+	return w.latency.snapshot(serverName, "")
+}
+
+func (w *DynamicWrapper) handleServerLatency(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_latency", "proxy", request)
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		result := mcp.NewToolResultError("name is required")
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_latency", "proxy", result)
+		return result, nil
+	}
+	tool := request.GetString("tool", "")
+	format := request.GetString("format", "text")
+
+	w.mu.RLock()
+	_, err = w.lookupServer(name)
+	w.mu.RUnlock()
+	if err == nil && format != "text" && format != "json" {
+		err = fmt.Errorf("invalid format %q (must be text or json)", format)
+	}
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_latency", "proxy", result)
+		return result, nil
+	}
+
+	latencies := w.latency.snapshot(name, tool)
+
+	var result strings.Builder
+	if format == "json" {
+		if latencies == nil {
+			latencies = []ToolLatency{}
+		}
+		data, err := json.MarshalIndent(latencies, "", "  ")
+		if err != nil {
+			result := mcp.NewToolResultError(fmt.Sprintf("Failed to encode latency stats: %v", err))
+			result = w.addRecordingMetadata(result)
+			w.recordMessage("response", "tool_call", "server_latency", "proxy", result)
+			return result, nil
+		}
+		result.Write(data)
+	} else {
+		result.WriteString("Server Latency:\n")
+		result.WriteString("===============\n\n")
+		result.WriteString(fmt.Sprintf("Server: %s\n\n", name))
+		if len(latencies) == 0 {
+			result.WriteString("No tool calls recorded yet.\n")
+		}
+		for _, latency := range latencies {
+			result.WriteString(fmt.Sprintf("%s: %d calls, mean %.1fms\n", latency.Tool, latency.Count, latency.MeanMs))
+			for _, bucket := range latency.Buckets {
+				if bucket.Count > 0 {
+					result.WriteString(fmt.Sprintf("  <= %-6s %d\n", bucket.LE, bucket.Count))
+				}
+			}
+			result.WriteString(fmt.Sprintf("  Last %d samples kept; use format=json to export them\n\n", len(latency.SamplesMs)))
+		}
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_latency", "proxy", toolResult)
+	return toolResult, nil
+}
+
+func (w *DynamicWrapper) handleServerStatsReset(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_stats_reset", "proxy", request)
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		result := mcp.NewToolResultError("name is required")
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_stats_reset", "proxy", result)
+		return result, nil
+	}
+	tool := request.GetString("tool", "")
+
+	w.mu.RLock()
+	_, err = w.lookupServer(name)
+	w.mu.RUnlock()
+	if err != nil {
+		result := mcp.NewToolResultError(err.Error())
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_stats_reset", "proxy", result)
+		return result, nil
+	}
+
+	cleared := w.latency.reset(name, tool)
+	message := fmt.Sprintf("Reset latency stats of %d tools on server '%s'", cleared, name)
+	if tool != "" {
+		message = fmt.Sprintf("Reset latency stats of tool '%s' on server '%s'", tool, name)
+		if cleared == 0 {
+			message = fmt.Sprintf("No latency stats recorded for tool '%s' on server '%s'", tool, name)
+		}
+	}
+
+	toolResult := mcp.NewToolResultText(message)
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_stats_reset", "proxy", toolResult)
+	return toolResult, nil
+}
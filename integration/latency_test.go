@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/discovery"
+)
+
+func TestLatencyHistogramAndSamples(t *testing.T) {
+	stats := newLatencyStats()
+	stats.observe("api", "search", 3*time.Millisecond)
+	stats.observe("api", "search", 4*time.Millisecond)
+	stats.observe("api", "search", 800*time.Millisecond)
+	stats.observe("api", "search", time.Minute)
+	stats.observe("api", "fetch", time.Millisecond)
+
+	latencies := stats.snapshot("api", "search")
+	if len(latencies) != 1 {
+		t.Fatalf("expected one tool, got %+v", latencies)
+	}
+	search := latencies[0]
+	counts := make(map[string]int)
+	for _, bucket := range search.Buckets {
+		counts[bucket.LE] = bucket.Count
+	}
+	if counts["5ms"] != 2 || counts["1s"] != 1 || counts["+Inf"] != 1 || search.Count != 4 {
+		t.Errorf("unexpected buckets %+v", search.Buckets)
+	}
+	if len(search.SamplesMs) != 4 || search.SamplesMs[0] != 3 || search.SamplesMs[3] != 60000 {
+		t.Errorf("unexpected samples %v", search.SamplesMs)
+	}
+
+	// The sample buffer is bounded and keeps the latest calls in order
+	for i := 0; i < maxLatencySamples+10; i++ {
+		stats.observe("api", "fetch", time.Duration(i)*time.Millisecond)
+	}
+	fetch := stats.snapshot("api", "fetch")[0]
+	if len(fetch.SamplesMs) != maxLatencySamples || fetch.SamplesMs[0] != 10 || fetch.SamplesMs[maxLatencySamples-1] != maxLatencySamples+9 {
+		t.Errorf("expected the latest %d samples oldest first, got %v...%v", maxLatencySamples, fetch.SamplesMs[0], fetch.SamplesMs[len(fetch.SamplesMs)-1])
+	}
+
+	if cleared := stats.reset("api", "fetch"); cleared != 1 {
+		t.Errorf("expected one tool reset, got %d", cleared)
+	}
+	if latencies := stats.snapshot("api", ""); len(latencies) != 1 || latencies[0].Tool != "search" {
+		t.Errorf("expected only search left, got %+v", latencies)
+	}
+}
+
+func TestServerLatencyAndStatsResetTools(t *testing.T) {
+	fake := client.NewFakeClient("api", client.ToolInfo{Name: "search"})
+	w := newTestWrapper(t, "api", fake)
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "search",
+		PrefixedName: "api_search",
+		ServerName:   "api",
+	})
+	callTool(t, handler, nil)
+	callTool(t, handler, nil)
+
+	text := resultText(callTool(t, w.handleServerLatency, map[string]interface{}{"name": "api", "format": "json"}))
+	var latencies []ToolLatency
+	if err := json.Unmarshal([]byte(text), &latencies); err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", text, err)
+	}
+	if len(latencies) != 1 || latencies[0].Tool != "search" || latencies[0].Count != 2 || len(latencies[0].SamplesMs) != 2 {
+		t.Fatalf("unexpected latency export %+v", latencies)
+	}
+
+	if result := callTool(t, w.handleServerLatency, map[string]interface{}{"name": "missing"}); !result.IsError {
+		t.Error("expected an error for an unknown server")
+	}
+
+	text = resultText(callTool(t, w.handleServerStatsReset, map[string]interface{}{"name": "api"}))
+	if !strings.Contains(text, "1 tools") {
+		t.Errorf("expected one tool reset, got %q", text)
+	}
+	text = resultText(callTool(t, w.handleServerLatency, map[string]interface{}{"name": "api"}))
+	if !strings.Contains(text, "No tool calls recorded yet") {
+		t.Errorf("expected empty stats after reset, got:\n%s", text)
+	}
+}
+
+func TestServerRemoveDropsLatency(t *testing.T) {
+	fake := client.NewFakeClient("api", client.ToolInfo{Name: "search"})
+	w := newTestWrapper(t, "api", fake)
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "search",
+		PrefixedName: "api_search",
+		ServerName:   "api",
+	})
+	callTool(t, handler, nil)
+
+	if result := callTool(t, w.handleServerRemove, map[string]interface{}{"name": "api"}); result.IsError {
+		t.Fatalf("server_remove failed: %s", resultText(result))
+	}
+	if latencies := w.Latency("api"); len(latencies) != 0 {
+		t.Errorf("expected the removed server's latency dropped, got %+v", latencies)
+	}
+}
@@ -0,0 +1,199 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// loadWindow is the period recent throughput and latency are measured over
+const loadWindow = time.Minute
+
+// ServerLoad describes the current and recent tool call load of one server
+type ServerLoad struct {
+	Server       string        `json:"server"`
+	InFlight     int           `json:"in_flight"`      // Calls waiting on the backend
+	Queued       int           `json:"queued"`         // Calls waiting on the rate limiter
+	RecentCalls  int           `json:"recent_calls"`   // Calls completed within loadWindow
+	AvgQueueWait time.Duration `json:"avg_queue_wait"` // Mean rate limiter wait of recent calls
+	AvgLatency   time.Duration `json:"avg_latency"`    // Mean backend time of recent calls
+	TotalCalls   int           `json:"total_calls"`
+}
+
+// completedCall is one finished call kept for the recent window
+type completedCall struct {
+	end       time.Time
+	queueWait time.Duration
+	latency   time.Duration
+}
+
+// serverLoadCounters are the live counters for one server
+type serverLoadCounters struct {
+	inFlight int
+	queued   int
+	total    int
+	recent   []completedCall // Oldest first
+}
+
+// loadTracker counts in-flight, queued and completed tool calls per server
+type loadTracker struct {
+	mu      sync.Mutex
+	servers map[string]*serverLoadCounters
+	now     func() time.Time
+}
+
+func newLoadTracker() *loadTracker {
+	return &loadTracker{
+		servers: make(map[string]*serverLoadCounters),
+		now:     time.Now,
+	}
+}
+
+// counters returns the counters for serverName. The caller holds t.mu.
+func (t *loadTracker) counters(serverName string) *serverLoadCounters {
+	counters, ok := t.servers[serverName]
+	if !ok {
+		counters = &serverLoadCounters{}
+		t.servers[serverName] = counters
+	}
+	return counters
+}
+
+// callLoad tracks one tool call through its queued and in-flight phases
+type callLoad struct {
+	tracker    *loadTracker
+	serverName string
+	queuedAt   time.Time
+	startedAt  time.Time
+}
+
+// enqueue marks a call as waiting on the rate limiter
+func (t *loadTracker) enqueue(serverName string) *callLoad {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counters(serverName).queued++
+	return &callLoad{tracker: t, serverName: serverName, queuedAt: t.now()}
+}
+
+// start moves a call from queued to in flight
+func (c *callLoad) start() {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+	counters := c.tracker.counters(c.serverName)
+	counters.queued--
+	counters.inFlight++
+	c.startedAt = c.tracker.now()
+}
+
+// abandon removes a call that never reached the backend
+func (c *callLoad) abandon() {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+	c.tracker.counters(c.serverName).queued--
+}
+
+// finish records a call that reached the backend as completed and returns
+// its backend latency
+func (c *callLoad) finish() time.Duration {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+	now := c.tracker.now()
+	latency := now.Sub(c.startedAt)
+	counters := c.tracker.counters(c.serverName)
+	counters.inFlight--
+	counters.total++
+	counters.recent = append(counters.recent, completedCall{
+		end:       now,
+		queueWait: c.startedAt.Sub(c.queuedAt),
+		latency:   latency,
+	})
+	counters.prune(now)
+	return latency
+}
+
+// prune drops completed calls older than loadWindow
+func (c *serverLoadCounters) prune(now time.Time) {
+	cutoff := now.Add(-loadWindow)
+	i := 0
+	for i < len(c.recent) && c.recent[i].end.Before(cutoff) {
+		i++
+	}
+	c.recent = c.recent[i:]
+}
+
+// busy reports whether serverName has calls queued or in flight
+func (t *loadTracker) busy(serverName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counters, ok := t.servers[serverName]
+	return ok && (counters.inFlight > 0 || counters.queued > 0)
+}
+
+// snapshot returns the load of every server that has seen calls, sorted
+// by server name
+func (t *loadTracker) snapshot() []ServerLoad {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	loads := make([]ServerLoad, 0, len(t.servers))
+	for name, counters := range t.servers {
+		counters.prune(now)
+		load := ServerLoad{
+			Server:      name,
+			InFlight:    counters.inFlight,
+			Queued:      counters.queued,
+			RecentCalls: len(counters.recent),
+			TotalCalls:  counters.total,
+		}
+		if n := len(counters.recent); n > 0 {
+			var queueWait, latency time.Duration
+			for _, call := range counters.recent {
+				queueWait += call.queueWait
+				latency += call.latency
+			}
+			load.AvgQueueWait = queueWait / time.Duration(n)
+			load.AvgLatency = latency / time.Duration(n)
+		}
+		loads = append(loads, load)
+	}
+	sort.Slice(loads, func(i, j int) bool { return loads[i].Server < loads[j].Server })
+	return loads
+}
+
+// Load returns the live tool call load of each server
+func (w *DynamicWrapper) Load() []ServerLoad {
+	return w.load.snapshot()
+}
+
+func (w *DynamicWrapper) handleProxyLoad(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "proxy_load", "proxy", request)
+
+	loads := w.Load()
+
+	var result strings.Builder
+	result.WriteString("Proxy Load:\n")
+	result.WriteString("===========\n\n")
+	if len(loads) == 0 {
+		result.WriteString("No tool calls yet.\n")
+	}
+	for _, load := range loads {
+		result.WriteString(fmt.Sprintf("%s\n", load.Server))
+		result.WriteString(fmt.Sprintf("  In flight: %d\n", load.InFlight))
+		result.WriteString(fmt.Sprintf("  Queued (rate limit): %d\n", load.Queued))
+		result.WriteString(fmt.Sprintf("  Last %v: %d calls, avg queue wait %v, avg backend latency %v\n",
+			loadWindow, load.RecentCalls, load.AvgQueueWait.Round(time.Millisecond), load.AvgLatency.Round(time.Millisecond)))
+		result.WriteString(fmt.Sprintf("  Total calls: %d\n\n", load.TotalCalls))
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "proxy_load", "proxy", toolResult)
+	return toolResult, nil
+}
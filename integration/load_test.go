@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+func TestProxyLoadCountsQueuedAndInFlightCalls(t *testing.T) {
+	fake := client.NewFakeClient("api", client.ToolInfo{Name: "search"})
+	w := newTestWrapper(t, "api", fake)
+	w.dynamicServers["api"].Config.RateLimit = &config.RateLimitConfig{RequestsPerSecond: 1}
+
+	backend := make(chan struct{})
+	fake.SetCallToolFunc(func(name string, args map[string]interface{}) (*client.CallToolResult, error) {
+		<-backend
+		return &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "ok"}}}, nil
+	})
+	limiter := make(chan struct{})
+	w.rateLimiter.sleep = func(ctx context.Context, d time.Duration) error {
+		<-limiter
+		return nil
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "search",
+		PrefixedName: "api_search",
+		ServerName:   "api",
+	})
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			callTool(t, handler, nil)
+			done <- struct{}{}
+		}()
+	}
+
+	// The first call holds the only token and waits on the backend; the
+	// second waits on the rate limiter
+	waitForLoad(t, w, func(load ServerLoad) bool { return load.InFlight == 1 && load.Queued == 1 })
+
+	close(limiter)
+	waitForLoad(t, w, func(load ServerLoad) bool { return load.InFlight == 2 && load.Queued == 0 })
+
+	close(backend)
+	<-done
+	<-done
+	load := w.Load()[0]
+	if load.InFlight != 0 || load.RecentCalls != 2 || load.TotalCalls != 2 {
+		t.Errorf("unexpected load after calls completed: %+v", load)
+	}
+
+	text := resultText(callTool(t, w.handleProxyLoad, nil))
+	if !strings.Contains(text, "api") || !strings.Contains(text, "2 calls") {
+		t.Errorf("unexpected proxy_load output:\n%s", text)
+	}
+}
+
+// waitForLoad polls the api server's load until ready returns true
+func waitForLoad(t *testing.T, w *DynamicWrapper, ready func(ServerLoad) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, load := range w.Load() {
+			if load.Server == "api" && ready(load) {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("load never reached the expected state: %+v", w.Load())
+}
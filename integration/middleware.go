@@ -0,0 +1,40 @@
+package integration
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ToolMiddleware wraps the handler for a proxied tool call. Middleware can
+// inspect or rewrite the request, short-circuit with its own result, or
+// post-process the result returned by next. The prefixed tool name is
+// available as request.Params.Name.
+type ToolMiddleware func(next server.ToolHandlerFunc) server.ToolHandlerFunc
+
+// Use registers middleware for proxied tool calls. Middleware registered
+// first runs outermost. It applies to all backend tools, including those
+// registered before Use was called, but not to management tools.
+func (w *DynamicWrapper) Use(middleware ...ToolMiddleware) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.middleware = append(w.middleware, middleware...)
+}
+
+// withMiddleware wraps handler so that the middleware chain registered at
+// call time is applied around it
+func (w *DynamicWrapper) withMiddleware(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		w.mu.RLock()
+		chain := w.middleware
+		w.mu.RUnlock()
+
+		wrapped := handler
+		for i := len(chain) - 1; i >= 0; i-- {
+			wrapped = chain[i](wrapped)
+		}
+		return wrapped(ctx, request)
+	}
+}
@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/client"
+	"mcp-debug/discovery"
+)
+
+func TestMiddlewareOrderAndShortCircuit(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "backend"}}})
+	w := newTestWrapper(t, "fake", fake)
+
+	// Handler created before middleware is registered must still pick it up
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+
+	var order []string
+	trace := func(name string) ToolMiddleware {
+		return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+			return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				order = append(order, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	w.Use(trace("outer"), trace("inner"))
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "backend" {
+		t.Errorf("expected backend result, got %q", text)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("expected [outer inner], got %v", order)
+	}
+
+	// Middleware can answer without calling the backend
+	w.Use(func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError("rate limited"), nil
+		}
+	})
+	calls := len(fake.Calls())
+
+	result, _ = handler(context.Background(), mcp.CallToolRequest{})
+	if text := result.Content[0].(mcp.TextContent).Text; !result.IsError || text != "rate limited" {
+		t.Errorf("expected short-circuit result, got %q", text)
+	}
+	if len(fake.Calls()) != calls {
+		t.Error("expected backend not to be called")
+	}
+}
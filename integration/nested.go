@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+)
+
+// hasRecordingMetadata reports whether a result already carries the
+// recording annotation added by addRecordingMetadata
+func hasRecordingMetadata(result *mcp.CallToolResult) bool {
+	if result.Meta != nil && result.Meta.AdditionalFields[recordingMetaKey] != nil {
+		return true
+	}
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok && strings.HasPrefix(text.Text, recordingMetadataPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNestedProxy reports whether a backend's tools look like those of another
+// mcp-debug proxy, which exposes its management tools next to the tools of
+// its own backends
+func isNestedProxy(toolNames []string) bool {
+	var proxyInfo, serverList bool
+	for _, name := range toolNames {
+		proxyInfo = proxyInfo || name == "proxy_info"
+		serverList = serverList || name == "server_list"
+	}
+	return proxyInfo && serverList
+}
+
+// toolInfoNames returns the names of tools
+func toolInfoNames(tools []client.ToolInfo) []string {
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+// logNestedProxy points out how to get clean tool names when serverName
+// is another mcp-debug proxy
+func logNestedProxy(serverName string, toolNames []string) {
+	if isNestedProxy(toolNames) {
+		log.Printf("Server '%s' looks like a nested mcp-debug proxy; its tools are already prefixed, so consider noPrefix, or flattenPrefix when both use the same prefix", serverName)
+	}
+}
@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestNestedProxyBackend(t *testing.T) {
+	// The inner proxy serves its fs backend's tools under the fs prefix,
+	// next to its own management tools, and annotates results with its
+	// recording file
+	inner := client.NewFakeClient("fs",
+		client.ToolInfo{Name: "fs_read"}, client.ToolInfo{Name: "proxy_info"}, client.ToolInfo{Name: "server_list"})
+	inner.SetToolResult("fs_read", &client.CallToolResult{Content: []client.ContentItem{
+		{Type: "text", Text: "file contents"},
+		{Type: "text", Text: recordingMetadataPrefix + "inner.jsonl\n   Full path: /tmp/inner.jsonl"},
+	}})
+	if !isNestedProxy(toolInfoNames([]client.ToolInfo{{Name: "fs_read"}, {Name: "proxy_info"}, {Name: "server_list"}})) {
+		t.Error("expected the inner proxy to be detected")
+	}
+
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return inner })
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{
+		{Name: "fs", Prefix: "fs", FlattenPrefix: true, Transport: "stdio", Command: "mcp-debug"},
+	}}
+	if _, err := w.Reload(context.Background(), cfg); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	// Already prefixed tools keep their name; the rest are prefixed as usual
+	if w.baseServer.GetTool("fs_read") == nil || w.baseServer.GetTool("fs_fs_read") != nil {
+		t.Error("expected fs_read not to be prefixed twice")
+	}
+	if w.baseServer.GetTool("fs_proxy_info") == nil {
+		t.Error("expected the inner management tools to be prefixed")
+	}
+
+	if err := w.EnableRecording(filepath.Join(t.TempDir(), "outer.jsonl")); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	defer w.DisableRecording()
+
+	result := callTool(t, w.baseServer.GetTool("fs_read").Handler, nil)
+	annotations := 0
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok && strings.HasPrefix(text.Text, recordingMetadataPrefix) {
+			annotations++
+		}
+	}
+	if annotations != 1 || len(result.Content) != 2 {
+		t.Errorf("expected the inner proxy's single recording annotation, got %#v", result.Content)
+	}
+}
@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"context"
+	"time"
+
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// Proxy is the embeddable MCP debug proxy. It serves the tools of the
+// configured servers together with the dynamic management tools
+// (server_add, server_remove, record_start, ...) over stdio.
+//
+// A typical embedding:
+//
+//	p := integration.New(cfg)
+//	if err := p.Initialize(ctx); err != nil { ... }
+//	defer p.Shutdown(context.Background())
+//	err := p.Start(ctx) // returns when ctx is cancelled or stdin closes
+type Proxy struct {
+	wrapper *DynamicWrapper
+}
+
+// New creates a proxy for the given configuration
+func New(cfg *config.ProxyConfig) *Proxy {
+	return &Proxy{
+		wrapper: NewDynamicWrapper(cfg),
+	}
+}
+
+// Initialize connects to the configured servers and registers their tools.
+// By default starting without any tools is allowed, so servers can be added
+// later with server_add; with onNoTools "exit" it returns an error wrapping
+// ErrNoToolsDiscovered instead.
+func (p *Proxy) Initialize(ctx context.Context) error {
+	return p.wrapper.Initialize(ctx)
+}
+
+// Start serves MCP over stdin/stdout until ctx is cancelled or stdin is
+// closed, then closes all backend connections. Cancellation is not
+// reported as an error.
+func (p *Proxy) Start(ctx context.Context) error {
+	return p.wrapper.StartContext(ctx)
+}
+
+// Shutdown closes all backend connections and stops any active recording
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	return p.wrapper.Shutdown(ctx)
+}
+
+// Reload applies a new configuration, connecting, reconnecting or removing
+// servers whose config changed. See DynamicWrapper.Reload.
+func (p *Proxy) Reload(ctx context.Context, cfg *config.ProxyConfig) (*ReloadResult, error) {
+	return p.wrapper.Reload(ctx, cfg)
+}
+
+// Tools returns the backend tools currently exposed by the proxy
+func (p *Proxy) Tools() []discovery.RemoteTool {
+	return p.wrapper.proxyServer.GetRegisteredTools()
+}
+
+// Use registers middleware around proxied tool calls
+func (p *Proxy) Use(middleware ...ToolMiddleware) {
+	p.wrapper.Use(middleware...)
+}
+
+// EnableRecording starts recording tool call traffic to filename
+func (p *Proxy) EnableRecording(filename string) error {
+	return p.wrapper.EnableRecording(filename)
+}
+
+// StartTraceTap streams recorded messages as newline-delimited JSON to
+// clients connecting to address, "unix:PATH" or "tcp:HOST:PORT"
+func (p *Proxy) StartTraceTap(address string) error {
+	return p.wrapper.StartTraceTap(address)
+}
+
+// ToggleRecording stops an active recording or starts a new one
+func (p *Proxy) ToggleRecording() error {
+	return p.wrapper.ToggleRecording()
+}
+
+// SetMaxMessageLogBytes caps the size of individual recorded messages
+func (p *Proxy) SetMaxMessageLogBytes(limit int) {
+	p.wrapper.SetMaxMessageLogBytes(limit)
+}
+
+// SetRecordFlushPolicy sets how recorded messages are flushed to disk
+func (p *Proxy) SetRecordFlushPolicy(policy FlushPolicy, interval time.Duration) error {
+	return p.wrapper.SetRecordFlushPolicy(policy, interval)
+}
+
+// SetStartupSummaryFormat sets how Initialize logs the startup summary
+func (p *Proxy) SetStartupSummaryFormat(format SummaryFormat) error {
+	return p.wrapper.SetStartupSummaryFormat(format)
+}
+
+// StartupSummary returns the state of the configured servers
+func (p *Proxy) StartupSummary() *StartupSummary {
+	return p.wrapper.StartupSummary()
+}
+
+// InitResult returns the per-server outcome of Initialize, including when
+// it failed, or nil before Initialize has run
+func (p *Proxy) InitResult() *InitResult {
+	return p.wrapper.InitResult()
+}
@@ -2,9 +2,19 @@ package integration
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 	
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -15,6 +25,10 @@ import (
 	"mcp-debug/proxy"
 )
 
+// connectRetryDelay is the backoff step between connect attempts; the wait
+// grows linearly with each retry
+const connectRetryDelay = 500 * time.Millisecond
+
 // ProxyServer manages the complete MCP proxy server
 type ProxyServer struct {
 	config           *config.ProxyConfig
@@ -25,19 +39,49 @@ type ProxyServer struct {
 	discoveryResults []*discovery.DiscoveryResult // Store for populateStaticServers access
 	recorderFunc     proxy.RecorderFunc // Optional recorder for tool call traffic
 	metadataFunc     func(*mcp.CallToolResult) *mcp.CallToolResult // Optional metadata injector
+	toolConflicts    []ToolConflict // Duplicate tool decisions, in registration order
+	initResult       *InitResult    // Per-server outcome of Initialize
+	record           config.RecordSettings // config.Record, guarded by recordMu rather than mu
 
 	mu           sync.RWMutex
+	recordMu     sync.RWMutex
 	initialized  bool
 }
 
+// ToolConflict records how a tool name clash between two servers was resolved
+type ToolConflict struct {
+	ToolName      string // Original tool name on the backend
+	ServerName    string // Server whose tool clashed
+	ConflictsWith string // Server that registered the name first
+	Action        config.DuplicateToolPolicy
+	RegisteredAs  string // Exposed tool name, empty if the tool was skipped
+}
+
+// String returns a human-readable description of the decision
+func (c ToolConflict) String() string {
+	outcome := "skipped"
+	if c.RegisteredAs != "" {
+		outcome = "registered as " + c.RegisteredAs
+	}
+	return fmt.Sprintf("tool %q from %s conflicts with %s (%s): %s",
+		c.ToolName, c.ServerName, c.ConflictsWith, c.Action, outcome)
+}
+
 // NewProxyServer creates a new proxy server with the given configuration
 func NewProxyServer(cfg *config.ProxyConfig) *ProxyServer {
-	return &ProxyServer{
+	p := &ProxyServer{
 		config:     cfg,
+		record:     cfg.Record,
 		registry:   proxy.NewToolRegistry(),
 		discoverer: discovery.NewDiscoverer(cfg),
 		clients:    make([]client.MCPClient, 0),
 	}
+	// Discovery opens the persistent connections, so the startup budget
+	// bounds connecting and retrying too
+	p.discoverer.SetConnectFunc(func(ctx context.Context, serverConfig config.ServerConfig) (client.MCPClient, error) {
+		return p.createAndConnectClient(ctx, serverConfig.Name)
+	})
+	return p
 }
 
 // Initialize sets up the proxy server by connecting to all remote servers and discovering tools
@@ -55,21 +99,32 @@ func (p *ProxyServer) Initialize(ctx context.Context) error {
 	// (DynamicWrapper pre-assigns this before calling Initialize)
 	if p.mcpServer == nil {
 		p.mcpServer = server.NewMCPServer(
-			"Dynamic MCP Proxy",
-			"1.0.0",
+			p.config.Proxy.ServerName(),
+			p.config.Proxy.ServerVersion(),
 			server.WithToolCapabilities(true),
 		)
 	}
 	
-	// Discover tools from all configured servers
+	// Discover tools from all configured servers, keeping their clients
+	// connected. With a startup budget, servers still connecting (or
+	// retrying) when it runs out are deferred: they are added disconnected
+	// and can be reconnected later.
+	startCtx := ctx
+	if budget := p.config.Proxy.GetStartupBudget(); budget > 0 {
+		var cancel context.CancelFunc
+		cause := fmt.Errorf("%w: not connected within %v", ErrStartupBudgetExceeded, budget)
+		startCtx, cancel = context.WithTimeoutCause(ctx, budget, cause)
+		defer cancel()
+	}
 	log.Println("Discovering tools from remote servers...")
-	results, err := p.discoverer.DiscoverAll(ctx)
+	results, err := p.discoverer.DiscoverAll(startCtx)
 	if err != nil {
 		return fmt.Errorf("failed to discover tools: %w", err)
 	}
 
 	// Store results for populateStaticServers to access
 	p.discoveryResults = results
+	p.initResult = newInitResult(p.config.Servers)
 
 	// Process discovery results
 	successfulResults := discovery.GetSuccessfulResults(results)
@@ -79,39 +134,95 @@ func (p *ProxyServer) Initialize(ctx context.Context) error {
 	log.Printf("Discovery complete: %d successful, %d failed", len(successfulResults), len(failedResults))
 	
 	// Report failed discoveries
+	var requiredFailures []string
 	for _, result := range failedResults {
 		log.Printf("Failed to discover tools from %s: %v", result.ServerName, result.Error)
+		var connectErr *discovery.ConnectError
+		status := ServerInitDiscoveryFailed
+		if errors.Is(result.Error, ErrStartupBudgetExceeded) {
+			status = ServerInitDeferred
+		} else if errors.As(result.Error, &connectErr) {
+			status = ServerInitConnectFailed
+		}
+		p.initResult.record(result.ServerName, status, 0, result.Duration, result.Error)
+		if p.serverRequired(result.ServerName) {
+			requiredFailures = append(requiredFailures, fmt.Sprintf("%s (%v)", result.ServerName, result.Error))
+		}
+	}
+	if len(requiredFailures) > 0 {
+		p.closeStartupClients(successfulResults)
+		return fmt.Errorf("%w: %s", ErrRequiredServerFailed, strings.Join(requiredFailures, ", "))
 	}
 	
-	// Process successful discoveries
+	// Process successful discoveries in dependency order so a server's
+	// dependsOn servers are up before it
 	totalTools := 0
-	for _, result := range successfulResults {
+	connected := make(map[string]bool)
+	for _, result := range p.inStartOrder(successfulResults) {
 		log.Printf("Discovered %d tools from %s in %v", result.ToolCount(), result.ServerName, result.Duration)
 		totalTools += result.ToolCount()
-		
-		// Connect to the server and keep client alive
-		mcpClient, err := p.createAndConnectClient(ctx, result.ServerName)
-		if err != nil {
-			log.Printf("Warning: Failed to create persistent client for %s: %v", result.ServerName, err)
+		toolNames := make([]string, 0, len(result.Tools))
+		for _, tool := range result.Tools {
+			toolNames = append(toolNames, tool.OriginalName)
+		}
+		logNestedProxy(result.ServerName, toolNames)
+
+		if dependency := p.missingDependency(result.ServerName, connected); dependency != "" {
+			err := &discovery.DependencyError{Dependency: dependency}
+			log.Printf("Warning: Not connecting %s: %v", result.ServerName, err)
+			result.Client.Close()
+			p.initResult.record(result.ServerName, ServerInitConnectFailed, 0, result.Duration, err)
+			if p.serverRequired(result.ServerName) {
+				requiredFailures = append(requiredFailures, fmt.Sprintf("%s (%v)", result.ServerName, err))
+			}
 			continue
 		}
 		
+		// Keep the client discovery connected
+		mcpClient := result.Client
 		p.clients = append(p.clients, mcpClient)
+		connected[result.ServerName] = true
 		
 		// Register tools in registry
+		registered := 0
+		var conflicts []ToolConflict
 		for _, tool := range result.Tools {
+			tool, register, conflict, err := p.resolveToolConflict(tool)
+			if err != nil {
+				p.closeStartupClients(successfulResults)
+				return err
+			}
+			if conflict != nil {
+				conflicts = append(conflicts, *conflict)
+			}
+			if !register {
+				continue
+			}
 			p.registry.RegisterTool(tool, mcpClient)
+			registered++
 
 			// Note: Handlers will be created by DynamicWrapper using dynamic lookup pattern
 			// This allows hot-swapping to work correctly for static servers
 			log.Printf("Registered tool in registry (handler to be created by wrapper): %s", tool.PrefixedName)
 		}
+		p.recordToolConflicts(conflicts)
+		p.initResult.record(result.ServerName, ServerInitConnected, registered, result.Duration, nil)
+	}
+	
+	if len(requiredFailures) > 0 {
+		p.closeStartupClients(successfulResults)
+		return fmt.Errorf("%w: %s", ErrRequiredServerFailed, strings.Join(requiredFailures, ", "))
 	}
 	
 	log.Printf("Successfully registered %d tools from %d servers", totalTools, len(successfulResults))
 	
-	// Allow starting with zero tools for dynamic management
-	if totalTools == 0 {
+	// Starting with zero tools is allowed for dynamic management unless
+	// the configuration asks to fail instead
+	if len(p.registry.GetAllTools()) == 0 {
+		if p.config.GetProxySettings().OnNoTools == config.NoToolsExit {
+			p.closeStartupClients(successfulResults)
+			return fmt.Errorf("%w (%d of %d servers failed)", ErrNoToolsDiscovered, len(failedResults), len(results))
+		}
 		log.Printf("Starting with no tools - use server_add to add MCP servers dynamically")
 	}
 	
@@ -119,19 +230,77 @@ func (p *ProxyServer) Initialize(ctx context.Context) error {
 	return nil
 }
 
-// Start starts the MCP proxy server
+// closeStartupClients closes the clients of a failed Initialize: those
+// already kept and those discovery left open for results not processed yet
+func (p *ProxyServer) closeStartupClients(results []*discovery.DiscoveryResult) {
+	for _, c := range p.clients {
+		c.Close()
+	}
+	p.clients = nil
+	for _, result := range results {
+		if result.Client != nil {
+			result.Client.Close()
+		}
+	}
+}
+
+// Start starts the MCP proxy server and blocks until stdin is closed or
+// SIGINT/SIGTERM is received
 func (p *ProxyServer) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return p.StartContext(ctx)
+}
+
+// StartContext serves MCP over stdio until ctx is cancelled or stdin is
+// closed, then shuts down all client connections
+func (p *ProxyServer) StartContext(ctx context.Context) error {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
-	
-	if !p.initialized {
+	initialized := p.initialized
+	p.mu.RUnlock()
+
+	if !initialized {
 		return fmt.Errorf("server not initialized - call Initialize() first")
 	}
-	
+
 	log.Println("Starting MCP proxy server...")
-	
-	// Start the MCP server (this blocks)
-	return server.ServeStdio(p.mcpServer)
+	return serveStdio(ctx, p.mcpServer, os.Stdin, os.Stdout, p.Shutdown)
+}
+
+// serveStdio runs mcpServer on in/out in a goroutine and returns when ctx is
+// cancelled or the input stream ends. shutdown is always called before
+// returning so backend clients do not outlive the server; cancellation is
+// not reported as an error.
+func serveStdio(ctx context.Context, mcpServer *server.MCPServer, in io.Reader, out io.Writer, shutdown func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.NewStdioServer(mcpServer).Listen(ctx, in, out)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		// Close clients first so in-flight tool calls fail fast, then wait
+		// for the stdio server to drain
+		if shutdownErr := shutdown(context.Background()); shutdownErr != nil {
+			log.Printf("Shutdown error: %v", shutdownErr)
+		}
+		err = <-done
+	case err = <-done:
+		cancel()
+		if shutdownErr := shutdown(context.Background()); shutdownErr != nil {
+			log.Printf("Shutdown error: %v", shutdownErr)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
 }
 
 // Shutdown gracefully shuts down the proxy server
@@ -158,6 +327,100 @@ func (p *ProxyServer) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// serverRequired reports whether the configured server must connect for
+// startup to succeed
+func (p *ProxyServer) serverRequired(serverName string) bool {
+	for _, serverConfig := range p.config.Servers {
+		if serverConfig.Name == serverName {
+			return serverConfig.Required
+		}
+	}
+	return false
+}
+
+// hiddenArguments returns the arguments of a tool that the configured
+// server hides from clients
+func (p *ProxyServer) hiddenArguments(serverName, toolName string) []string {
+	for _, serverConfig := range p.config.Servers {
+		if serverConfig.Name == serverName {
+			return serverConfig.HiddenArguments[toolName]
+		}
+	}
+	return nil
+}
+
+// hideSchemaArguments removes arguments from an input schema's properties
+// and required list. A schema that isn't a JSON object is returned as is.
+func hideSchemaArguments(toolName string, schema json.RawMessage, hidden []string) json.RawMessage {
+	var object map[string]interface{}
+	if err := json.Unmarshal(schema, &object); err != nil {
+		log.Printf("Warning: arguments of tool %s not hidden: invalid input schema: %v", toolName, err)
+		return schema
+	}
+
+	if properties, ok := object["properties"].(map[string]interface{}); ok {
+		for _, name := range hidden {
+			delete(properties, name)
+		}
+	}
+	if required, ok := object["required"].([]interface{}); ok {
+		kept := make([]interface{}, 0, len(required))
+		for _, name := range required {
+			if !slices.Contains(hidden, fmt.Sprint(name)) {
+				kept = append(kept, name)
+			}
+		}
+		if len(kept) > 0 {
+			object["required"] = kept
+		} else {
+			delete(object, "required")
+		}
+	}
+
+	rewritten, err := json.Marshal(object)
+	if err != nil {
+		return schema
+	}
+	return rewritten
+}
+
+// inStartOrder sorts discovery results by the config's dependency levels.
+// The config was validated, so StartLevels only fails for configs built in
+// code; the results are then kept in config order.
+func (p *ProxyServer) inStartOrder(results []*discovery.DiscoveryResult) []*discovery.DiscoveryResult {
+	levels, err := p.config.StartLevels()
+	if err != nil {
+		return results
+	}
+	position := make(map[string]int, len(p.config.Servers))
+	for _, level := range levels {
+		for _, serverConfig := range level {
+			position[serverConfig.Name] = len(position)
+		}
+	}
+	ordered := append([]*discovery.DiscoveryResult(nil), results...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return position[ordered[i].ServerName] < position[ordered[j].ServerName]
+	})
+	return ordered
+}
+
+// missingDependency returns the first dependsOn server of serverName that
+// is not in connected, or "" if all are
+func (p *ProxyServer) missingDependency(serverName string, connected map[string]bool) string {
+	for _, serverConfig := range p.config.Servers {
+		if serverConfig.Name != serverName {
+			continue
+		}
+		for _, dependency := range serverConfig.DependsOn {
+			if !connected[dependency] {
+				return dependency
+			}
+		}
+	}
+	return ""
+}
+
 // createAndConnectClient creates and connects a client for persistent use
 func (p *ProxyServer) createAndConnectClient(ctx context.Context, serverName string) (client.MCPClient, error) {
 	// Find server config
@@ -172,7 +435,47 @@ func (p *ProxyServer) createAndConnectClient(ctx context.Context, serverName str
 	if serverConfig == nil {
 		return nil, fmt.Errorf("server config not found: %s", serverName)
 	}
-	
+
+	var mcpClient client.MCPClient
+	err := retryConnect(ctx, serverName, p.maxRetries(*serverConfig), func() error {
+		var err error
+		mcpClient, err = p.connectClient(ctx, serverConfig)
+		return err
+	})
+	return mcpClient, err
+}
+
+// maxRetries returns how many times to retry a failed connect to a server:
+// its own maxRetries, or else the proxy's. The caller holds p.mu or w.mu,
+// which both guard replacing p.config.
+func (p *ProxyServer) maxRetries(serverConfig config.ServerConfig) int {
+	return serverConfig.ResolveMaxRetries(*p.config.GetProxySettings().MaxRetries)
+}
+
+// retryConnect calls connect until it succeeds, maxRetries retries have
+// failed or ctx ends, waiting a growing multiple of connectRetryDelay
+// between attempts. connect must leave nothing open when it fails.
+func retryConnect(ctx context.Context, serverName string, maxRetries int, connect func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := connect()
+		if err == nil || attempt >= maxRetries {
+			return err
+		}
+
+		delay := time.Duration(attempt+1) * connectRetryDelay
+		log.Printf("Connecting to %s failed (attempt %d of %d), retrying in %v: %v",
+			serverName, attempt+1, maxRetries+1, delay, err)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// connectClient makes a single attempt to create, connect and initialize a
+// client for serverConfig
+func (p *ProxyServer) connectClient(ctx context.Context, serverConfig *config.ServerConfig) (client.MCPClient, error) {
 	// Create client based on transport
 	var mcpClient client.MCPClient
 	
@@ -184,6 +487,12 @@ func (p *ProxyServer) createAndConnectClient(ctx context.Context, serverName str
 		inheritCfg := serverConfig.ResolveInheritConfig(p.config.Inherit)
 		stdioClient.SetInheritConfig(inheritCfg)
 
+		// Initialize is bounded by the connection timeout, tool calls by the server timeout
+		stdioClient.SetTimeouts(serverConfig.GetConnectionTimeout(p.config.Proxy.ConnectionTimeout), serverConfig.GetServerTimeout(p.config.Proxy.DefaultTimeout))
+		stdioClient.SetResourceLimits(serverConfig.Limits)
+		stdioClient.SetConnectTrace(p.config.Proxy.TraceConnect)
+		stdioClient.SetAllowAnyProtocol(p.config.Proxy.ProtocolMismatch == config.ProtocolMismatchWarn)
+
 		// Set environment variables if specified
 		if len(serverConfig.Env) > 0 {
 			var env []string
@@ -198,25 +507,203 @@ func (p *ProxyServer) createAndConnectClient(ctx context.Context, serverName str
 		return nil, fmt.Errorf("unsupported transport: %s", serverConfig.Transport)
 	}
 	
-	// Connect and initialize
-	if err := mcpClient.Connect(ctx); err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
+	// Connect and initialize. ctx only bounds connecting: the process runs
+	// until the client is closed, so it must not be tied to ctx.
+	if err := mcpClient.Connect(context.WithoutCancel(ctx)); err != nil {
+		return nil, &ConnectionError{Server: serverConfig.Name, Err: err}
 	}
 	
-	if _, err := mcpClient.Initialize(ctx); err != nil {
+	if _, err := initializeClient(ctx, mcpClient, serverConfig.Name, p.recorderFunc); err != nil {
 		mcpClient.Close()
 		return nil, fmt.Errorf("failed to initialize: %w", err)
 	}
+	if err := warmupClient(ctx, mcpClient, *serverConfig, p.recorderFunc); err != nil {
+		mcpClient.Close()
+		return nil, err
+	}
 	
 	return mcpClient, nil
 }
 
-// createMCPTool creates an mcp.Tool from a RemoteTool
+// initializeClient performs the initialize handshake on a connected client.
+// When recorder is set, the request and the backend's response (or a
+// HandshakeError) are recorded with message type "initialize".
+func initializeClient(ctx context.Context, mcpClient client.MCPClient, serverName string, recorder proxy.RecorderFunc) (*client.InitializeResult, error) {
+	if recorder != nil {
+		recorder("request", "initialize", "", serverName, client.NewInitializeParams(client.ProxyClientName, client.ProxyClientVersion))
+	}
+
+	result, err := mcpClient.Initialize(ctx)
+	if recorder != nil {
+		if err != nil {
+			recorder("response", "initialize", "", serverName, HandshakeError{Error: err.Error()})
+		} else {
+			recorder("response", "initialize", "", serverName, result)
+		}
+	}
+	return result, err
+}
+
+// resolveToolConflict applies the duplicate tool policy to a tool about to be
+// registered. It returns the tool to register (possibly renamed), whether it
+// should be registered at all and the conflict, if there was one. Callers
+// record the conflicts with recordToolConflicts once the tools they resolved
+// were added. Under the "error" policy a clash is an error. Callers must hold
+// p.mu.
+func (p *ProxyServer) resolveToolConflict(tool discovery.RemoteTool) (discovery.RemoteTool, bool, *ToolConflict, error) {
+	tool = p.sanitizeToolName(tool)
+
+	// Unprefixed (noPrefix) tools must not shadow the proxy's own tools
+	if _, registered := p.registry.GetTool(tool.PrefixedName); !registered && p.mcpServer != nil && p.mcpServer.GetTool(tool.PrefixedName) != nil {
+		log.Printf("Skipping tool %s from server %s: name is reserved by the proxy", tool.PrefixedName, tool.ServerName)
+		return tool, false, nil, nil
+	}
+
+	// An exposed-name clash (possible with noPrefix servers) takes priority
+	// over an original-name clash, since registering it would replace the
+	// other server's tool
+	var clash *discovery.RemoteTool
+	sameName := false
+	for _, existing := range p.registry.GetAllTools() {
+		if existing.ServerName == tool.ServerName {
+			continue
+		}
+		if existing.PrefixedName == tool.PrefixedName {
+			clash = &existing
+			sameName = true
+			break
+		}
+		if clash == nil && existing.OriginalName == tool.OriginalName {
+			clash = &existing
+		}
+	}
+	if clash == nil {
+		return tool, true, nil, nil
+	}
+
+	conflict := ToolConflict{
+		ToolName:      tool.OriginalName,
+		ServerName:    tool.ServerName,
+		ConflictsWith: clash.ServerName,
+		Action:        p.config.GetProxySettings().DuplicateTools,
+	}
+
+	// "allow" keeps both tools under different names; for an identical
+	// exposed name the first server keeps it. "rename" only renames a tool
+	// whose exposed name is taken; distinct names are kept as they are.
+	if sameName && conflict.Action == config.DuplicateToolsAllow {
+		conflict.Action = config.DuplicateToolsFirstWins
+	}
+	if !sameName && conflict.Action == config.DuplicateToolsRename {
+		conflict.Action = config.DuplicateToolsAllow
+	}
+
+	register := true
+	switch conflict.Action {
+	case config.DuplicateToolsError:
+		register = false
+	case config.DuplicateToolsFirstWins:
+		register = false
+	case config.DuplicateToolsRename:
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s_%d", tool.PrefixedName, n)
+			if _, exists := p.registry.GetTool(candidate); !exists {
+				tool.PrefixedName = candidate
+				break
+			}
+		}
+	}
+	if register {
+		conflict.RegisteredAs = tool.PrefixedName
+	}
+
+	if conflict.Action == config.DuplicateToolsError {
+		return tool, false, nil, fmt.Errorf("duplicate tool %q: server %s conflicts with server %s",
+			tool.OriginalName, tool.ServerName, clash.ServerName)
+	}
+	return tool, register, &conflict, nil
+}
+
+// recordToolConflicts keeps the conflicts resolved for tools that were
+// added, for GetToolConflicts. Callers must hold p.mu.
+func (p *ProxyServer) recordToolConflicts(conflicts []ToolConflict) {
+	for _, conflict := range conflicts {
+		p.toolConflicts = append(p.toolConflicts, conflict)
+		log.Printf("Duplicate tool: %s", conflict)
+	}
+}
+
+// exposedToolName applies proxy.toolNames to a prefixed tool name
+func (p *ProxyServer) exposedToolName(prefixedName string) string {
+	settings := p.config.GetProxySettings()
+	if settings.ToolNames == config.ToolNamesKeep {
+		return prefixedName
+	}
+	return config.SanitizeToolName(prefixedName, settings.ToolNameReplacement)
+}
+
+// sanitizeToolName renames a tool whose exposed name has characters clients
+// may reject. The registry keeps the original name, which is what calls are
+// forwarded with. A sanitized name already taken by another of the server's
+// tools gets a numeric suffix. Callers must hold p.mu.
+func (p *ProxyServer) sanitizeToolName(tool discovery.RemoteTool) discovery.RemoteTool {
+	sanitized := p.exposedToolName(tool.PrefixedName)
+	if sanitized == tool.PrefixedName {
+		return tool
+	}
+
+	candidate := sanitized
+	for n := 2; ; n++ {
+		existing, taken := p.registry.GetTool(candidate)
+		if !taken || existing.ServerName != tool.ServerName || existing.OriginalName == tool.OriginalName {
+			break
+		}
+		candidate = fmt.Sprintf("%s_%d", sanitized, n)
+	}
+
+	log.Printf("Exposing tool %q from server %s as %s: the name has characters clients may reject", tool.PrefixedName, tool.ServerName, candidate)
+	tool.PrefixedName = candidate
+	return tool
+}
+
+// recordSettings returns the record settings of the current config.
+// Recording happens while mu is held, during Initialize, so they have a
+// lock of their own.
+func (p *ProxyServer) recordSettings() config.RecordSettings {
+	p.recordMu.RLock()
+	defer p.recordMu.RUnlock()
+	return p.record
+}
+
+// setConfig replaces the config. The caller holds mu.
+func (p *ProxyServer) setConfig(cfg *config.ProxyConfig) {
+	p.config = cfg
+	p.recordMu.Lock()
+	p.record = cfg.Record
+	p.recordMu.Unlock()
+}
+
+// GetToolConflicts returns the duplicate tool decisions made so far
+func (p *ProxyServer) GetToolConflicts() []ToolConflict {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	conflicts := make([]ToolConflict, len(p.toolConflicts))
+	copy(conflicts, p.toolConflicts)
+	return conflicts
+}
+
+// createMCPTool creates an mcp.Tool from a RemoteTool. Arguments the
+// server's config hides are left out of the input schema.
 func (p *ProxyServer) createMCPTool(remoteTool discovery.RemoteTool) mcp.Tool {
 	description := fmt.Sprintf("[%s] %s", remoteTool.ServerName, remoteTool.Description)
 
 	if len(remoteTool.InputSchema) > 0 {
-		return mcp.NewToolWithRawSchema(remoteTool.PrefixedName, description, remoteTool.InputSchema)
+		schema := remoteTool.InputSchema
+		if hidden := p.hiddenArguments(remoteTool.ServerName, remoteTool.OriginalName); len(hidden) > 0 {
+			schema = hideSchemaArguments(remoteTool.PrefixedName, schema, hidden)
+		}
+		return mcp.NewToolWithRawSchema(remoteTool.PrefixedName, description, schema)
 	}
 
 	return mcp.NewTool(remoteTool.PrefixedName,
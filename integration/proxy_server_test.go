@@ -0,0 +1,284 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+func TestResolveToolConflictUnprefixed(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   config.DuplicateToolPolicy
+		register bool
+		wantName string
+		wantErr  bool
+	}{
+		{"allow keeps first", config.DuplicateToolsAllow, false, "", false},
+		{"first-wins", config.DuplicateToolsFirstWins, false, "", false},
+		{"rename", config.DuplicateToolsRename, true, "read_2", false},
+		{"error", config.DuplicateToolsError, false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{DuplicateTools: tt.policy}})
+			p := w.proxyServer
+			primary := client.NewFakeClient("primary")
+			p.registry.RegisterTool(discovery.CreatePrefixedTool("primary", "", discovery.ToolInfo{Name: "read"}), primary)
+
+			// A second noPrefix server exposing the same name
+			tool, register, _, err := p.resolveToolConflict(discovery.CreatePrefixedTool("other", "", discovery.ToolInfo{Name: "read"}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error result: %v", err)
+			}
+			if register != tt.register {
+				t.Fatalf("expected register=%v, got %v", tt.register, register)
+			}
+			if register && tool.PrefixedName != tt.wantName {
+				t.Errorf("expected %s, got %s", tt.wantName, tool.PrefixedName)
+			}
+			if registered, _ := p.registry.GetTool("read"); registered.ServerName != "primary" {
+				t.Errorf("expected primary to keep 'read', got %s", registered.ServerName)
+			}
+		})
+	}
+}
+
+func TestResolveToolConflictRenameKeepsDistinctNames(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{DuplicateTools: config.DuplicateToolsRename}})
+	p := w.proxyServer
+	p.registry.RegisterTool(discovery.CreatePrefixedTool("fs", "fs", discovery.ToolInfo{Name: "read"}), client.NewFakeClient("fs"))
+
+	// db_read doesn't collide with fs_read, so it keeps its name
+	tool, register, conflict, err := p.resolveToolConflict(discovery.CreatePrefixedTool("db", "db", discovery.ToolInfo{Name: "read"}))
+	if err != nil || !register {
+		t.Fatalf("expected tool to register, got register=%v err=%v", register, err)
+	}
+	if tool.PrefixedName != "db_read" {
+		t.Errorf("expected db_read to keep its name, got %s", tool.PrefixedName)
+	}
+	if conflict == nil || conflict.Action != config.DuplicateToolsAllow {
+		t.Errorf("expected the clash to be allowed, got %+v", conflict)
+	}
+}
+
+func TestToolConflictErrorRecordsNothing(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{DuplicateTools: config.DuplicateToolsError}})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"}); result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	if result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "db", "command": "fake-server"}); !result.IsError {
+		t.Fatal("expected the clashing server_add to fail")
+	}
+	if conflicts := w.proxyServer.GetToolConflicts(); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts recorded for a failed add, got %v", conflicts)
+	}
+}
+
+func TestInitializeToolConflictErrorClosesClients(t *testing.T) {
+	primary := testBackendConfig("primary")
+	primary.Prefix = ""
+	primary.NoPrefix = true
+	other := testBackendConfig("other")
+	other.Prefix = ""
+	other.NoPrefix = true
+
+	w := NewDynamicWrapper(&config.ProxyConfig{
+		Servers: []config.ServerConfig{primary, other},
+		Proxy:   config.ProxySettings{DuplicateTools: config.DuplicateToolsError},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := w.Initialize(ctx); err == nil || !strings.Contains(err.Error(), "duplicate tool") {
+		t.Fatalf("expected a duplicate tool error, got %v", err)
+	}
+	if clients := w.proxyServer.clients; len(clients) != 0 {
+		t.Errorf("expected the connected clients to be closed, got %d left", len(clients))
+	}
+}
+
+func TestResolveToolConflictReservedName(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+
+	_, register, _, err := w.proxyServer.resolveToolConflict(discovery.CreatePrefixedTool("primary", "", discovery.ToolInfo{Name: "server_list"}))
+	if err != nil || register {
+		t.Errorf("expected management tool name to be skipped, got register=%v err=%v", register, err)
+	}
+
+	// A prefixed tool with the same original name is fine
+	_, register, _, err = w.proxyServer.resolveToolConflict(discovery.CreatePrefixedTool("helper", "helper", discovery.ToolInfo{Name: "server_list"}))
+	if err != nil || !register {
+		t.Errorf("expected helper_server_list to register, got register=%v err=%v", register, err)
+	}
+}
+
+func TestSanitizeToolNames(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "foo.bar baz"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	tool := w.baseServer.GetTool("fs_foo_bar_baz")
+	if tool == nil {
+		t.Fatal("expected fs_foo_bar_baz to be exposed")
+	}
+	if w.baseServer.GetTool("fs_foo.bar baz") != nil {
+		t.Error("expected the unsanitized name not to be exposed")
+	}
+
+	// Calls reach the backend under the original name
+	result = callTool(t, tool.Handler, nil)
+	if result.IsError {
+		t.Fatalf("call failed: %s", resultText(result))
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Name != "foo.bar baz" {
+		t.Errorf("expected a call to 'foo.bar baz', got %v", calls)
+	}
+}
+
+func TestSanitizeToolNamesCollision(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	p := w.proxyServer
+	p.registry.RegisterTool(discovery.CreatePrefixedTool("fs", "fs", discovery.ToolInfo{Name: "foo_bar"}), client.NewFakeClient("fs"))
+
+	tool, register, _, err := p.resolveToolConflict(discovery.CreatePrefixedTool("fs", "fs", discovery.ToolInfo{Name: "foo.bar"}))
+	if err != nil || !register {
+		t.Fatalf("expected tool to register, got register=%v err=%v", register, err)
+	}
+	if tool.PrefixedName != "fs_foo_bar_2" || tool.OriginalName != "foo.bar" {
+		t.Errorf("expected fs_foo_bar_2 for foo.bar, got %s for %s", tool.PrefixedName, tool.OriginalName)
+	}
+}
+
+func TestToolNamesKeep(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{ToolNames: config.ToolNamesKeep}})
+
+	tool, _, _, _ := w.proxyServer.resolveToolConflict(discovery.CreatePrefixedTool("fs", "fs", discovery.ToolInfo{Name: "foo.bar"}))
+	if tool.PrefixedName != "fs_foo.bar" {
+		t.Errorf("expected fs_foo.bar, got %s", tool.PrefixedName)
+	}
+
+	w = NewDynamicWrapper(&config.ProxyConfig{Proxy: config.ProxySettings{ToolNameReplacement: "-"}})
+	tool, _, _, _ = w.proxyServer.resolveToolConflict(discovery.CreatePrefixedTool("fs", "fs", discovery.ToolInfo{Name: "foo.bar"}))
+	if tool.PrefixedName != "fs_foo-bar" {
+		t.Errorf("expected fs_foo-bar, got %s", tool.PrefixedName)
+	}
+}
+
+func TestNoPrefixRoundTrip(t *testing.T) {
+	primary := testBackendConfig("primary")
+	primary.Prefix = ""
+	primary.NoPrefix = true
+
+	_, c := startTestProxy(t, &config.ProxyConfig{
+		Servers: []config.ServerConfig{primary, testBackendConfig("helper")},
+	})
+	ctx := context.Background()
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, tool := range tools.Tools {
+		found[tool.Name] = true
+	}
+	for _, name := range []string{"echo", "reverse", "helper_echo", "helper_reverse"} {
+		if !found[name] {
+			t.Errorf("expected %s in tool list", name)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+	request.Params.Arguments = map[string]interface{}{"message": "plain"}
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		t.Fatalf("call tool: %v", err)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "echo: plain" {
+		t.Errorf("expected 'echo: plain', got %q", text)
+	}
+}
+
+func TestCreateMCPToolHidesArguments(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{Servers: []config.ServerConfig{{
+		Name:             "tracker",
+		DefaultArguments: config.ToolArguments{"list_issues": {"project": "web"}},
+		HiddenArguments:  config.ToolArgumentNames{"list_issues": {"project"}},
+	}}})
+	schema := `{"type":"object","properties":{"project":{"type":"string"},"state":{"type":"string"}},"required":["project","state"]}`
+	tool := discovery.CreatePrefixedTool("tracker", "tracker", discovery.ToolInfo{
+		Name:        "list_issues",
+		InputSchema: json.RawMessage(schema),
+	})
+
+	mcpTool := w.proxyServer.createMCPTool(tool)
+	var rewritten struct {
+		Properties map[string]interface{} `json:"properties"`
+		Required   []string               `json:"required"`
+	}
+	if err := json.Unmarshal(mcpTool.RawInputSchema, &rewritten); err != nil {
+		t.Fatalf("invalid rewritten schema: %v", err)
+	}
+	if _, ok := rewritten.Properties["project"]; ok {
+		t.Errorf("expected project hidden from the schema, got %s", mcpTool.RawInputSchema)
+	}
+	if _, ok := rewritten.Properties["state"]; !ok || len(rewritten.Required) != 1 || rewritten.Required[0] != "state" {
+		t.Errorf("expected state kept and still required, got %s", mcpTool.RawInputSchema)
+	}
+
+	// Other tools of the server keep their schema
+	other := tool
+	other.OriginalName = "get_issue"
+	if got := string(w.proxyServer.createMCPTool(other).RawInputSchema); got != schema {
+		t.Errorf("expected an unchanged schema, got %s", got)
+	}
+}
+
+func TestInitializeHidesArgumentsOfConfiguredServers(t *testing.T) {
+	backend := testBackendConfig("backend")
+	backend.DefaultArguments = config.ToolArguments{"echo": {"message": "fixed"}}
+	backend.HiddenArguments = config.ToolArgumentNames{"echo": {"message"}}
+	_, c := startTestProxy(t, &config.ProxyConfig{Servers: []config.ServerConfig{backend}})
+
+	tools, err := c.ListTools(context.Background(), mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	found := false
+	for _, tool := range tools.Tools {
+		if tool.Name != "backend_echo" {
+			continue
+		}
+		found = true
+		if _, ok := tool.InputSchema.Properties["message"]; ok {
+			t.Errorf("expected message hidden from the schema, got %+v", tool.InputSchema)
+		}
+		for _, name := range tool.InputSchema.Required {
+			if name == "message" {
+				t.Errorf("expected message no longer required, got %v", tool.InputSchema.Required)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected backend_echo to be listed, got %+v", tools.Tools)
+	}
+}
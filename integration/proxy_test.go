@@ -0,0 +1,411 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+func TestProxyLifecycleWithoutServers(t *testing.T) {
+	p := New(&config.ProxyConfig{})
+
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize without servers should succeed: %v", err)
+	}
+	if tools := p.Tools(); len(tools) != 0 {
+		t.Errorf("expected no tools, got %d", len(tools))
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+}
+
+func TestProxyReportsBuildVersionByDefault(t *testing.T) {
+	p := New(&config.ProxyConfig{})
+
+	response := p.wrapper.baseServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`))
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if want := `"version":"` + config.DefaultProxyVersion + `"`; !strings.Contains(string(data), want) {
+		t.Errorf("expected the build version in serverInfo, got %s", data)
+	}
+}
+
+func TestInitializeNoToolsPolicy(t *testing.T) {
+	failing := config.ServerConfig{
+		Name:      "missing",
+		Prefix:    "missing",
+		Transport: "stdio",
+		Command:   "/nonexistent/mcp-server",
+	}
+
+	p := New(&config.ProxyConfig{Servers: []config.ServerConfig{failing}})
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Errorf("default policy should start empty, got %v", err)
+	}
+
+	p = New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{failing},
+		Proxy:   config.ProxySettings{OnNoTools: config.NoToolsExit},
+	})
+	err := p.Initialize(context.Background())
+	if !errors.Is(err, ErrNoToolsDiscovered) {
+		t.Errorf("expected ErrNoToolsDiscovered, got %v", err)
+	}
+}
+
+func TestInitializeAllowEmpty(t *testing.T) {
+	failing := config.ServerConfig{
+		Name:      "missing",
+		Prefix:    "missing",
+		Transport: "stdio",
+		Command:   "/nonexistent/mcp-server",
+	}
+	allow, deny := true, false
+
+	p := New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{failing},
+		Proxy:   config.ProxySettings{AllowEmpty: &allow},
+	})
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Errorf("allowEmpty true should start empty, got %v", err)
+	}
+
+	p = New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{failing},
+		Proxy:   config.ProxySettings{AllowEmpty: &deny},
+	})
+	if err := p.Initialize(context.Background()); !errors.Is(err, ErrNoToolsDiscovered) {
+		t.Errorf("expected ErrNoToolsDiscovered with allowEmpty false, got %v", err)
+	}
+
+	// Also without any configured servers
+	p = New(&config.ProxyConfig{Proxy: config.ProxySettings{AllowEmpty: &deny}})
+	if err := p.Initialize(context.Background()); !errors.Is(err, ErrNoToolsDiscovered) {
+		t.Errorf("expected ErrNoToolsDiscovered without servers, got %v", err)
+	}
+}
+
+func TestInitializeRequiredServer(t *testing.T) {
+	failing := config.ServerConfig{
+		Name:      "missing",
+		Prefix:    "missing",
+		Transport: "stdio",
+		Command:   "/nonexistent/mcp-server",
+		Required:  true,
+	}
+
+	p := New(&config.ProxyConfig{Servers: []config.ServerConfig{failing}})
+	err := p.Initialize(context.Background())
+	if !errors.Is(err, ErrRequiredServerFailed) {
+		t.Fatalf("expected ErrRequiredServerFailed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected the error to name the server, got %v", err)
+	}
+}
+
+func TestInitResultPartialStartup(t *testing.T) {
+	missing := config.ServerConfig{
+		Name:      "missing",
+		Prefix:    "missing",
+		Transport: "stdio",
+		Command:   "/nonexistent/mcp-server",
+	}
+	w, _ := startTestProxy(t, &config.ProxyConfig{
+		Servers: []config.ServerConfig{testBackendConfig("backend"), missing},
+	})
+
+	result := w.InitResult()
+	if result == nil {
+		t.Fatal("expected an init result after Initialize")
+	}
+	if !result.Partial() || result.Connected != 1 || result.Failed != 1 {
+		t.Fatalf("expected one connected and one failed server, got %+v", result)
+	}
+	if len(result.Servers) != 2 || result.Servers[0].Name != "backend" || result.Servers[1].Name != "missing" {
+		t.Fatalf("expected servers in config order, got %+v", result.Servers)
+	}
+
+	backend := result.Servers[0]
+	if backend.Status != ServerInitConnected || backend.Tools == 0 || backend.Tools != result.Tools {
+		t.Errorf("unexpected backend result: %+v", backend)
+	}
+	failed := result.FailedServers()
+	if len(failed) != 1 || failed[0].Status != ServerInitConnectFailed || failed[0].Error == "" {
+		t.Errorf("expected missing to fail connecting with an error, got %+v", failed)
+	}
+	if info := w.dynamicServers["missing"]; info.ErrorMessage != failed[0].Error {
+		t.Errorf("expected the server's error message to match the init result, got %q", info.ErrorMessage)
+	}
+}
+
+func TestInitResultKeptOnFailure(t *testing.T) {
+	p := New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{{
+			Name:      "missing",
+			Prefix:    "missing",
+			Transport: "stdio",
+			Command:   "/nonexistent/mcp-server",
+			Required:  true,
+		}},
+	})
+	if p.InitResult() != nil {
+		t.Error("expected no init result before Initialize")
+	}
+	if err := p.Initialize(context.Background()); err == nil {
+		t.Fatal("expected required server failure")
+	}
+	result := p.InitResult()
+	if result == nil || result.Failed != 1 || result.Partial() {
+		t.Errorf("expected one failed server, got %+v", result)
+	}
+}
+
+func TestStartupSummary(t *testing.T) {
+	p := New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{{
+			Name:      "missing",
+			Prefix:    "missing",
+			Transport: "stdio",
+			Command:   "/nonexistent/mcp-server",
+			Inherit:   &config.InheritConfig{Mode: config.InheritNone},
+		}},
+	})
+	if err := p.SetStartupSummaryFormat("yaml"); err == nil {
+		t.Error("expected error for unknown summary format")
+	}
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	summary := p.StartupSummary()
+	if len(summary.Servers) != 1 || summary.Connected != 0 || summary.Tools != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	server := summary.Servers[0]
+	if server.Name != "missing" || server.Transport != "stdio" || server.Inherit != "none" {
+		t.Errorf("unexpected server summary: %+v", server)
+	}
+	if server.Connected || server.Error == "" {
+		t.Errorf("expected a failed server with an error, got %+v", server)
+	}
+
+	text := formatStartupSummary(summary)
+	if !strings.Contains(text, "0/1 servers connected") || !strings.Contains(text, "inherit=none") {
+		t.Errorf("unexpected text summary:\n%s", text)
+	}
+}
+
+func TestServeStdioReturnsOnCancel(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	in, inWriter := io.Pipe()
+	defer inWriter.Close()
+
+	shutdown := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStdio(ctx, w.baseServer, in, io.Discard, func(context.Context) error {
+			shutdown <- struct{}{}
+			return nil
+		})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected nil error on cancellation, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveStdio did not return after cancellation")
+	}
+
+	select {
+	case <-shutdown:
+	default:
+		t.Error("expected shutdown to be called")
+	}
+}
+
+func TestServeStdioReturnsOnEOF(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	in, inWriter := io.Pipe()
+
+	shutdownCalled := false
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStdio(context.Background(), w.baseServer, in, io.Discard, func(context.Context) error {
+			shutdownCalled = true
+			return nil
+		})
+	}()
+
+	inWriter.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected nil error on EOF, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveStdio did not return after stdin closed")
+	}
+	if !shutdownCalled {
+		t.Error("expected shutdown to be called")
+	}
+}
+
+func TestInitializeDependsOn(t *testing.T) {
+	app := testBackendConfig("app")
+	app.DependsOn = []string{"db"}
+	reports := testBackendConfig("reports")
+	reports.DependsOn = []string{"missing"}
+	missing := config.ServerConfig{
+		Name:      "missing",
+		Prefix:    "missing",
+		Transport: "stdio",
+		Command:   "/nonexistent/mcp-server",
+	}
+
+	var initialized []string
+	p := New(&config.ProxyConfig{Servers: []config.ServerConfig{app, testBackendConfig("db"), reports, missing}})
+	p.wrapper.proxyServer.recorderFunc = func(direction, messageType, toolName, serverName string, message interface{}) {
+		if direction == "request" && messageType == "initialize" {
+			initialized = append(initialized, serverName)
+		}
+	}
+	ctx := context.Background()
+	if err := p.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	// app is listed first but connects after db
+	if strings.Join(initialized, ",") != "db,app" {
+		t.Errorf("expected db to connect before app, got %v", initialized)
+	}
+
+	result := p.InitResult()
+	if server, _ := result.Server("app"); server.Status != ServerInitConnected {
+		t.Errorf("expected app to connect, got %+v", server)
+	}
+	server, _ := result.Server("reports")
+	if server.Status != ServerInitDiscoveryFailed || !strings.Contains(server.Error, "server missing did not connect") {
+		t.Errorf("expected reports not to start without its dependency, got %+v", server)
+	}
+}
+
+func TestDependencyRunningWhileDependentStarts(t *testing.T) {
+	// app exits at startup unless db is still running
+	addressFile := filepath.Join(t.TempDir(), "db.addr")
+	db := testBackendConfig("db")
+	db.Env[testBackendListenEnv] = addressFile
+	app := testBackendConfig("app")
+	app.Env[testBackendNeedsEnv] = addressFile
+	app.DependsOn = []string{"db"}
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{db, app}}
+
+	results, err := discovery.NewDiscoverer(cfg).DiscoverAll(context.Background())
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	for _, result := range results {
+		if !result.IsSuccessful() {
+			t.Errorf("discovery: expected %s to succeed, got %v", result.ServerName, result.Error)
+		}
+		if result.Client != nil {
+			t.Errorf("discovery: expected the client of %s to be closed", result.ServerName)
+		}
+	}
+
+	p := New(cfg)
+	ctx := context.Background()
+	if err := p.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer p.Shutdown(ctx)
+	if server, _ := p.InitResult().Server("app"); server.Status != ServerInitConnected {
+		t.Errorf("initialize: expected app to connect, got %+v", server)
+	}
+}
+
+func TestInitializeStartupBudget(t *testing.T) {
+	slow := testBackendConfig("slow")
+	slow.Env[testBackendDelayEnv] = "30s"
+
+	p := New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{testBackendConfig("fast"), slow},
+		Proxy:   config.ProxySettings{ConnectionTimeout: "60s", StartupBudget: "2s"},
+	})
+	ctx := context.Background()
+	start := time.Now()
+	if err := p.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected Initialize to give up on slow after the budget, took %v", elapsed)
+	}
+
+	result := p.InitResult()
+	if server, _ := result.Server("fast"); server.Status != ServerInitConnected {
+		t.Errorf("expected fast to connect, got %+v", server)
+	}
+	server, _ := result.Server("slow")
+	if server.Status != ServerInitDeferred || !strings.Contains(server.Error, ErrStartupBudgetExceeded.Error()) {
+		t.Errorf("expected slow to be deferred, got %+v", server)
+	}
+
+	// The deferred server is kept for server_reconnect
+	info, ok := p.wrapper.dynamicServers["slow"]
+	if !ok || info.IsConnected {
+		t.Errorf("expected slow to be listed as disconnected, got %+v", info)
+	}
+}
+
+func TestInitializeStartupBudgetCoversConnect(t *testing.T) {
+	// The retries of a failing server would take 10s, well past the budget
+	retries := 4
+	missing := config.ServerConfig{
+		Name:       "missing",
+		Prefix:     "missing",
+		Transport:  "stdio",
+		Command:    "/nonexistent/mcp-server",
+		MaxRetries: &retries,
+	}
+
+	p := New(&config.ProxyConfig{
+		Servers: []config.ServerConfig{testBackendConfig("fast"), missing},
+		Proxy:   config.ProxySettings{StartupBudget: "1s"},
+	})
+	ctx := context.Background()
+	start := time.Now()
+	if err := p.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("expected Initialize to stop at the budget, took %v", elapsed)
+	}
+	result := p.InitResult()
+	if server, _ := result.Server("fast"); server.Status != ServerInitConnected {
+		t.Errorf("expected fast to connect, got %+v", server)
+	}
+	server, _ := result.Server("missing")
+	if server.Status != ServerInitDeferred || !strings.Contains(server.Error, ErrStartupBudgetExceeded.Error()) {
+		t.Errorf("expected missing to be deferred, got %+v", server)
+	}
+}
@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"mcp-debug/config"
+)
+
+// RateLimitState describes the token bucket of one rate limited tool
+type RateLimitState struct {
+	Tool              string  `json:"tool"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	Tokens            float64 `json:"tokens"`   // Available now; negative while calls are waiting
+	Delayed           int     `json:"delayed"`  // Calls that waited for a token
+	Rejected          int     `json:"rejected"` // Calls failed with a rate limit error
+}
+
+// tokenBucket refills at rate tokens per second up to burst
+type tokenBucket struct {
+	rate     float64
+	burst    int
+	tokens   float64
+	last     time.Time
+	delayed  int
+	rejected int
+}
+
+// rateLimiter keeps a token bucket per server and tool. The clock and sleep
+// functions are fields so tests can run without real delays.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*tokenBucket // server -> original tool name -> bucket
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]map[string]*tokenBucket),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// wait takes a token for a call to tool on serverName, sleeping until one is
+// available when the policy is to wait. It fails with ErrRateLimited when
+// the policy is to reject, or when the wait would exceed maxWait.
+func (l *rateLimiter) wait(ctx context.Context, serverName, tool string, limit *config.RateLimitConfig, maxWait time.Duration) error {
+	rate, burst := limit.ForTool(tool)
+	if rate <= 0 {
+		return nil
+	}
+
+	delay, err := l.reserve(serverName, tool, rate, burst, limit.Action(), maxWait)
+	if err != nil || delay == 0 {
+		return err
+	}
+	return l.sleep(ctx, delay)
+}
+
+// reserve takes a token from the tool's bucket and returns how long the
+// caller must wait before using it. Waiting callers take tokens in advance,
+// so concurrent calls queue up in order.
+func (l *rateLimiter) reserve(serverName, tool string, rate float64, burst int, action config.RateLimitAction, maxWait time.Duration) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	tools, ok := l.buckets[serverName]
+	if !ok {
+		tools = make(map[string]*tokenBucket)
+		l.buckets[serverName] = tools
+	}
+	bucket, ok := tools[tool]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		tools[tool] = bucket
+	}
+
+	// Pick up config changes, then refill for the time since the last call
+	bucket.rate, bucket.burst = rate, burst
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, nil
+	}
+
+	delay := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	if action == config.RateLimitReject || delay > maxWait {
+		bucket.rejected++
+		return 0, fmt.Errorf("%w for tool '%s' (%g requests/s, burst %d); retry in %v",
+			ErrRateLimited, tool, rate, burst, delay.Round(time.Millisecond))
+	}
+	bucket.tokens--
+	bucket.delayed++
+	return delay, nil
+}
+
+// state returns the buckets of serverName, sorted by tool name
+func (l *rateLimiter) state(serverName string) []RateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var states []RateLimitState
+	for tool, bucket := range l.buckets[serverName] {
+		states = append(states, RateLimitState{
+			Tool:              tool,
+			RequestsPerSecond: bucket.rate,
+			Burst:             bucket.burst,
+			Tokens:            math.Min(float64(bucket.burst), bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate),
+			Delayed:           bucket.delayed,
+			Rejected:          bucket.rejected,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Tool < states[j].Tool })
+	return states
+}
+
+// RateLimitState returns the rate limiter state of a server's tools. Tools
+// appear once they have been called.
+func (w *DynamicWrapper) RateLimitState(serverName string) []RateLimitState {
+	return w.rateLimiter.state(serverName)
+}
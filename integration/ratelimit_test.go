@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// fakeClock drives a rateLimiter without real delays: sleeping advances
+// the clock
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) install(l *rateLimiter) {
+	l.now = func() time.Time { return c.now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		c.slept = append(c.slept, d)
+		c.now = c.now.Add(d)
+		return nil
+	}
+}
+
+func newRateLimitedWrapper(t *testing.T, limit *config.RateLimitConfig) (*DynamicWrapper, *fakeClock, func() string) {
+	t.Helper()
+
+	fake := client.NewFakeClient("api", client.ToolInfo{Name: "search"})
+	w := newTestWrapper(t, "api", fake)
+	w.dynamicServers["api"].Config.RateLimit = limit
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	clock.install(w.rateLimiter)
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "search",
+		PrefixedName: "api_search",
+		ServerName:   "api",
+	})
+	call := func() string {
+		result := callTool(t, handler, map[string]interface{}{"q": "x"})
+		if result.IsError {
+			return resultText(result)
+		}
+		return ""
+	}
+	return w, clock, call
+}
+
+func TestRateLimitReject(t *testing.T) {
+	w, clock, call := newRateLimitedWrapper(t, &config.RateLimitConfig{
+		RequestsPerSecond: 1,
+		Burst:             2,
+		OnLimit:           config.RateLimitReject,
+	})
+
+	for i := 0; i < 2; i++ {
+		if errText := call(); errText != "" {
+			t.Fatalf("call %d within burst failed: %s", i, errText)
+		}
+	}
+	if errText := call(); !strings.Contains(errText, ErrRateLimited.Error()) {
+		t.Fatalf("expected a rate limit error, got %q", errText)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if errText := call(); errText != "" {
+		t.Fatalf("expected a call to succeed after refill, got %s", errText)
+	}
+
+	state := w.RateLimitState("api")
+	if len(state) != 1 || state[0].Tool != "search" || state[0].Rejected != 1 || state[0].Delayed != 0 {
+		t.Errorf("unexpected limiter state: %+v", state)
+	}
+	if len(clock.slept) != 0 {
+		t.Errorf("reject mode should not wait, slept %v", clock.slept)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	w, clock, call := newRateLimitedWrapper(t, &config.RateLimitConfig{
+		RequestsPerSecond: 2,
+		Tools: map[string]config.ToolRateLimit{
+			"search": {RequestsPerSecond: 4},
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		if errText := call(); errText != "" {
+			t.Fatalf("call %d failed: %s", i, errText)
+		}
+	}
+	// Burst 1 at the tool's 4 requests/s: the 2nd and 3rd calls wait 250ms each
+	if len(clock.slept) != 2 || clock.slept[0] != 250*time.Millisecond || clock.slept[1] != 250*time.Millisecond {
+		t.Errorf("expected two 250ms waits, got %v", clock.slept)
+	}
+	if state := w.RateLimitState("api"); len(state) != 1 || state[0].Delayed != 2 {
+		t.Errorf("unexpected limiter state: %+v", state)
+	}
+}
+
+func TestRateLimitWaitExceedsTimeout(t *testing.T) {
+	_, clock, call := newRateLimitedWrapper(t, &config.RateLimitConfig{RequestsPerSecond: 0.01})
+
+	if errText := call(); errText != "" {
+		t.Fatalf("first call failed: %s", errText)
+	}
+	// The next token is 100s away, beyond the 30s default call timeout
+	if errText := call(); !strings.Contains(errText, ErrRateLimited.Error()) {
+		t.Fatalf("expected a rate limit error, got %q", errText)
+	}
+	if len(clock.slept) != 0 {
+		t.Errorf("expected no wait, slept %v", clock.slept)
+	}
+}
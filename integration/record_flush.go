@@ -0,0 +1,97 @@
+package integration
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// FlushPolicy controls when recorded messages reach the recording file
+type FlushPolicy string
+
+const (
+	// FlushAlways writes and syncs every message as it is recorded (default)
+	FlushAlways FlushPolicy = "always"
+	// FlushInterval buffers messages and flushes them on a fixed interval
+	FlushInterval FlushPolicy = "interval"
+	// FlushOnClose buffers messages until the recording is stopped
+	FlushOnClose FlushPolicy = "close"
+)
+
+// DefaultFlushInterval is used by FlushInterval when no interval is given
+const DefaultFlushInterval = time.Second
+
+// ParseFlushPolicy converts a flag or config value to a FlushPolicy.
+// An empty value selects FlushAlways.
+func ParseFlushPolicy(value string) (FlushPolicy, error) {
+	switch policy := FlushPolicy(value); policy {
+	case "":
+		return FlushAlways, nil
+	case FlushAlways, FlushInterval, FlushOnClose:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid flush policy %q (must be always, interval or close)", value)
+	}
+}
+
+// SetRecordFlushPolicy sets how recorded messages are flushed to disk.
+// Buffered policies trade durability for throughput: messages still in the
+// buffer are lost if the process is killed, but a stopped recording or a
+// Shutdown always flushes them. The policy applies to the next recording
+// started; interval is only used by FlushInterval.
+func (w *DynamicWrapper) SetRecordFlushPolicy(policy FlushPolicy, interval time.Duration) error {
+	if _, err := ParseFlushPolicy(string(policy)); err != nil {
+		return err
+	}
+	if interval < 0 {
+		return fmt.Errorf("invalid flush interval %v", interval)
+	}
+	if interval == 0 {
+		interval = DefaultFlushInterval
+	}
+
+	w.recordMu.Lock()
+	defer w.recordMu.Unlock()
+	w.recordFlush = policy
+	w.recordFlushInterval = interval
+	return nil
+}
+
+// runRecordFlusher flushes the recording buffer every interval until stop
+// is closed
+func (w *DynamicWrapper) runRecordFlusher(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.recordMu.Lock()
+			if w.recordEnabled {
+				if err := w.flushRecording(); err != nil {
+					log.Printf("Failed to flush recording file: %v", err)
+				}
+			}
+			w.recordMu.Unlock()
+		}
+	}
+}
+
+// flushRecording writes any buffered messages and index entries and syncs
+// the recording file.
+// The caller holds w.recordMu.
+func (w *DynamicWrapper) flushRecording() error {
+	if w.recordBuffer != nil {
+		if err := w.recordBuffer.Flush(); err != nil {
+			return err
+		}
+	}
+	if w.recordIndex != nil {
+		if err := w.recordIndex.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.recordFile.Sync()
+}
@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// RecordingIndexSuffix is appended to a recording's filename to name its
+// index, e.g. session.jsonl.idx
+const RecordingIndexSuffix = ".idx"
+
+// RecordingIndexEntry locates one recorded message in its recording file.
+// An index has one entry per line, in the order of the recording.
+type RecordingIndexEntry struct {
+	Number      int       `json:"number"` // 1-based, not counting the session header
+	Line        int       `json:"line"`   // 1-based line number in the file
+	Offset      int64     `json:"offset"` // Byte offset of the line
+	Length      int       `json:"length"` // Bytes of the line, including its newline
+	Timestamp   time.Time `json:"timestamp"`
+	Direction   string    `json:"direction"`
+	MessageType string    `json:"message_type"`
+	ToolName    string    `json:"tool_name,omitempty"`
+}
+
+// RecordingIndexFilename returns the name of the index of a recording
+func RecordingIndexFilename(recording string) string {
+	return recording + RecordingIndexSuffix
+}
+
+// NewRecordingIndexEntry describes a message read from the line at offset
+func NewRecordingIndexEntry(number, line int, offset int64, length int, message RecordedMessage) RecordingIndexEntry {
+	return RecordingIndexEntry{
+		Number:      number,
+		Line:        line,
+		Offset:      offset,
+		Length:      length,
+		Timestamp:   message.Timestamp,
+		Direction:   message.Direction,
+		MessageType: message.MessageType,
+		ToolName:    message.ToolName,
+	}
+}
+
+// WriteRecordingIndexEntry writes one line of a recording index
+func WriteRecordingIndexEntry(w io.Writer, entry RecordingIndexEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", entryBytes)
+	return err
+}
+
+// openRecordingIndex creates the index of the recording being started, if
+// record.index is set. Without an index the recording still works, so a
+// failure is only logged. The caller holds w.recordMu.
+func (w *DynamicWrapper) openRecordingIndex(filename string) {
+	if !w.proxyServer.recordSettings().Index {
+		return
+	}
+	file, err := os.Create(RecordingIndexFilename(filename))
+	if err != nil {
+		log.Printf("Failed to create recording index, recording without one: %v", err)
+		return
+	}
+	w.recordIndexFile = file
+	w.recordIndex = bufio.NewWriter(file)
+}
+
+// indexRecord adds the message just written to the recording to its index.
+// The caller holds w.recordMu.
+func (w *DynamicWrapper) indexRecord(recorded RecordedMessage, length int) {
+	if w.recordIndex != nil {
+		entry := NewRecordingIndexEntry(w.recordCount+1, w.recordLine+1, w.recordOffset, length, recorded)
+		if err := WriteRecordingIndexEntry(w.recordIndex, entry); err != nil {
+			log.Printf("Failed to write recording index: %v", err)
+		}
+	}
+	w.recordOffset += int64(length)
+	w.recordLine++
+}
+
+// closeRecordingIndex flushes and closes the index, if one is written. The
+// caller holds w.recordMu.
+func (w *DynamicWrapper) closeRecordingIndex() {
+	if w.recordIndex == nil {
+		return
+	}
+	if err := w.recordIndex.Flush(); err != nil {
+		log.Printf("Failed to flush recording index: %v", err)
+	}
+	if err := w.recordIndexFile.Close(); err != nil {
+		log.Printf("Failed to close recording index: %v", err)
+	}
+	w.recordIndex = nil
+	w.recordIndexFile = nil
+}
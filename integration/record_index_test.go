@@ -0,0 +1,96 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/discovery"
+)
+
+func TestRecordingIndex(t *testing.T) {
+	for _, policy := range []FlushPolicy{FlushAlways, FlushOnClose} {
+		t.Run(string(policy), func(t *testing.T) {
+			w := newTestWrapper(t, "fake", client.NewFakeClient("fake"))
+			w.proxyServer.record.Index = true
+			if err := w.SetRecordFlushPolicy(policy, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			filename := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := w.EnableRecording(filename); err != nil {
+				t.Fatalf("enable recording: %v", err)
+			}
+			for _, name := range []string{"read", "write"} {
+				handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+					OriginalName: name,
+					PrefixedName: "fake_" + name,
+					ServerName:   "fake",
+				})
+				callTool(t, handler, nil)
+			}
+			w.DisableRecording()
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("read recording: %v", err)
+			}
+			lines := strings.Split(string(data), "\n")
+
+			indexFile, err := os.Open(RecordingIndexFilename(filename))
+			if err != nil {
+				t.Fatalf("open index: %v", err)
+			}
+			defer indexFile.Close()
+
+			var entries []RecordingIndexEntry
+			scanner := bufio.NewScanner(indexFile)
+			for scanner.Scan() {
+				var entry RecordingIndexEntry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					t.Fatalf("invalid index entry: %v", err)
+				}
+				entries = append(entries, entry)
+			}
+			if len(entries) != 4 {
+				t.Fatalf("expected an entry per message, got %d", len(entries))
+			}
+
+			for i, entry := range entries {
+				line := string(data[entry.Offset : entry.Offset+int64(entry.Length)])
+				if entry.Number != i+1 || lines[entry.Line-1]+"\n" != line {
+					t.Errorf("entry %d doesn't locate its line: %+v", i, entry)
+				}
+				var message RecordedMessage
+				if err := json.Unmarshal([]byte(line), &message); err != nil {
+					t.Fatalf("entry %d doesn't locate a message: %v", i, err)
+				}
+				if message.ToolName != entry.ToolName || message.Direction != entry.Direction || !message.Timestamp.Equal(entry.Timestamp) {
+					t.Errorf("entry %d doesn't describe its message: %+v", i, entry)
+				}
+			}
+			if last := entries[3]; last.ToolName != "fake_write" || last.Offset+int64(last.Length) != int64(len(data)) {
+				t.Errorf("expected the last entry to end the recording, got %+v", last)
+			}
+		})
+	}
+}
+
+func TestRecordingIndexDisabled(t *testing.T) {
+	w := newTestWrapper(t, "fake", client.NewFakeClient("fake"))
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	w.recordMessage("request", "tool_call", "fake_read", "fake", map[string]string{})
+	w.DisableRecording()
+
+	if _, err := os.Stat(RecordingIndexFilename(filename)); !os.IsNotExist(err) {
+		t.Errorf("expected no index without record.index, got %v", err)
+	}
+}
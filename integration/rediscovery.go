@@ -0,0 +1,244 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/discovery"
+)
+
+// defaultRediscoverInterval bounds how often a backend's tools are re-listed
+// in response to tools/list_changed notifications
+const defaultRediscoverInterval = 2 * time.Second
+
+// rediscoveryState coalesces list_changed notifications for one server
+type rediscoveryState struct {
+	pending bool      // A re-discovery is scheduled and has not started yet
+	last    time.Time // When the last re-discovery started
+}
+
+// RediscoveryResult summarizes a tool set update after tools/list_changed
+type RediscoveryResult struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+}
+
+// watchNotifications subscribes to server notifications when the client
+// supports them. Backends that never send notifications are unaffected.
+func (w *DynamicWrapper) watchNotifications(serverName string, mcpClient client.MCPClient) {
+	notifier, ok := mcpClient.(client.Notifier)
+	if !ok {
+		return
+	}
+	notifier.SetNotificationHandler(func(method string, params json.RawMessage) {
+		switch method {
+		case client.NotificationToolsListChanged:
+			w.scheduleRediscovery(serverName)
+		case client.NotificationMessage:
+			w.relayLogMessage(serverName, params)
+		}
+	})
+}
+
+// scheduleRediscovery re-lists the server's tools in the background.
+// Notifications arriving while one is pending are coalesced, and runs are
+// spaced at least rediscoverInterval apart to avoid thrashing.
+func (w *DynamicWrapper) scheduleRediscovery(serverName string) {
+	w.rediscoverMu.Lock()
+	state, ok := w.rediscovery[serverName]
+	if !ok {
+		state = &rediscoveryState{}
+		w.rediscovery[serverName] = state
+	}
+	if state.pending {
+		w.rediscoverMu.Unlock()
+		return
+	}
+	state.pending = true
+	wait := time.Until(state.last.Add(w.rediscoverInterval))
+	w.rediscoverMu.Unlock()
+
+	// Runs in its own goroutine: the notification is delivered on the
+	// goroutine reading the client's responses, so listing tools
+	// synchronously would deadlock
+	go func() {
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+
+		w.rediscoverMu.Lock()
+		state.pending = false
+		state.last = time.Now()
+		w.rediscoverMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := w.rediscoverServer(ctx, serverName); err != nil {
+			log.Printf("Re-discovery for server '%s' failed: %v", serverName, err)
+		}
+	}()
+}
+
+// rediscoverServer re-lists a connected server's tools and updates the
+// registered set: new tools are registered, vanished tools are removed and
+// existing tools pick up description/schema changes.
+func (w *DynamicWrapper) rediscoverServer(ctx context.Context, serverName string) (*RediscoveryResult, error) {
+	w.mu.RLock()
+	serverInfo, err := w.lookupServer(serverName)
+	var mcpClient client.MCPClient
+	if err == nil && serverInfo.IsConnected {
+		mcpClient = serverInfo.Client
+	}
+	w.mu.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if mcpClient == nil {
+		return nil, &ServerError{Server: serverName, Err: ErrServerDisconnected}
+	}
+
+	// List outside the lock: this is a round-trip to the backend
+	tools, err := mcpClient.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The server may have been reconnected or removed meanwhile
+	var exists bool
+	if serverInfo, exists = w.dynamicServers[serverName]; !exists || serverInfo.Client != mcpClient {
+		return nil, fmt.Errorf("server '%s' changed during re-discovery", serverName)
+	}
+
+	w.proxyServer.mu.Lock()
+	registered := make(map[string]discovery.RemoteTool)
+	for _, tool := range w.proxyServer.registry.GetServerTools(serverName) {
+		registered[tool.OriginalName] = tool
+	}
+
+	result := &RediscoveryResult{}
+	var added []discovery.RemoteTool
+	listed := make(map[string]bool)
+	for _, tool := range tools {
+		listed[tool.Name] = true
+
+		if existing, found := registered[tool.Name]; found {
+			if existing.Description != tool.Description || string(existing.InputSchema) != string(tool.InputSchema) {
+				existing.Description = tool.Description
+				existing.InputSchema = tool.InputSchema
+				added = append(added, existing)
+				result.Updated = append(result.Updated, existing.PrefixedName)
+			}
+			continue
+		}
+
+		remoteTool, register, conflict, err := w.proxyServer.resolveToolConflict(discovery.RemoteTool{
+			OriginalName: tool.Name,
+			PrefixedName: discovery.PrefixedToolName(serverInfo.Config.ToolPrefixFor(tool.Name), tool.Name),
+			Description:  tool.Description,
+			InputSchema:  tool.InputSchema,
+			ServerName:   serverName,
+			ServerPrefix: serverInfo.Config.ToolPrefix(),
+		})
+		if err != nil {
+			log.Printf("Skipping new tool %s from server '%s': %v", tool.Name, serverName, err)
+			continue
+		}
+		if conflict != nil {
+			w.proxyServer.recordToolConflicts([]ToolConflict{*conflict})
+		}
+		if register {
+			added = append(added, remoteTool)
+			result.Added = append(result.Added, remoteTool.PrefixedName)
+		}
+	}
+
+	for _, tool := range added {
+		w.proxyServer.registry.RegisterTool(tool, mcpClient)
+	}
+
+	var removed []string
+	for originalName, tool := range registered {
+		if !listed[originalName] {
+			w.proxyServer.registry.UnregisterTool(tool.PrefixedName)
+			removed = append(removed, tool.PrefixedName)
+		}
+	}
+	w.proxyServer.mu.Unlock()
+
+	// Update the exposed tools (AddTool replaces tools with the same name)
+	for _, tool := range added {
+		w.baseServer.AddTool(w.proxyServer.createMCPTool(tool), w.createDynamicProxyHandler(tool))
+	}
+	if len(removed) > 0 {
+		w.baseServer.DeleteTools(removed...)
+	}
+	result.Removed = sortedStrings(removed)
+
+	serverTools := make([]string, 0, len(tools))
+	for _, tool := range w.proxyServer.registry.GetServerTools(serverName) {
+		serverTools = append(serverTools, tool.PrefixedName)
+	}
+	serverInfo.Tools = serverTools
+
+	log.Printf("Re-discovered tools for server '%s': %d added, %d removed, %d updated",
+		serverName, len(result.Added), len(result.Removed), len(result.Updated))
+	w.recordMessage("notification", "tools_list_changed", "", serverName, result)
+
+	return result, nil
+}
+
+func (w *DynamicWrapper) handleServerRediscover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_rediscover", "proxy", request)
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		result := mcp.NewToolResultError("name is required")
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_rediscover", "proxy", result)
+		return result, nil
+	}
+
+	changes, err := w.rediscoverServer(ctx, name)
+	if err != nil {
+		result := mcp.NewToolResultError(fmt.Sprintf("Failed to re-discover tools for server '%s': %v", name, err))
+		result = w.addRecordingMetadata(result)
+		w.recordMessage("response", "tool_call", "server_rediscover", "proxy", result)
+		return result, nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Re-discovered tools for server '%s'\n", name))
+	if len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Updated) == 0 {
+		result.WriteString("No changes.\n")
+	}
+	for _, section := range []struct {
+		label string
+		tools []string
+	}{
+		{"Added", changes.Added},
+		{"Removed", changes.Removed},
+		{"Updated", changes.Updated},
+	} {
+		if len(section.tools) > 0 {
+			result.WriteString(fmt.Sprintf("%s (%d): %s\n", section.label, len(section.tools), strings.Join(section.tools, ", ")))
+		}
+	}
+
+	toolResult := mcp.NewToolResultText(result.String())
+	toolResult = w.addRecordingMetadata(toolResult)
+	w.recordMessage("response", "tool_call", "server_rediscover", "proxy", toolResult)
+	return toolResult, nil
+}
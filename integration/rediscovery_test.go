@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestRediscoverServerUpdatesToolSet(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"}, client.ToolInfo{Name: "old"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	fake.SetTools(
+		client.ToolInfo{Name: "read", Description: "Read a file"},
+		client.ToolInfo{Name: "new"},
+	)
+	changes, err := w.rediscoverServer(context.Background(), "fs")
+	if err != nil {
+		t.Fatalf("rediscover failed: %v", err)
+	}
+
+	if len(changes.Added) != 1 || changes.Added[0] != "fs_new" {
+		t.Errorf("expected fs_new added, got %v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "fs_old" {
+		t.Errorf("expected fs_old removed, got %v", changes.Removed)
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0] != "fs_read" {
+		t.Errorf("expected fs_read updated, got %v", changes.Updated)
+	}
+
+	tools := w.dynamicServers["fs"].Tools
+	if len(tools) != 2 || tools[0] != "fs_new" || tools[1] != "fs_read" {
+		t.Errorf("expected [fs_new fs_read], got %v", tools)
+	}
+	if w.baseServer.GetTool("fs_old") != nil {
+		t.Error("expected fs_old to be removed from the MCP server")
+	}
+	if w.baseServer.GetTool("fs_new") == nil {
+		t.Error("expected fs_new to be exposed by the MCP server")
+	}
+}
+
+func TestListChangedNotificationTriggersRediscovery(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.rediscoverInterval = 0
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	// Unrelated notifications are ignored
+	fake.Notify("notifications/message", nil)
+
+	fake.SetTools(client.ToolInfo{Name: "read"}, client.ToolInfo{Name: "write"})
+	fake.Notify(client.NotificationToolsListChanged, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w.mu.RLock()
+		count := len(w.dynamicServers["fs"].Tools)
+		w.mu.RUnlock()
+		if count == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected notification to trigger re-discovery")
+}
+
+func TestServerRediscoverTool(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fake := client.NewFakeClient("fs", client.ToolInfo{Name: "read"})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient { return fake })
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{"name": "fs", "command": "fake-server"})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+
+	result = callTool(t, w.handleServerRediscover, map[string]interface{}{"name": "fs"})
+	if result.IsError || !strings.Contains(resultText(result), "No changes") {
+		t.Errorf("expected no changes, got %s", resultText(result))
+	}
+
+	fake.SetTools(client.ToolInfo{Name: "read"}, client.ToolInfo{Name: "plugin"})
+	result = callTool(t, w.handleServerRediscover, map[string]interface{}{"name": "fs"})
+	if result.IsError || !strings.Contains(resultText(result), "Added (1): fs_plugin") {
+		t.Errorf("expected fs_plugin added, got %s", resultText(result))
+	}
+	if w.baseServer.GetTool("fs_plugin") == nil {
+		t.Error("expected fs_plugin to be exposed by the MCP server")
+	}
+
+	result = callTool(t, w.handleServerRediscover, map[string]interface{}{"name": "missing"})
+	if !result.IsError {
+		t.Error("expected an error for an unknown server")
+	}
+}
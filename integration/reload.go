@@ -0,0 +1,289 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// ReloadResult summarizes the server changes applied by Reload
+type ReloadResult struct {
+	Added       []string          `json:"added,omitempty"`
+	Removed     []string          `json:"removed,omitempty"`
+	Reconnected []string          `json:"reconnected,omitempty"`
+	Failed      map[string]string `json:"failed,omitempty"` // Server name -> connect error
+}
+
+// Reload applies a new configuration to the running proxy. Servers are
+// diffed by name against the current config: removed servers are closed and
+// their tools unregistered, new servers are connected, and servers whose
+// config (or resolved inherit settings) changed are reconnected with the new
+// config. Unchanged servers and servers added with server_add are left
+// alone. A server that fails to connect stays listed as disconnected, as at
+// startup, so server_reconnect can retry it.
+//
+// Composite tools are registered again from the new config. An invalid cfg
+// is rejected without changing anything. Proxy-level settings other than
+// the inherit defaults take effect on the next restart.
+func (w *DynamicWrapper) Reload(ctx context.Context, cfg *config.ProxyConfig) (*ReloadResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	oldCfg := w.proxyServer.config
+	oldServers := make(map[string]config.ServerConfig, len(oldCfg.Servers))
+	for _, serverConfig := range oldCfg.Servers {
+		oldServers[serverConfig.Name] = serverConfig
+	}
+	newServers := make(map[string]bool, len(cfg.Servers))
+	for _, serverConfig := range cfg.Servers {
+		newServers[serverConfig.Name] = true
+	}
+
+	if !reflect.DeepEqual(oldCfg.Proxy, cfg.Proxy) {
+		log.Printf("Config reload: proxy settings changed; they take effect after a restart")
+	}
+
+	result := &ReloadResult{}
+	for _, serverConfig := range oldCfg.Servers {
+		if !newServers[serverConfig.Name] {
+			w.removeServerLocked(serverConfig.Name)
+			result.Removed = append(result.Removed, serverConfig.Name)
+		}
+	}
+
+	// Clients created from here on resolve inherit settings from the new config
+	// and idle servers still starting finish with the old one
+	w.spawnMu.Lock()
+	w.proxyServer.mu.Lock()
+	w.proxyServer.setConfig(cfg)
+	w.proxyServer.mu.Unlock()
+	w.spawnMu.Unlock()
+
+	for _, serverConfig := range cfg.Servers {
+		oldConfig, existed := oldServers[serverConfig.Name]
+		switch {
+		case !existed:
+			if _, exists := w.dynamicServers[serverConfig.Name]; exists {
+				// Added with server_add before it appeared in the config
+				if result.Failed == nil {
+					result.Failed = make(map[string]string)
+				}
+				result.Failed[serverConfig.Name] = (&ServerError{Server: serverConfig.Name, Err: ErrServerAlreadyExists}).Error()
+				continue
+			}
+			result.Added = append(result.Added, serverConfig.Name)
+		case serverChanged(oldConfig, serverConfig, oldCfg.Inherit, cfg.Inherit):
+			w.removeServerLocked(serverConfig.Name)
+			result.Reconnected = append(result.Reconnected, serverConfig.Name)
+		default:
+			continue
+		}
+
+		if err := w.connectServerLocked(ctx, serverConfig); err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[serverConfig.Name] = err.Error()
+		}
+	}
+
+	w.registerCompositeTools()
+
+	log.Printf("Config reload: %d added, %d removed, %d reconnected, %d failed",
+		len(result.Added), len(result.Removed), len(result.Reconnected), len(result.Failed))
+	w.recordMessage("notification", "config_reload", "", "proxy", result)
+	return result, nil
+}
+
+// serverChanged reports whether a server must be reconnected to apply a new
+// config, including changes to the proxy-level inherit defaults it uses
+func serverChanged(oldConfig, newConfig config.ServerConfig, oldInherit, newInherit *config.InheritConfig) bool {
+	if !reflect.DeepEqual(oldConfig, newConfig) {
+		return true
+	}
+	return !reflect.DeepEqual(oldConfig.ResolveInheritConfig(oldInherit), newConfig.ResolveInheritConfig(newInherit))
+}
+
+// newServerClient creates a client for serverConfig, started with
+// envSnapshot as its environment when that is non-nil and the client
+// supports it
+func (w *DynamicWrapper) newServerClient(serverConfig config.ServerConfig, envSnapshot []string) client.MCPClient {
+	mcpClient := w.clientFactory(serverConfig)
+	if snapshotter, ok := mcpClient.(client.EnvironmentSnapshotter); ok && envSnapshot != nil {
+		snapshotter.SetEnvironmentSnapshot(envSnapshot)
+	}
+	return mcpClient
+}
+
+// captureEnvSnapshotLocked keeps the environment a server was first started
+// with when proxy.snapshotEnv is set, so reconnects reuse it rather than
+// resolving it again from the proxy's current environment. The caller holds
+// w.mu.
+func (w *DynamicWrapper) captureEnvSnapshotLocked(serverInfo *DynamicServerInfo) {
+	if serverInfo.EnvSnapshot != nil || !w.proxyServer.config.Proxy.SnapshotEnv {
+		return
+	}
+	if snapshotter, ok := serverInfo.Client.(client.EnvironmentSnapshotter); ok {
+		serverInfo.EnvSnapshot = snapshotter.Environment()
+	}
+}
+
+// removeServerLocked closes a server's client and unregisters its tools.
+// The caller holds w.mu.
+func (w *DynamicWrapper) removeServerLocked(name string) {
+	serverInfo, exists := w.dynamicServers[name]
+	if !exists {
+		return
+	}
+
+	if serverInfo.Client != nil {
+		if err := serverInfo.Client.Close(); err != nil {
+			log.Printf("Error closing client %s: %v", name, err)
+		}
+	}
+
+	w.proxyServer.mu.Lock()
+	newClients := make([]client.MCPClient, 0, len(w.proxyServer.clients))
+	for _, c := range w.proxyServer.clients {
+		if c.ServerName() != name {
+			newClients = append(newClients, c)
+		}
+	}
+	w.proxyServer.clients = newClients
+
+	var removed []string
+	for _, tool := range w.proxyServer.registry.GetServerTools(name) {
+		w.proxyServer.registry.UnregisterTool(tool.PrefixedName)
+		removed = append(removed, tool.PrefixedName)
+	}
+	w.proxyServer.mu.Unlock()
+
+	if len(removed) > 0 {
+		w.baseServer.DeleteTools(removed...)
+	}
+	delete(w.dynamicServers, name)
+	w.latency.reset(name, "")
+	log.Printf("Removed server '%s' and %d tools", name, len(removed))
+}
+
+// connectServerLocked connects a configured server and registers its tools.
+// On failure the server is still listed, disconnected, so it can be retried
+// with server_reconnect. The caller holds w.mu.
+func (w *DynamicWrapper) connectServerLocked(ctx context.Context, serverConfig config.ServerConfig) error {
+	serverInfo := &DynamicServerInfo{
+		Name:   serverConfig.Name,
+		Config: serverConfig,
+		Tools:  []string{},
+	}
+	w.dynamicServers[serverConfig.Name] = serverInfo
+
+	tools, mcpClient, err := w.startServerClient(ctx, serverConfig, nil)
+	if err != nil {
+		serverInfo.ErrorMessage = err.Error()
+		log.Printf("Failed to connect server '%s': %v", serverConfig.Name, err)
+		return err
+	}
+
+	w.proxyServer.mu.Lock()
+	var discoveredTools []discovery.RemoteTool
+	var conflicts []ToolConflict
+	for _, tool := range tools {
+		discoveredTool, register, conflict, err := w.proxyServer.resolveToolConflict(discovery.RemoteTool{
+			OriginalName: tool.Name,
+			PrefixedName: discovery.PrefixedToolName(serverConfig.ToolPrefixFor(tool.Name), tool.Name),
+			Description:  tool.Description,
+			InputSchema:  tool.InputSchema,
+			ServerName:   serverConfig.Name,
+			ServerPrefix: serverConfig.ToolPrefix(),
+		})
+		if err != nil {
+			w.proxyServer.mu.Unlock()
+			mcpClient.Close()
+			serverInfo.ErrorMessage = fmt.Sprintf("Failed to register tools: %v", err)
+			return err
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+		if register {
+			discoveredTools = append(discoveredTools, discoveredTool)
+		}
+	}
+	w.proxyServer.recordToolConflicts(conflicts)
+	for _, discoveredTool := range discoveredTools {
+		w.proxyServer.registry.RegisterTool(discoveredTool, mcpClient)
+	}
+	w.proxyServer.clients = append(w.proxyServer.clients, mcpClient)
+	w.proxyServer.mu.Unlock()
+
+	for _, discoveredTool := range discoveredTools {
+		w.baseServer.AddTool(w.proxyServer.createMCPTool(discoveredTool), w.createDynamicProxyHandler(discoveredTool))
+		serverInfo.Tools = append(serverInfo.Tools, discoveredTool.PrefixedName)
+	}
+
+	serverInfo.Client = mcpClient
+	serverInfo.IsConnected = true
+	w.captureEnvSnapshotLocked(serverInfo)
+	log.Printf("Connected server '%s' with %d tools", serverConfig.Name, len(serverInfo.Tools))
+	return nil
+}
+
+// startServerClient creates, connects and initializes a client for
+// serverConfig and lists its tools. A non-nil envSnapshot is used as the
+// server's environment.
+func (w *DynamicWrapper) startServerClient(ctx context.Context, serverConfig config.ServerConfig, envSnapshot []string) ([]client.ToolInfo, client.MCPClient, error) {
+	mcpClient, err := w.connectServerClient(ctx, serverConfig, envSnapshot, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	tools, err := mcpClient.ListTools(ctx)
+	if err != nil {
+		mcpClient.Close()
+		return nil, nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	logNestedProxy(serverConfig.Name, toolInfoNames(tools))
+	return tools, mcpClient, nil
+}
+
+// connectServerClient creates, connects, initializes and warms up a client
+// for serverConfig, retrying per its maxRetries. A non-nil envSnapshot is
+// used as the server's environment; with relay, the server's notifications
+// are handled as those of a managed server. The caller holds w.mu, or
+// w.spawnMu for reading so the config isn't replaced meanwhile.
+func (w *DynamicWrapper) connectServerClient(ctx context.Context, serverConfig config.ServerConfig, envSnapshot []string, relay bool) (client.MCPClient, error) {
+	if serverConfig.Transport != "stdio" {
+		return nil, fmt.Errorf("unsupported transport: %s", serverConfig.Transport)
+	}
+
+	var mcpClient client.MCPClient
+	err := retryConnect(ctx, serverConfig.Name, w.proxyServer.maxRetries(serverConfig), func() error {
+		attempt := w.newServerClient(serverConfig, envSnapshot)
+		if relay {
+			w.watchNotifications(serverConfig.Name, attempt)
+		}
+
+		if err := attempt.Connect(ctx); err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		if _, err := initializeClient(ctx, attempt, serverConfig.Name, w.recordMessage); err != nil {
+			attempt.Close()
+			return fmt.Errorf("failed to initialize: %w", err)
+		}
+		if err := warmupClient(ctx, attempt, serverConfig, w.recordMessage); err != nil {
+			attempt.Close()
+			return err
+		}
+		mcpClient = attempt
+		return nil
+	})
+	return mcpClient, err
+}
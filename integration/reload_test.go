@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestReloadAppliesServerChanges(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	connects := make(map[string]int)
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		connects[serverConfig.Name]++
+		return client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "read"})
+	})
+	server := func(name string, args ...string) config.ServerConfig {
+		return config.ServerConfig{Name: name, Prefix: name, Transport: "stdio", Command: "fake-server", Args: args}
+	}
+	ctx := context.Background()
+
+	result, err := w.Reload(ctx, &config.ProxyConfig{Servers: []config.ServerConfig{server("a"), server("b")}})
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if len(result.Added) != 2 || w.baseServer.GetTool("a_read") == nil || w.baseServer.GetTool("b_read") == nil {
+		t.Fatalf("expected a and b added with their tools, got %+v", result)
+	}
+
+	result, err = w.Reload(ctx, &config.ProxyConfig{Servers: []config.ServerConfig{server("a", "--verbose"), server("c")}})
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if len(result.Added) != 1 || result.Added[0] != "c" {
+		t.Errorf("expected c added, got %v", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0] != "b" {
+		t.Errorf("expected b removed, got %v", result.Removed)
+	}
+	if len(result.Reconnected) != 1 || result.Reconnected[0] != "a" || connects["a"] != 2 {
+		t.Errorf("expected a reconnected once, got %v (%d connects)", result.Reconnected, connects["a"])
+	}
+	if _, exists := w.dynamicServers["b"]; exists || w.baseServer.GetTool("b_read") != nil {
+		t.Error("expected b and its tools to be removed")
+	}
+	if info := w.dynamicServers["a"]; !info.IsConnected || len(info.Config.Args) != 1 {
+		t.Errorf("expected a connected with the new config, got %+v", info)
+	}
+
+	// An invalid config is rejected and the running servers are kept
+	invalid := &config.ProxyConfig{Servers: []config.ServerConfig{server("a"), server("a")}}
+	if _, err := w.Reload(ctx, invalid); err == nil {
+		t.Fatal("expected an error for an invalid config")
+	}
+	if len(w.dynamicServers) != 2 || connects["a"] != 2 {
+		t.Errorf("expected the running config to be kept, got %d servers", len(w.dynamicServers))
+	}
+}
+
+func TestReloadKeepsFailedServerForReconnect(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name)
+		fake.SetConnectError(ErrServerDisconnected)
+		return fake
+	})
+
+	cfg := &config.ProxyConfig{Servers: []config.ServerConfig{{Name: "down", Prefix: "down", Transport: "stdio", Command: "fake-server"}}}
+	result, err := w.Reload(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if result.Failed["down"] == "" {
+		t.Errorf("expected down to be reported as failed, got %+v", result)
+	}
+	if info, exists := w.dynamicServers["down"]; !exists || info.IsConnected || info.ErrorMessage == "" {
+		t.Errorf("expected down listed as disconnected, got %+v", info)
+	}
+}
@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+
+	"mcp-debug/config"
+)
+
+// sanitizerPatterns caches compiled sanitizer patterns. Patterns are
+// validated when the configuration is loaded.
+var sanitizerPatterns sync.Map // pattern -> *regexp.Regexp
+
+// SanitizerMatch describes a tool call argument that matched a sanitizer rule
+type SanitizerMatch struct {
+	Rule     string
+	Action   config.SanitizerAction
+	Argument string // Path of the matching argument, e.g. "options.args[1]"
+}
+
+func (m SanitizerMatch) String() string {
+	return fmt.Sprintf("argument '%s' matches sanitizer rule '%s'", m.Argument, m.Rule)
+}
+
+// sanitizeArguments checks every string in args against rules and returns
+// the matches, ordered by argument path
+func sanitizeArguments(rules []config.SanitizerRule, args map[string]interface{}) []SanitizerMatch {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var matches []SanitizerMatch
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		switch v := value.(type) {
+		case string:
+			for _, rule := range rules {
+				if re := sanitizerPattern(rule.Pattern); re != nil && re.MatchString(v) {
+					matches = append(matches, SanitizerMatch{Rule: rule.DisplayName(), Action: rule.Action, Argument: path})
+				}
+			}
+		case map[string]interface{}:
+			for key, item := range v {
+				walk(path+"."+key, item)
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), item)
+			}
+		}
+	}
+	for key, value := range args {
+		walk(key, value)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Argument < matches[j].Argument
+	})
+	return matches
+}
+
+// checkSanitizer applies rules to a tool call's arguments. Warnings are
+// logged; the first blocking match is returned.
+func checkSanitizer(serverName, toolName string, rules []config.SanitizerRule, args map[string]interface{}) (SanitizerMatch, bool) {
+	var blocked *SanitizerMatch
+	for _, match := range sanitizeArguments(rules, args) {
+		if match.Action == config.SanitizerBlock {
+			if blocked == nil {
+				m := match
+				blocked = &m
+			}
+			continue
+		}
+		log.Printf("Sanitizer warning for %s on server '%s': %s", toolName, serverName, match)
+	}
+
+	if blocked == nil {
+		return SanitizerMatch{}, false
+	}
+	log.Printf("Sanitizer blocked %s on server '%s': %s", toolName, serverName, blocked)
+	return *blocked, true
+}
+
+// sanitizerPattern returns the compiled pattern, or nil if it is invalid
+// (only possible when the configuration was not validated)
+func sanitizerPattern(pattern string) *regexp.Regexp {
+	if re, ok := sanitizerPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Printf("Ignoring invalid sanitizer pattern %q: %v", pattern, err)
+		return nil
+	}
+	sanitizerPatterns.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,117 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/proxy"
+)
+
+// handleServerCall forwards a call to any tool of a server by name, whether
+// or not the proxy registered it. The backend's result is returned as sent:
+// the tool's transforms and resultContent: text are not applied, and
+// neither is rateLimit. Sanitizer rules still are, so server_call is no way
+// around them.
+func (w *DynamicWrapper) handleServerCall(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Record the request
+	w.recordMessage("request", "tool_call", "server_call", "proxy", request)
+	start := time.Now()
+
+	respond := func(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		result = w.addRecordingMetadata(result)
+		w.recordToolResponse("server_call", "", "proxy", result, start)
+		return result, nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		return respond(mcp.NewToolResultError("name is required"))
+	}
+	toolName, err := request.RequireString("tool")
+	if err != nil {
+		return respond(mcp.NewToolResultError("tool is required"))
+	}
+	args := map[string]interface{}{}
+	if raw, ok := request.GetArguments()["arguments"]; ok && raw != nil {
+		if args, ok = raw.(map[string]interface{}); !ok {
+			return respond(mcp.NewToolResultError("arguments must be an object"))
+		}
+	}
+
+	result, blocked := w.callServerTool(ctx, name, toolName, args)
+	if blocked {
+		result = w.addRecordingMetadata(result)
+		w.recordBlockedResponse("server_call", "", "proxy", result, start)
+		return result, nil
+	}
+	return respond(result)
+}
+
+// callServerTool calls a tool of a server by its original name, waking an
+// idle server first, and returns the backend's result as sent. Failures,
+// including calls blocked by the server's sanitizer rules, are returned as
+// error results; blocked reports the latter.
+func (w *DynamicWrapper) callServerTool(ctx context.Context, name, toolName string, args map[string]interface{}) (result *mcp.CallToolResult, blocked bool) {
+	w.mu.RLock()
+	serverInfo, err := w.lookupServer(name)
+	var mcpClient client.MCPClient
+	var sanitizer []config.SanitizerRule
+	var idle bool
+	var errorMessage string
+	if err == nil {
+		sanitizer = serverInfo.Config.Sanitizer
+		idle = serverInfo.Idle
+		errorMessage = serverInfo.ErrorMessage
+		if serverInfo.IsConnected {
+			mcpClient = serverInfo.Client
+		}
+	}
+	w.mu.RUnlock()
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), false
+	}
+
+	if mcpClient == nil && idle {
+		if mcpClient, err = w.wakeIdleServer(ctx, name); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("[%s] failed to reconnect idle server: %v", name, err)), false
+		}
+	}
+	if mcpClient == nil {
+		errorMsg := (&ServerError{Server: name, Err: ErrServerDisconnected}).Error()
+		if errorMessage != "" {
+			errorMsg += fmt.Sprintf(": %s", errorMessage)
+		}
+		return mcp.NewToolResultError(errorMsg + "\nUse server_reconnect to restore connection."), false
+	}
+
+	if match, blocked := checkSanitizer(name, toolName, sanitizer, args); blocked {
+		return mcp.NewToolResultError(fmt.Sprintf("Blocked by proxy: %s. The call was not forwarded to server '%s'.", match, name)), true
+	}
+
+	// The client bounds the call by the server's timeout
+	w.touchServer(name)
+	callResult, err := mcpClient.CallTool(ctx, toolName, args)
+	w.touchServer(name)
+	if err != nil {
+		var clientErr *client.ClientError
+		if errors.As(err, &clientErr) {
+			return newBackendErrorResult(name, clientErr), false
+		}
+		if isConnectionError(err) {
+			w.mu.Lock()
+			serverInfo.IsConnected = false
+			serverInfo.ErrorMessage = err.Error()
+			w.mu.Unlock()
+			return mcp.NewToolResultError(fmt.Sprintf("Server '%s' connection failed: %v\nUse server_reconnect to restore connection.", name, err)), false
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("[%s] %v", name, err)), false
+	}
+
+	return proxy.TransformResult(callResult, false), false
+}
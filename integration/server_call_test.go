@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestServerCall(t *testing.T) {
+	fake := client.NewFakeClient("fs")
+	fake.SetToolResult("hidden", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "  raw result  "}}})
+	w := newTestWrapper(t, "fs", fake)
+	w.dynamicServers["fs"].Config.Transforms = config.ToolTransforms{"hidden": {{Op: config.TransformTrim}}}
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+
+	// The tool was never registered, and its transforms are not applied
+	result := callTool(t, w.handleServerCall, map[string]interface{}{
+		"name":      "fs",
+		"tool":      "hidden",
+		"arguments": map[string]interface{}{"path": "/tmp"},
+	})
+	w.DisableRecording()
+	if result.IsError || resultText(result) != "  raw result  " {
+		t.Fatalf("expected the backend's result as sent, got %#v", result)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Name != "hidden" || calls[0].Args["path"] != "/tmp" {
+		t.Errorf("expected the call to be forwarded with its arguments, got %+v", calls)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if strings.Count(string(data), `"tool_name":"server_call"`) != 2 {
+		t.Errorf("expected the request and response to be recorded, got:\n%s", data)
+	}
+}
+
+func TestServerCallErrors(t *testing.T) {
+	fake := client.NewFakeClient("fs")
+	w := newTestWrapper(t, "fs", fake)
+	w.dynamicServers["fs"].Config.Sanitizer = []config.SanitizerRule{{Pattern: "rm -rf", Action: config.SanitizerBlock}}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"missing tool", map[string]interface{}{"name": "fs"}, "tool is required"},
+		{"unknown server", map[string]interface{}{"name": "db", "tool": "query"}, "Server 'db' not found"},
+		{"arguments not an object", map[string]interface{}{"name": "fs", "tool": "run", "arguments": "[1]"}, "arguments must be an object"},
+		{"sanitizer", map[string]interface{}{"name": "fs", "tool": "run", "arguments": map[string]interface{}{"cmd": "rm -rf /"}}, "Blocked by proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callTool(t, w.handleServerCall, tt.args)
+			if !result.IsError || !strings.Contains(resultText(result), tt.want) {
+				t.Errorf("expected an error containing %q, got %q", tt.want, resultText(result))
+			}
+		})
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("expected no call to reach the backend, got %+v", calls)
+	}
+
+	t.Run("disconnected", func(t *testing.T) {
+		w.dynamicServers["fs"].IsConnected = false
+		result := callTool(t, w.handleServerCall, map[string]interface{}{"name": "fs", "tool": "read"})
+		if !result.IsError || !strings.Contains(resultText(result), "server_reconnect") {
+			t.Errorf("expected a disconnected error, got %q", resultText(result))
+		}
+	})
+}
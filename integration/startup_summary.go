@@ -0,0 +1,140 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"mcp-debug/config"
+)
+
+// SummaryFormat selects how the startup summary is logged
+type SummaryFormat string
+
+const (
+	// SummaryText logs one aligned line per server (default)
+	SummaryText SummaryFormat = "text"
+	// SummaryJSON logs the summary as a single JSON object for tooling
+	SummaryJSON SummaryFormat = "json"
+)
+
+// ParseSummaryFormat converts a flag value to a SummaryFormat.
+// An empty value selects SummaryText.
+func ParseSummaryFormat(value string) (SummaryFormat, error) {
+	switch format := SummaryFormat(value); format {
+	case "":
+		return SummaryText, nil
+	case SummaryText, SummaryJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid startup summary format %q (must be text or json)", value)
+	}
+}
+
+// StartupSummary describes the effective configuration after Initialize
+type StartupSummary struct {
+	Servers   []ServerSummary `json:"servers"`
+	Connected int             `json:"connected"`
+	Tools     int             `json:"tools"`
+}
+
+// ServerSummary describes one configured server after Initialize
+type ServerSummary struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	Inherit   string `json:"inherit,omitempty"` // Resolved inherit mode (stdio only)
+	Tools     int    `json:"tools"`
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SetStartupSummaryFormat sets how Initialize logs the startup summary
+func (w *DynamicWrapper) SetStartupSummaryFormat(format SummaryFormat) error {
+	if _, err := ParseSummaryFormat(string(format)); err != nil {
+		return err
+	}
+	w.summaryFormat = format
+	return nil
+}
+
+// StartupSummary returns the state of the configured servers, in config order
+func (w *DynamicWrapper) StartupSummary() *StartupSummary {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	summary := &StartupSummary{Servers: []ServerSummary{}}
+	for _, serverConfig := range w.proxyServer.config.Servers {
+		serverInfo, exists := w.dynamicServers[serverConfig.Name]
+		if !exists {
+			continue
+		}
+
+		server := ServerSummary{
+			Name:      serverConfig.Name,
+			Transport: serverConfig.Transport,
+			Tools:     len(serverInfo.Tools),
+			Connected: serverInfo.IsConnected,
+			Error:     serverInfo.ErrorMessage,
+		}
+		if serverConfig.Transport == "stdio" {
+			mode := serverConfig.ResolveInheritConfig(w.proxyServer.config.Inherit).Mode
+			if mode == "" {
+				mode = config.InheritTier1
+			}
+			server.Inherit = string(mode)
+		}
+
+		summary.Servers = append(summary.Servers, server)
+		summary.Tools += server.Tools
+		if server.Connected {
+			summary.Connected++
+		}
+	}
+	return summary
+}
+
+// logStartupSummary logs the startup summary as a single log entry
+func (w *DynamicWrapper) logStartupSummary() {
+	summary := w.StartupSummary()
+
+	if w.summaryFormat == SummaryJSON {
+		data, err := json.Marshal(summary)
+		if err != nil {
+			log.Printf("Failed to encode startup summary: %v", err)
+			return
+		}
+		log.Printf("Startup summary: %s", data)
+		return
+	}
+
+	log.Print(formatStartupSummary(summary))
+}
+
+// formatStartupSummary renders the summary as a header and one aligned line
+// per server
+func formatStartupSummary(summary *StartupSummary) string {
+	nameWidth := len("SERVER")
+	for _, server := range summary.Servers {
+		if len(server.Name) > nameWidth {
+			nameWidth = len(server.Name)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Startup summary: %d/%d servers connected, %d tools",
+		summary.Connected, len(summary.Servers), summary.Tools)
+	for _, server := range summary.Servers {
+		inherit := server.Inherit
+		if inherit == "" {
+			inherit = "-"
+		}
+		status := "connected"
+		if !server.Connected {
+			status = "failed: " + server.Error
+		}
+		fmt.Fprintf(&b, "\n  %-*s  %-5s  inherit=%-11s  tools=%-3d  %s",
+			nameWidth, server.Name, server.Transport, inherit, server.Tools, status)
+	}
+	return b.String()
+}
@@ -0,0 +1,230 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tapQueueSize is how many messages a trace tap client may fall behind
+// before messages are dropped for it
+const tapQueueSize = 1024
+
+// tapCloseTimeout bounds how long Close leaves clients to take what was
+// queued for them before their connections are dropped
+const tapCloseTimeout = time.Second
+
+// TapDropped is sent to a trace tap client in place of the messages dropped
+// because it fell behind, before the next message it receives
+type TapDropped struct {
+	Dropped int `json:"dropped"`
+}
+
+// traceTap streams recorded messages, one RecordedMessage JSON object per
+// line, to every client connected to its listener. Each client has its own
+// queue and writer, so a slow client loses messages instead of blocking the
+// proxy or the other clients.
+type traceTap struct {
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[*tapClient]struct{}
+	closed   bool
+}
+
+type tapClient struct {
+	conn    net.Conn
+	queue   chan []byte
+	dropped int // Messages dropped since the last one queued; guarded by traceTap.mu
+}
+
+// ParseTapAddress splits a trace tap address into a network and address
+// for net.Listen: "unix:PATH" or a bare path for a Unix domain socket, and
+// "tcp:HOST:PORT" for a TCP port
+func ParseTapAddress(address string) (network, addr string, err error) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "tcp:"):
+		network, addr = "tcp", strings.TrimPrefix(address, "tcp:")
+	default:
+		network, addr = "unix", address
+	}
+	if addr == "" {
+		return "", "", fmt.Errorf("invalid trace tap address %q: expected unix:PATH or tcp:HOST:PORT", address)
+	}
+	return network, addr, nil
+}
+
+// newTraceTap listens on address and starts accepting clients. A stale
+// socket file left by a previous run is replaced.
+func newTraceTap(address string) (*traceTap, error) {
+	network, addr, err := ParseTapAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for trace taps: %w", err)
+	}
+
+	tap := &traceTap{
+		listener: listener,
+		clients:  make(map[*tapClient]struct{}),
+	}
+	go tap.accept()
+	return tap, nil
+}
+
+// Addr returns the address the tap listens on
+func (t *traceTap) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *traceTap) accept() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Trace tap stopped accepting clients: %v", err)
+			}
+			return
+		}
+
+		c := &tapClient{conn: conn, queue: make(chan []byte, tapQueueSize)}
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			conn.Close()
+			return
+		}
+		t.clients[c] = struct{}{}
+		t.mu.Unlock()
+		log.Printf("Trace tap client connected: %s", conn.RemoteAddr())
+		go t.serve(c)
+	}
+}
+
+// serve writes a client's queued messages until the queue is closed or a
+// write fails
+func (t *traceTap) serve(c *tapClient) {
+	for line := range c.queue {
+		if _, err := c.conn.Write(line); err != nil {
+			t.remove(c)
+			break
+		}
+	}
+	c.conn.Close()
+	// Drain what was queued before the client was removed
+	for range c.queue {
+	}
+}
+
+// remove disconnects a client and closes its queue
+func (t *traceTap) remove(c *tapClient) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.clients[c]; ok {
+		delete(t.clients, c)
+		close(c.queue)
+		log.Printf("Trace tap client disconnected: %s", c.conn.RemoteAddr())
+	}
+}
+
+// active reports whether any client is connected
+func (t *traceTap) active() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.clients) > 0
+}
+
+// broadcast queues a message for every client without waiting. A client
+// whose queue is full misses the message and is told how many it missed
+// once there is room again.
+func (t *traceTap) broadcast(message []byte) {
+	line := append(append([]byte(nil), message...), '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for c := range t.clients {
+		if c.dropped > 0 {
+			marker, _ := json.Marshal(TapDropped{Dropped: c.dropped})
+			select {
+			case c.queue <- append(marker, '\n'):
+				c.dropped = 0
+			default:
+				c.dropped++
+				continue
+			}
+		}
+		select {
+		case c.queue <- line:
+		default:
+			c.dropped++
+		}
+	}
+}
+
+// Close stops accepting clients and disconnects the connected ones. A
+// client that doesn't take its queued messages within tapCloseTimeout is
+// dropped, so no writer is left blocked on it.
+func (t *traceTap) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	deadline := time.Now().Add(tapCloseTimeout)
+	for c := range t.clients {
+		delete(t.clients, c)
+		close(c.queue)
+		c.conn.SetWriteDeadline(deadline)
+	}
+	t.mu.Unlock()
+
+	return t.listener.Close()
+}
+
+// StartTraceTap streams every message the proxy records, whether or not a
+// recording file is open, to clients connecting to address ("unix:PATH" or
+// "tcp:HOST:PORT"). It is stopped by Shutdown.
+func (w *DynamicWrapper) StartTraceTap(address string) error {
+	tap, err := newTraceTap(address)
+	if err != nil {
+		return err
+	}
+
+	w.recordMu.Lock()
+	defer w.recordMu.Unlock()
+
+	if w.tap != nil {
+		tap.Close()
+		return fmt.Errorf("a trace tap is already listening on %s", w.tap.Addr())
+	}
+	w.tap = tap
+	log.Printf("Trace tap listening on %s %s", tap.Addr().Network(), tap.Addr())
+	return nil
+}
+
+// stopTraceTap closes the trace tap, if one was started
+func (w *DynamicWrapper) stopTraceTap() error {
+	w.recordMu.Lock()
+	tap := w.tap
+	w.tap = nil
+	w.recordMu.Unlock()
+
+	if tap == nil {
+		return nil
+	}
+	return tap.Close()
+}
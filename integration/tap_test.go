@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mcp-debug/client"
+)
+
+func TestParseTapAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"unix:/tmp/mcp.sock", "unix", "/tmp/mcp.sock"},
+		{"/tmp/mcp.sock", "unix", "/tmp/mcp.sock"},
+		{"tcp:127.0.0.1:7777", "tcp", "127.0.0.1:7777"},
+	}
+	for _, tt := range tests {
+		network, addr, err := ParseTapAddress(tt.address)
+		if err != nil || network != tt.network || addr != tt.addr {
+			t.Errorf("ParseTapAddress(%q) = %q, %q, %v; want %q, %q", tt.address, network, addr, err, tt.network, tt.addr)
+		}
+	}
+	if _, _, err := ParseTapAddress("tcp:"); err == nil {
+		t.Error("expected an error for an empty address")
+	}
+}
+
+func TestTraceTapStreamsRecordedMessages(t *testing.T) {
+	w := newTestWrapper(t, "fs", client.NewFakeClient("fs"))
+	socket := filepath.Join(t.TempDir(), "tap.sock")
+	if err := w.StartTraceTap("unix:" + socket); err != nil {
+		t.Fatalf("start trace tap: %v", err)
+	}
+
+	// Two taps, without a recording file
+	var readers []*bufio.Reader
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			t.Fatalf("dial tap: %v", err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		readers = append(readers, bufio.NewReader(conn))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.tap.mu.Lock()
+		connected := len(w.tap.clients)
+		w.tap.mu.Unlock()
+		if connected == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 tap clients, got %d", connected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.recordMessage("request", "tool_call", "server_list", "proxy", map[string]string{"name": "server_list"})
+	for i, reader := range readers {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("tap %d: read: %v", i, err)
+		}
+		var recorded RecordedMessage
+		if err := json.Unmarshal(line, &recorded); err != nil {
+			t.Fatalf("tap %d: invalid line %q: %v", i, line, err)
+		}
+		if recorded.Direction != "request" || recorded.ToolName != "server_list" {
+			t.Errorf("tap %d: unexpected message %+v", i, recorded)
+		}
+	}
+	if w.GetRecordingStatus().Enabled {
+		t.Error("the tap must not start a recording")
+	}
+
+	if err := w.Shutdown(t.Context()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestTraceTapDropsForSlowClients(t *testing.T) {
+	tap := &traceTap{clients: make(map[*tapClient]struct{})}
+	slow := &tapClient{queue: make(chan []byte, 2)}
+	tap.clients[slow] = struct{}{}
+
+	// Nothing reads the queue; broadcasting must not block
+	for _, message := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`} {
+		tap.broadcast([]byte(message))
+	}
+	if slow.dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", slow.dropped)
+	}
+
+	<-slow.queue
+	<-slow.queue
+	tap.broadcast([]byte(`{"n":5}`))
+	if got := string(<-slow.queue); got != "{\"dropped\":2}\n" {
+		t.Errorf("expected a dropped marker, got %q", got)
+	}
+	if got := string(<-slow.queue); got != "{\"n\":5}\n" {
+		t.Errorf("expected the next message after the marker, got %q", got)
+	}
+}
+
+func TestTraceTapCloseDisconnectsStuckClients(t *testing.T) {
+	tap, err := newTraceTap("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start tap: %v", err)
+	}
+
+	// Nothing reads the client's end, so its writer blocks
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	stuck := &tapClient{conn: conn, queue: make(chan []byte, tapQueueSize)}
+	tap.mu.Lock()
+	tap.clients[stuck] = struct{}{}
+	tap.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		tap.serve(stuck)
+		close(done)
+	}()
+	tap.broadcast([]byte(`{"n":1}`))
+
+	if err := tap.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(tapCloseTimeout + 5*time.Second):
+		t.Fatal("expected Close to disconnect a client that stopped reading")
+	}
+}
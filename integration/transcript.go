@@ -0,0 +1,159 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+// withTranscript appends an entry for each call of a proxied tool to its
+// server's transcript, when the server's config has one. The config is
+// looked up per call, so a reload enabling or moving it applies at once.
+func (w *DynamicWrapper) withTranscript(tool discovery.RemoteTool, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := handler(ctx, request)
+
+		w.mu.RLock()
+		var transcript *config.TranscriptConfig
+		if serverInfo, exists := w.dynamicServers[tool.ServerName]; exists {
+			transcript = serverInfo.Config.Transcript
+		}
+		w.mu.RUnlock()
+
+		if transcript != nil {
+			entry := formatTranscriptEntry(tool, request.GetArguments(), result, err, start, time.Since(start), transcript.GetMaxBytes())
+			w.writeTranscript(transcript.File, entry)
+		}
+		return result, err
+	}
+}
+
+// writeTranscript appends an entry to a transcript file. Entries are
+// written whole, one call at a time, so concurrent calls don't interleave.
+func (w *DynamicWrapper) writeTranscript(filename, entry string) {
+	w.transcriptMu.Lock()
+	defer w.transcriptMu.Unlock()
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to open transcript %s: %v", filename, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.WriteString(entry); err != nil {
+		log.Printf("Failed to write transcript %s: %v", filename, err)
+	}
+}
+
+// formatTranscriptEntry describes one tool call: a heading with the time,
+// the tool and the outcome, then the arguments and a summary of the result,
+// each cut to maxBytes
+func formatTranscriptEntry(tool discovery.RemoteTool, args map[string]interface{}, result *mcp.CallToolResult, err error, start time.Time, duration time.Duration, maxBytes int) string {
+	outcome := "ok"
+	var summary string
+	switch {
+	case err != nil:
+		outcome, summary = "failed", err.Error()
+	case result == nil:
+		summary = "(no result)"
+	default:
+		if result.IsError {
+			outcome = "error"
+		}
+		summary = summarizeResult(result)
+	}
+
+	arguments := "{}"
+	if len(args) > 0 {
+		if data, err := json.Marshal(redactArguments(args)); err == nil {
+			arguments = string(data)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s (%s on %s) %s in %s\n", start.Format("2006-01-02 15:04:05.000"),
+		tool.PrefixedName, tool.OriginalName, tool.ServerName, outcome, duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  arguments: %s\n", truncateTranscript(arguments, maxBytes))
+	fmt.Fprintf(&b, "  result: %s\n\n", strings.ReplaceAll(truncateTranscript(summary, maxBytes), "\n", "\n    "))
+	return b.String()
+}
+
+// summarizeResult joins a result's text, leaving out the recording
+// annotation, and counts the items that aren't text
+func summarizeResult(result *mcp.CallToolResult) string {
+	var texts []string
+	other := 0
+	for _, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		switch {
+		case !ok:
+			other++
+		case !strings.HasPrefix(text.Text, recordingMetadataPrefix):
+			texts = append(texts, text.Text)
+		}
+	}
+	summary := strings.Join(texts, "\n")
+	if other > 0 {
+		summary += fmt.Sprintf(" (+%d non-text items)", other)
+	}
+	if summary == "" && result.StructuredContent != nil {
+		summary = "(structured content only)"
+	}
+	return summary
+}
+
+// redactArguments returns a copy of args with the values of secret-looking
+// arguments, e.g. api_key or token, replaced by config.RedactedValue, at
+// any depth
+func redactArguments(args map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if client.LooksLikeSecret("_" + key) {
+			redacted[key] = config.RedactedValue
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactArguments(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+// truncateTranscript cuts text to maxBytes, on a character boundary, noting
+// how much was left out
+func truncateTranscript(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… (%d more bytes)", text[:cut], len(text)-cut)
+}
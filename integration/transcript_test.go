@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+func TestTranscript(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "first line\n" + strings.Repeat("x", 100)}}})
+	fake.SetToolError("write", errors.New("disk full"))
+	w := newTestWrapper(t, "fake", fake)
+
+	filename := filepath.Join(t.TempDir(), "fake-transcript.log")
+	w.dynamicServers["fake"].Config.Transcript = &config.TranscriptConfig{File: filename, MaxBytes: 40}
+
+	read := w.createDynamicProxyHandler(discovery.RemoteTool{OriginalName: "read", PrefixedName: "fake_read", ServerName: "fake"})
+	write := w.createDynamicProxyHandler(discovery.RemoteTool{OriginalName: "write", PrefixedName: "fake_write", ServerName: "fake"})
+	callTool(t, read, map[string]interface{}{
+		"path":    "/tmp/a",
+		"api_key": "s3cret",
+		"options": map[string]interface{}{"token": "t0ken"},
+	})
+	callTool(t, write, map[string]interface{}{"path": "/tmp/b"})
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	transcript := string(data)
+
+	entries := strings.Split(strings.TrimSpace(transcript), "\n\n")
+	if len(entries) != 2 {
+		t.Fatalf("expected an entry per call, got:\n%s", transcript)
+	}
+	if !strings.Contains(entries[0], "fake_read (read on fake) ok in") || !strings.Contains(entries[0], "result: first line\n    xxx") {
+		t.Errorf("unexpected entry for the successful call:\n%s", entries[0])
+	}
+	if !strings.Contains(entries[0], "(71 more bytes)") {
+		t.Errorf("expected the result cut to maxBytes:\n%s", entries[0])
+	}
+	if strings.Contains(transcript, "s3cret") || strings.Contains(transcript, "t0ken") || !strings.Contains(transcript, `"api_key":"[REDACTED]"`) {
+		t.Errorf("expected secret-looking arguments redacted:\n%s", transcript)
+	}
+	if !strings.Contains(entries[1], "fake_write (write on fake) error in") || !strings.Contains(entries[1], "disk full") {
+		t.Errorf("unexpected entry for the failed call:\n%s", entries[1])
+	}
+}
@@ -0,0 +1,162 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/config"
+)
+
+// truncatedSuffix marks text cut by a truncate transform
+const truncatedSuffix = "... [truncated]"
+
+// applyTransforms runs the transform pipeline over each text item of a
+// successful result. Error results and other content are left alone, as is
+// structured content. The input result is not modified.
+func applyTransforms(result *mcp.CallToolResult, steps []config.TransformStep) (*mcp.CallToolResult, error) {
+	if len(steps) == 0 || result.IsError {
+		return result, nil
+	}
+
+	transformed := *result
+	transformed.Content = make([]mcp.Content, len(result.Content))
+	for i, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			transformed.Content[i] = content
+			continue
+		}
+		for j, step := range steps {
+			var err error
+			if text.Text, err = applyTransform(text.Text, step); err != nil {
+				return nil, fmt.Errorf("transform %d (%s): %w", j, step.Op, err)
+			}
+		}
+		transformed.Content[i] = text
+	}
+	return &transformed, nil
+}
+
+// applyTransform applies a single step to text
+func applyTransform(text string, step config.TransformStep) (string, error) {
+	switch step.Op {
+	case config.TransformTrim:
+		return strings.TrimSpace(text), nil
+	case config.TransformExtract:
+		return extractJSONPath(text, step.Path)
+	case config.TransformTruncate:
+		runes := []rune(text)
+		if len(runes) <= step.Max {
+			return text, nil
+		}
+		return string(runes[:step.Max]) + truncatedSuffix, nil
+	default:
+		return "", fmt.Errorf("unknown op %q", step.Op)
+	}
+}
+
+// extractJSONPath parses text as JSON and returns the value at a dotted
+// path: strings as they are, other values as JSON
+func extractJSONPath(text, path string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", fmt.Errorf("result is not JSON: %w", err)
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			next, ok := current[segment]
+			if !ok {
+				return "", fmt.Errorf("path %s: no field %q", path, segment)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(current) {
+				return "", fmt.Errorf("path %s: no index %q in a list of %d", path, segment, len(current))
+			}
+			value = current[index]
+		default:
+			return "", fmt.Errorf("path %s: %q is not inside an object or list", path, segment)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// limitResultSize cuts a successful result whose content exceeds maxBytes,
+// so clients with a size limit get usable output instead of an error. Text
+// items count their text and other items their JSON encoding. The item
+// crossing the budget is cut, on a character boundary if it is text and
+// dropped otherwise, later items are dropped, and a "[truncated N bytes]"
+// text item is appended. Structured content counts its JSON encoding and,
+// as a partial copy would no longer match its schema, is dropped whole
+// when the result is cut, which the appended item says. It returns the
+// result, not modified, and the number of bytes left out, 0 if none were.
+func limitResultSize(result *mcp.CallToolResult, maxBytes int) (*mcp.CallToolResult, int) {
+	if maxBytes <= 0 || result.IsError {
+		return result, 0
+	}
+
+	sizes := make([]int, len(result.Content))
+	total := 0
+	for i, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			sizes[i] = len(text.Text)
+		} else if data, err := json.Marshal(content); err == nil {
+			sizes[i] = len(data)
+		}
+		total += sizes[i]
+	}
+	structuredSize := 0
+	if result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			structuredSize = len(data)
+		}
+	}
+	if total+structuredSize <= maxBytes {
+		return result, 0
+	}
+
+	limited := *result
+	limited.Content = nil
+	limited.StructuredContent = nil
+	remaining := maxBytes
+	for i, content := range result.Content {
+		if sizes[i] <= remaining {
+			limited.Content = append(limited.Content, content)
+			remaining -= sizes[i]
+			continue
+		}
+		if text, ok := content.(mcp.TextContent); ok && remaining > 0 {
+			cut := remaining
+			for cut > 0 && !utf8.RuneStart(text.Text[cut]) {
+				cut--
+			}
+			text.Text = text.Text[:cut]
+			limited.Content = append(limited.Content, text)
+			remaining -= cut
+		}
+		break
+	}
+	dropped := total - (maxBytes - remaining)
+	note := fmt.Sprintf("[truncated %d bytes]", dropped+structuredSize)
+	if structuredSize > 0 {
+		note = fmt.Sprintf("[truncated %d bytes, including structuredContent]", dropped+structuredSize)
+	}
+	limited.Content = append(limited.Content, mcp.NewTextContent(note))
+	return &limited, dropped + structuredSize
+}
@@ -0,0 +1,303 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/discovery"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		steps   []config.TransformStep
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "trim",
+			text:  "  padded \n",
+			steps: []config.TransformStep{{Op: config.TransformTrim}},
+			want:  "padded",
+		},
+		{
+			name:  "extract string",
+			text:  `{"data":{"items":[{"name":"first"}]}}`,
+			steps: []config.TransformStep{{Op: config.TransformExtract, Path: "data.items.0.name"}},
+			want:  "first",
+		},
+		{
+			name:  "extract object",
+			text:  `{"data":{"count":2,"ok":true}}`,
+			steps: []config.TransformStep{{Op: config.TransformExtract, Path: "data"}},
+			want:  `{"count":2,"ok":true}`,
+		},
+		{
+			name: "pipeline",
+			text: `  {"body":"abcdefgh"}  `,
+			steps: []config.TransformStep{
+				{Op: config.TransformTrim},
+				{Op: config.TransformExtract, Path: "body"},
+				{Op: config.TransformTruncate, Max: 3},
+			},
+			want: "abc" + truncatedSuffix,
+		},
+		{
+			name:  "truncate short text",
+			text:  "abc",
+			steps: []config.TransformStep{{Op: config.TransformTruncate, Max: 3}},
+			want:  "abc",
+		},
+		{
+			name:    "extract non-JSON",
+			text:    "plain",
+			steps:   []config.TransformStep{{Op: config.TransformExtract, Path: "data"}},
+			wantErr: "not JSON",
+		},
+		{
+			name:    "extract missing field",
+			text:    `{"data":{}}`,
+			steps:   []config.TransformStep{{Op: config.TransformExtract, Path: "data.missing"}},
+			wantErr: `no field "missing"`,
+		},
+		{
+			name:    "extract bad index",
+			text:    `{"items":[1]}`,
+			steps:   []config.TransformStep{{Op: config.TransformExtract, Path: "items.3"}},
+			wantErr: `no index "3"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := mcp.NewToolResultText(tt.text)
+			result, err := applyTransforms(input, tt.steps)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := resultText(result); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if resultText(input) != tt.text {
+				t.Error("expected the input result to be left unchanged")
+			}
+		})
+	}
+
+	t.Run("error results pass through", func(t *testing.T) {
+		input := mcp.NewToolResultError("  failed  ")
+		result, err := applyTransforms(input, []config.TransformStep{{Op: config.TransformTrim}})
+		if err != nil || result != input {
+			t.Errorf("expected the error result unchanged, got %#v, %v", result, err)
+		}
+	})
+}
+
+func TestDynamicProxyHandlerTransforms(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: `{"data":{"value":"kept"},"noise":"dropped"}`}}})
+	w := newTestWrapper(t, "fake", fake)
+	w.dynamicServers["fake"].Config.Transforms = config.ToolTransforms{
+		"read": {{Op: config.TransformExtract, Path: "data.value"}},
+	}
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+	result := callTool(t, handler, nil)
+	w.DisableRecording()
+
+	if got := resultText(result); got != "kept" {
+		t.Errorf("expected the transformed result, got %q", got)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var response *RecordedMessage
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, `{"timestamp"`) {
+			continue
+		}
+		var recorded RecordedMessage
+		if err := json.Unmarshal([]byte(line), &recorded); err != nil {
+			t.Fatalf("invalid recorded line: %v", err)
+		}
+		if recorded.Direction == "response" {
+			response = &recorded
+		}
+	}
+	if response == nil {
+		t.Fatal("expected a recorded response")
+	}
+	if !strings.Contains(string(response.Untransformed), "dropped") {
+		t.Errorf("expected the untransformed result in the recording, got %s", response.Untransformed)
+	}
+	if strings.Contains(string(response.Message), "dropped") {
+		t.Errorf("expected the recorded message to be transformed, got %s", response.Message)
+	}
+
+	t.Run("failed transform", func(t *testing.T) {
+		fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: "not json"}}})
+		result := callTool(t, handler, nil)
+		if !result.IsError || !strings.Contains(resultText(result), "failed to transform") {
+			t.Errorf("expected a transform error, got %#v", result)
+		}
+	})
+}
+
+func TestLimitResultSize(t *testing.T) {
+	text := func(texts ...string) *mcp.CallToolResult {
+		result := &mcp.CallToolResult{}
+		for _, s := range texts {
+			result.Content = append(result.Content, mcp.NewTextContent(s))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name        string
+		result      *mcp.CallToolResult
+		maxBytes    int
+		wantTexts   []string
+		wantDropped int
+	}{
+		{"unlimited", text("0123456789"), 0, []string{"0123456789"}, 0},
+		{"exactly at the budget", text("0123456789"), 10, []string{"0123456789"}, 0},
+		{"one byte over", text("0123456789"), 9, []string{"012345678", "[truncated 1 bytes]"}, 1},
+		{"across items", text("01234", "56789"), 7, []string{"01234", "56", "[truncated 3 bytes]"}, 3},
+		{"item ending at the budget", text("01234", "56789"), 5, []string{"01234", "[truncated 5 bytes]"}, 5},
+		{"character boundary", text("añb"), 2, []string{"a", "[truncated 3 bytes]"}, 3},
+		{"error result", &mcp.CallToolResult{IsError: true, Content: []mcp.Content{mcp.NewTextContent("0123456789")}}, 5, []string{"0123456789"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited, dropped := limitResultSize(tt.result, tt.maxBytes)
+			if dropped != tt.wantDropped {
+				t.Errorf("expected %d bytes dropped, got %d", tt.wantDropped, dropped)
+			}
+			var texts []string
+			for _, content := range limited.Content {
+				texts = append(texts, content.(mcp.TextContent).Text)
+			}
+			if strings.Join(texts, "|") != strings.Join(tt.wantTexts, "|") {
+				t.Errorf("expected %q, got %q", tt.wantTexts, texts)
+			}
+		})
+	}
+
+	t.Run("structured content", func(t *testing.T) {
+		structured := map[string]any{"rows": strings.Repeat("x", 20)}
+		size := len(`{"rows":"xxxxxxxxxxxxxxxxxxxx"}`)
+
+		result := &mcp.CallToolResult{Content: []mcp.Content{mcp.NewTextContent("0123456789")}, StructuredContent: structured}
+		if limited, dropped := limitResultSize(result, 10+size); dropped != 0 || limited.StructuredContent == nil {
+			t.Errorf("expected a result within the budget unchanged, got %#v (%d bytes)", limited, dropped)
+		}
+
+		limited, dropped := limitResultSize(result, 12)
+		if dropped != size || limited.StructuredContent != nil {
+			t.Errorf("expected only the structured content dropped, got %#v (%d bytes)", limited, dropped)
+		}
+		if len(limited.Content) != 2 || limited.Content[1].(mcp.TextContent).Text != fmt.Sprintf("[truncated %d bytes, including structuredContent]", size) {
+			t.Errorf("expected a note on the dropped structured content, got %#v", limited.Content)
+		}
+
+		limited, dropped = limitResultSize(result, 4)
+		if dropped != 6+size || resultText(limited) != "0123" || limited.StructuredContent != nil {
+			t.Errorf("expected the text cut and the structured content dropped, got %#v (%d bytes)", limited, dropped)
+		}
+		if result.StructuredContent == nil {
+			t.Error("expected the input result unchanged")
+		}
+	})
+
+	t.Run("non-text item over the budget", func(t *testing.T) {
+		result := &mcp.CallToolResult{Content: []mcp.Content{
+			mcp.NewTextContent("caption"),
+			mcp.NewImageContent(strings.Repeat("A", 1000), "image/png"),
+		}}
+		limited, dropped := limitResultSize(result, 100)
+		if len(limited.Content) != 2 || resultText(limited) != "caption" || dropped < 1000 {
+			t.Errorf("expected the image dropped, got %#v (%d bytes)", limited.Content, dropped)
+		}
+		if len(result.Content) != 2 || result.Content[1].(mcp.ImageContent).Data == "" {
+			t.Error("expected the input result unchanged")
+		}
+	})
+}
+
+func TestDynamicProxyHandlerMaxResultBytes(t *testing.T) {
+	fake := client.NewFakeClient("fake")
+	fake.SetToolResult("read", &client.CallToolResult{Content: []client.ContentItem{{Type: "text", Text: strings.Repeat("x", 50)}}})
+	w := newTestWrapper(t, "fake", fake)
+	w.proxyServer.config.Proxy.MaxResultBytes = 20
+
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := w.EnableRecording(filename); err != nil {
+		t.Fatalf("enable recording: %v", err)
+	}
+	handler := w.createDynamicProxyHandler(discovery.RemoteTool{
+		OriginalName: "read",
+		PrefixedName: "fake_read",
+		ServerName:   "fake",
+	})
+	result := callTool(t, handler, nil)
+	w.DisableRecording()
+
+	if got := resultText(result); got != strings.Repeat("x", 20) {
+		t.Errorf("expected the result cut to 20 bytes, got %q", got)
+	}
+	if marker := result.Content[1].(mcp.TextContent).Text; marker != "[truncated 30 bytes]" {
+		t.Errorf("expected the truncation marker, got %q", marker)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var response *RecordedMessage
+	for _, line := range strings.Split(string(data), "\n") {
+		var recorded RecordedMessage
+		if strings.HasPrefix(line, `{"timestamp"`) && json.Unmarshal([]byte(line), &recorded) == nil && recorded.Direction == "response" {
+			response = &recorded
+		}
+	}
+	if response == nil {
+		t.Fatal("expected a recorded response")
+	}
+	if !strings.Contains(string(response.Message), strings.Repeat("x", 50)) || response.ClientTruncated != 30 {
+		t.Errorf("expected the whole result recorded with the bytes cut, got %s (%d)", response.Message, response.ClientTruncated)
+	}
+
+	// A server can lift the proxy's budget
+	unlimited := 0
+	w.dynamicServers["fake"].Config.MaxResultBytes = &unlimited
+	if result := callTool(t, handler, nil); len(result.Content) != 1 || resultText(result) != strings.Repeat("x", 50) {
+		t.Errorf("expected the whole result with maxResultBytes 0, got %#v", result.Content)
+	}
+}
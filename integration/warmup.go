@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/proxy"
+)
+
+// warmupClient makes the server's warmup calls on a freshly initialized
+// client, in order. A failed call with onFailure continue is logged and the
+// next call made; any other failure is returned. When recorder is set, the
+// calls are recorded with message type "warmup".
+func warmupClient(ctx context.Context, mcpClient client.MCPClient, serverConfig config.ServerConfig, recorder proxy.RecorderFunc) error {
+	for _, call := range serverConfig.Warmup {
+		start := time.Now()
+		if recorder != nil {
+			recorder("request", "warmup", call.Tool, serverConfig.Name, map[string]interface{}{"name": call.Tool, "arguments": call.Arguments})
+		}
+
+		result, err := mcpClient.CallTool(ctx, call.Tool, call.Arguments)
+		if recorder != nil {
+			if err != nil {
+				recorder("response", "warmup", call.Tool, serverConfig.Name, map[string]string{"error": err.Error()})
+			} else {
+				recorder("response", "warmup", call.Tool, serverConfig.Name, result)
+			}
+		}
+		if err == nil && result.IsError {
+			err = fmt.Errorf("tool returned an error: %s", warmupResultText(result))
+		}
+
+		if err != nil {
+			err = fmt.Errorf("warmup call %s failed: %w", call.Tool, err)
+			if call.OnFailure == config.WarmupContinue {
+				log.Printf("Warning: server %s: %v; continuing (onFailure is continue)", serverConfig.Name, err)
+				continue
+			}
+			return err
+		}
+		log.Printf("Warmup call %s on server %s succeeded in %v: %s", call.Tool, serverConfig.Name, time.Since(start), warmupResultText(result))
+	}
+	return nil
+}
+
+// warmupResultText joins the text content of a warmup result for logging
+func warmupResultText(result *client.CallToolResult) string {
+	var texts []string
+	for _, item := range result.Content {
+		if item.Type == "text" && item.Text != "" {
+			texts = append(texts, item.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return "(no text)"
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+)
+
+func TestServerAddWarmup(t *testing.T) {
+	w := NewDynamicWrapper(&config.ProxyConfig{})
+	fakes := make(map[string]*client.FakeClient)
+	w.SetClientFactory(func(serverConfig config.ServerConfig) client.MCPClient {
+		fake := client.NewFakeClient(serverConfig.Name, client.ToolInfo{Name: "search"}, client.ToolInfo{Name: "load_index"})
+		if serverConfig.Name == "broken" {
+			fake.SetToolError("load_index", fmt.Errorf("index not found"))
+		}
+		fakes[serverConfig.Name] = fake
+		return fake
+	})
+
+	result := callTool(t, w.handleServerAdd, map[string]interface{}{
+		"name":             "docs",
+		"command":          "fake-server",
+		"warmup_tool":      "load_index",
+		"warmup_arguments": map[string]interface{}{"path": "/srv/index"},
+	})
+	if result.IsError {
+		t.Fatalf("server_add failed: %s", resultText(result))
+	}
+	calls := fakes["docs"].Calls()
+	if len(calls) != 1 || calls[0].Name != "load_index" || calls[0].Args["path"] != "/srv/index" {
+		t.Errorf("expected one warmup call with its arguments, got %+v", calls)
+	}
+	if warmup := w.dynamicServers["docs"].Config.Warmup; len(warmup) != 1 {
+		t.Errorf("expected the warmup call to be kept for reconnects, got %+v", warmup)
+	}
+
+	// A failed warmup fails the add
+	result = callTool(t, w.handleServerAdd, map[string]interface{}{
+		"name": "broken", "command": "fake-server", "warmup_tool": "load_index",
+	})
+	if !result.IsError || !strings.Contains(resultText(result), "index not found") {
+		t.Errorf("expected the warmup failure, got %q", resultText(result))
+	}
+	if _, exists := w.dynamicServers["broken"]; exists {
+		t.Error("server with a failed warmup should not be registered")
+	}
+}
+
+func TestInitializeWarmup(t *testing.T) {
+	noRetries := 0
+	warm := testBackendConfig("warm")
+	warm.Warmup = []config.WarmupCall{{Tool: "echo", Arguments: map[string]interface{}{"message": "ready"}}}
+	cold := testBackendConfig("cold")
+	cold.MaxRetries = &noRetries
+	cold.Warmup = []config.WarmupCall{{Tool: "missing"}}
+	lenient := testBackendConfig("lenient")
+	lenient.Warmup = []config.WarmupCall{{Tool: "missing", OnFailure: config.WarmupContinue}}
+
+	// Servers connect concurrently
+	var mu sync.Mutex
+	var warmups []string
+	p := New(&config.ProxyConfig{Servers: []config.ServerConfig{warm, cold, lenient}})
+	p.wrapper.proxyServer.recorderFunc = func(direction, messageType, toolName, serverName string, message interface{}) {
+		if direction == "request" && messageType == "warmup" {
+			mu.Lock()
+			warmups = append(warmups, serverName+"/"+toolName)
+			mu.Unlock()
+		}
+	}
+	ctx := context.Background()
+	if err := p.Initialize(ctx); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	defer p.Shutdown(ctx)
+
+	result := p.InitResult()
+	for name, want := range map[string]ServerInitStatus{
+		"warm": ServerInitConnected, "cold": ServerInitConnectFailed, "lenient": ServerInitConnected,
+	} {
+		if server, _ := result.Server(name); server.Status != want {
+			t.Errorf("%s: expected %s, got %+v", name, want, server)
+		}
+	}
+	if server, _ := result.Server("cold"); !strings.Contains(server.Error, "warmup call missing failed") {
+		t.Errorf("expected cold to fail its warmup, got %q", server.Error)
+	}
+	sort.Strings(warmups)
+	if strings.Join(warmups, ",") != "cold/missing,lenient/missing,warm/echo" {
+		t.Errorf("expected one warmup call per server, got %v", warmups)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"mcp-debug/config"
+)
+
+// logSettings are the resolved logging options for proxy mode
+type logSettings struct {
+	file   string // Empty for the default log file
+	level  config.LogLevel
+	format config.LogFormat
+}
+
+// resolveLogSettings combines the log settings of cfg, which may be nil,
+// with the MCP_LOG_FILE, MCP_LOG_LEVEL and MCP_LOG_FORMAT environment
+// variables and the given flag values. Flags override the environment,
+// which overrides the config file.
+func resolveLogSettings(cfg *config.ProxyConfig, file, level, format string) (logSettings, error) {
+	proxySettings := (&config.ProxyConfig{}).GetProxySettings()
+	if cfg != nil {
+		proxySettings = cfg.GetProxySettings()
+	}
+	settings := logSettings{
+		file:   firstNonEmpty(file, os.Getenv("MCP_LOG_FILE"), proxySettings.LogFile),
+		level:  proxySettings.LogLevel,
+		format: proxySettings.LogFormat,
+	}
+
+	var err error
+	if value := firstNonEmpty(level, os.Getenv("MCP_LOG_LEVEL")); value != "" {
+		if settings.level, err = config.ParseLogLevel(value); err != nil {
+			return settings, err
+		}
+	}
+	if value := firstNonEmpty(format, os.Getenv("MCP_LOG_FORMAT")); value != "" {
+		if settings.format, err = config.ParseLogFormat(value); err != nil {
+			return settings, err
+		}
+	}
+	return settings, nil
+}
+
+// firstNonEmpty returns the first of values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// logLevelRank orders log levels from most to least verbose
+var logLevelRank = map[config.LogLevel]int{
+	config.LogLevelDebug: 0,
+	config.LogLevelInfo:  1,
+	config.LogLevelWarn:  2,
+}
+
+// warnPrefixes start the messages that report a warning or failure,
+// compared case-insensitively. Only the start of a message is checked, so
+// a server or tool whose name contains "error" doesn't raise its level.
+var warnPrefixes = []string{"!!!", "warning:", "warn:", "[warn]", "error:", "error ", "[error]", "failed ", "failed:"}
+
+// messageLevel classifies a log message. The proxy logs with the standard
+// logger, so the level is inferred: client traces carry a [DEBUG] prefix,
+// and warnings and failures start with one of warnPrefixes.
+func messageLevel(message string) config.LogLevel {
+	if strings.HasPrefix(message, "[DEBUG]") {
+		return config.LogLevelDebug
+	}
+	lower := strings.ToLower(message)
+	for _, prefix := range warnPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return config.LogLevelWarn
+		}
+	}
+	return config.LogLevelInfo
+}
+
+// logWriter filters log messages by level and writes them as text or JSON
+// lines. The standard logger writes each message with a single Write and,
+// with its flags cleared, without a prefix, so logWriter adds the time.
+type logWriter struct {
+	out    io.Writer
+	level  config.LogLevel
+	format config.LogFormat
+	now    func() time.Time
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	level := messageLevel(message)
+	if logLevelRank[level] < logLevelRank[w.level] {
+		return len(p), nil
+	}
+
+	var line []byte
+	if w.format == config.LogFormatJSON {
+		line, _ = json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{w.now().Format(time.RFC3339Nano), string(level), message})
+		line = append(line, '\n')
+	} else {
+		line = []byte(w.now().Format("2006/01/02 15:04:05.000000") + " " + message + "\n")
+	}
+	if _, err := w.out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
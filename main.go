@@ -2,56 +2,112 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	
+	"gopkg.in/yaml.v3"
+
+	"mcp-debug/client"
 	"mcp-debug/config"
+	"mcp-debug/discovery"
 	"mcp-debug/integration"
 	"mcp-debug/playback"
 )
 
-const Version = "1.1.0"
+// Version is the build version, also reported to MCP clients by default
+const Version = config.DefaultProxyVersion
 
 var (
 	BuildTime = "unknown"
 	GitCommit = "unknown"
 )
 
-// setupLogging configures logging for stdio MCP mode
-func setupLogging(logFile string) error {
+// stdinConfigPath as a config path reads the configuration from stdin
+const stdinConfigPath = "-"
+
+// loadConfig loads and validates the configuration at path, or from stdin
+// when path is "-". Only commands that do not serve MCP may use stdin: in
+// proxy mode stdin carries the protocol.
+func loadConfig(path string) (*config.ProxyConfig, error) {
+	if path != stdinConfigPath {
+		return config.LoadConfig(path)
+	}
+	return loadConfigFrom(os.Stdin)
+}
+
+// loadConfigFrom reads a YAML configuration to EOF and validates it
+func loadConfigFrom(r io.Reader) (*config.ProxyConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+	}
+	return config.LoadConfigFromString(string(data))
+}
+
+// defaultLogFile is where stdio MCP mode logs when no --log path is given
+var defaultLogFile = "/tmp/mcp-proxy.log"
+
+// setupLogging configures logging for stdio MCP mode. When the default log
+// file can't be opened, e.g. with a read-only /tmp in a sandbox, it logs to
+// stderr with a warning instead; stdio MCP only uses stdin and stdout, so
+// that is safe. It only fails for an explicit log file that can't be opened.
+func setupLogging(settings logSettings) error {
+	f, err := openLogFile(settings.file)
+	var out io.Writer = f
+	if err != nil {
+		if settings.file != "" {
+			return err
+		}
+		out = os.Stderr
+	}
+
+	// Set log output to file; logWriter adds the timestamp
+	log.SetOutput(&logWriter{out: out, level: settings.level, format: settings.format, now: time.Now})
+	log.SetFlags(0)
+	if err != nil {
+		log.Printf("Warning: %v; logging to stderr instead", err)
+	}
+	log.Printf("=== MCP Proxy Server Started ===")
+	if f != nil {
+		log.Printf("Logging to: %s", f.Name())
+	}
+
+	return nil
+}
+
+// openLogFile opens logFile, or the default log file if it is empty, for
+// appending
+func openLogFile(logFile string) (*os.File, error) {
 	// Default log file if not specified
 	if logFile == "" {
-		logFile = "/tmp/mcp-proxy.log"
+		logFile = defaultLogFile
 	}
-	
+
 	// Ensure directory exists
 	dir := filepath.Dir(logFile)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
-	
+
 	// Open log file
 	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
-	
-	// Set log output to file
-	log.SetOutput(f)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-	log.Printf("=== MCP Proxy Server Started ===")
-	log.Printf("Logging to: %s", logFile)
-	
-	return nil
+	return f, nil
 }
 
 func main() {
@@ -71,29 +127,57 @@ func main() {
 	var (
 		proxyMode      = flag.Bool("proxy", false, "Run in proxy mode")
 		dynamicMode    = flag.Bool("dynamic", false, "Run in dynamic proxy mode (true dynamic tool registration)")
-		configPath     = flag.String("config", "", "Path to configuration file (required for proxy mode)")
-		logFile        = flag.String("log", "", "Log file path (defaults to /tmp/mcp-proxy.log for stdio mode)")
+		configPath     = flag.String("config", "", "Path to configuration file, or a directory of *.yaml fragments (required for proxy mode)")
+		logFile        = flag.String("log", "", "Log file path (defaults to proxy.logFile, then /tmp/mcp-proxy.log for stdio mode, falling back to stderr if that can't be opened)")
+		logLevel       = flag.String("log-level", "", "Log level: debug, info or warn (defaults to proxy.logLevel, then debug)")
+		logFormat      = flag.String("log-format", "", "Log format: text or json (defaults to proxy.logFormat, then text)")
 		recordFile     = flag.String("record", "", "Record JSON-RPC traffic to file for playback")
+		traceTap       = flag.String("trace-tap", "", "Stream recorded messages as JSON lines to clients of a Unix socket (unix:PATH or PATH) or TCP port (tcp:HOST:PORT), with or without --record")
+		maxMessageLog  = flag.Int("max-message-log-bytes", 0, "Truncate recorded messages larger than this many bytes (0 = unlimited)")
+		recordFlush    = flag.String("record-flush", "always", "When recorded messages are flushed to disk: always, interval or close")
+		flushInterval  = flag.Duration("record-flush-interval", integration.DefaultFlushInterval, "Flush interval for --record-flush interval")
 		playbackClient = flag.String("playback-client", "", "Act as MCP client replaying recorded session file")
+		playFrom       = flag.String("playback-from", "", "With --playback-client, start at recorded message N, the first call of tool:NAME or the first message at time:RFC3339, found via the recording's .idx index")
+		playControl    = flag.String("playback-control", "", "With --playback-client, start paused and take pause/step/resume/speed commands from this file (e.g. /dev/tty or a named pipe) or tcp:HOST:PORT")
 		playbackServer = flag.String("playback-server", "", "Act as MCP server replaying recorded responses")
+		strictPlayback = flag.Bool("strict", false, "With --playback-server, answer requests that don't match the recording with an error and exit non-zero")
+		templates      = flag.Bool("playback-templates", false, "With --playback-server, substitute {{request.id}}, {{now}} and {{now.unix}} in replayed responses")
+		maxPlayMsgs    = flag.Int("playback-max-messages", 0, "Fail playback once the recording has more than this many messages (0 = unlimited)")
+		maxPlayBytes   = flag.Int64("playback-max-bytes", 0, "Fail playback once more than this many bytes of the recording are read (0 = unlimited)")
+		skipInvalid    = flag.Bool("playback-skip-invalid", false, "Log and skip recording lines that aren't valid JSON instead of failing playback")
+		startupSummary = flag.String("startup-summary", "text", "Format of the startup summary logged after initialization: text or json")
+		watch          = flag.Bool("watch", false, "Reload the config file when it changes (proxy mode)")
+		traceConnect   = flag.Bool("trace-connect", false, "Log each step of starting and initializing backend servers, with timing (same as proxy.traceConnect)")
+		pidFile        = flag.String("pid-file", "", "Write the proxy's process id to this file, removed on clean shutdown (proxy mode)")
+		allowEmpty     = flag.Bool("allow-empty", true, "Start even when no tools are discovered; --allow-empty=false fails startup instead (same as proxy.allowEmpty)")
+		fixturesFile   = flag.String("fixtures", "", "Serve the tools and canned responses defined in this YAML file, without backends")
 	)
 	flag.Parse()
-	
+
 	// Handle playback modes
+	playbackOptions := playback.ParseOptions{MaxMessages: *maxPlayMsgs, MaxBytes: *maxPlayBytes, SkipInvalid: *skipInvalid}
 	if *playbackClient != "" {
-		if err := runPlaybackClient(*playbackClient); err != nil {
+		if err := runPlaybackClient(*playbackClient, playbackOptions, *playFrom, *playControl); err != nil {
 			log.Fatalf("Playback client failed: %v", err)
 		}
 		return
 	}
-	
+
 	if *playbackServer != "" {
-		if err := runPlaybackServer(*playbackServer); err != nil {
+		if err := runPlaybackServer(*playbackServer, playbackOptions, *strictPlayback, *templates); err != nil {
 			log.Fatalf("Playback server failed: %v", err)
 		}
 		return
 	}
-	
+
+	if *fixturesFile != "" {
+		if err := runFixturesServer(*fixturesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Fixtures server failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Handle proxy modes
 	if *proxyMode || *dynamicMode {
 		if *configPath == "" {
@@ -101,20 +185,47 @@ func main() {
 			fmt.Fprintln(os.Stderr, "Usage: mcp-server --dynamic --config /path/to/config.yaml")
 			os.Exit(1)
 		}
-		
-		// Set up file logging for stdio mode
-		if err := setupLogging(*logFile); err != nil {
+		if *configPath == stdinConfigPath {
+			fmt.Fprintln(os.Stderr, "Error: --config - cannot be used with --proxy or --dynamic: stdin carries the MCP protocol")
+			fmt.Fprintln(os.Stderr, "Write the configuration to a file, or use '-' with config validate or dump-schema")
+			os.Exit(1)
+		}
+
+		// Set up file logging for stdio mode, with the config's log settings.
+		// A config that fails to load is reported by the proxy once logging is up.
+		logConfig, _ := config.LoadConfig(*configPath)
+		settings, err := resolveLogSettings(logConfig, *logFile, *logLevel, *logFormat)
+		if err == nil {
+			err = setupLogging(settings)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to setup logging: %v\n", err)
 			os.Exit(1)
 		}
-		
+
 		// Use dynamic proxy with management tools
-		if err := runDynamicProxyWithManagement(*configPath, *recordFile); err != nil {
+		opts := proxyOptions{
+			recordFile:         *recordFile,
+			traceTap:           *traceTap,
+			maxMessageLogBytes: *maxMessageLog,
+			recordFlush:        *recordFlush,
+			flushInterval:      *flushInterval,
+			startupSummary:     *startupSummary,
+			watch:              *watch,
+			pidFile:            *pidFile,
+			traceConnect:       *traceConnect,
+		}
+		if flagSet("allow-empty") {
+			opts.allowEmpty = allowEmpty
+		}
+		if err := runDynamicProxyWithManagement(*configPath, opts); err != nil {
+			// Also on stderr, where MCP clients show a server that failed to start
+			fmt.Fprintf(os.Stderr, "Dynamic proxy server failed: %v\n", err)
 			log.Fatalf("Dynamic proxy server failed: %v", err)
 		}
 		return
 	}
-	
+
 	// Handle CLI commands and configuration (original mode)
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -130,6 +241,15 @@ func main() {
 		case "tools":
 			handleToolsCommand()
 			return
+		case "dump-schema":
+			handleDumpSchemaCommand(os.Args[2:])
+			return
+		case "recording":
+			handleRecordingCommand(os.Args[2:])
+			return
+		case "print-env":
+			handlePrintEnvCommand(os.Args[2:])
+			return
 		default:
 			if strings.HasPrefix(os.Args[1], "-") {
 				fmt.Printf("Unknown flag: %s\n", os.Args[1])
@@ -183,9 +303,53 @@ func helloHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTo
 	return mcp.NewToolResultText(fmt.Sprintf("Hello, %s!", name)), nil
 }
 
+// proxyOptions carries the proxy mode command line flags
+type proxyOptions struct {
+	recordFile         string
+	traceTap           string // Address for live trace tap clients
+	maxMessageLogBytes int
+	recordFlush        string
+	flushInterval      time.Duration
+	startupSummary     string
+	watch              bool
+	pidFile            string // Written at startup and removed on shutdown
+	traceConnect       bool   // Overrides proxy.traceConnect when set
+	allowEmpty         *bool  // Overrides proxy.allowEmpty and onNoTools when set
+}
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// applyFlagOverrides applies command line flags that override proxy settings
+func applyFlagOverrides(cfg *config.ProxyConfig, opts proxyOptions) {
+	if opts.traceConnect {
+		cfg.Proxy.TraceConnect = true
+	}
+	if opts.allowEmpty != nil {
+		cfg.Proxy.AllowEmpty = opts.allowEmpty
+		cfg.Proxy.OnNoTools = ""
+	}
+}
+
 // runDynamicProxyWithManagement runs the proxy with dynamic management tools
-func runDynamicProxyWithManagement(configPath, recordFile string) error {
-	ctx := context.Background()
+func runDynamicProxyWithManagement(configPath string, opts proxyOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.pidFile != "" {
+		if err := writePIDFile(opts.pidFile); err != nil {
+			return err
+		}
+		defer removePIDFile(opts.pidFile)
+	}
 
 	// Load configuration
 	log.Printf("Loading configuration from: %s", configPath)
@@ -195,62 +359,127 @@ func runDynamicProxyWithManagement(configPath, recordFile string) error {
 	}
 
 	log.Printf("Configuration loaded: %d servers configured", len(cfg.Servers))
+	applyFlagOverrides(cfg, opts)
+
+	// Create the proxy (uses mark3labs/mcp-go which works with stdio)
+	p := integration.New(cfg)
 
-	// Create dynamic wrapper (uses mark3labs/mcp-go which works with stdio)
-	wrapper := integration.NewDynamicWrapper(cfg)
+	format, err := integration.ParseSummaryFormat(opts.startupSummary)
+	if err != nil {
+		return err
+	}
+	if err := p.SetStartupSummaryFormat(format); err != nil {
+		return err
+	}
 
 	// Enable recording if specified
-	if recordFile != "" {
-		log.Printf("Recording JSON-RPC traffic to: %s", recordFile)
-		if err := wrapper.EnableRecording(recordFile); err != nil {
+	p.SetMaxMessageLogBytes(opts.maxMessageLogBytes)
+	flushPolicy, err := integration.ParseFlushPolicy(opts.recordFlush)
+	if err != nil {
+		return err
+	}
+	if err := p.SetRecordFlushPolicy(flushPolicy, opts.flushInterval); err != nil {
+		return err
+	}
+	if opts.recordFile != "" {
+		log.Printf("Recording JSON-RPC traffic to: %s", opts.recordFile)
+		if err := p.EnableRecording(opts.recordFile); err != nil {
 			return fmt.Errorf("failed to enable recording: %w", err)
 		}
 	}
 
+	if opts.traceTap != "" {
+		if err := p.StartTraceTap(opts.traceTap); err != nil {
+			return err
+		}
+	}
+
+	// Toggle recording on SIGUSR1 so a session can be captured without restarting
+	toggleChan := make(chan os.Signal, 1)
+	notifyRecordToggle(toggleChan)
+	go func() {
+		for range toggleChan {
+			if err := p.ToggleRecording(); err != nil {
+				log.Printf("Failed to toggle recording: %v", err)
+			}
+		}
+	}()
+
 	// Initialize with static servers
 	log.Println("Initializing proxy server...")
-	if err := wrapper.Initialize(ctx); err != nil {
-		// Allow starting with no tools for dynamic management
-		if !strings.Contains(err.Error(), "no tools were successfully discovered") {
-			return fmt.Errorf("failed to initialize: %w", err)
+	if err := p.Initialize(ctx); err != nil {
+		if result := p.InitResult(); result != nil && result.Failed > 0 {
+			log.Printf("Failed servers: %s", describeFailedServers(result))
+		}
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	if result := p.InitResult(); result.Partial() {
+		log.Printf("Warning: started with %d of %d servers connected; failed: %s",
+			result.Connected, len(result.Servers), describeFailedServers(result))
+	}
+	defer func() {
+		if err := p.Shutdown(context.Background()); err != nil {
+			log.Printf("Shutdown error: %v", err)
+		}
+	}()
+
+	if opts.watch {
+		log.Printf("Watching %s for changes", configPath)
+		err := watchConfig(ctx, configPath, configWatchDebounce, func(cfg *config.ProxyConfig) {
+			applyFlagOverrides(cfg, opts)
+			if _, err := p.Reload(ctx, cfg); err != nil {
+				log.Printf("Config reload failed, keeping the running config: %v", err)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch config: %w", err)
 		}
-		log.Println("Starting with no initial servers - use server_add to add servers dynamically")
 	}
 
-	// Start the server
-	return wrapper.Start()
+	// Serve until stdin closes or a shutdown signal arrives
+	return p.Start(ctx)
+}
+
+// describeFailedServers lists the servers that failed to start with their
+// errors, e.g. "db (connect_failed: timeout), fs (discovery_failed: ...)"
+func describeFailedServers(result *integration.InitResult) string {
+	var failed []string
+	for _, server := range result.FailedServers() {
+		failed = append(failed, fmt.Sprintf("%s (%s: %s)", server.Name, server.Status, server.Error))
+	}
+	return strings.Join(failed, ", ")
 }
 
 // runProxyServer runs the MCP proxy server with the given configuration
 func runDynamicProxyServer(configPath string) error {
 	log.Printf("Loading configuration from: %s", configPath)
-	
+
 	// Load configuration
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
-	
+
 	log.Printf("Configuration loaded: %d servers configured", len(cfg.Servers))
 
 	// Create dynamic proxy server
 	proxyServer := integration.NewDynamicProxyServer(cfg)
-	
+
 	// Set up graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	
+
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	
+
 	go func() {
 		<-sigChan
 		log.Printf("Shutting down...")
 		cancel()
 		proxyServer.Shutdown()
 	}()
-	
+
 	// Start connecting to servers in background
 	go func() {
 		for _, serverConfig := range cfg.Servers {
@@ -259,7 +488,7 @@ func runDynamicProxyServer(configPath string) error {
 			}
 		}
 	}()
-	
+
 	// Start the MCP server (this will block)
 	log.Printf("Starting dynamic MCP proxy server...")
 	return proxyServer.Serve()
@@ -267,25 +496,25 @@ func runDynamicProxyServer(configPath string) error {
 
 func runProxyServer(configPath string) error {
 	ctx := context.Background()
-	
+
 	// Load configuration
 	log.Printf("Loading configuration from: %s", configPath)
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
-	
+
 	log.Printf("Configuration loaded: %d servers configured", len(cfg.Servers))
-	
+
 	// Create proxy server
 	proxyServer := integration.NewProxyServer(cfg)
-	
+
 	// Initialize proxy server (connect to remotes and discover tools)
 	log.Println("Initializing proxy server...")
 	if err := proxyServer.Initialize(ctx); err != nil {
 		return fmt.Errorf("failed to initialize proxy server: %w", err)
 	}
-	
+
 	// Set up graceful shutdown with signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -303,7 +532,7 @@ func runProxyServer(configPath string) error {
 			log.Printf("Shutdown error: %v", err)
 		}
 	}()
-	
+
 	// Start the proxy server (this blocks)
 	log.Println("Proxy server initialized successfully. Starting MCP server...")
 	return proxyServer.Start()
@@ -324,10 +553,10 @@ func printUsage() {
     This MCP server can run in multiple modes:
     
     1. PROXY MODE (recommended):
-       %s --proxy --config /path/to/config.yaml [--record session.jsonl]
+       %s --proxy --config /path/to/config.yaml [--record session.jsonl] [--trace-tap ADDR] [--watch] [--pid-file proxy.pid]
        
        Connects to multiple MCP servers and exposes their tools with prefixes.
-       Optional recording creates playback files.
+       Optional recording creates playback files; --trace-tap streams them live.
        
     2. STANDALONE MODE:
        %s (without flags)
@@ -335,14 +564,21 @@ func printUsage() {
        Runs as a simple MCP server with hello_world tool.
     
     3. PLAYBACK CLIENT MODE:
-       %s --playback-client session.jsonl
+       %s --playback-client session.jsonl [--playback-from N|tool:NAME]
        
-       Acts as MCP client replaying recorded requests.
+       Acts as MCP client replaying recorded requests, optionally starting
+       part way through via the recording's .idx index.
        
     4. PLAYBACK SERVER MODE:
        %s --playback-server session.jsonl
        
        Acts as MCP server replaying recorded responses.
+       
+    5. FIXTURES MODE:
+       %s --fixtures fixtures.yaml
+       
+       Serves the tools and canned responses defined in a YAML file,
+       for client development without real backends.
     
     For direct testing:
     %s --help           Show this help message
@@ -351,6 +587,12 @@ func printUsage() {
     %s env              Environment variable management
     %s test             Test MCP tools directly
     %s tools            Tool interface commands
+    %s dump-schema      Print the aggregated tool schema as JSON
+                        [--config config.yaml|-] [--output file.json]
+    %s recording merge  Merge recordings into one timeline
+                        a.jsonl b.jsonl [-o merged.jsonl]
+    %s print-env        Print the shell command that launches a server as the proxy does
+                        --server NAME [--config config.yaml] [--format env|export] [--reveal]
     
     For MCP client usage (proxy mode):
     1. Create a configuration file:
@@ -382,10 +624,11 @@ func printUsage() {
     Environment Variables:
     - MCP_DEBUG=1: Enable debug logging
     - MCP_CONFIG_PATH: Path to configuration file
+    - MCP_LOG_FILE, MCP_LOG_LEVEL, MCP_LOG_FORMAT: Proxy logging, overriding proxy.logFile, proxy.logLevel and proxy.logFormat
     
     For more information about MCP:
     https://modelcontextprotocol.io/
-`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 }
 
 // handleVersionCommand shows version information
@@ -445,16 +688,21 @@ func handleConfigCommand() {
 		fmt.Printf(`Configuration Management:
     %s config init              Create default configuration file
     %s config show              Show current configuration
-    %s config set <key> <value> Set configuration value
+    %s config set <key> <value> Set configuration value (validated before saving)
     %s config get <key>         Get configuration value
-    %s config validate          Validate configuration file
+    %s config validate [path]   Validate configuration file ('-' reads stdin)
     %s config path              Show configuration file path
+    %s config migrate [--dry-run] Add explicit inherit block to old-style config
+    %s config add-server --name <name> --prefix <prefix> --command "<cmd>" [--transport stdio]
+                            [--url <url>] [--env KEY=VAL ...] [--inherit <mode>]
     
+Keys are dotted paths into the YAML, e.g. proxy.maxRetries or servers.0.command.
+
 Example:
     %s config init
-    %s config set api_key "your-api-key"
-    %s config set database_url "postgres://localhost/mydb"
-`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+    %s config set proxy.maxRetries 5
+    %s config get servers.0.command
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 		return
 	}
 
@@ -501,19 +749,234 @@ proxy:
 		if len(os.Args) >= 4 {
 			configPath = os.Args[3]
 		}
-		cfg, err := config.LoadConfig(configPath)
+		cfg, err := loadConfig(configPath)
 		if err != nil {
 			fmt.Printf("Configuration validation failed: %v\n", err)
 			return
 		}
 		fmt.Printf("Configuration is valid: %d server(s) configured\n", len(cfg.Servers))
+	case "get":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: config get <key> [config-path]")
+			return
+		}
+		configPath := getConfigPath()
+		if len(os.Args) >= 5 {
+			configPath = os.Args[4]
+		}
+		cfg, err := config.LoadRawConfig(configPath)
+		if err != nil {
+			fmt.Printf("Error loading configuration: %v\n", err)
+			return
+		}
+		value, err := cfg.GetValue(os.Args[3])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if s, ok := value.(string); ok {
+			fmt.Println(s)
+			return
+		}
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			fmt.Printf("Error encoding value: %v\n", err)
+			return
+		}
+		fmt.Print(string(out))
+	case "set":
+		if len(os.Args) < 5 {
+			fmt.Println("Usage: config set <key> <value> [config-path]")
+			return
+		}
+		configPath := getConfigPath()
+		if len(os.Args) >= 6 {
+			configPath = os.Args[5]
+		}
+		cfg, err := config.LoadRawConfig(configPath)
+		if err != nil {
+			fmt.Printf("Error loading configuration: %v\n", err)
+			return
+		}
+		if err := cfg.SetValue(os.Args[3], os.Args[4]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := config.SaveConfig(configPath, cfg); err != nil {
+			fmt.Printf("Configuration not saved: %v\n", err)
+			return
+		}
+		fmt.Printf("Set %s = %s\n", os.Args[3], os.Args[4])
+	case "add-server":
+		handleConfigAddServer(os.Args[3:])
 	case "path":
 		fmt.Printf("Configuration file path: %s\n", getConfigPath())
+	case "migrate":
+		configPath := getConfigPath()
+		dryRun := false
+		for _, arg := range os.Args[3:] {
+			if arg == "--dry-run" {
+				dryRun = true
+			} else {
+				configPath = arg
+			}
+		}
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			fmt.Printf("Error reading configuration file: %v\n", err)
+			return
+		}
+		migrated, changed, err := config.MigrateConfig(data)
+		if err != nil {
+			fmt.Printf("Migration failed: %v\n", err)
+			return
+		}
+		if !changed {
+			fmt.Printf("Configuration already up to date: %s\n", configPath)
+			return
+		}
+		if _, err := config.LoadConfigFromString(string(migrated)); err != nil {
+			fmt.Printf("Migrated configuration is invalid: %v\n", err)
+			return
+		}
+		if dryRun {
+			fmt.Printf("--- %s\n+++ %s (migrated)\n", configPath, configPath)
+			fmt.Print(diffLines(string(data), string(migrated)))
+			return
+		}
+		if err := os.WriteFile(configPath, migrated, 0644); err != nil {
+			fmt.Printf("Error writing configuration file: %v\n", err)
+			return
+		}
+		fmt.Printf("Configuration migrated: %s\n", configPath)
 	default:
 		fmt.Printf("Unknown config command: %s\n", os.Args[2])
 	}
 }
 
+// envFlag collects repeated --env KEY=VAL flags
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	return fmt.Sprint(map[string]string(e))
+}
+
+func (e envFlag) Set(value string) error {
+	idx := strings.Index(value, "=")
+	if idx <= 0 {
+		return fmt.Errorf("expected KEY=VAL, got %q", value)
+	}
+	e[value[:idx]] = value[idx+1:]
+	return nil
+}
+
+// handleConfigAddServer appends a server to the configuration file
+func handleConfigAddServer(args []string) {
+	env := envFlag{}
+	fs := flag.NewFlagSet("config add-server", flag.ContinueOnError)
+	configPath := fs.String("config", getConfigPath(), "Path to configuration file")
+	name := fs.String("name", "", "Server name (required)")
+	prefix := fs.String("prefix", "", "Tool name prefix (defaults to name)")
+	command := fs.String("command", "", "Command to run, including arguments")
+	transport := fs.String("transport", "stdio", "Transport: stdio or http")
+	url := fs.String("url", "", "URL for http transport")
+	inherit := fs.String("inherit", "", "Environment inheritance mode: none, tier1, tier1+tier2, all")
+	fs.Var(env, "env", "Environment variable KEY=VAL (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+
+	if *name == "" {
+		fmt.Println("Error: --name is required")
+		return
+	}
+	if *prefix == "" {
+		*prefix = *name
+	}
+
+	serverConfig := config.ServerConfig{
+		Name:      *name,
+		Prefix:    *prefix,
+		Transport: *transport,
+		URL:       *url,
+	}
+	if parts := strings.Fields(*command); len(parts) > 0 {
+		serverConfig.Command = parts[0]
+		serverConfig.Args = parts[1:]
+	}
+	if len(env) > 0 {
+		serverConfig.Env = env
+	}
+	if *inherit != "" {
+		serverConfig.Inherit = &config.InheritConfig{Mode: config.InheritMode(*inherit)}
+	}
+
+	cfg, err := config.LoadRawConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		fmt.Println("Run 'config init' to create one.")
+		return
+	}
+	cfg.Servers = append(cfg.Servers, serverConfig)
+
+	if err := config.SaveConfig(*configPath, cfg); err != nil {
+		fmt.Printf("Server not added: %v\n", err)
+		return
+	}
+
+	block, _ := yaml.Marshal([]config.ServerConfig{serverConfig})
+	fmt.Printf("Added server '%s' to %s:\n\n", serverConfig.Name, *configPath)
+	fmt.Print(string(block))
+}
+
+// diffLines returns a minimal line diff between two texts, prefixing
+// removed lines with "-", added lines with "+" and unchanged lines with " "
+func diffLines(oldText, newText string) string {
+	a := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out.WriteString("-" + a[i] + "\n")
+	}
+	for ; j < len(b); j++ {
+		out.WriteString("+" + b[j] + "\n")
+	}
+	return out.String()
+}
+
 // handleEnvCommand manages environment variables
 func handleEnvCommand() {
 	if len(os.Args) < 3 {
@@ -733,38 +1196,418 @@ Example:
 	}
 }
 
-// runPlaybackClient runs the playback client mode
-func runPlaybackClient(recordingFile string) error {
+// schemaDocument is the output of dump-schema: every proxied tool grouped by server
+type schemaDocument struct {
+	Servers []serverSchema `json:"servers"`
+}
+
+type serverSchema struct {
+	Name   string                 `json:"name"`
+	Prefix string                 `json:"prefix"`
+	Tools  []discovery.RemoteTool `json:"tools"`
+}
+
+// handleDumpSchemaCommand connects to all configured servers and prints the
+// aggregated tool schema as JSON
+func handleDumpSchemaCommand(args []string) {
+	fs := flag.NewFlagSet("dump-schema", flag.ContinueOnError)
+	configPath := fs.String("config", getConfigPath(), "Path to configuration file ('-' reads stdin)")
+	output := fs.String("output", "", "Write the schema to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	p := integration.New(cfg)
+	if err := p.Initialize(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error discovering tools: %v\n", err)
+		os.Exit(1)
+	}
+	defer p.Shutdown(ctx)
+
+	// Group by server in config order; tools are already sorted by name
+	doc := schemaDocument{Servers: []serverSchema{}}
+	tools := p.Tools()
+	for _, serverConfig := range cfg.Servers {
+		server := serverSchema{
+			Name:   serverConfig.Name,
+			Prefix: serverConfig.Prefix,
+			Tools:  []discovery.RemoteTool{},
+		}
+		for _, tool := range tools {
+			if tool.ServerName == serverConfig.Name {
+				server.Tools = append(server.Tools, tool)
+			}
+		}
+		doc.Servers = append(doc.Servers, server)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding schema: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote schema for %d tools to %s\n", len(tools), *output)
+}
+
+// handlePrintEnvCommand prints how the proxy would launch a server, as a
+// shell command that reproduces the launch outside the proxy
+func handlePrintEnvCommand(args []string) {
+	fs := flag.NewFlagSet("print-env", flag.ContinueOnError)
+	configPath := fs.String("config", getConfigPath(), "Path to configuration file ('-' reads stdin)")
+	serverName := fs.String("server", "", "Server to print the launch of")
+	format := fs.String("format", "env", "Output format: env (an env -i command line) or export (export lines, then the command)")
+	reveal := fs.Bool("reveal", false, "Show configured env values and secret-looking inherited values instead of redacting them")
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+	if *serverName == "" {
+		fmt.Fprintln(os.Stderr, "Error: --server is required")
+		os.Exit(1)
+	}
+	if *format != "env" && *format != "export" {
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q: must be one of: env, export\n", *format)
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+	var serverConfig *config.ServerConfig
+	for i := range cfg.Servers {
+		if cfg.Servers[i].Name == *serverName {
+			serverConfig = &cfg.Servers[i]
+		}
+	}
+	if serverConfig == nil {
+		fmt.Fprintf(os.Stderr, "Error: server '%s' not found in %s\n", *serverName, *configPath)
+		os.Exit(1)
+	}
+	if serverConfig.Transport != "stdio" {
+		fmt.Fprintf(os.Stderr, "Error: server '%s' uses the %s transport; only stdio servers are launched\n", *serverName, serverConfig.Transport)
+		os.Exit(1)
+	}
+
+	spec, err := client.ResolveLaunch(*serverConfig, cfg.Inherit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving launch: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Values from the server's env block are redacted like in proxy_config;
+	// inherited values only when they look like secrets
+	secret := func(key string) bool {
+		_, configured := serverConfig.Env[key]
+		return configured || client.LooksLikeSecret(key)
+	}
+	if *reveal {
+		secret = func(string) bool { return false }
+	}
+	output, redacted := formatLaunch(spec, *format, secret)
+	fmt.Print(output)
+	if redacted > 0 {
+		fmt.Fprintf(os.Stderr, "# %d values redacted; pass --reveal to show them\n", redacted)
+	}
+}
+
+// formatLaunch renders a launch as a shell command: "env -i KEY=VALUE ...
+// command args" for the env format, or export lines followed by the
+// command for the export format. Values of keys for which secret returns
+// true are replaced by config.RedactedValue; the count is returned.
+func formatLaunch(spec *client.LaunchSpec, format string, secret func(key string) bool) (string, int) {
+	env := append([]string(nil), spec.Env...)
+	sort.Strings(env)
+
+	var assignments []string
+	redacted := 0
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		if secret(key) {
+			value = config.RedactedValue
+			redacted++
+		}
+		assignments = append(assignments, key+"="+shellQuote(value))
+	}
+
+	command := []string{shellQuote(spec.Command)}
+	for _, arg := range spec.Args {
+		command = append(command, shellQuote(arg))
+	}
+
+	var out strings.Builder
+	if format == "export" {
+		for _, assignment := range assignments {
+			out.WriteString("export " + assignment + "\n")
+		}
+		out.WriteString(strings.Join(command, " ") + "\n")
+		return out.String(), redacted
+	}
+	out.WriteString("env -i")
+	for _, assignment := range assignments {
+		out.WriteString(" \\\n  " + assignment)
+	}
+	out.WriteString(" \\\n  " + strings.Join(command, " ") + "\n")
+	return out.String(), redacted
+}
+
+// shellQuote quotes value for a POSIX shell unless it only has characters
+// that need no quoting
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-./:@%+=,", r))
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// handleRecordingCommand handles recording file commands
+func handleRecordingCommand(args []string) {
+	if len(args) < 1 || args[0] != "merge" {
+		fmt.Printf(`Recording Commands:
+    %s recording merge a.jsonl b.jsonl [-o merged.jsonl]
+        Interleave recordings by timestamp into one recording (stdout by default)
+`, os.Args[0])
+		return
+	}
+
+	// Accept -o anywhere, since the inputs usually come first
+	var inputs []string
+	output := ""
+	rest := args[1:]
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "-o", "--output", "-output":
+			if i+1 >= len(rest) {
+				fmt.Fprintln(os.Stderr, "Error: -o requires a file name")
+				os.Exit(1)
+			}
+			output = rest[i+1]
+			i++
+		default:
+			inputs = append(inputs, rest[i])
+		}
+	}
+	if len(inputs) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: recording merge needs at least two recordings")
+		os.Exit(1)
+	}
+
+	var merged *playback.PlaybackSession
+	var shared []string
+	var err error
+	if output == "" {
+		merged, shared, err = mergeRecordings(inputs, os.Stdout)
+	} else {
+		merged, shared, err = mergeRecordingsToFile(inputs, output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error merging recordings: %v\n", err)
+		os.Exit(1)
+	}
+	if len(shared) > 0 {
+		fmt.Fprintf(os.Stderr, "Note: server names recorded in more than one input: %s\n", strings.Join(shared, ", "))
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Merged %d messages from %d recordings into %s\n", len(merged.Messages), len(inputs), output)
+	}
+}
+
+// mergeRecordingsToFile merges the recordings at paths into output. The
+// merge is written to a temporary file next to output and renamed over it,
+// so output is only replaced once the merge succeeded. Output must not be
+// one of the inputs.
+func mergeRecordingsToFile(paths []string, output string) (*playback.PlaybackSession, []string, error) {
+	for _, path := range paths {
+		if samePath(path, output) {
+			return nil, nil, fmt.Errorf("output %s is also an input", output)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output: %w", err)
+	}
+	tmp.Chmod(0644) // CreateTemp makes the file private
+	merged, shared, err := mergeRecordings(paths, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), output)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+	return merged, shared, nil
+}
+
+// samePath reports whether two paths name the same file, comparing their
+// cleaned absolute forms and, when both exist, the files themselves
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// mergeRecordings merges the recording files at paths into one session
+// written to out. It also returns the server names found in several inputs.
+func mergeRecordings(paths []string, out io.Writer) (*playback.PlaybackSession, []string, error) {
+	sessions := make([]*playback.PlaybackSession, 0, len(paths))
+	for _, path := range paths {
+		session, err := playback.ParseRecordingFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	merged := playback.MergeSessions(sessions...)
+	if err := playback.WriteSession(out, merged); err != nil {
+		return nil, nil, err
+	}
+	return merged, playback.SharedServerNames(sessions...), nil
+}
+
+// runPlaybackClient runs the playback client mode. The recording is streamed
+// rather than loaded, so recordings of any size can be replayed. With a
+// start target, replay skips ahead to it using the recording's index. With a
+// control address, replay starts paused and is paced by its commands.
+func runPlaybackClient(recordingFile string, options playback.ParseOptions, from, controlAddress string) error {
 	log.SetOutput(os.Stderr) // Ensure logs go to stderr, not stdout
 	log.Printf("Starting playback client with recording: %s", recordingFile)
-	
-	// Parse the recording file
-	session, err := playback.ParseRecordingFile(recordingFile)
+
+	client, err := playback.NewStreamingPlaybackClient(recordingFile, options)
 	if err != nil {
 		return fmt.Errorf("failed to parse recording file: %w", err)
 	}
-	
-	log.Printf("Loaded session with %d messages", len(session.Messages))
-	
-	// Create and run playback client
-	client := playback.NewPlaybackClient(session)
+	defer client.Close()
+
+	if from != "" {
+		if err := client.SeekTo(from); err != nil {
+			return fmt.Errorf("failed to seek to %s: %w", from, err)
+		}
+		log.Printf("Playback starting from %s", from)
+	}
+
+	if controlAddress != "" {
+		control := playback.NewController(true)
+		stop, err := startPlaybackControl(controlAddress, control)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		client.SetController(control)
+	}
+
 	return client.Run()
 }
 
-// runPlaybackServer runs the playback server mode
-func runPlaybackServer(recordingFile string) error {
+// startPlaybackControl feeds playback commands to control in the
+// background. "tcp:HOST:PORT" accepts any number of connections, each
+// answered on the connection; anything else is a file read line by line,
+// such as /dev/tty or a named pipe, answered on stderr. Once the file ends,
+// playback resumes rather than staying paused with no way to continue. The
+// returned function stops accepting connections.
+func startPlaybackControl(address string, control *playback.Controller) (func(), error) {
+	const greeting = "Playback paused; type help for commands, an empty line steps"
+
+	if tcpAddress, ok := strings.CutPrefix(address, "tcp:"); ok {
+		listener, err := net.Listen("tcp", tcpAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for playback control: %w", err)
+		}
+		log.Printf("Playback control listening on %s", listener.Addr())
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					fmt.Fprintln(conn, greeting)
+					control.ReadCommands(conn, conn)
+				}()
+			}
+		}()
+		return func() { listener.Close() }, nil
+	}
+
+	if _, err := os.Stat(address); err != nil {
+		return nil, fmt.Errorf("invalid playback control: %w", err)
+	}
+	// Opening a named pipe blocks until a writer opens it
+	log.Printf("Reading playback control commands from %s", address)
+	go func() {
+		f, err := os.Open(address)
+		if err != nil {
+			log.Printf("Failed to open playback control %s: %v", address, err)
+			control.Handle("quit")
+			return
+		}
+		defer f.Close()
+		fmt.Fprintln(os.Stderr, greeting)
+		if err := control.ReadCommands(f, os.Stderr); err != nil {
+			log.Printf("Playback control input failed: %v", err)
+		}
+		log.Printf("Playback control input from %s ended, resuming playback", address)
+		control.Handle("resume")
+	}()
+	return func() {}, nil
+}
+
+// runFixturesServer serves the tools of a fixtures file over stdio
+func runFixturesServer(fixturesFile string) error {
+	log.SetOutput(os.Stderr) // Ensure logs go to stderr, not stdout
+
+	fixtures, err := playback.LoadFixtures(fixturesFile)
+	if err != nil {
+		return err
+	}
+	s, err := playback.NewFixtureServer(fixtures)
+	if err != nil {
+		return err
+	}
+	log.Printf("Serving %d fixture tools from %s", len(fixtures.Tools), fixturesFile)
+	return server.ServeStdio(s)
+}
+
+// runPlaybackServer runs the playback server mode, streaming the recording
+func runPlaybackServer(recordingFile string, options playback.ParseOptions, strict, templating bool) error {
 	log.SetOutput(os.Stderr) // Ensure logs go to stderr, not stdout
 	log.Printf("Starting playback server with recording: %s", recordingFile)
-	
-	// Parse the recording file
-	session, err := playback.ParseRecordingFile(recordingFile)
+
+	server, err := playback.NewStreamingPlaybackServer(recordingFile, options, strict)
 	if err != nil {
 		return fmt.Errorf("failed to parse recording file: %w", err)
 	}
-	
-	log.Printf("Loaded session with %d messages", len(session.Messages))
-	
-	// Create and run playback server
-	server := playback.NewPlaybackServer(session)
+	defer server.Close()
+
+	server.SetTemplating(templating)
 	return server.Run()
-}
\ No newline at end of file
+}
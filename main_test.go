@@ -1,11 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-debug/client"
+	"mcp-debug/config"
+	"mcp-debug/playback"
 )
 
 // TestHelloWorldHandler tests the hello_world tool handler directly
@@ -198,3 +210,398 @@ func TestToolHandlerWithEmptyArgs(t *testing.T) {
 		}
 	}
 }
+
+// TestLoadConfigFromReader tests reading a configuration piped on stdin
+func TestLoadConfigFromReader(t *testing.T) {
+	cfg, err := loadConfigFrom(strings.NewReader(`
+servers:
+  - name: "math"
+    prefix: "math"
+    transport: "stdio"
+    command: "/usr/bin/math-server"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Servers) != 1 || cfg.Servers[0].Name != "math" {
+		t.Errorf("expected server 'math', got %+v", cfg.Servers)
+	}
+
+	if _, err := loadConfigFrom(strings.NewReader("servers:\n  - name: \"math\"\n")); err == nil {
+		t.Error("expected validation error for incomplete config")
+	}
+}
+
+func TestMergeRecordings(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.jsonl")
+	b := filepath.Join(dir, "b.jsonl")
+	os.WriteFile(a, []byte(`# MCP Recording Session
+{"start_time":"2026-01-12T10:00:05Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:10Z","direction":"request","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{}}
+{"timestamp":"2026-01-12T10:00:30Z","direction":"response","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{}}
+`), 0644)
+	os.WriteFile(b, []byte(`# MCP Recording Session
+{"start_time":"2026-01-12T10:00:00Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:20Z","direction":"request","message_type":"tool_call","tool_name":"db_query","server_name":"db","message":{}}
+{"timestamp":"2026-01-12T10:00:25Z","direction":"request","message_type":"tool_call","tool_name":"fs_list","server_name":"fs","message":{}}
+`), 0644)
+
+	var out bytes.Buffer
+	merged, shared, err := mergeRecordings([]string{a, b}, &out)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if len(shared) != 1 || shared[0] != "fs" {
+		t.Errorf("expected fs to be reported as shared, got %v", shared)
+	}
+	if !merged.StartTime.Equal(time.Date(2026, 1, 12, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected earliest start time, got %v", merged.StartTime)
+	}
+
+	// The output must be a valid recording ordered by timestamp, not file order
+	mergedFile := filepath.Join(dir, "merged.jsonl")
+	os.WriteFile(mergedFile, out.Bytes(), 0644)
+	session, err := playback.ParseRecordingFile(mergedFile)
+	if err != nil {
+		t.Fatalf("parse merged recording: %v", err)
+	}
+	var order []string
+	for _, message := range session.Messages {
+		order = append(order, message.ServerName+":"+message.ToolName)
+	}
+	want := "fs:fs_read db:db_query fs:fs_list fs:fs_read"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("expected order %q, got %q", want, got)
+	}
+}
+
+func TestMergeRecordingsToFile(t *testing.T) {
+	dir := t.TempDir()
+	recording := `# MCP Recording Session
+{"start_time":"2026-01-12T10:00:00Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:10Z","direction":"request","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{}}
+`
+	a := filepath.Join(dir, "a.jsonl")
+	b := filepath.Join(dir, "b.jsonl")
+	os.WriteFile(a, []byte(recording), 0644)
+	os.WriteFile(b, []byte(recording), 0644)
+
+	// Naming an input as the output, however spelled, must leave it intact
+	for _, output := range []string{a, filepath.Join(dir, ".", "b.jsonl")} {
+		if _, _, err := mergeRecordingsToFile([]string{a, b}, output); err == nil || !strings.Contains(err.Error(), "is also an input") {
+			t.Errorf("output %s: expected an error naming it an input, got %v", output, err)
+		}
+	}
+	for _, input := range []string{a, b} {
+		if data, _ := os.ReadFile(input); string(data) != recording {
+			t.Errorf("expected %s to be unchanged, got %q", input, data)
+		}
+	}
+
+	output := filepath.Join(dir, "merged.jsonl")
+	merged, _, err := mergeRecordingsToFile([]string{a, b}, output)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if len(merged.Messages) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(merged.Messages))
+	}
+	if session, err := playback.ParseRecordingFile(output); err != nil || len(session.Messages) != 2 {
+		t.Errorf("expected the merged recording in %s, got %v, %v", output, session, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("expected no temporary files left, got %d entries", len(entries))
+	}
+
+	// A failed merge leaves an existing output alone
+	if _, _, err := mergeRecordingsToFile([]string{a, filepath.Join(dir, "missing.jsonl")}, output); err == nil {
+		t.Error("expected an error for a missing input")
+	}
+	if session, err := playback.ParseRecordingFile(output); err != nil || len(session.Messages) != 2 {
+		t.Errorf("expected the earlier output to survive a failed merge, got %v, %v", session, err)
+	}
+}
+
+func TestWatchConfigReloadsValidChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("servers: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan *config.ProxyConfig, 4)
+	if err := watchConfig(ctx, path, 100*time.Millisecond, func(cfg *config.ProxyConfig) { reloads <- cfg }); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	// Two quick writes are debounced into one reload
+	valid := "servers:\n  - name: fs\n    prefix: fs\n    transport: stdio\n    command: fs-server\n"
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(path, []byte(valid), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case cfg := <-reloads:
+		if len(cfg.Servers) != 1 || cfg.Servers[0].Name != "fs" {
+			t.Errorf("unexpected reloaded config: %+v", cfg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a reload after the config changed")
+	}
+
+	// An invalid config is not passed on
+	if err := os.WriteFile(path, []byte("servers: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-reloads:
+		t.Errorf("unexpected reload: %+v", cfg)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestWatchConfigDirectory(t *testing.T) {
+	dir := t.TempDir()
+	fs := "servers:\n  - name: fs\n    prefix: fs\n    transport: stdio\n    command: fs-server\n"
+	if err := os.WriteFile(filepath.Join(dir, "10-fs.yaml"), []byte(fs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan *config.ProxyConfig, 4)
+	if err := watchConfig(ctx, dir, 100*time.Millisecond, func(cfg *config.ProxyConfig) { reloads <- cfg }); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	// Files that aren't fragments are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-reloads:
+		t.Errorf("unexpected reload: %+v", cfg)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// A new fragment is picked up
+	git := "servers:\n  - name: git\n    prefix: git\n    transport: stdio\n    command: git-server\n"
+	if err := os.WriteFile(filepath.Join(dir, "20-git.yml"), []byte(git), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-reloads:
+		if len(cfg.Servers) != 2 || cfg.Servers[1].Name != "git" {
+			t.Errorf("unexpected reloaded config: %+v", cfg.Servers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a reload after a fragment was added")
+	}
+}
+
+func TestSetupLoggingFallsBackToStderr(t *testing.T) {
+	output, flags := log.Writer(), log.Flags()
+	defer func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	}()
+
+	// A regular file where the log directory should be can't be created
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	unwritable := filepath.Join(blocker, "mcp-proxy.log")
+
+	// An explicit --log path must be writable
+	if err := setupLogging(logSettings{file: unwritable}); err == nil {
+		t.Error("expected an unwritable --log path to fail")
+	}
+
+	// The default path falls back to stderr
+	original := defaultLogFile
+	defaultLogFile = unwritable
+	defer func() { defaultLogFile = original }()
+	if err := setupLogging(logSettings{}); err != nil {
+		t.Fatalf("expected a fallback to stderr, got %v", err)
+	}
+	if writer, ok := log.Writer().(*logWriter); !ok || writer.out != os.Stderr {
+		t.Error("expected logging to stderr")
+	}
+}
+
+func TestResolveLogSettings(t *testing.T) {
+	cfg, err := config.LoadConfigFromString("servers: []\nproxy:\n  logFile: /var/log/proxy.log\n  logLevel: warn\n  logFormat: json\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := resolveLogSettings(cfg, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings != (logSettings{file: "/var/log/proxy.log", level: config.LogLevelWarn, format: config.LogFormatJSON}) {
+		t.Errorf("expected the config's settings, got %+v", settings)
+	}
+
+	// The environment overrides the config, and flags override both
+	t.Setenv("MCP_LOG_FILE", "/tmp/env.log")
+	t.Setenv("MCP_LOG_LEVEL", "info")
+	settings, err = resolveLogSettings(cfg, "/tmp/flag.log", "debug", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings != (logSettings{file: "/tmp/flag.log", level: config.LogLevelDebug, format: config.LogFormatJSON}) {
+		t.Errorf("expected flag and env overrides, got %+v", settings)
+	}
+
+	if _, err := resolveLogSettings(nil, "", "", "xml"); err == nil {
+		t.Error("expected an invalid --log-format to fail")
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	now := func() time.Time { return time.Date(2026, 1, 12, 10, 0, 0, 0, time.UTC) }
+	logger := log.New(&logWriter{out: &out, level: config.LogLevelInfo, format: config.LogFormatJSON, now: now}, "", 0)
+
+	logger.Printf("[DEBUG] CallTool(fs, read): connected=true")
+	logger.Printf("Connected server 'fs' with 3 tools")
+	logger.Printf("Failed to connect server 'db': timeout")
+
+	want := `{"time":"2026-01-12T10:00:00Z","level":"info","msg":"Connected server 'fs' with 3 tools"}
+{"time":"2026-01-12T10:00:00Z","level":"warn","msg":"Failed to connect server 'db': timeout"}
+`
+	if out.String() != want {
+		t.Errorf("expected debug lines to be filtered, got:\n%s", out.String())
+	}
+}
+
+func TestMessageLevel(t *testing.T) {
+	tests := []struct {
+		message string
+		want    config.LogLevel
+	}{
+		{"[DEBUG] CallTool(fs, read): connected=true", config.LogLevelDebug},
+		{"Warning: config unknown key; ignoring it", config.LogLevelWarn},
+		{"WARNING: disk almost full", config.LogLevelWarn},
+		{"Error closing client fs: broken pipe", config.LogLevelWarn},
+		{"ERROR: backend crashed", config.LogLevelWarn},
+		{"[error] backend crashed", config.LogLevelWarn},
+		{"Failed to connect server 'db': timeout", config.LogLevelWarn},
+		{"!!! recording disabled", config.LogLevelWarn},
+		{"Connected server 'errors' with 3 tools", config.LogLevelInfo},
+		{"Registered tool logs_search_failures", config.LogLevelInfo},
+		{"Server 'fs' has a warning_threshold of 5", config.LogLevelInfo},
+		{"Errorless run finished", config.LogLevelInfo},
+		{"Failover server ready", config.LogLevelInfo},
+	}
+	for _, tt := range tests {
+		if got := messageLevel(tt.message); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.message, tt.want, got)
+		}
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.pid")
+
+	// A stale file is overwritten
+	if err := os.WriteFile(path, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePIDFile(path); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expected our pid, got %q", data)
+	}
+
+	removePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the pid file to be removed")
+	}
+
+	// A file taken over by another instance is left alone
+	os.WriteFile(path, []byte("999999\n"), 0644)
+	removePIDFile(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Error("expected another instance's pid file to be kept")
+	}
+}
+
+func TestFormatLaunch(t *testing.T) {
+	spec := &client.LaunchSpec{
+		Command: "npx",
+		Args:    []string{"-y", "@scope/pkg", "/tmp/my dir"},
+		Env:     []string{"PATH=/usr/bin", "API_KEY=abc", "MODE=it's"},
+	}
+	secret := func(key string) bool { return key == "API_KEY" }
+
+	output, redacted := formatLaunch(spec, "env", secret)
+	want := "env -i \\\n  API_KEY='[REDACTED]' \\\n  MODE='it'\\''s' \\\n  PATH=/usr/bin \\\n  npx -y @scope/pkg '/tmp/my dir'\n"
+	if output != want || redacted != 1 {
+		t.Errorf("unexpected env output (%d redacted):\n%s\nwant:\n%s", redacted, output, want)
+	}
+
+	output, redacted = formatLaunch(spec, "export", func(string) bool { return false })
+	want = "export API_KEY=abc\nexport MODE='it'\\''s'\nexport PATH=/usr/bin\nnpx -y @scope/pkg '/tmp/my dir'\n"
+	if output != want || redacted != 0 {
+		t.Errorf("unexpected export output (%d redacted):\n%s\nwant:\n%s", redacted, output, want)
+	}
+
+	if quoted := shellQuote(""); quoted != "''" {
+		t.Errorf("expected an empty value to be quoted, got %s", quoted)
+	}
+}
+
+func TestPlaybackControl(t *testing.T) {
+	t.Run("file resumes once it ends", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "commands")
+		if err := os.WriteFile(path, []byte("speed 2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		control := playback.NewController(true)
+		stop, err := startPlaybackControl(path, control)
+		if err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		defer stop()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			status, _ := control.Handle("status")
+			if strings.HasPrefix(status, "running, speed 2") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected playback to resume after the commands ended, got %q", status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("listener closes when stopped", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := listener.Addr().String()
+		listener.Close()
+
+		stop, err := startPlaybackControl("tcp:"+address, playback.NewController(true))
+		if err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		stop()
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			t.Error("expected the control listener to be closed")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writePIDFile writes the process id to path. An existing file, left by a
+// proxy that didn't shut down cleanly or by another running instance, is
+// overwritten with a warning.
+func writePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		log.Printf("Warning: overwriting existing pid file %s (pid %s)", path, strings.TrimSpace(string(data)))
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return nil
+}
+
+// removePIDFile removes the pid file at path if it still holds this
+// process's id, so a file taken over by another instance is left alone
+func removePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("Failed to remove pid file %s: %v", path, err)
+	}
+}
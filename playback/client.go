@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"time"
@@ -13,16 +14,24 @@ import (
 type PlaybackClient struct {
 	session  *PlaybackSession
 	messages []json.RawMessage
+	source   *RecordingReader // Set when streaming; messages is then unused
+	sent     int
 	delay    time.Duration
+	control  *Controller // Optional interactive pacing; nil replays at full speed
 }
 
 // NewPlaybackClient creates a new playback client
 func NewPlaybackClient(session *PlaybackSession) *PlaybackClient {
 	clientMessages := session.GetClientMessages()
-	messages := make([]json.RawMessage, len(clientMessages))
+	messages := make([]json.RawMessage, 0, len(clientMessages))
 	
-	for i, msg := range clientMessages {
-		messages[i] = msg.Message
+	for _, msg := range clientMessages {
+		// Truncated requests can't be replayed meaningfully
+		if IsTruncated(msg) {
+			log.Printf("Skipping truncated request for %s", msg.ToolName)
+			continue
+		}
+		messages = append(messages, msg.Message)
 	}
 	
 	return &PlaybackClient{
@@ -32,18 +41,112 @@ func NewPlaybackClient(session *PlaybackSession) *PlaybackClient {
 	}
 }
 
+// NewStreamingPlaybackClient creates a playback client that reads requests
+// from the recording as they are sent rather than loading it into memory.
+// The caller must Close the client.
+func NewStreamingPlaybackClient(filename string, options ParseOptions) (*PlaybackClient, error) {
+	reader, err := OpenRecording(filename, options)
+	if err != nil {
+		return nil, err
+	}
+	return &PlaybackClient{
+		session: reader.Header(),
+		source:  reader,
+		delay:   100 * time.Millisecond,
+	}, nil
+}
+
+// SeekTo skips a streaming client ahead to a seek target, as accepted by
+// RecordingIndex.Find, so replay starts there
+func (c *PlaybackClient) SeekTo(target string) error {
+	if c.source == nil {
+		return fmt.Errorf("seeking needs a streaming playback client")
+	}
+	return c.source.SeekTo(target)
+}
+
+// Close closes the recording of a streaming client
+func (c *PlaybackClient) Close() error {
+	if c.source == nil {
+		return nil
+	}
+	return c.source.Close()
+}
+
+// nextMessage returns the next request to send, or false once all have
+// been sent
+func (c *PlaybackClient) nextMessage() (json.RawMessage, bool, error) {
+	if c.source == nil {
+		if c.sent >= len(c.messages) {
+			return nil, false, nil
+		}
+		return c.messages[c.sent], true, nil
+	}
+
+	for {
+		msg, err := c.source.Next()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("error reading recording: %w", err)
+		}
+		if msg.Direction != "request" || IsHandshake(msg) {
+			continue
+		}
+		if IsTruncated(msg) {
+			log.Printf("Skipping truncated request for %s", msg.ToolName)
+			continue
+		}
+		return msg.Message, true, nil
+	}
+}
+
+// progress formats n against the number of messages, which is only known
+// up front when the recording is in memory
+func progress(n, total int, streaming bool) string {
+	if streaming {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
 // SetDelay sets the delay between messages
 func (c *PlaybackClient) SetDelay(delay time.Duration) {
 	c.delay = delay
 }
 
+// SetController paces the client with interactive commands instead of
+// replaying at full speed
+func (c *PlaybackClient) SetController(control *Controller) {
+	c.control = control
+}
+
+// pace waits until the next message may be sent and returns the delay to
+// send it after, or false once the controller stopped playback
+func (c *PlaybackClient) pace(message json.RawMessage) (time.Duration, bool) {
+	if c.control == nil {
+		return c.delay, true
+	}
+	speed, ok := c.control.wait()
+	if !ok {
+		log.Printf("Playback stopped by control command after %d messages", c.sent)
+		return 0, false
+	}
+	log.Printf("Sending request %d: %s", c.sent+1, message)
+	return time.Duration(float64(c.delay) / speed), true
+}
+
 // Run starts the playback client
 func (c *PlaybackClient) Run() error {
-	log.Printf("Starting playback client with %d messages", len(c.messages))
+	if c.source != nil {
+		log.Printf("Starting playback client, streaming requests from the recording")
+	} else {
+		log.Printf("Starting playback client with %d messages", len(c.messages))
+	}
 	
 	// Wait for server to be ready by reading from stdin
 	scanner := bufio.NewScanner(os.Stdin)
-	messageIndex := 0
 	
 	for scanner.Scan() {
 		serverResponse := scanner.Text()
@@ -52,14 +155,21 @@ func (c *PlaybackClient) Run() error {
 		log.Printf("Server response: %s", serverResponse)
 		
 		// Send next client request if available
-		if messageIndex < len(c.messages) {
-			time.Sleep(c.delay)
+		message, ok, err := c.nextMessage()
+		if err != nil {
+			return err
+		}
+		if ok {
+			delay, proceed := c.pace(message)
+			if !proceed {
+				break
+			}
+			time.Sleep(delay)
 			
 			// Send message to stdout (which goes to server's stdin)
-			fmt.Println(string(c.messages[messageIndex]))
-			log.Printf("Sent client request %d/%d", messageIndex+1, len(c.messages))
-			
-			messageIndex++
+			fmt.Println(string(message))
+			c.sent++
+			log.Printf("Sent client request %s", progress(c.sent, len(c.messages), c.source != nil))
 		} else {
 			log.Printf("All messages sent, exiting")
 			break
@@ -76,15 +186,31 @@ func (c *PlaybackClient) Run() error {
 
 // RunBatch sends all messages without waiting for responses (for testing)
 func (c *PlaybackClient) RunBatch() error {
-	log.Printf("Starting batch playback with %d messages", len(c.messages))
+	if c.source != nil {
+		log.Printf("Starting batch playback, streaming requests from the recording")
+	} else {
+		log.Printf("Starting batch playback with %d messages", len(c.messages))
+	}
 	
-	for i, message := range c.messages {
-		fmt.Println(string(message))
-		log.Printf("Sent message %d/%d", i+1, len(c.messages))
-		
-		if i < len(c.messages)-1 {
-			time.Sleep(c.delay)
+	for {
+		message, ok, err := c.nextMessage()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
 		}
+		delay, proceed := c.pace(message)
+		if !proceed {
+			break
+		}
+		if c.sent > 0 {
+			time.Sleep(delay)
+		}
+		
+		fmt.Println(string(message))
+		c.sent++
+		log.Printf("Sent message %s", progress(c.sent, len(c.messages), c.source != nil))
 	}
 	
 	log.Printf("Batch playback finished")
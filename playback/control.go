@@ -0,0 +1,130 @@
+package playback
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// controlHelp lists the commands a Controller accepts
+const controlHelp = `commands:
+  pause (p)         stop before the next message
+  resume (r)        send the remaining messages
+  step [N] (s)      send the next N messages (default 1) and pause again;
+                    an empty line steps too
+  speed X           divide the delay between messages by X, e.g. 0.5 or 4
+  status            show whether playback is paused, the speed and messages sent
+  quit (q)          stop playback`
+
+// Controller paces a playback client from interactive commands, so a
+// recording can be replayed message by message. Commands come from any
+// number of readers, e.g. a terminal and TCP connections.
+type Controller struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	steps  int     // Messages that may still be sent while paused
+	speed  float64 // Divides the client's delay
+	sent   int
+	quit   bool
+}
+
+// NewController creates a controller, paused until resumed or stepped if
+// paused is set
+func NewController(paused bool) *Controller {
+	c := &Controller{paused: paused, speed: 1}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Handle applies one command and returns the reply to show the user
+func (c *Controller) Handle(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		fields = []string{"step"}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.cond.Broadcast()
+
+	switch fields[0] {
+	case "pause", "p":
+		c.paused = true
+		c.steps = 0
+		return fmt.Sprintf("paused after %d messages", c.sent), nil
+	case "resume", "r":
+		c.paused = false
+		return "resumed", nil
+	case "step", "s":
+		n := 1
+		if len(fields) > 1 {
+			var err error
+			if n, err = strconv.Atoi(fields[1]); err != nil || n < 1 {
+				return "", fmt.Errorf("invalid step count %q: must be a positive number", fields[1])
+			}
+		}
+		c.paused = true
+		c.steps += n
+		return fmt.Sprintf("stepping %d", n), nil
+	case "speed":
+		if len(fields) < 2 {
+			return "", fmt.Errorf("speed needs a value, e.g. speed 2")
+		}
+		speed, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || speed <= 0 {
+			return "", fmt.Errorf("invalid speed %q: must be a positive number", fields[1])
+		}
+		c.speed = speed
+		return fmt.Sprintf("speed %g", speed), nil
+	case "status":
+		state := "running"
+		if c.paused {
+			state = "paused"
+		}
+		return fmt.Sprintf("%s, speed %g, %d messages sent", state, c.speed, c.sent), nil
+	case "quit", "q":
+		c.quit = true
+		return "stopping playback", nil
+	case "help", "?":
+		return controlHelp, nil
+	default:
+		return "", fmt.Errorf("unknown command %q; type help for a list", fields[0])
+	}
+}
+
+// ReadCommands handles each line read from r as a command, writing replies
+// and errors to out, until r is exhausted
+func (c *Controller) ReadCommands(r io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		reply, err := c.Handle(scanner.Text())
+		if err != nil {
+			reply = "error: " + err.Error()
+		}
+		fmt.Fprintln(out, reply)
+	}
+	return scanner.Err()
+}
+
+// wait blocks until the next message may be sent and returns the speed to
+// send it at, or false once playback was quit
+func (c *Controller) wait() (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.paused && c.steps == 0 && !c.quit {
+		c.cond.Wait()
+	}
+	if c.quit {
+		return 0, false
+	}
+	if c.paused {
+		c.steps--
+	}
+	c.sent++
+	return c.speed, true
+}
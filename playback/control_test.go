@@ -0,0 +1,95 @@
+package playback
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitResult runs c.wait in the background
+func waitResult(c *Controller) chan bool {
+	done := make(chan bool, 1)
+	go func() {
+		_, ok := c.wait()
+		done <- ok
+	}()
+	return done
+}
+
+func expectBlocked(t *testing.T, done chan bool) {
+	t.Helper()
+	select {
+	case <-done:
+		t.Fatal("expected the message to be held back")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectSent(t *testing.T, done chan bool, want bool) {
+	t.Helper()
+	select {
+	case ok := <-done:
+		if ok != want {
+			t.Fatalf("expected wait to return %v, got %v", want, ok)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the message to be released")
+	}
+}
+
+func TestControllerStepping(t *testing.T) {
+	c := NewController(true)
+
+	done := waitResult(c)
+	expectBlocked(t, done)
+
+	// An empty line steps once
+	if _, err := c.Handle(""); err != nil {
+		t.Fatal(err)
+	}
+	expectSent(t, done, true)
+	expectBlocked(t, waitResult(c))
+
+	// The pending wait and the next one are released by step 2
+	c.Handle("step 2")
+	time.Sleep(10 * time.Millisecond)
+	expectSent(t, waitResult(c), true)
+	done = waitResult(c)
+	expectBlocked(t, done)
+
+	c.Handle("resume")
+	expectSent(t, done, true)
+	expectSent(t, waitResult(c), true)
+
+	c.Handle("speed 4")
+	if speed, ok := c.wait(); !ok || speed != 4 {
+		t.Errorf("expected speed 4, got %g", speed)
+	}
+
+	c.Handle("pause")
+	done = waitResult(c)
+	expectBlocked(t, done)
+	c.Handle("quit")
+	expectSent(t, done, false)
+}
+
+func TestControllerCommands(t *testing.T) {
+	c := NewController(false)
+	var out bytes.Buffer
+	input := strings.Join([]string{"speed 0", "step x", "jump", "status", "help"}, "\n")
+	if err := c.ReadCommands(strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`error: invalid speed "0"`,
+		`error: invalid step count "x"`,
+		`error: unknown command "jump"`,
+		"running, speed 1, 0 messages sent",
+		"step [N] (s)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in replies, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package playback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+
+	"mcp-debug/config"
+)
+
+// Fixtures is a fixed tool list with canned responses, served by
+// --fixtures for client development without real backends
+type Fixtures struct {
+	Name    string        `yaml:"name,omitempty"`    // Server name reported to clients
+	Version string        `yaml:"version,omitempty"` // Server version reported to clients
+	Tools   []FixtureTool `yaml:"tools"`
+}
+
+// FixtureTool is a tool and the response it always gives
+type FixtureTool struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description,omitempty"`
+	InputSchema map[string]interface{} `yaml:"inputSchema,omitempty"` // JSON schema of the arguments (default: any object)
+	Response    FixtureResponse        `yaml:"response"`
+}
+
+// FixtureResponse is a canned tool result. Text may use the placeholders
+// {{args.NAME}}, {{tool}}, {{now}} and {{now.unix}}.
+type FixtureResponse struct {
+	Text       string      `yaml:"text,omitempty"`
+	Structured interface{} `yaml:"structured,omitempty"` // structuredContent, also sent as JSON text when text is empty
+	IsError    bool        `yaml:"isError,omitempty"`
+	Delay      string      `yaml:"delay,omitempty"` // Wait this long before answering, e.g. "500ms"
+}
+
+// LoadFixtures reads and validates a fixtures file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures file: %w", err)
+	}
+	var fixtures Fixtures
+	if err := yaml.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures file: %w", err)
+	}
+	if err := fixtures.Validate(); err != nil {
+		return nil, err
+	}
+	return &fixtures, nil
+}
+
+// Validate checks that tools have unique, valid names and a response
+func (f *Fixtures) Validate() error {
+	if len(f.Tools) == 0 {
+		return fmt.Errorf("fixtures define no tools")
+	}
+	names := make(map[string]bool, len(f.Tools))
+	for i, tool := range f.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tool %d: name is required", i)
+		}
+		if !config.ValidToolName(tool.Name) {
+			return fmt.Errorf("tool %s: name may only contain letters, digits, '_' and '-'", tool.Name)
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("duplicate tool name: %s", tool.Name)
+		}
+		names[tool.Name] = true
+		if tool.Response.Text == "" && tool.Response.Structured == nil {
+			return fmt.Errorf("tool %s: response needs text or structured", tool.Name)
+		}
+		if tool.Response.Delay != "" {
+			if _, err := time.ParseDuration(tool.Response.Delay); err != nil {
+				return fmt.Errorf("tool %s: invalid delay: %w", tool.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NewFixtureServer creates an MCP server exposing the fixture tools
+func NewFixtureServer(fixtures *Fixtures) (*server.MCPServer, error) {
+	name, version := fixtures.Name, fixtures.Version
+	if name == "" {
+		name = "MCP Debug Fixtures"
+	}
+	if version == "" {
+		version = "1.0.0"
+	}
+	s := server.NewMCPServer(name, version, server.WithToolCapabilities(true))
+
+	for _, fixture := range fixtures.Tools {
+		schema := fixture.InputSchema
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		rawSchema, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: invalid inputSchema: %w", fixture.Name, err)
+		}
+		s.AddTool(mcp.NewToolWithRawSchema(fixture.Name, fixture.Description, rawSchema), fixtureHandler(fixture))
+	}
+	return s, nil
+}
+
+// fixtureHandler answers every call of the tool with its canned response
+func fixtureHandler(fixture FixtureTool) server.ToolHandlerFunc {
+	delay, _ := time.ParseDuration(fixture.Response.Delay)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		response := fixture.Response
+		text := renderFixtureText(response.Text, fixture.Name, request.GetArguments())
+		var result *mcp.CallToolResult
+		if response.Structured != nil {
+			if text == "" {
+				encoded, err := json.Marshal(response.Structured)
+				if err != nil {
+					return nil, fmt.Errorf("tool %s: invalid structured response: %w", fixture.Name, err)
+				}
+				text = string(encoded)
+			}
+			result = mcp.NewToolResultStructured(response.Structured, text)
+		} else {
+			result = mcp.NewToolResultText(text)
+		}
+		result.IsError = response.IsError
+		return result, nil
+	}
+}
+
+// fixtureArgPattern matches {{args.NAME}} placeholders
+var fixtureArgPattern = regexp.MustCompile(`\{\{args\.([A-Za-z0-9_-]+)\}\}`)
+
+// renderFixtureText substitutes the placeholders in a fixture's text.
+// String arguments are inserted as is, others as JSON; a missing argument
+// leaves an empty string.
+func renderFixtureText(text, toolName string, args map[string]interface{}) string {
+	if text == "" {
+		return text
+	}
+	text = fixtureArgPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := args[fixtureArgPattern.FindStringSubmatch(placeholder)[1]]
+		if !ok {
+			return ""
+		}
+		if s, isString := value.(string); isString {
+			return s
+		}
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	})
+
+	now := time.Now().UTC()
+	return strings.NewReplacer(
+		"{{tool}}", toolName,
+		PlaceholderNowUnix, strconv.FormatInt(now.Unix(), 10),
+		PlaceholderNow, now.Format(time.RFC3339),
+	).Replace(text)
+}
@@ -0,0 +1,70 @@
+package playback
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestLoadFixturesExample(t *testing.T) {
+	fixtures, err := LoadFixtures("../examples/fixtures.yaml")
+	if err != nil {
+		t.Fatalf("load example fixtures: %v", err)
+	}
+	s, err := NewFixtureServer(fixtures)
+	if err != nil {
+		t.Fatalf("create fixture server: %v", err)
+	}
+	for _, tool := range fixtures.Tools {
+		if s.GetTool(tool.Name) == nil {
+			t.Errorf("expected tool %s to be registered", tool.Name)
+		}
+	}
+}
+
+func TestFixtureHandler(t *testing.T) {
+	call := func(tool FixtureTool, args map[string]interface{}) *mcp.CallToolResult {
+		t.Helper()
+		request := mcp.CallToolRequest{}
+		request.Params.Name = tool.Name
+		request.Params.Arguments = args
+		result, err := fixtureHandler(tool)(context.Background(), request)
+		if err != nil {
+			t.Fatalf("%s: %v", tool.Name, err)
+		}
+		return result
+	}
+
+	result := call(FixtureTool{Name: "greet", Response: FixtureResponse{Text: "{{tool}}: hello {{args.name}}, {{args.count}} times{{args.missing}}"}},
+		map[string]interface{}{"name": "Ada", "count": 3})
+	if text := result.Content[0].(mcp.TextContent).Text; text != "greet: hello Ada, 3 times" {
+		t.Errorf("unexpected templated text: %q", text)
+	}
+
+	result = call(FixtureTool{Name: "status", Response: FixtureResponse{Structured: map[string]interface{}{"ok": false}, IsError: true}}, nil)
+	if !result.IsError || result.StructuredContent == nil || result.Content[0].(mcp.TextContent).Text != `{"ok":false}` {
+		t.Errorf("unexpected structured result: %#v", result)
+	}
+}
+
+func TestFixturesValidate(t *testing.T) {
+	tests := []struct {
+		fixtures Fixtures
+		errMatch string
+	}{
+		{Fixtures{}, "no tools"},
+		{Fixtures{Tools: []FixtureTool{{Response: FixtureResponse{Text: "x"}}}}, "name is required"},
+		{Fixtures{Tools: []FixtureTool{{Name: "a.b", Response: FixtureResponse{Text: "x"}}}}, "may only contain"},
+		{Fixtures{Tools: []FixtureTool{{Name: "a", Response: FixtureResponse{Text: "x"}}, {Name: "a", Response: FixtureResponse{Text: "y"}}}}, "duplicate tool name"},
+		{Fixtures{Tools: []FixtureTool{{Name: "a"}}}, "needs text or structured"},
+		{Fixtures{Tools: []FixtureTool{{Name: "a", Response: FixtureResponse{Text: "x", Delay: "soon"}}}}, "invalid delay"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.fixtures.Validate(); err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+			t.Errorf("expected error containing %q, got %v", tt.errMatch, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package playback
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-debug/integration"
+)
+
+// ErrNotInIndex is returned when a seek target isn't in the recording
+var ErrNotInIndex = errors.New("not found in recording index")
+
+// RecordingIndex locates the messages of a recording file, so a reader can
+// seek to one without scanning the messages before it
+type RecordingIndex struct {
+	Entries []integration.RecordingIndexEntry
+}
+
+// LoadRecordingIndex reads the index next to a recording, as written with
+// record.index. A missing or stale index, e.g. of a recording that was
+// edited or is still being written, is rebuilt from the recording and saved
+// for the next time.
+func LoadRecordingIndex(filename string) (*RecordingIndex, error) {
+	indexFile := integration.RecordingIndexFilename(filename)
+	index, err := readRecordingIndex(indexFile)
+	if err == nil {
+		if err = index.check(filename); err == nil {
+			return index, nil
+		}
+	}
+	if !os.IsNotExist(err) {
+		log.Printf("Rebuilding recording index %s: %v", indexFile, err)
+	}
+
+	index, err = BuildRecordingIndex(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Save(indexFile); err != nil {
+		log.Printf("Failed to save recording index: %v", err)
+	}
+	return index, nil
+}
+
+// BuildRecordingIndex scans a recording and indexes its messages. Like
+// OpenRecording it takes the first JSON line for the session header and
+// skips comments, blank lines and invalid lines.
+func BuildRecordingIndex(filename string) (*RecordingIndex, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+	defer file.Close()
+
+	index := &RecordingIndex{}
+	reader := bufio.NewReader(file)
+	var offset int64
+	line := 0
+	header := false
+	for {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			line++
+			trimmed := bytes.TrimSpace(data)
+			if len(trimmed) > 0 && trimmed[0] != '#' {
+				var message integration.RecordedMessage
+				if !header {
+					header = json.Valid(trimmed)
+				} else if json.Unmarshal(trimmed, &message) == nil {
+					index.Entries = append(index.Entries, integration.NewRecordingIndexEntry(len(index.Entries)+1, line, offset, len(data), message))
+				}
+			}
+			offset += int64(len(data))
+		}
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+	}
+}
+
+// readRecordingIndex reads an index file
+func readRecordingIndex(indexFile string) (*RecordingIndex, error) {
+	file, err := os.Open(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	index := &RecordingIndex{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry integration.RecordingIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid index entry %d: %w", len(index.Entries)+1, err)
+		}
+		index.Entries = append(index.Entries, entry)
+	}
+	return index, scanner.Err()
+}
+
+// check reports why the index doesn't match the recording: its last entry
+// must end the file and locate a message with the same timestamp
+func (i *RecordingIndex) check(filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if len(i.Entries) == 0 {
+		return fmt.Errorf("index is empty")
+	}
+	last := i.Entries[len(i.Entries)-1]
+	if end := last.Offset + int64(last.Length); end != info.Size() {
+		return fmt.Errorf("index ends at byte %d of %d", end, info.Size())
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data := make([]byte, last.Length)
+	if _, err := file.ReadAt(data, last.Offset); err != nil {
+		return err
+	}
+	var message integration.RecordedMessage
+	if err := json.Unmarshal(bytes.TrimSpace(data), &message); err != nil || !message.Timestamp.Equal(last.Timestamp) {
+		return fmt.Errorf("last entry doesn't match message %d", last.Number)
+	}
+	return nil
+}
+
+// Save writes the index to a file, replacing it atomically
+func (i *RecordingIndex) Save(indexFile string) error {
+	var buf bytes.Buffer
+	for _, entry := range i.Entries {
+		if err := integration.WriteRecordingIndexEntry(&buf, entry); err != nil {
+			return err
+		}
+	}
+	tmp := indexFile + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, indexFile)
+}
+
+// Message returns the entry of the message with a 1-based number
+func (i *RecordingIndex) Message(number int) (integration.RecordingIndexEntry, bool) {
+	if number < 1 || number > len(i.Entries) {
+		return integration.RecordingIndexEntry{}, false
+	}
+	return i.Entries[number-1], true
+}
+
+// FirstToolCall returns the entry of the first request calling a tool
+func (i *RecordingIndex) FirstToolCall(toolName string) (integration.RecordingIndexEntry, bool) {
+	for _, entry := range i.Entries {
+		if entry.Direction == "request" && entry.MessageType == "tool_call" && entry.ToolName == toolName {
+			return entry, true
+		}
+	}
+	return integration.RecordingIndexEntry{}, false
+}
+
+// AtTime returns the entry of the first message recorded at or after t
+func (i *RecordingIndex) AtTime(t time.Time) (integration.RecordingIndexEntry, bool) {
+	n := sort.Search(len(i.Entries), func(j int) bool {
+		return !i.Entries[j].Timestamp.Before(t)
+	})
+	if n == len(i.Entries) {
+		return integration.RecordingIndexEntry{}, false
+	}
+	return i.Entries[n], true
+}
+
+// Find returns the entry of a seek target: a 1-based message number,
+// "tool:NAME" for the first call of a tool, or "time:RFC3339" for the first
+// message recorded at or after a time
+func (i *RecordingIndex) Find(target string) (integration.RecordingIndexEntry, error) {
+	var entry integration.RecordingIndexEntry
+	var found bool
+	switch {
+	case strings.HasPrefix(target, "tool:"):
+		entry, found = i.FirstToolCall(strings.TrimPrefix(target, "tool:"))
+	case strings.HasPrefix(target, "time:"):
+		t, err := time.Parse(time.RFC3339, strings.TrimPrefix(target, "time:"))
+		if err != nil {
+			return entry, fmt.Errorf("invalid seek time: %w", err)
+		}
+		entry, found = i.AtTime(t)
+	default:
+		number, err := strconv.Atoi(target)
+		if err != nil {
+			return entry, fmt.Errorf("invalid seek target %q: expected a message number, tool:NAME or time:RFC3339", target)
+		}
+		entry, found = i.Message(number)
+	}
+	if !found {
+		return entry, fmt.Errorf("%s: %w", target, ErrNotInIndex)
+	}
+	return entry, nil
+}
+
+// Seek positions the reader so that Next returns the message entry
+// locates. Message counts and line numbers continue from that message, as
+// if the ones before it had been read.
+func (r *RecordingReader) Seek(entry integration.RecordingIndexEntry) error {
+	if _, err := r.file.Seek(entry.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek recording: %w", err)
+	}
+	r.scanner = bufio.NewScanner(r.file)
+	r.pending = nil
+	r.line = entry.Line - 1
+	r.messages = entry.Number - 1
+	r.bytes = entry.Offset
+	return nil
+}
+
+// SeekTo positions the reader at a seek target, as accepted by
+// RecordingIndex.Find, using the recording's index
+func (r *RecordingReader) SeekTo(target string) error {
+	index, err := LoadRecordingIndex(r.file.Name())
+	if err != nil {
+		return err
+	}
+	entry, err := index.Find(target)
+	if err != nil {
+		return err
+	}
+	return r.Seek(entry)
+}
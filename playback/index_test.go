@@ -0,0 +1,128 @@
+package playback
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"mcp-debug/integration"
+)
+
+const indexedRecording = `# MCP Recording Session
+{"start_time":"2026-01-12T10:00:00Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:10Z","direction":"request","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{"id":1}}
+{"timestamp":"2026-01-12T10:00:11Z","direction":"response","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{"id":1}}
+
+# A comment between messages
+{"timestamp":"2026-01-12T10:00:20Z","direction":"request","message_type":"tool_call","tool_name":"fs_list","server_name":"fs","message":{"id":2}}
+{"timestamp":"2026-01-12T10:00:21Z","direction":"response","message_type":"tool_call","tool_name":"fs_list","server_name":"fs","message":{"id":2}}
+`
+
+func TestLoadRecordingIndex(t *testing.T) {
+	path := writeRecording(t, indexedRecording)
+
+	index, err := LoadRecordingIndex(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(index.Entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(index.Entries))
+	}
+	if entry := index.Entries[2]; entry.Number != 3 || entry.Line != 7 || entry.ToolName != "fs_list" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if _, err := os.Stat(integration.RecordingIndexFilename(path)); err != nil {
+		t.Errorf("expected the built index to be saved: %v", err)
+	}
+
+	// An appended message makes the saved index stale
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"timestamp":"2026-01-12T10:00:30Z","direction":"request","message_type":"tool_call","tool_name":"fs_stat","server_name":"fs","message":{"id":3}}` + "\n")
+	file.Close()
+
+	index, err = LoadRecordingIndex(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(index.Entries) != 5 || index.Entries[4].ToolName != "fs_stat" {
+		t.Errorf("expected the stale index rebuilt, got %+v", index.Entries)
+	}
+	saved, err := readRecordingIndex(integration.RecordingIndexFilename(path))
+	if err != nil || len(saved.Entries) != 5 {
+		t.Errorf("expected the rebuilt index saved, got %v, %v", saved, err)
+	}
+}
+
+func TestRecordingIndexFind(t *testing.T) {
+	index, err := BuildRecordingIndex(writeRecording(t, indexedRecording))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"2", 2},
+		{"tool:fs_list", 3},
+		{"time:2026-01-12T10:00:15Z", 3},
+		{"time:2026-01-12T10:00:21Z", 4},
+	}
+	for _, tt := range tests {
+		entry, err := index.Find(tt.target)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.target, err)
+			continue
+		}
+		if entry.Number != tt.want {
+			t.Errorf("%s: expected message %d, got %d", tt.target, tt.want, entry.Number)
+		}
+	}
+
+	for _, target := range []string{"0", "5", "tool:fs_write", "time:2026-01-12T11:00:00Z"} {
+		if _, err := index.Find(target); !errors.Is(err, ErrNotInIndex) {
+			t.Errorf("%s: expected ErrNotInIndex, got %v", target, err)
+		}
+	}
+	if _, err := index.Find("first"); err == nil {
+		t.Error("expected an invalid target to fail")
+	}
+}
+
+func TestRecordingReaderSeekTo(t *testing.T) {
+	path := writeRecording(t, indexedRecording)
+
+	reader, err := OpenRecording(path, ParseOptions{MaxMessages: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	if err := reader.SeekTo("tool:fs_list"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	message, err := reader.Next()
+	if err != nil || message.ToolName != "fs_list" || message.Direction != "request" {
+		t.Fatalf("expected the fs_list request, got %+v, %v", message, err)
+	}
+	// Messages before the target count against MaxMessages
+	if _, err := reader.Next(); !errors.Is(err, ErrRecordingTooLarge) {
+		t.Errorf("expected the fourth message to exceed the limit, got %v", err)
+	}
+
+	// Seeking back works too
+	if err := reader.SeekTo("1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message, err := reader.Next(); err != nil || message.ToolName != "fs_read" {
+		t.Errorf("expected the first message, got %+v, %v", message, err)
+	}
+	reader.Next()
+	reader.Next()
+	if _, err := reader.Next(); !errors.Is(err, ErrRecordingTooLarge) {
+		t.Errorf("expected the limit to apply after seeking, got %v", err)
+	}
+}
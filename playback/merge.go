@@ -0,0 +1,92 @@
+package playback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"mcp-debug/integration"
+)
+
+// MergeSessions combines several recordings into a single timeline. Messages
+// are ordered by timestamp regardless of which session they came from;
+// messages with equal timestamps keep their input order. Each message keeps
+// its server_name. The merged session starts at the earliest start time.
+func MergeSessions(sessions ...*PlaybackSession) *PlaybackSession {
+	merged := &PlaybackSession{}
+	var serverInfos []string
+	seenInfo := make(map[string]bool)
+
+	for _, session := range sessions {
+		if merged.StartTime.IsZero() || session.StartTime.Before(merged.StartTime) {
+			merged.StartTime = session.StartTime
+		}
+		if !seenInfo[session.ServerInfo] {
+			seenInfo[session.ServerInfo] = true
+			serverInfos = append(serverInfos, session.ServerInfo)
+		}
+		merged.Messages = append(merged.Messages, session.Messages...)
+	}
+
+	sort.SliceStable(merged.Messages, func(i, j int) bool {
+		return merged.Messages[i].Timestamp.Before(merged.Messages[j].Timestamp)
+	})
+	merged.ServerInfo = strings.Join(serverInfos, ", ")
+	if len(sessions) > 1 {
+		merged.ServerInfo = fmt.Sprintf("Merged from %d recordings: %s", len(sessions), merged.ServerInfo)
+	}
+	return merged
+}
+
+// SharedServerNames returns the backend server names recorded in more than
+// one session, sorted. The proxy's own management traffic ("proxy") is
+// present in every recording and is not reported.
+func SharedServerNames(sessions ...*PlaybackSession) []string {
+	counts := make(map[string]int)
+	for _, session := range sessions {
+		names := make(map[string]bool)
+		for _, message := range session.Messages {
+			if message.ServerName != "" && message.ServerName != "proxy" {
+				names[message.ServerName] = true
+			}
+		}
+		for name := range names {
+			counts[name]++
+		}
+	}
+
+	var shared []string
+	for name, count := range counts {
+		if count > 1 {
+			shared = append(shared, name)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
+
+// WriteSession writes session in the recording file format: the header
+// followed by one message per line
+func WriteSession(w io.Writer, session *PlaybackSession) error {
+	header := integration.RecordingSession{
+		StartTime:  session.StartTime,
+		ServerInfo: session.ServerInfo,
+		Messages:   []integration.RecordedMessage{},
+	}
+	if err := integration.WriteRecordingHeader(w, header); err != nil {
+		return err
+	}
+
+	for _, message := range session.Messages {
+		line, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,7 +3,10 @@ package playback
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"strings"
 	"time"
@@ -18,77 +21,299 @@ type PlaybackSession struct {
 	Messages   []integration.RecordedMessage    `json:"messages"`
 }
 
-// ParseRecordingFile parses a recorded session file
-func ParseRecordingFile(filename string) (*PlaybackSession, error) {
+// ParseOptions controls how a recording is read. Zero limits mean no limit.
+type ParseOptions struct {
+	MaxMessages int   // Recorded messages, not counting the session header
+	MaxBytes    int64 // File size, including comments and blank lines
+
+	// SkipInvalid logs and skips lines that aren't valid JSON instead of
+	// failing, e.g. the last line of a recording cut short by a crash
+	SkipInvalid bool
+}
+
+// ErrRecordingTooLarge is returned when a recording exceeds the limits in
+// its ParseOptions
+var ErrRecordingTooLarge = errors.New("recording exceeds parse limit")
+
+// snippetLength is how much of an invalid line a ParseError quotes
+const snippetLength = 60
+
+// ParseError reports a recording line that couldn't be parsed
+type ParseError struct {
+	Line    int    // 1-based line number in the file
+	Snippet string // The offending content, shortened to snippetLength
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Snippet)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// snippet returns up to snippetLength bytes of line around offset, where a
+// JSON syntax error was found
+func snippet(line string, offset int64) string {
+	if len(line) <= snippetLength {
+		return line
+	}
+	start := int(offset) - snippetLength/2
+	if start < 0 {
+		start = 0
+	}
+	if start > len(line)-snippetLength {
+		start = len(line) - snippetLength
+	}
+	result := line[start : start+snippetLength]
+	if start > 0 {
+		result = "..." + result
+	}
+	if start+snippetLength < len(line) {
+		result += "..."
+	}
+	return result
+}
+
+// RecordingReader reads a recording file one message at a time, so a
+// recording can be replayed without holding it in memory
+type RecordingReader struct {
+	file     *os.File
+	scanner  *bufio.Scanner
+	options  ParseOptions
+	header   *PlaybackSession
+	pending  []integration.RecordedMessage // Messages read while looking for the header
+	line     int
+	messages int
+	bytes    int64
+	skipped  int
+}
+
+// OpenRecording opens a recording file for streaming and reads up to its
+// session header. The caller must Close the reader.
+func OpenRecording(filename string, options ParseOptions) (*RecordingReader, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open recording file: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var session *PlaybackSession
-	var messages []integration.RecordedMessage
+	reader := &RecordingReader{
+		file:    file,
+		scanner: bufio.NewScanner(file),
+		options: options,
+	}
+	for reader.header == nil {
+		line, ok, err := reader.nextLine()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		
-		// Skip comments and empty lines
-		if line == "" || strings.HasPrefix(line, "#") {
+		var session PlaybackSession
+		if err := json.Unmarshal([]byte(line), &session); err == nil {
+			reader.header = &session
 			continue
 		}
+		message, err := reader.parseMessage(line)
+		if err == nil && message != nil {
+			err = reader.countMessage()
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if message != nil {
+			reader.pending = append(reader.pending, *message)
+		}
+	}
+
+	if reader.header == nil {
+		// Create a default session if header not found
+		reader.header = &PlaybackSession{
+			StartTime:  time.Now(),
+			ServerInfo: "Unknown",
+		}
+	}
+	return reader, nil
+}
+
+// Header returns the recording's session header, without messages
+func (r *RecordingReader) Header() *PlaybackSession {
+	return r.header
+}
+
+// Next returns the next recorded message, or io.EOF at the end of the
+// recording. An invalid line fails with a *ParseError unless
+// ParseOptions.SkipInvalid is set.
+func (r *RecordingReader) Next() (integration.RecordedMessage, error) {
+	if len(r.pending) > 0 {
+		message := r.pending[0]
+		r.pending = r.pending[1:]
+		return message, nil
+	}
 
-		// Try to parse as session header first
-		if session == nil {
-			var tempSession PlaybackSession
-			if err := json.Unmarshal([]byte(line), &tempSession); err == nil {
-				session = &tempSession
-				continue
-			}
+	for {
+		line, ok, err := r.nextLine()
+		if err != nil {
+			return integration.RecordedMessage{}, err
+		}
+		if !ok {
+			return integration.RecordedMessage{}, io.EOF
 		}
 
-		// Parse as recorded message
-		var message integration.RecordedMessage
-		if err := json.Unmarshal([]byte(line), &message); err != nil {
-			// Skip invalid lines but continue parsing
+		message, err := r.parseMessage(line)
+		if err != nil {
+			return integration.RecordedMessage{}, err
+		}
+		if message == nil {
 			continue
 		}
+		if err := r.countMessage(); err != nil {
+			return integration.RecordedMessage{}, err
+		}
+		return *message, nil
+	}
+}
 
-		messages = append(messages, message)
+// Skipped returns the number of invalid lines skipped so far
+func (r *RecordingReader) Skipped() int {
+	return r.skipped
+}
+
+// Close closes the recording file
+func (r *RecordingReader) Close() error {
+	return r.file.Close()
+}
+
+// nextLine returns the next line that is neither empty nor a comment, or
+// false at the end of the file
+func (r *RecordingReader) nextLine() (string, bool, error) {
+	for r.scanner.Scan() {
+		r.line++
+		r.bytes += int64(len(r.scanner.Bytes())) + 1
+		if r.options.MaxBytes > 0 && r.bytes > r.options.MaxBytes {
+			return "", false, fmt.Errorf("%w: more than %d bytes", ErrRecordingTooLarge, r.options.MaxBytes)
+		}
+
+		line := strings.TrimSpace(r.scanner.Text())
+
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line, true, nil
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+	if err := r.scanner.Err(); err != nil {
+		return "", false, fmt.Errorf("error reading file: %w", &ParseError{Line: r.line + 1, Err: err})
 	}
+	return "", false, nil
+}
 
-	if session == nil {
-		// Create a default session if header not found
-		session = &PlaybackSession{
-			StartTime:  time.Now(),
-			ServerInfo: "Unknown",
+// countMessage counts one more message against the MaxMessages limit
+func (r *RecordingReader) countMessage() error {
+	r.messages++
+	if r.options.MaxMessages > 0 && r.messages > r.options.MaxMessages {
+		return fmt.Errorf("%w: more than %d messages", ErrRecordingTooLarge, r.options.MaxMessages)
+	}
+	return nil
+}
+
+// parseMessage parses the line just read as a recorded message. It returns
+// nil without an error for an invalid line skipped per SkipInvalid.
+func (r *RecordingReader) parseMessage(line string) (*integration.RecordedMessage, error) {
+	var message integration.RecordedMessage
+	err := json.Unmarshal([]byte(line), &message)
+	if err == nil {
+		return &message, nil
+	}
+
+	var offset int64
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		offset = syntaxErr.Offset
+	}
+	parseErr := &ParseError{Line: r.line, Snippet: snippet(line, offset), Err: err}
+	if !r.options.SkipInvalid {
+		return nil, parseErr
+	}
+	r.skipped++
+	log.Printf("Skipping invalid recording %v", parseErr)
+	return nil, nil
+}
+
+// ParseRecordingFile parses a recorded session file into memory. Use
+// OpenRecording to stream recordings too large to load.
+func ParseRecordingFile(filename string) (*PlaybackSession, error) {
+	return ParseRecordingFileWithOptions(filename, ParseOptions{})
+}
+
+// ParseRecordingFileWithOptions parses a recorded session file into memory,
+// failing with ErrRecordingTooLarge once the recording exceeds the limits
+// in options
+func ParseRecordingFileWithOptions(filename string, options ParseOptions) (*PlaybackSession, error) {
+	reader, err := OpenRecording(filename, options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var messages []integration.RecordedMessage
+	for {
+		message, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
+		messages = append(messages, message)
 	}
 
+	session := reader.Header()
 	session.Messages = messages
 	return session, nil
 }
 
-// GetClientMessages returns only the client request messages
+// IsTruncated returns true if the message was replaced by a truncation marker
+// because it exceeded the recording's size limit
+func IsTruncated(message integration.RecordedMessage) bool {
+	var marker integration.TruncatedMessage
+	if err := json.Unmarshal(message.Message, &marker); err != nil {
+		return false
+	}
+	return marker.Truncated
+}
+
+// IsHandshake returns true for a recorded backend initialize request or response
+func IsHandshake(message integration.RecordedMessage) bool {
+	return message.MessageType == "initialize"
+}
+
+// GetClientMessages returns only the client request messages. Backend
+// initialize handshakes are excluded; see GetHandshakes.
 func (s *PlaybackSession) GetClientMessages() []integration.RecordedMessage {
 	var clientMessages []integration.RecordedMessage
 	for _, message := range s.Messages {
-		if message.Direction == "request" {
+		if message.Direction == "request" && !IsHandshake(message) {
 			clientMessages = append(clientMessages, message)
 		}
 	}
 	return clientMessages
 }
 
-// GetServerMessages returns only the server response messages
+// GetServerMessages returns only the server response messages. Backend
+// initialize handshakes are excluded; see GetHandshakes.
 func (s *PlaybackSession) GetServerMessages() []integration.RecordedMessage {
 	var serverMessages []integration.RecordedMessage
 	for _, message := range s.Messages {
-		if message.Direction == "response" {
+		if message.Direction == "response" && !IsHandshake(message) {
 			serverMessages = append(serverMessages, message)
 		}
 	}
@@ -101,6 +326,9 @@ func (s *PlaybackSession) GetMessagePairs() []MessagePair {
 	var currentRequest *integration.RecordedMessage
 
 	for _, message := range s.Messages {
+		if IsHandshake(message) {
+			continue
+		}
 		if message.Direction == "request" {
 			currentRequest = &message
 		} else if message.Direction == "response" && currentRequest != nil {
@@ -115,6 +343,18 @@ func (s *PlaybackSession) GetMessagePairs() []MessagePair {
 	return pairs
 }
 
+// GetHandshakes returns the recorded backend initialize responses, in the
+// order the backends were initialized
+func (s *PlaybackSession) GetHandshakes() []integration.RecordedMessage {
+	var handshakes []integration.RecordedMessage
+	for _, message := range s.Messages {
+		if message.Direction == "response" && IsHandshake(message) {
+			handshakes = append(handshakes, message)
+		}
+	}
+	return handshakes
+}
+
 // MessagePair represents a request-response pair
 type MessagePair struct {
 	Request  integration.RecordedMessage
@@ -0,0 +1,189 @@
+package playback
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-debug/integration"
+)
+
+// corruptedRecording has a damaged message on line 4 and a last line cut
+// short, as left by a crash
+const corruptedRecording = `# MCP Recording Session
+{"start_time":"2026-01-12T10:00:00Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:10Z","direction":"request","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{}}
+{"timestamp":"2026-01-12T10:00:11Z","direction":"response","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{"content": [x]}}
+{"timestamp":"2026-01-12T10:00:20Z","direction":"request","message_type":"tool_call","tool_name":"fs_list","server_name":"fs","message":{}}
+{"timestamp":"2026-01-12T10:00:21Z","direction":"respo`
+
+func writeRecording(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseRecordingFileReportsLine(t *testing.T) {
+	path := writeRecording(t, corruptedRecording)
+
+	_, err := ParseRecordingFile(path)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if parseErr.Line != 4 {
+		t.Errorf("expected line 4, got %d", parseErr.Line)
+	}
+	if !strings.HasPrefix(err.Error(), "line 4: invalid character 'x'") {
+		t.Errorf("expected the error to lead with the line number, got %q", err.Error())
+	}
+	// The snippet is centered on the syntax error, not the start of the line
+	if !strings.Contains(parseErr.Snippet, "[x]") || len(parseErr.Snippet) > snippetLength+6 {
+		t.Errorf("expected a short snippet around the error, got %q", parseErr.Snippet)
+	}
+}
+
+func TestParseRecordingFileSkipInvalid(t *testing.T) {
+	path := writeRecording(t, corruptedRecording)
+
+	session, err := ParseRecordingFileWithOptions(path, ParseOptions{SkipInvalid: true})
+	if err != nil {
+		t.Fatalf("expected invalid lines to be skipped, got %v", err)
+	}
+	var tools []string
+	for _, message := range session.Messages {
+		tools = append(tools, message.Direction+":"+message.ToolName)
+	}
+	if got := strings.Join(tools, " "); got != "request:fs_read request:fs_list" {
+		t.Errorf("expected the two valid messages, got %q", got)
+	}
+	if session.ServerInfo != "Dynamic MCP Proxy v1.0.0" {
+		t.Errorf("expected the header to be parsed, got %q", session.ServerInfo)
+	}
+
+	reader, err := OpenRecording(path, ParseOptions{SkipInvalid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	for {
+		if _, err := reader.Next(); err != nil {
+			break
+		}
+	}
+	if reader.Skipped() != 2 {
+		t.Errorf("expected 2 skipped lines, got %d", reader.Skipped())
+	}
+}
+
+func TestParseRecordingFileLimits(t *testing.T) {
+	path := writeRecording(t, corruptedRecording)
+
+	_, err := ParseRecordingFileWithOptions(path, ParseOptions{MaxMessages: 1, SkipInvalid: true})
+	if !errors.Is(err, ErrRecordingTooLarge) {
+		t.Errorf("expected ErrRecordingTooLarge for too many messages, got %v", err)
+	}
+	_, err = ParseRecordingFileWithOptions(path, ParseOptions{MaxBytes: 100, SkipInvalid: true})
+	if !errors.Is(err, ErrRecordingTooLarge) {
+		t.Errorf("expected ErrRecordingTooLarge for too many bytes, got %v", err)
+	}
+}
+
+// streamedRecording has a header and three tool call messages
+const streamedRecording = `{"start_time":"2026-01-12T10:00:00Z","server_info":"Dynamic MCP Proxy v1.0.0","messages":[]}
+{"timestamp":"2026-01-12T10:00:10Z","direction":"request","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{}}
+{"timestamp":"2026-01-12T10:00:11Z","direction":"response","message_type":"tool_call","tool_name":"fs_read","server_name":"fs","message":{"content":[]}}
+{"timestamp":"2026-01-12T10:00:20Z","direction":"request","message_type":"tool_call","tool_name":"fs_list","server_name":"fs","message":{}}
+`
+
+func TestOpenRecordingStreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		options ParseOptions
+		want    int   // Messages read before the stream ends
+		wantErr error // nil for a clean io.EOF
+	}{
+		{
+			name:    "under the limits",
+			content: streamedRecording,
+			options: ParseOptions{MaxMessages: 3, MaxBytes: int64(len(streamedRecording))},
+			want:    3,
+		},
+		{
+			name:    "over the message limit",
+			content: streamedRecording,
+			options: ParseOptions{MaxMessages: 2},
+			want:    2,
+			wantErr: ErrRecordingTooLarge,
+		},
+		{
+			name:    "over the byte limit",
+			content: streamedRecording,
+			options: ParseOptions{MaxBytes: int64(len(streamedRecording)) - 10},
+			want:    2,
+			wantErr: ErrRecordingTooLarge,
+		},
+		{
+			name:    "interrupted mid-line",
+			content: streamedRecording + `{"timestamp":"2026-01-12T10:00:21Z","direction":"respo`,
+			want:    3,
+			wantErr: &ParseError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := OpenRecording(writeRecording(t, tt.content), tt.options)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer reader.Close()
+
+			read := 0
+			for {
+				_, err = reader.Next()
+				if err != nil {
+					break
+				}
+				read++
+			}
+			if read != tt.want {
+				t.Errorf("expected %d messages before the stream ended, got %d", tt.want, read)
+			}
+			var parseErr *ParseError
+			switch {
+			case tt.wantErr == nil && err != io.EOF:
+				t.Errorf("expected io.EOF, got %v", err)
+			case errors.As(tt.wantErr, &parseErr) && !errors.As(err, &parseErr):
+				t.Errorf("expected a ParseError, got %v", err)
+			case tt.wantErr == ErrRecordingTooLarge && !errors.Is(err, ErrRecordingTooLarge):
+				t.Errorf("expected ErrRecordingTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIsTruncated(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{`{"_truncated":true,"original_size":4096}`, true},
+		{`{"content":[{"type":"text","text":"ok"}]}`, false},
+		{`{"_truncated":false}`, false},
+		{`"not an object"`, false},
+	}
+	for _, tt := range tests {
+		if got := IsTruncated(integration.RecordedMessage{Message: json.RawMessage(tt.message)}); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.message, tt.want, got)
+		}
+	}
+}
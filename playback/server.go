@@ -4,32 +4,316 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"time"
+
+	"mcp-debug/integration"
 )
 
 // PlaybackServer replays recorded server responses
 type PlaybackServer struct {
-	session   *PlaybackSession
-	responses []json.RawMessage
-	delay     time.Duration
+	session        *PlaybackSession
+	responses      []json.RawMessage
+	handshakes     []integration.RecordedMessage // Recorded backend initialize responses
+	handshakeIndex int
+	delay          time.Duration
+	templating     bool // Substitute placeholders in responses; see SetTemplating
+
+	// Streaming: responses and pairs are read from source as needed
+	source    *RecordingReader
+	sent      int
+	nextPair  *MessagePair
+	sourceErr error
+
+	// Strict mode: requests must match the recorded tool calls in order
+	strict     bool
+	pairs      []MessagePair
+	pairIndex  int
+	unexpected int
 }
 
-// NewPlaybackServer creates a new playback server
-func NewPlaybackServer(session *PlaybackSession) *PlaybackServer {
+// NewPlaybackServer creates a new playback server. In strict mode each
+// request must match the next recorded tool call (tool name and arguments);
+// anything else is answered with an error and makes Run fail at the end of
+// the session.
+func NewPlaybackServer(session *PlaybackSession, strict bool) *PlaybackServer {
 	serverMessages := session.GetServerMessages()
 	responses := make([]json.RawMessage, len(serverMessages))
 	
 	for i, msg := range serverMessages {
-		responses[i] = msg.Message
+		responses[i] = recordedResponse(msg)
 	}
 	
+	playbackServer := &PlaybackServer{
+		session:    session,
+		responses:  responses,
+		handshakes: session.GetHandshakes(),
+		delay:      50 * time.Millisecond, // Small delay before responding
+		strict:     strict,
+	}
+	if strict {
+		playbackServer.pairs = session.GetMessagePairs()
+	}
+	return playbackServer
+}
+
+// NewStreamingPlaybackServer creates a playback server that reads responses
+// from the recording as they are needed rather than loading it into memory.
+// The recorded handshakes, which can be needed at any time, are read up
+// front in a first pass. The caller must Close the server.
+func NewStreamingPlaybackServer(filename string, options ParseOptions, strict bool) (*PlaybackServer, error) {
+	handshakes, err := readHandshakes(filename, options)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := OpenRecording(filename, options)
+	if err != nil {
+		return nil, err
+	}
 	return &PlaybackServer{
-		session:   session,
-		responses: responses,
-		delay:     50 * time.Millisecond, // Small delay before responding
+		session:    reader.Header(),
+		handshakes: handshakes,
+		delay:      50 * time.Millisecond,
+		strict:     strict,
+		source:     reader,
+	}, nil
+}
+
+// readHandshakes returns the recorded backend initialize responses
+func readHandshakes(filename string, options ParseOptions) ([]integration.RecordedMessage, error) {
+	reader, err := OpenRecording(filename, options)
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
+
+	var handshakes []integration.RecordedMessage
+	for {
+		msg, err := reader.Next()
+		if err == io.EOF {
+			return handshakes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.Direction == "response" && IsHandshake(msg) {
+			handshakes = append(handshakes, msg)
+		}
+	}
+}
+
+// Close closes the recording of a streaming server
+func (s *PlaybackServer) Close() error {
+	if s.source == nil {
+		return nil
+	}
+	return s.source.Close()
+}
+
+// nextResponse returns the next recorded response to replay, or false once
+// all have been replayed
+func (s *PlaybackServer) nextResponse() (json.RawMessage, bool, error) {
+	if s.source == nil {
+		if s.sent >= len(s.responses) {
+			return nil, false, nil
+		}
+		return s.responses[s.sent], true, nil
+	}
+
+	for {
+		msg, err := s.source.Next()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("error reading recording: %w", err)
+		}
+		if msg.Direction == "response" && !IsHandshake(msg) {
+			return recordedResponse(msg), true, nil
+		}
+	}
+}
+
+// peekPair returns the next recorded request-response pair without
+// consuming it, or nil once all have been matched
+func (s *PlaybackServer) peekPair() (*MessagePair, error) {
+	if s.source == nil {
+		if s.pairIndex < len(s.pairs) {
+			return &s.pairs[s.pairIndex], nil
+		}
+		return nil, nil
+	}
+	if s.nextPair != nil {
+		return s.nextPair, nil
+	}
+
+	// Pair messages as GetMessagePairs does
+	var request *integration.RecordedMessage
+	for {
+		msg, err := s.source.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading recording: %w", err)
+		}
+		if IsHandshake(msg) {
+			continue
+		}
+		if msg.Direction == "request" {
+			request = &msg
+		} else if msg.Direction == "response" && request != nil {
+			s.nextPair = &MessagePair{Request: *request, Response: msg}
+			return s.nextPair, nil
+		}
+	}
+}
+
+// recordedResponse returns the message to replay for a recorded response.
+// Truncated responses are replaced by an error to keep request/response pairing.
+func recordedResponse(msg integration.RecordedMessage) json.RawMessage {
+	if IsTruncated(msg) {
+		errorBytes, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"error": map[string]interface{}{
+				"code":    -32000,
+				"message": "Recorded response was truncated",
+			},
+			"id": nil,
+		})
+		return errorBytes
+	}
+	return msg.Message
+}
+
+// strictResponse answers request in strict mode: with the recorded response
+// when it matches the next recorded tool call, otherwise with an error. It
+// returns nil for notifications, which need no answer.
+func (s *PlaybackServer) strictResponse(request string) json.RawMessage {
+	var incoming struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+		Params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(request), &incoming); err == nil && strings.HasPrefix(incoming.Method, "notifications/") {
+		return nil
+	}
+
+	expected := "nothing (all recorded requests were replayed)"
+	pair, err := s.peekPair()
+	if err != nil {
+		// Reported by Run once this request has been answered
+		s.sourceErr = err
+	}
+	if pair != nil {
+		if incoming.Method == "tools/call" && calledAs(pair.Request, incoming.Params.Name) && sameArguments(pair.Request, incoming.Params.Arguments) {
+			s.pairIndex++
+			s.nextPair = nil
+			log.Printf("Sent server response %s", progress(s.pairIndex, len(s.pairs), s.source != nil))
+			return recordedResponse(pair.Response)
+		}
+		expected = fmt.Sprintf("tools/call %s", pair.Request.ToolName)
+		if pair.Request.OriginalToolName != "" && pair.Request.OriginalToolName != pair.Request.ToolName {
+			expected += fmt.Sprintf(" (or %s)", pair.Request.OriginalToolName)
+		}
+	}
+
+	s.unexpected++
+	received := incoming.Method
+	if incoming.Params.Name != "" {
+		received += " " + incoming.Params.Name
+	}
+	log.Printf("!!! STRICT PLAYBACK: unexpected request %q, expected %s", received, expected)
+
+	id := incoming.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	errorBytes, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"error": map[string]interface{}{
+			"code":    -32000,
+			"message": fmt.Sprintf("Unexpected request %q: the recording expects %s", received, expected),
+		},
+		"id": id,
+	})
+	return errorBytes
+}
+
+// calledAs reports whether a recorded tool call request was for the named
+// tool. Its original name matches too when recorded, so a recording made
+// through the proxy can be replayed to a client of the backend itself.
+func calledAs(recorded integration.RecordedMessage, name string) bool {
+	return name == recorded.ToolName || (recorded.OriginalToolName != "" && name == recorded.OriginalToolName)
+}
+
+// sameArguments reports whether a recorded tool call request carried the
+// given arguments. Truncated requests only match on tool name.
+func sameArguments(recorded integration.RecordedMessage, arguments json.RawMessage) bool {
+	if IsTruncated(recorded) {
+		return true
+	}
+	var request struct {
+		Params struct {
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(recorded.Message, &request); err != nil {
+		return false
+	}
+
+	var want, got map[string]interface{}
+	json.Unmarshal(request.Params.Arguments, &want)
+	json.Unmarshal(arguments, &got)
+	if len(want) == 0 && len(got) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// handshakeResponse answers an initialize request with the next recorded
+// backend handshake, reusing the last one once all have been replayed. It
+// returns false if request is not an initialize request or the recording
+// has no handshakes.
+func (s *PlaybackServer) handshakeResponse(request string) (json.RawMessage, bool) {
+	var incoming struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(request), &incoming); err != nil || incoming.Method != "initialize" || len(s.handshakes) == 0 {
+		return nil, false
+	}
+
+	handshake := s.handshakes[len(s.handshakes)-1]
+	if s.handshakeIndex < len(s.handshakes) {
+		handshake = s.handshakes[s.handshakeIndex]
+		s.handshakeIndex++
+	}
+	log.Printf("Replaying initialize handshake recorded from server '%s'", handshake.ServerName)
+
+	response := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      incoming.ID,
+	}
+	var failed integration.HandshakeError
+	if err := json.Unmarshal(handshake.Message, &failed); err == nil && failed.Error != "" {
+		response["error"] = map[string]interface{}{
+			"code":    -32000,
+			"message": failed.Error,
+		}
+	} else {
+		response["result"] = handshake.Message
+	}
+
+	responseBytes, _ := json.Marshal(response)
+	return responseBytes, true
 }
 
 // SetDelay sets the delay before sending responses
@@ -37,12 +321,20 @@ func (s *PlaybackServer) SetDelay(delay time.Duration) {
 	s.delay = delay
 }
 
-// Run starts the playback server
+// Run starts the playback server on stdin and stdout
 func (s *PlaybackServer) Run() error {
-	log.Printf("Starting playback server with %d responses", len(s.responses))
+	return s.run(os.Stdin, os.Stdout)
+}
+
+// run serves the client requests read from in, writing responses to out
+func (s *PlaybackServer) run(in io.Reader, out io.Writer) error {
+	if s.source != nil {
+		log.Printf("Starting playback server, streaming responses from the recording")
+	} else {
+		log.Printf("Starting playback server with %d responses", len(s.responses))
+	}
 	
-	scanner := bufio.NewScanner(os.Stdin)
-	responseIndex := 0
+	scanner := bufio.NewScanner(in)
 	
 	for scanner.Scan() {
 		clientRequest := scanner.Text()
@@ -50,15 +342,36 @@ func (s *PlaybackServer) Run() error {
 		// Log client request (to stderr)
 		log.Printf("Client request: %s", clientRequest)
 		
+		// Initialize requests are answered from the recorded handshakes
+		if response, ok := s.handshakeResponse(clientRequest); ok {
+			time.Sleep(s.delay)
+			fmt.Fprintln(out, string(s.render(response, clientRequest)))
+			continue
+		}
+		
+		if s.strict {
+			if response := s.strictResponse(clientRequest); response != nil {
+				time.Sleep(s.delay)
+				fmt.Fprintln(out, string(s.render(response, clientRequest)))
+			}
+			if s.sourceErr != nil {
+				return s.sourceErr
+			}
+			continue
+		}
+		
 		// Send corresponding server response if available
-		if responseIndex < len(s.responses) {
+		response, ok, err := s.nextResponse()
+		if err != nil {
+			return err
+		}
+		if ok {
 			time.Sleep(s.delay)
 			
-			// Send response to stdout (which goes to client)
-			fmt.Println(string(s.responses[responseIndex]))
-			log.Printf("Sent server response %d/%d", responseIndex+1, len(s.responses))
-			
-			responseIndex++
+			// Send response to the client
+			fmt.Fprintln(out, string(s.render(response, clientRequest)))
+			s.sent++
+			log.Printf("Sent server response %s", progress(s.sent, len(s.responses), s.source != nil))
 		} else {
 			// If no more responses, send a generic error
 			errorResponse := map[string]interface{}{
@@ -71,7 +384,7 @@ func (s *PlaybackServer) Run() error {
 			}
 			
 			errorBytes, _ := json.Marshal(errorResponse)
-			fmt.Println(string(errorBytes))
+			fmt.Fprintln(out, string(errorBytes))
 			log.Printf("Sent generic error response (no more recorded responses)")
 		}
 	}
@@ -80,13 +393,22 @@ func (s *PlaybackServer) Run() error {
 		return fmt.Errorf("error reading client requests: %w", err)
 	}
 	
+	if s.unexpected > 0 {
+		return fmt.Errorf("strict playback: %d unexpected request(s), %s recorded requests matched", s.unexpected, progress(s.pairIndex, len(s.pairs), s.source != nil))
+	}
+	
 	log.Printf("Playback server finished")
 	return nil
 }
 
 // RunStateless starts the server without maintaining request-response pairing
-// Useful for testing where request order might differ
+// Useful for testing where request order might differ. Cycling through the
+// responses needs them in memory, so a streaming server can't run stateless.
 func (s *PlaybackServer) RunStateless() error {
+	if s.source != nil {
+		return fmt.Errorf("stateless playback needs the recording in memory; use NewPlaybackServer")
+	}
+	
 	log.Printf("Starting stateless playback server")
 	
 	scanner := bufio.NewScanner(os.Stdin)
@@ -96,12 +418,18 @@ func (s *PlaybackServer) RunStateless() error {
 		clientRequest := scanner.Text()
 		log.Printf("Client request: %s", clientRequest)
 		
+		if response, ok := s.handshakeResponse(clientRequest); ok {
+			time.Sleep(s.delay)
+			fmt.Println(string(s.render(response, clientRequest)))
+			continue
+		}
+		
 		// Always cycle through responses
 		if len(s.responses) > 0 {
 			time.Sleep(s.delay)
 			
 			response := s.responses[responseIndex%len(s.responses)]
-			fmt.Println(string(response))
+			fmt.Println(string(s.render(response, clientRequest)))
 			log.Printf("Sent cycled response %d (index %d)", responseIndex+1, responseIndex%len(s.responses))
 			
 			responseIndex++
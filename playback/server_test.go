@@ -0,0 +1,126 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"mcp-debug/integration"
+)
+
+func TestStrictPlaybackMatchesOriginalToolName(t *testing.T) {
+	request := integration.RecordedMessage{
+		Direction:        "request",
+		MessageType:      "tool_call",
+		ToolName:         "fs_read",
+		OriginalToolName: "read",
+		ServerName:       "fs",
+		Message:          json.RawMessage(`{"params":{"name":"fs_read","arguments":{"path":"/tmp"}}}`),
+	}
+	response := integration.RecordedMessage{
+		Direction:   "response",
+		MessageType: "tool_call",
+		ToolName:    "fs_read",
+		ServerName:  "fs",
+		Message:     json.RawMessage(`{"content":[{"type":"text","text":"contents"}]}`),
+	}
+
+	tests := []struct {
+		name       string
+		call       string
+		unexpected int
+	}{
+		{"prefixed name", "fs_read", 0},
+		{"original name", "read", 0},
+		{"other name", "write", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPlaybackServer(&PlaybackSession{Messages: []integration.RecordedMessage{request, response}}, true)
+			s.strictResponse(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tt.call + `","arguments":{"path":"/tmp"}}}`)
+			if s.unexpected != tt.unexpected {
+				t.Errorf("expected %d unexpected requests, got %d", tt.unexpected, s.unexpected)
+			}
+		})
+	}
+}
+
+func TestPlaybackMismatchedRequest(t *testing.T) {
+	session := &PlaybackSession{Messages: []integration.RecordedMessage{
+		{Direction: "request", MessageType: "tool_call", ToolName: "fs_read", ServerName: "fs",
+			Message: json.RawMessage(`{"params":{"name":"fs_read","arguments":{"path":"/tmp"}}}`)},
+		{Direction: "response", MessageType: "tool_call", ToolName: "fs_read", ServerName: "fs",
+			Message: json.RawMessage(`{"content":[{"type":"text","text":"contents"}]}`)},
+	}}
+	request := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_write","arguments":{"path":"/tmp"}}}` + "\n"
+
+	t.Run("strict", func(t *testing.T) {
+		var out bytes.Buffer
+		err := NewPlaybackServer(session, true).run(strings.NewReader(request), &out)
+		if err == nil || !strings.Contains(err.Error(), "1 unexpected request") {
+			t.Errorf("expected strict playback to fail, got %v", err)
+		}
+		if !strings.Contains(out.String(), `"error"`) || !strings.Contains(out.String(), "tools/call fs_read") {
+			t.Errorf("expected an error naming the recorded request, got %s", out.String())
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := NewPlaybackServer(session, false).run(strings.NewReader(request), &out); err != nil {
+			t.Errorf("expected lenient playback to pass, got %v", err)
+		}
+		if !strings.Contains(out.String(), "contents") {
+			t.Errorf("expected the recorded response, got %s", out.String())
+		}
+	})
+}
+
+func TestStreamingPlayback(t *testing.T) {
+	request := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs_read","arguments":{}}}` + "\n"
+
+	t.Run("under the limits", func(t *testing.T) {
+		s, err := NewStreamingPlaybackServer(writeRecording(t, streamedRecording), ParseOptions{MaxMessages: 3}, false)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer s.Close()
+		s.SetDelay(0)
+
+		var out bytes.Buffer
+		if err := s.run(strings.NewReader(request+request), &out); err != nil {
+			t.Fatalf("expected playback to pass, got %v", err)
+		}
+		if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "content") || !strings.Contains(lines[1], "No more recorded responses") {
+			t.Errorf("expected the recorded response then the generic error, got %s", out.String())
+		}
+	})
+
+	t.Run("over the limits", func(t *testing.T) {
+		_, err := NewStreamingPlaybackServer(writeRecording(t, streamedRecording), ParseOptions{MaxMessages: 2}, false)
+		if !errors.Is(err, ErrRecordingTooLarge) {
+			t.Errorf("expected ErrRecordingTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("client disconnects early", func(t *testing.T) {
+		s, err := NewStreamingPlaybackServer(writeRecording(t, streamedRecording), ParseOptions{}, false)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		s.SetDelay(0)
+
+		var out bytes.Buffer
+		if err := s.run(strings.NewReader(""), &out); err != nil {
+			t.Errorf("expected playback to stop cleanly, got %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("expected no responses, got %s", out.String())
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("close: %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response template placeholders, substituted when templating is enabled.
+// A placeholder that is a whole JSON string value ("{{request.id}}") is
+// replaced by the raw JSON value, so numeric ids stay numbers; inside a
+// longer string only the text is substituted.
+const (
+	PlaceholderRequestID = "{{request.id}}" // The incoming request's id
+	PlaceholderNow       = "{{now}}"        // Current time, RFC 3339 in UTC
+	PlaceholderNowUnix   = "{{now.unix}}"   // Current time, seconds since the epoch
+)
+
+// SetTemplating enables placeholder substitution in replayed responses.
+// It is off by default, so recordings are replayed literally.
+func (s *PlaybackServer) SetTemplating(enabled bool) {
+	s.templating = enabled
+}
+
+// render substitutes the template placeholders in response using the
+// incoming request. Responses are returned unchanged when templating is off.
+func (s *PlaybackServer) render(response json.RawMessage, request string) json.RawMessage {
+	if !s.templating || !bytes.Contains(response, []byte("{{")) {
+		return response
+	}
+
+	var incoming struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.Unmarshal([]byte(request), &incoming)
+	id := incoming.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	now := time.Now().UTC()
+	unix := strconv.FormatInt(now.Unix(), 10)
+
+	out := bytes.ReplaceAll(response, []byte(strconv.Quote(PlaceholderRequestID)), id)
+	out = bytes.ReplaceAll(out, []byte(PlaceholderRequestID), []byte(strings.Trim(string(id), `"`)))
+	out = bytes.ReplaceAll(out, []byte(strconv.Quote(PlaceholderNowUnix)), []byte(unix))
+	out = bytes.ReplaceAll(out, []byte(PlaceholderNowUnix), []byte(unix))
+	out = bytes.ReplaceAll(out, []byte(PlaceholderNow), []byte(now.Format(time.RFC3339)))
+	return out
+}
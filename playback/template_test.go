@@ -0,0 +1,111 @@
+package playback
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	tests := []struct {
+		name       string
+		templating bool
+		request    string
+		response   string
+		want       string
+	}{
+		{
+			name:     "templating off",
+			request:  `{"jsonrpc":"2.0","id":7,"method":"tools/call"}`,
+			response: `{"id":"{{request.id}}"}`,
+			want:     `{"id":"{{request.id}}"}`,
+		},
+		{
+			name:       "numeric id as a whole value",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","id":7,"method":"tools/call"}`,
+			response:   `{"id":"{{request.id}}"}`,
+			want:       `{"id":7}`,
+		},
+		{
+			name:       "string id as a whole value",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","id":"abc","method":"tools/call"}`,
+			response:   `{"id":"{{request.id}}"}`,
+			want:       `{"id":"abc"}`,
+		},
+		{
+			name:       "id inside a longer string",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","id":"abc","method":"tools/call"}`,
+			response:   `{"text":"answer to {{request.id}}"}`,
+			want:       `{"text":"answer to abc"}`,
+		},
+		{
+			name:       "request without an id",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			response:   `{"id":"{{request.id}}"}`,
+			want:       `{"id":null}`,
+		},
+		{
+			name:       "request that isn't JSON",
+			templating: true,
+			request:    `not json`,
+			response:   `{"id":"{{request.id}}"}`,
+			want:       `{"id":null}`,
+		},
+		{
+			name:       "unknown variables are left alone",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","id":1,"method":"tools/call"}`,
+			response:   `{"text":"{{request.method}} {{later}}","id":"{{request.id}}"}`,
+			want:       `{"text":"{{request.method}} {{later}}","id":1}`,
+		},
+		{
+			name:       "unclosed placeholder is left alone",
+			templating: true,
+			request:    `{"jsonrpc":"2.0","id":1,"method":"tools/call"}`,
+			response:   `{"text":"{{request.id"}`,
+			want:       `{"text":"{{request.id"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPlaybackServer(&PlaybackSession{}, false)
+			s.SetTemplating(tt.templating)
+			if got := string(s.render(json.RawMessage(tt.response), tt.request)); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTimePlaceholders(t *testing.T) {
+	s := NewPlaybackServer(&PlaybackSession{}, false)
+	s.SetTemplating(true)
+	before := time.Now().Unix()
+
+	var rendered struct {
+		Now     string `json:"now"`
+		Unix    int64  `json:"unix"`
+		Message string `json:"message"`
+	}
+	response := `{"now":"{{now}}","unix":"{{now.unix}}","message":"at {{now.unix}}"}`
+	if err := json.Unmarshal(s.render(json.RawMessage(response), `{"id":1}`), &rendered); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+
+	now, err := time.Parse(time.RFC3339, rendered.Now)
+	if err != nil || now.Unix() < before {
+		t.Errorf("expected the current time in RFC 3339, got %q", rendered.Now)
+	}
+	if rendered.Unix < before {
+		t.Errorf("expected the current Unix time as a number, got %d", rendered.Unix)
+	}
+	if rendered.Message != "at "+strconv.FormatInt(rendered.Unix, 10) {
+		t.Errorf("expected the Unix time inside a string, got %q", rendered.Message)
+	}
+}
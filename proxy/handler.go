@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -54,7 +56,7 @@ func CreateProxyHandler(mcpClient client.MCPClient, remoteTool discovery.RemoteT
 		}
 		
 		// Transform the result back to MCP format
-		mcpResult := transformResult(result)
+		mcpResult := TransformResult(result, false)
 
 		// Inject metadata if function provided
 		if metadataFunc != nil {
@@ -85,19 +87,41 @@ func extractArguments(request mcp.CallToolRequest) (map[string]interface{}, erro
 	return result, nil
 }
 
-// transformResult transforms a client.CallToolResult to mcp.CallToolResult
-func transformResult(clientResult *client.CallToolResult) *mcp.CallToolResult {
-	if clientResult.IsError {
-		// If the client result indicates an error, create an error result
-		if len(clientResult.Content) > 0 {
-			return mcp.NewToolResultError(clientResult.Content[0].Text)
+// TransformResult transforms a client.CallToolResult to mcp.CallToolResult.
+// Content items are forwarded as the server sent them, so images, audio,
+// resources and annotations reach the client; an item mcp-go can't decode
+// falls back to its text. With flattenText set, the text of all items is
+// joined into a single text item instead, for clients that only render text.
+// structuredContent, _meta and isError are forwarded unchanged either way.
+func TransformResult(clientResult *client.CallToolResult, flattenText bool) *mcp.CallToolResult {
+	result := transformContentItems(clientResult, flattenText)
+	result.IsError = clientResult.IsError
+	if len(clientResult.StructuredContent) > 0 {
+		var structured interface{}
+		if err := json.Unmarshal(clientResult.StructuredContent, &structured); err == nil {
+			result.StructuredContent = structured
 		}
-		return mcp.NewToolResultError("Tool execution failed")
 	}
-	
-	// For successful results, convert content to text
-	if len(clientResult.Content) > 0 {
-		// For now, combine all text content
+	if clientResult.Meta != nil {
+		result.Meta = mcp.NewMetaFromMap(clientResult.Meta)
+	}
+	return result
+}
+
+// transformContentItems converts the content of a result
+func transformContentItems(clientResult *client.CallToolResult, flattenText bool) *mcp.CallToolResult {
+	if len(clientResult.Content) == 0 {
+		if clientResult.IsError {
+			return mcp.NewToolResultError("Tool execution failed")
+		}
+		return mcp.NewToolResultText("Tool executed successfully")
+	}
+
+	if flattenText {
+		if clientResult.IsError {
+			// If the client result indicates an error, create an error result
+			return mcp.NewToolResultError(clientResult.Content[0].Text)
+		}
 		var text string
 		for i, content := range clientResult.Content {
 			if i > 0 {
@@ -107,8 +131,32 @@ func transformResult(clientResult *client.CallToolResult) *mcp.CallToolResult {
 		}
 		return mcp.NewToolResultText(text)
 	}
-	
-	return mcp.NewToolResultText("Tool executed successfully")
+
+	result := &mcp.CallToolResult{
+		Content: make([]mcp.Content, 0, len(clientResult.Content)),
+	}
+	for _, item := range clientResult.Content {
+		result.Content = append(result.Content, transformContent(item))
+	}
+	return result
+}
+
+// transformContent converts one content item, keeping everything mcp-go
+// can represent and falling back to the item's text otherwise
+func transformContent(item client.ContentItem) mcp.Content {
+	if len(item.Raw) > 0 {
+		if content, err := mcp.UnmarshalContent(item.Raw); err == nil {
+			return content
+		}
+		// Embedded resources hold an interface that only ParseContent decodes
+		var fields map[string]any
+		if err := json.Unmarshal(item.Raw, &fields); err == nil {
+			if content, err := mcp.ParseContent(fields); err == nil {
+				return content
+			}
+		}
+	}
+	return mcp.NewTextContent(item.Text)
 }
 
 // ToolRegistry manages the mapping of tools to their handlers and clients
@@ -131,6 +179,11 @@ func (r *ToolRegistry) RegisterTool(tool discovery.RemoteTool, mcpClient client.
 	r.clients[tool.ServerName] = mcpClient
 }
 
+// UnregisterTool removes a tool by prefixed name
+func (r *ToolRegistry) UnregisterTool(prefixedName string) {
+	delete(r.tools, prefixedName)
+}
+
 // GetTool returns the tool metadata for a prefixed tool name
 func (r *ToolRegistry) GetTool(prefixedName string) (discovery.RemoteTool, bool) {
 	tool, exists := r.tools[prefixedName]
@@ -143,15 +196,36 @@ func (r *ToolRegistry) GetClient(serverName string) (client.MCPClient, bool) {
 	return client, exists
 }
 
-// GetAllTools returns all registered tools
+// GetAllTools returns all registered tools, sorted by prefixed name
 func (r *ToolRegistry) GetAllTools() []discovery.RemoteTool {
 	var tools []discovery.RemoteTool
 	for _, tool := range r.tools {
 		tools = append(tools, tool)
 	}
+	sortTools(tools)
 	return tools
 }
 
+// GetServerTools returns all registered tools belonging to a server, sorted
+// by prefixed name
+func (r *ToolRegistry) GetServerTools(serverName string) []discovery.RemoteTool {
+	var tools []discovery.RemoteTool
+	for _, tool := range r.tools {
+		if tool.ServerName == serverName {
+			tools = append(tools, tool)
+		}
+	}
+	sortTools(tools)
+	return tools
+}
+
+// sortTools orders tools by prefixed name so listings are stable
+func sortTools(tools []discovery.RemoteTool) {
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].PrefixedName < tools[j].PrefixedName
+	})
+}
+
 // CreateHandlerForTool creates a proxy handler for a specific tool
 func (r *ToolRegistry) CreateHandlerForTool(prefixedToolName string, recorder RecorderFunc, metadataFunc func(*mcp.CallToolResult) *mcp.CallToolResult) (server.ToolHandlerFunc, error) {
 	// Get tool metadata
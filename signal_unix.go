@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyRecordToggle relays SIGUSR1 to ch to toggle recording at runtime
+func notifyRecordToggle(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGUSR1)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyRecordToggle is a no-op on Windows, which has no SIGUSR1;
+// use the record_start/record_stop management tools instead
+func notifyRecordToggle(ch chan<- os.Signal) {}